@@ -0,0 +1,292 @@
+package main
+
+import (
+	gpu_mount "GPUMounter/pkg/api/gpu-mount"
+	"GPUMounter/pkg/api/rest"
+	"GPUMounter/pkg/config"
+	"GPUMounter/pkg/util/gpu"
+	. "GPUMounter/pkg/util/log"
+	"GPUMounter/pkg/util/worker"
+	"context"
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"google.golang.org/grpc"
+	corev1 "k8s.io/api/core/v1"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net/http"
+	"strings"
+)
+
+func AddGPUV2(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	Logger.Info("access add gpu service v2")
+	podName := ps.ByName("pod")
+	namespace := ps.ByName("namespace")
+
+	var request rest.AddGPURequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		Logger.Error("Invalid request body: ", err)
+		writeError(w, rest.ErrInvalidRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	if request.GPUNum <= 0 {
+		Logger.Error("Invalid param gpuNum: ", request.GPUNum)
+		writeError(w, rest.ErrInvalidRequest, "gpuNum should be greater than 0")
+		return
+	}
+	if request.LeaseSeconds < 0 {
+		Logger.Error("Invalid param leaseSeconds: ", request.LeaseSeconds)
+		writeError(w, rest.ErrInvalidRequest, "leaseSeconds should not be negative")
+		return
+	}
+	if request.Placement != "" && !gpu.IsValidPlacementStrategy(request.Placement) {
+		Logger.Error("Invalid param placement: ", request.Placement)
+		writeError(w, rest.ErrInvalidRequest, "Unknown placement strategy: "+request.Placement)
+		return
+	}
+	if request.Shared && (request.GPUNum != 1 || request.IsEntireMount || request.MemoryLimitMiB == 0) {
+		Logger.Error("Invalid params of shared mount, gpuNum: ", request.GPUNum, " isEntireMount: ", request.IsEntireMount, " memoryLimitMiB: ", request.MemoryLimitMiB)
+		writeError(w, rest.ErrInvalidRequest, "shared mount should have gpuNum 1, memoryLimitMiB greater than 0 and no isEntireMount")
+		return
+	}
+	if request.MIGProfile != "" && (!gpu.IsValidMIGProfile(request.MIGProfile) || request.IsEntireMount || request.Shared || request.Placement != "") {
+		Logger.Error("Invalid params of MIG mount, migProfile: ", request.MIGProfile, " isEntireMount: ", request.IsEntireMount, " shared: ", request.Shared, " placement: ", request.Placement)
+		writeError(w, rest.ErrInvalidRequest, "MIG mount should have a migProfile like 1g.5gb and no isEntireMount, shared or placement")
+		return
+	}
+	Logger.Info("Pod: ", podName, " Namespace: ", namespace, " GPU Num: ", request.GPUNum, " Is entire mount: ", request.IsEntireMount)
+
+	pod, conn, restErr := connectToPodWorker(namespace, podName)
+	if restErr != nil {
+		writeError(w, restErr.Code, restErr.Message)
+		return
+	}
+
+	c := gpu_mount.NewAddGPUServiceClient(conn)
+	resp, err := c.AddGPU(r.Context(), &gpu_mount.AddGPURequest{
+		PodName:           podName,
+		Namespace:         namespace,
+		GpuNum:            request.GPUNum,
+		IsEntireMount:     request.IsEntireMount,
+		ContainerName:     request.Container,
+		AllContainers:     request.AllContainers,
+		LeaseSeconds:      request.LeaseSeconds,
+		ForceReclaim:      request.ForceReclaim,
+		PlacementStrategy: request.Placement,
+		Shared:            request.Shared,
+		MemoryLimitMib:    request.MemoryLimitMiB,
+		MigProfile:        request.MIGProfile,
+	})
+	if err != nil {
+		Logger.Error("Failed to call add gpu service")
+		Logger.Error(err)
+		writeError(w, rest.ErrInternal, err.Error())
+		return
+	}
+	if code := rest.AddGPUResultCode(resp.AddGpuResult); code != "" {
+		Logger.Error("Failed to add gpu for Pod: ", podName, " result: ", resp.AddGpuResult.String())
+		message := "Failed to add gpu for Pod: " + podName + " on Node: " + pod.Spec.NodeName + " (" + resp.AddGpuResult.String() + ")"
+		if resp.Message != "" {
+			message += ": " + resp.Message
+		}
+		if failed := rest.FailedContainersMessage(resp.ContainerResults); failed != "" {
+			message += ": " + failed
+		}
+		writeError(w, code, message)
+		return
+	}
+
+	response := &rest.AddGPUResponse{
+		Namespace:  namespace,
+		Pod:        podName,
+		Node:       pod.Spec.NodeName,
+		GPUs:       []*rest.MountedGPU{},
+		Containers: rest.NewContainerResults(resp.ContainerResults),
+	}
+	for _, gpuDev := range resp.Gpus {
+		response.GPUs = append(response.GPUs, &rest.MountedGPU{
+			UUID:           gpuDev.Uuid,
+			MinorNumber:    gpuDev.MinorNumber,
+			DeviceFilePath: gpuDev.DeviceFilePath,
+			SlavePod:       gpuDev.SlavePodName,
+			LeaseExpiresAt: gpuDev.LeaseExpiresAt,
+			MemoryLimitMiB: gpuDev.MemoryLimitMib,
+			MIGProfile:     gpuDev.MigProfile,
+			ParentUUID:     gpuDev.ParentUuid,
+		})
+	}
+	Logger.Info("Successfully add gpu for Pod: ", podName)
+	writeJSON(w, http.StatusOK, response)
+}
+
+func RemoveGPUV2(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	Logger.Info("access remove gpu service v2")
+	podName := ps.ByName("pod")
+	namespace := ps.ByName("namespace")
+
+	var request rest.RemoveGPURequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		Logger.Error("Invalid request body: ", err)
+		writeError(w, rest.ErrInvalidRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	if len(request.UUIDs) == 0 {
+		Logger.Error("no uuids in request")
+		writeError(w, rest.ErrInvalidRequest, "uuids should not be empty")
+		return
+	}
+	Logger.Info("Pod: ", podName, " Namespace: ", namespace, " UUIDs: ", strings.Join(request.UUIDs, ", "), " force: ", request.Force)
+
+	pod, conn, restErr := connectToPodWorker(namespace, podName)
+	if restErr != nil {
+		writeError(w, restErr.Code, restErr.Message)
+		return
+	}
+
+	c := gpu_mount.NewRemoveGPUServiceClient(conn)
+	resp, err := c.RemoveGPU(r.Context(), &gpu_mount.RemoveGPURequest{
+		PodName:       podName,
+		Namespace:     namespace,
+		Uuids:         request.UUIDs,
+		Force:         request.Force,
+		ContainerName: request.Container,
+		AllContainers: request.AllContainers,
+	})
+	if err != nil {
+		Logger.Error("Failed to call remove gpu service")
+		Logger.Error(err)
+		writeError(w, rest.ErrInternal, err.Error())
+		return
+	}
+	if code := rest.RemoveGPUResultCode(resp.RemoveGpuResult); code != "" {
+		Logger.Error("Failed to remove gpu for Pod: ", podName, " result: ", resp.RemoveGpuResult.String())
+		message := "Failed to remove GPU: " + strings.Join(request.UUIDs, ", ") + " from Pod: " + podName + " (" + resp.RemoveGpuResult.String() + ")"
+		if failed := rest.FailedContainersMessage(resp.ContainerResults); failed != "" {
+			message += ": " + failed
+		}
+		writeError(w, code, message)
+		return
+	}
+
+	Logger.Info("Successfully remove ", len(request.UUIDs), " GPUs: ", strings.Join(request.UUIDs, ", "))
+	writeJSON(w, http.StatusOK, &rest.RemoveGPUResponse{
+		Namespace:  namespace,
+		Pod:        podName,
+		Node:       pod.Spec.NodeName,
+		UUIDs:      request.UUIDs,
+		Containers: rest.NewContainerResults(resp.ContainerResults),
+	})
+}
+
+func ExtendLeaseV2(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	Logger.Info("access extend lease service v2")
+	podName := ps.ByName("pod")
+	namespace := ps.ByName("namespace")
+
+	var request rest.ExtendLeaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		Logger.Error("Invalid request body: ", err)
+		writeError(w, rest.ErrInvalidRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	if len(request.UUIDs) == 0 {
+		Logger.Error("no uuids in request")
+		writeError(w, rest.ErrInvalidRequest, "uuids should not be empty")
+		return
+	}
+	if request.LeaseSeconds <= 0 {
+		Logger.Error("Invalid param leaseSeconds: ", request.LeaseSeconds)
+		writeError(w, rest.ErrInvalidRequest, "leaseSeconds should be greater than 0")
+		return
+	}
+	Logger.Info("Pod: ", podName, " Namespace: ", namespace, " UUIDs: ", strings.Join(request.UUIDs, ", "), " lease seconds: ", request.LeaseSeconds)
+
+	pod, conn, restErr := connectToPodWorker(namespace, podName)
+	if restErr != nil {
+		writeError(w, restErr.Code, restErr.Message)
+		return
+	}
+
+	c := gpu_mount.NewLeaseServiceClient(conn)
+	resp, err := c.ExtendLease(r.Context(), &gpu_mount.ExtendLeaseRequest{
+		PodName:      podName,
+		Namespace:    namespace,
+		Uuids:        request.UUIDs,
+		LeaseSeconds: request.LeaseSeconds,
+	})
+	if err != nil {
+		Logger.Error("Failed to call extend lease service")
+		Logger.Error(err)
+		writeError(w, rest.ErrInternal, err.Error())
+		return
+	}
+	if code := rest.ExtendLeaseResultCode(resp.ExtendLeaseResult); code != "" {
+		Logger.Error("Failed to extend lease for Pod: ", podName, " result: ", resp.ExtendLeaseResult.String())
+		writeError(w, code, "Failed to extend lease of GPU: "+strings.Join(request.UUIDs, ", ")+" of Pod: "+podName+" ("+resp.ExtendLeaseResult.String()+")")
+		return
+	}
+
+	response := &rest.ExtendLeaseResponse{
+		Namespace: namespace,
+		Pod:       podName,
+		Node:      pod.Spec.NodeName,
+		Leases:    []*rest.Lease{},
+	}
+	for _, gpuDev := range resp.Gpus {
+		response.Leases = append(response.Leases, &rest.Lease{UUID: gpuDev.Uuid, ExpiresAt: gpuDev.LeaseExpiresAt})
+	}
+	Logger.Info("Successfully extend lease of ", len(resp.Gpus), " GPUs of Pod: ", podName)
+	writeJSON(w, http.StatusOK, response)
+}
+
+// connectToPodWorker finds the pod and returns the pooled connection to the gpu mounter worker on its node
+func connectToPodWorker(namespace string, podName string) (*corev1.Pod, *grpc.ClientConn, *rest.Error) {
+	clientset, err := config.GetClientSet()
+	if err != nil {
+		Logger.Error("Connect to k8s failed")
+		Logger.Error(err.Error())
+		return nil, nil, &rest.Error{Code: rest.ErrInternal, Message: err.Error()}
+	}
+	pod, err := clientset.CoreV1().Pods(namespace).Get(context.TODO(), podName, metav1.GetOptions{})
+	if err != nil {
+		if k8s_errors.IsNotFound(err) {
+			Logger.Error("No pod: " + podName + " in namespace: " + namespace)
+			return nil, nil, &rest.Error{Code: rest.ErrPodNotFound, Message: "No pod: " + podName + " in namespace: " + namespace}
+		}
+		Logger.Error(err)
+		return nil, nil, &rest.Error{Code: rest.ErrInternal, Message: err.Error()}
+	}
+	nodeName := pod.Spec.NodeName
+	Logger.Info("Found Pod: ", podName, " in Namespace: ", namespace, " on Node: ", nodeName)
+
+	conn, restErr := connectToNodeWorker(nodeName)
+	if restErr != nil {
+		return nil, nil, restErr
+	}
+	return pod, conn, nil
+}
+
+// connectToNodeWorker returns the pooled connection to the gpu mounter worker on the node
+func connectToNodeWorker(nodeName string) (*grpc.ClientConn, *rest.Error) {
+	conn, err := worker.GetConn(nodeName)
+	if err != nil {
+		if err.Error() == worker.NotFound {
+			return nil, &rest.Error{Code: rest.ErrWorkerNotFound, Message: "No gpu mounter worker on Node: " + nodeName}
+		}
+		return nil, &rest.Error{Code: rest.ErrInternal, Message: err.Error()}
+	}
+	return conn, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		Logger.Error("Failed to write response")
+		Logger.Error(err)
+	}
+}
+
+func writeError(w http.ResponseWriter, code rest.ErrorCode, message string) {
+	writeJSON(w, code.HTTPStatus(), &rest.ErrorResponse{Error: &rest.Error{Code: code, Message: message}})
+}
@@ -2,15 +2,20 @@ package main
 
 import (
 	gpu_mount "GPUMounter/pkg/api/gpu-mount"
+	"GPUMounter/pkg/api/rest"
+	"GPUMounter/pkg/auth"
 	"GPUMounter/pkg/config"
+	"GPUMounter/pkg/controller"
+	"GPUMounter/pkg/metrics"
 	. "GPUMounter/pkg/util/log"
+	"GPUMounter/pkg/util/quota"
+	"GPUMounter/pkg/util/worker"
 	"context"
 	"fmt"
 	"github.com/julienschmidt/httprouter"
-	"google.golang.org/grpc"
-	corev1 "k8s.io/api/core/v1"
 	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"net/http"
 	"strconv"
 	"strings"
@@ -65,28 +70,13 @@ func AddGPU(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	nodeName := pod.Spec.NodeName
 	Logger.Info("Found Pod: ", podName, " in Namespace: ", namespace, " on Node: ", nodeName)
 
-	workerMap, err := findAllWorker()
+	conn, err := worker.GetConn(nodeName)
 	if err != nil {
-		Logger.Error("Failed to found gpu mounter workers")
+		Logger.Error("Failed to connect to gpu mounter worker on Node: ", nodeName)
 		Logger.Error(err)
-		http.Error(w, err.Error(), 500)
-		return
-	}
-	worker, ok := workerMap[nodeName]
-	if !ok {
-		Logger.Error("Failed found gpu mounter on Node: ", nodeName)
 		http.Error(w, "Service Internal Error", 500)
 		return
 	}
-	workerIP := worker.Status.PodIP
-	conn, err := grpc.Dial(workerIP+":1200", grpc.WithInsecure())
-	if err != nil {
-		Logger.Error("Failed to connect to gpu mounter worker")
-		Logger.Error(err)
-		http.Error(w, "Service Internal Error", 500)
-		return
-	}
-	defer conn.Close()
 	c := gpu_mount.NewAddGPUServiceClient(conn)
 	resp, err := c.AddGPU(context.TODO(), &gpu_mount.AddGPURequest{
 		PodName:       podName,
@@ -113,6 +103,18 @@ func AddGPU(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 		Logger.Error("No Pod" + podName + " on Node: " + nodeName)
 		http.Error(w, "No Pod"+podName+" on Node: "+nodeName, 400)
 		return
+	case gpu_mount.AddGPUResponse_SlavePodTimeout:
+		Logger.Error("Timeout creating slave pod on Node: " + nodeName)
+		http.Error(w, "Timeout creating slave pod on Node: "+nodeName, 504)
+		return
+	case gpu_mount.AddGPUResponse_QuotaExceeded:
+		Logger.Error(resp.Message)
+		http.Error(w, resp.Message, 403)
+		return
+	default:
+		Logger.Error("Failed to create slave pod on Node: " + nodeName + " reason: " + resp.AddGpuResult.String())
+		http.Error(w, "Failed to create slave pod on Node: "+nodeName+" reason: "+resp.AddGpuResult.String(), 500)
+		return
 	}
 }
 
@@ -168,28 +170,13 @@ func RemoveGPU(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	nodeName := pod.Spec.NodeName
 	Logger.Info("Found Pod: ", podName, " in Namespace: ", namespace, " on Node: ", nodeName)
 
-	workerMap, err := findAllWorker()
-	if err != nil {
-		Logger.Error("Failed to found gpu mounter workers")
-		Logger.Error(err)
-		http.Error(w, err.Error(), 500)
-		return
-	}
-	worker, ok := workerMap[nodeName]
-	if !ok {
-		Logger.Error("Failed found gpu mounter on Node: ", nodeName)
-		http.Error(w, "Service Internal Error", 500)
-		return
-	}
-	workerIP := worker.Status.PodIP
-	conn, err := grpc.Dial(workerIP+":1200", grpc.WithInsecure())
+	conn, err := worker.GetConn(nodeName)
 	if err != nil {
-		Logger.Error("Failed to connect to gpu mounter worker")
+		Logger.Error("Failed to connect to gpu mounter worker on Node: ", nodeName)
 		Logger.Error(err)
 		http.Error(w, "Service Internal Error", 500)
 		return
 	}
-	defer conn.Close()
 	c := gpu_mount.NewRemoveGPUServiceClient(conn)
 	resp, err := c.RemoveGPU(context.TODO(), &gpu_mount.RemoveGPURequest{
 		PodName:   podName,
@@ -217,10 +204,22 @@ func RemoveGPU(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 		Logger.Error("Invalid UUIDs: ", strings.Join(uuids, ", "))
 		http.Error(w, "Invalid UUIDs: "+strings.Join(uuids, ", "), 400)
 		return
+	case gpu_mount.RemoveGPUResponse_ContainerNotFound:
+		Logger.Error("No running container of Pod: ", pod.Name, " in Namespace: ", pod.Namespace)
+		http.Error(w, "No running container of Pod: "+pod.Name, 400)
+		return
+	case gpu_mount.RemoveGPUResponse_UnmountFailed:
+		Logger.Error("Failed to unmount GPU: ", strings.Join(uuids, ", "), " from Pod: ", pod.Name)
+		http.Error(w, "Failed to unmount GPU: "+strings.Join(uuids, ", ")+" from Pod: "+pod.Name, 500)
+		return
 	case gpu_mount.RemoveGPUResponse_Success:
 		Logger.Info("Successfully remove ", len(uuids), " GPUs: ", strings.Join(uuids, ", "))
 		fmt.Fprintf(w, "Remove GPU Success\n")
 		return
+	default:
+		Logger.Error("Failed to remove gpu from Pod: " + pod.Name + " reason: " + resp.RemoveGpuResult.String())
+		http.Error(w, "Failed to remove gpu from Pod: "+pod.Name+" reason: "+resp.RemoveGpuResult.String(), 500)
+		return
 	}
 }
 
@@ -228,41 +227,54 @@ func main() {
 	InitLogger("/var/log/GPUMounter/", "GPUMounter-master.log")
 	defer Logger.Sync()
 
+	clientset, err := config.GetClientSet()
+	if err != nil {
+		Logger.Error("Connect to k8s failed")
+		Logger.Error(err)
+		return
+	}
+	dynamicClient, err := config.GetDynamicClient()
+	if err != nil {
+		Logger.Error("Connect to k8s failed")
+		Logger.Error(err)
+		return
+	}
+	if _, err := worker.GetRegistry(); err != nil {
+		Logger.Error("Failed to watch gpu mounter workers")
+		Logger.Error(err)
+		return
+	}
+	gpuMountController := controller.NewGPUMountController(clientset, dynamicClient, controller.WorkerMounter{})
+	go gpuMountController.Run(2, wait.NeverStop)
+	go quota.NewAccountant(clientset).Run(wait.NeverStop)
+
+	authorizer := auth.NewAuthorizer(clientset, auth.Enabled())
+	if auth.Enabled() {
+		Logger.Info("API authentication and authorization are enabled")
+	} else {
+		Logger.Warn("API authentication and authorization are disabled")
+	}
+
 	router := httprouter.New()
 	router.GET("/", Index)
-	router.GET("/addgpu/namespace/:namespace/pod/:pod/gpu/:gpuNum/isEntireMount/:isEntireMount", AddGPU)
-	router.POST("/removegpu/namespace/:namespace/pod/:pod/force/:force", RemoveGPU)
+	router.GET("/addgpu/namespace/:namespace/pod/:pod/gpu/:gpuNum/isEntireMount/:isEntireMount", authorizer.Protect(auth.VerbCreate, AddGPU))
+	router.POST("/removegpu/namespace/:namespace/pod/:pod/force/:force", authorizer.Protect(auth.VerbDelete, RemoveGPU))
+	router.POST(rest.PathPrefix+"/namespace/:namespace/pod/:pod/addgpu", authorizer.Protect(auth.VerbCreate, AddGPUV2))
+	router.POST(rest.PathPrefix+"/namespace/:namespace/pod/:pod/removegpu", authorizer.Protect(auth.VerbDelete, RemoveGPUV2))
+	router.POST(rest.PathPrefix+"/namespace/:namespace/pod/:pod/extendlease", authorizer.Protect(auth.VerbUpdate, ExtendLeaseV2))
+	router.GET(rest.PathPrefix+"/namespace/:namespace/pod/:pod/gpus", authorizer.Protect(auth.VerbGet, GetPodGPUs))
+	// node gpus show the pods of all namespaces
+	router.GET(rest.PathPrefix+"/nodes/:node/gpus", authorizer.Protect(auth.VerbList, ListNodeGPUs))
+	router.Handler(http.MethodGet, "/metrics", metrics.Handler())
 	srv := &http.Server{
 		Handler: router,
 		Addr:    ":8080",
 	}
 	Logger.Info("Start gpu mounter master on " + srv.Addr)
-	err := srv.ListenAndServe()
+	err = srv.ListenAndServe()
 	if err != nil {
 		Logger.Error("Failed to start gpu mounter master")
 		Logger.Error(err)
 		return
 	}
 }
-
-func findAllWorker() (map[string]corev1.Pod, error) {
-	clientSet, err := config.GetClientSet()
-	if err != nil {
-		Logger.Error("Connect to k8s failed")
-		Logger.Error(err.Error())
-		return nil, err
-	}
-	podList, err := clientSet.CoreV1().Pods("kube-system").List(context.TODO(), metav1.ListOptions{
-		LabelSelector: "app=gpu-mounter-worker",
-	})
-	if err != nil {
-		Logger.Error("Failed to gpu mounter worker")
-		return nil, err
-	}
-	workerMap := make(map[string]corev1.Pod)
-	for _, worker := range podList.Items {
-		workerMap[worker.Spec.NodeName] = worker
-		Logger.Info("Worker: ", worker.Name, " Node: ", worker.Spec.NodeName)
-	}
-	return workerMap, nil
-}
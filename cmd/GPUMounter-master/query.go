@@ -0,0 +1,81 @@
+package main
+
+import (
+	gpu_mount "GPUMounter/pkg/api/gpu-mount"
+	"GPUMounter/pkg/api/rest"
+	. "GPUMounter/pkg/util/log"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+func ListNodeGPUs(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	Logger.Info("access list node gpus service")
+	nodeName := ps.ByName("node")
+	Logger.Info("Node: ", nodeName)
+
+	conn, restErr := connectToNodeWorker(nodeName)
+	if restErr != nil {
+		writeError(w, restErr.Code, restErr.Message)
+		return
+	}
+
+	c := gpu_mount.NewGPUQueryServiceClient(conn)
+	resp, err := c.ListNodeGPUs(r.Context(), &gpu_mount.ListNodeGPUsRequest{})
+	if err != nil {
+		Logger.Error("Failed to call list node gpus service")
+		Logger.Error(err)
+		writeError(w, rest.ErrInternal, err.Error())
+		return
+	}
+
+	response := &rest.NodeGPUsResponse{
+		Node: nodeName,
+		GPUs: []*rest.GPU{},
+	}
+	for _, gpuDev := range resp.Gpus {
+		response.GPUs = append(response.GPUs, rest.NewGPU(gpuDev))
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func GetPodGPUs(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	Logger.Info("access get pod gpus service")
+	podName := ps.ByName("pod")
+	namespace := ps.ByName("namespace")
+	Logger.Info("Pod: ", podName, " Namespace: ", namespace)
+
+	pod, conn, restErr := connectToPodWorker(namespace, podName)
+	if restErr != nil {
+		writeError(w, restErr.Code, restErr.Message)
+		return
+	}
+
+	c := gpu_mount.NewGPUQueryServiceClient(conn)
+	resp, err := c.GetPodGPUs(r.Context(), &gpu_mount.GetPodGPUsRequest{
+		PodName:   podName,
+		Namespace: namespace,
+	})
+	if err != nil {
+		Logger.Error("Failed to call get pod gpus service")
+		Logger.Error(err)
+		writeError(w, rest.ErrInternal, err.Error())
+		return
+	}
+	if code := rest.GetPodGPUsResultCode(resp.GetPodGpusResult); code != "" {
+		Logger.Error("Failed to get gpus of Pod: ", podName, " result: ", resp.GetPodGpusResult.String())
+		writeError(w, code, "Failed to get gpus of Pod: "+podName+" on Node: "+pod.Spec.NodeName+" ("+resp.GetPodGpusResult.String()+")")
+		return
+	}
+
+	response := &rest.PodGPUsResponse{
+		Namespace: namespace,
+		Pod:       podName,
+		Node:      pod.Spec.NodeName,
+		MountType: resp.MountType,
+		GPUs:      []*rest.GPU{},
+	}
+	for _, gpuDev := range resp.Gpus {
+		response.GPUs = append(response.GPUs, rest.NewGPU(gpuDev))
+	}
+	writeJSON(w, http.StatusOK, response)
+}
@@ -2,12 +2,43 @@ package main
 
 import (
 	gpu_mount_api "GPUMounter/pkg/api/gpu-mount"
+	"GPUMounter/pkg/metrics"
 	gpu_mount "GPUMounter/pkg/server/gpu-mount"
 	. "GPUMounter/pkg/util/log"
+	"GPUMounter/pkg/util/mtls"
+	"GPUMounter/pkg/util/worker"
+	"context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"net"
+	"net/http"
+	"strings"
+	"time"
 )
 
+const (
+	// metricsInterval is the interval of refreshing mounted gpu and orphaned slave pod gauges
+	metricsInterval = 30 * time.Second
+	// leaseInterval is the interval of reclaiming gpus whose lease expired
+	leaseInterval = 30 * time.Second
+)
+
+// chainUnaryInterceptors runs the interceptors in order before the handler
+func chainUnaryInterceptors(interceptors ...grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		chained := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, next := interceptors[i], chained
+			chained = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return interceptor(ctx, req, info, next)
+			}
+		}
+		return chained(ctx, req)
+	}
+}
+
 func main() {
 	InitLogger("/var/log/GPUMounter/", "GPUMounter-worker.log")
 	defer Logger.Sync()
@@ -21,15 +52,67 @@ func main() {
 	}
 	Logger.Info("Successfully created gpu mounter")
 
-	lis, err := net.Listen("tcp", ":1200")
+	if err := gpuMounter.Reconcile(); err != nil {
+		Logger.Error("Failed to reconcile mount ledger")
+		Logger.Error(err)
+	}
+
+	go wait.Until(gpuMounter.UpdateMetrics, metricsInterval, wait.NeverStop)
+	go wait.Until(gpuMounter.ReclaimExpiredLeases, leaseInterval, wait.NeverStop)
+	sharedEnforceInterval, err := gpu_mount.GetSharedEnforceInterval()
+	if err != nil {
+		Logger.Error("Invalid shared enforce interval")
+		Logger.Error(err)
+		return
+	}
+	go wait.Until(gpuMounter.EnforceSharedGPUs, sharedEnforceInterval, wait.NeverStop)
+	if gpuMounter.Idle != nil {
+		go wait.Until(gpuMounter.UnmountIdleGPUs, gpuMounter.Idle.Interval, wait.NeverStop)
+	}
+	go func() {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		Logger.Info("Start metrics server on :" + worker.WorkerMetricsPort)
+		if err := http.ListenAndServe(":"+worker.WorkerMetricsPort, mux); err != nil {
+			Logger.Error("Failed to start metrics server")
+			Logger.Error(err)
+		}
+	}()
+
+	lis, err := net.Listen("tcp", ":"+worker.WorkerPort)
 	if err != nil {
 		Logger.Error("Listen Port Failed")
 		Logger.Error(err)
 	}
 
-	s := grpc.NewServer()
+	interceptors := []grpc.UnaryServerInterceptor{metrics.UnaryServerInterceptor}
+	var serverOptions []grpc.ServerOption
+	if mtls.Enabled() {
+		reloader, err := mtls.NewReloader(mtls.GetCertDir())
+		if err != nil {
+			Logger.Error("Failed to load gRPC TLS certificates")
+			Logger.Error(err)
+			return
+		}
+		serverOptions = append(serverOptions, grpc.Creds(mtls.ServerCredentials(reloader)))
+		// only the allowed clients can mount and unmount gpus
+		clientAllowlist := mtls.GetAllowlist("GRPC_CLIENT_ALLOWLIST", mtls.DefaultClientAllowlist)
+		interceptors = append(interceptors, mtls.AuthorizeInterceptor(clientAllowlist, "gpu_mount.AddGPUService", "gpu_mount.RemoveGPUService", "gpu_mount.LeaseService"))
+		Logger.Info("gRPC mutual TLS is enabled, allowed clients: ", strings.Join(clientAllowlist, ", "))
+	} else {
+		Logger.Warn("gRPC TLS is disabled, serving insecurely")
+	}
+	serverOptions = append(serverOptions, grpc.UnaryInterceptor(chainUnaryInterceptors(interceptors...)))
+
+	s := grpc.NewServer(serverOptions...)
 	gpu_mount_api.RegisterAddGPUServiceServer(s, gpuMounter)
 	gpu_mount_api.RegisterRemoveGPUServiceServer(s, gpuMounter)
+	gpu_mount_api.RegisterGPUQueryServiceServer(s, gpuMounter)
+	gpu_mount_api.RegisterLeaseServiceServer(s, gpuMounter)
+	// checked by master to drop connections to unhealthy workers
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(s, healthServer)
 	err = s.Serve(lis)
 	if err != nil {
 		Logger.Error("service start failed")
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"GPUMounter/pkg/api/rest"
+	"GPUMounter/pkg/client"
+	"GPUMounter/pkg/util/event"
+	"GPUMounter/pkg/util/gpu"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+var retryInterval = 2 * time.Second
+
+// retryWhile calls fn until it returns an error other than code, or the timeout is reached
+func retryWhile(ctx context.Context, code rest.ErrorCode, timeout time.Duration, fn func() error) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := fn()
+		if client.Code(err) != code || time.Now().After(deadline) {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "%v, retrying in %s\n", err, retryInterval)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// waitPodRunning waits until the pod is running, since gpus can only be mounted into running containers
+func (o *options) waitPodRunning(podName string) error {
+	return wait.PollImmediate(retryInterval, o.timeout, func() (bool, error) {
+		pod, err := o.clientset.CoreV1().Pods(o.namespace).Get(context.TODO(), podName, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		switch pod.Status.Phase {
+		case corev1.PodRunning:
+			return true, nil
+		case corev1.PodSucceeded, corev1.PodFailed:
+			return false, fmt.Errorf("pod %s/%s is %s", o.namespace, podName, pod.Status.Phase)
+		}
+		return false, nil
+	})
+}
+
+func newAddCommand(o *options) *cobra.Command {
+	request := &rest.AddGPURequest{}
+	var lease time.Duration
+	var waitFor bool
+	cmd := &cobra.Command{
+		Use:   "add POD",
+		Short: "Mount GPUs into a running pod",
+		Example: `  kubectl gpumount add gpu-pod --gpus 2
+  kubectl gpumount add gpu-pod --gpus 1 --container trainer --lease 2h --wait
+  kubectl gpumount add gpu-pod --shared --memory-limit 4096
+  kubectl gpumount add gpu-pod --gpus 2 --mig-profile 1g.5gb`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			podName, err := podArg(args)
+			if err != nil {
+				return err
+			}
+			if request.GPUNum <= 0 {
+				return errors.New("--gpus should be greater than 0")
+			}
+			if request.Shared != (request.MemoryLimitMiB != 0) {
+				return errors.New("--shared and --memory-limit should be given together")
+			}
+			request.LeaseSeconds = int64(lease / time.Second)
+			if waitFor {
+				if err := o.waitPodRunning(podName); err != nil {
+					return err
+				}
+			}
+			master, stop, err := o.master()
+			if err != nil {
+				return err
+			}
+			defer stop()
+
+			ctx := context.Background()
+			var response *rest.AddGPUResponse
+			add := func() (err error) {
+				response, err = master.AddGPU(ctx, o.namespace, podName, request)
+				return err
+			}
+			if waitFor {
+				// wait for gpus released by other pods
+				err = retryWhile(ctx, rest.ErrInsufficientGPU, o.timeout, add)
+			} else {
+				err = add()
+			}
+			if err != nil {
+				return err
+			}
+			return printObject(cmd.OutOrStdout(), o.output, response, func(w io.Writer) {
+				printMountedGPUs(w, response.GPUs)
+			})
+		},
+	}
+	flags := cmd.Flags()
+	flags.Int32Var(&request.GPUNum, "gpus", 1, "number of GPUs to mount")
+	flags.BoolVar(&request.IsEntireMount, "entire", false, "mount the GPUs entirely, so they can only be removed together")
+	flags.StringVarP(&request.Container, "container", "c", "", "container to mount the GPUs into, default to the first container")
+	flags.BoolVar(&request.AllContainers, "all-containers", false, "mount the GPUs into all containers of the pod")
+	flags.DurationVar(&lease, "lease", 0, "remove the GPUs after the duration, e.g. 2h")
+	flags.BoolVar(&request.ForceReclaim, "force-reclaim", false, "kill the processes on the GPUs when the lease expires")
+	flags.BoolVar(&request.Shared, "shared", false, "mount a GPU shared with other pods, requires --memory-limit")
+	flags.Uint64Var(&request.MemoryLimitMiB, "memory-limit", 0, "GPU memory in MiB the pod may use on the shared GPU")
+	flags.StringVar(&request.MIGProfile, "mig-profile", "", "mount MIG devices of the profile, e.g. 1g.5gb, instead of whole GPUs")
+	flags.StringVar(&request.Placement, "placement", "", "placement strategy of the GPUs: first-fit, topology, numa or spread, default to the strategy of the worker")
+	flags.BoolVar(&waitFor, "wait", false, "wait for the pod to be running and for free GPUs on its node, up to --timeout")
+	return cmd
+}
+
+func newRemoveCommand(o *options) *cobra.Command {
+	request := &rest.RemoveGPURequest{}
+	var all bool
+	var waitFor bool
+	cmd := &cobra.Command{
+		Use:   "remove POD [UUID...]",
+		Short: "Unmount GPUs from a pod",
+		Example: `  kubectl gpumount remove gpu-pod GPU-f61ffc1a-9e61-1c0e-2211-4f8f252fe7bc
+  kubectl gpumount remove gpu-pod --all --force`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			podName, err := podArg(args)
+			if err != nil {
+				return err
+			}
+			request.UUIDs = args[1:]
+			if all == (len(request.UUIDs) != 0) {
+				return errors.New("either UUIDs or --all should be given")
+			}
+			master, stop, err := o.master()
+			if err != nil {
+				return err
+			}
+			defer stop()
+
+			ctx := context.Background()
+			if all {
+				pod, err := master.ListPodGPUs(ctx, o.namespace, podName)
+				if err != nil {
+					return err
+				}
+				request.UUIDs = hotMountedUUIDs(pod.GPUs)
+				if len(request.UUIDs) == 0 {
+					return fmt.Errorf("no GPU is mounted into pod %s/%s", o.namespace, podName)
+				}
+			}
+			var response *rest.RemoveGPUResponse
+			remove := func() (err error) {
+				response, err = master.RemoveGPU(ctx, o.namespace, podName, request)
+				return err
+			}
+			if waitFor {
+				// wait for the processes on the gpus to exit
+				err = retryWhile(ctx, rest.ErrGPUBusy, o.timeout, remove)
+			} else {
+				err = remove()
+			}
+			if err != nil {
+				return err
+			}
+			return printObject(cmd.OutOrStdout(), o.output, response, func(w io.Writer) {
+				printRow(w, "UUID", "POD", "NODE")
+				for _, uuid := range response.UUIDs {
+					printRow(w, uuid, response.Namespace+"/"+response.Pod, response.Node)
+				}
+			})
+		},
+	}
+	flags := cmd.Flags()
+	flags.BoolVar(&all, "all", false, "remove all GPUs hot mounted into the pod")
+	flags.BoolVar(&request.Force, "force", false, "kill the processes on the GPUs before removing them")
+	flags.StringVarP(&request.Container, "container", "c", "", "container to remove the GPUs from, default to the first container")
+	flags.BoolVar(&request.AllContainers, "all-containers", false, "remove the GPUs from all containers of the pod")
+	flags.BoolVar(&waitFor, "wait", false, "wait for the processes on the GPUs to exit, up to --timeout")
+	return cmd
+}
+
+// hotMountedUUIDs returns the uuids of the gpus mounted by gpu mounter, the gpus the pod is started with have no slave pod
+func hotMountedUUIDs(gpus []*rest.GPU) []string {
+	var uuids []string
+	for _, gpuDev := range gpus {
+		if gpuDev.SlavePod != "" || gpuDev.MountType == string(gpu.SharedMount) {
+			uuids = append(uuids, gpuDev.UUID)
+		}
+	}
+	return uuids
+}
+
+func newListCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list POD",
+		Short: "List the GPUs of a pod reported by the worker on its node",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			podName, err := podArg(args)
+			if err != nil {
+				return err
+			}
+			master, stop, err := o.master()
+			if err != nil {
+				return err
+			}
+			defer stop()
+
+			response, err := master.ListPodGPUs(context.Background(), o.namespace, podName)
+			if err != nil {
+				return err
+			}
+			return printObject(cmd.OutOrStdout(), o.output, response, func(w io.Writer) {
+				printPodGPUs(w, response.GPUs)
+			})
+		},
+	}
+}
+
+// podStatus is the hot mount state recorded on the pod
+type podStatus struct {
+	Namespace   string            `json:"namespace"`
+	Pod         string            `json:"pod"`
+	Node        string            `json:"node"`
+	MountedGPUs []*gpu.MountedGPU `json:"mountedGPUs"`
+	Events      []*podEvent       `json:"events"`
+}
+
+type podEvent struct {
+	Type     string      `json:"type"`
+	Reason   string      `json:"reason"`
+	Message  string      `json:"message"`
+	Count    int32       `json:"count"`
+	LastSeen metav1.Time `json:"lastSeen"`
+}
+
+func newStatusCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "status POD",
+		Short: "Show the GPUs mounted into a pod and the events of mounting, without calling the master",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			podName, err := podArg(args)
+			if err != nil {
+				return err
+			}
+			pod, err := o.clientset.CoreV1().Pods(o.namespace).Get(context.TODO(), podName, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			mountedGPUs, err := gpu.GetMountedGPUs(pod.Annotations)
+			if err != nil {
+				return fmt.Errorf("invalid annotation %s: %v", gpu.MountedGPUsAnnotation, err)
+			}
+			events, err := o.clientset.CoreV1().Events(o.namespace).List(context.TODO(), metav1.ListOptions{
+				FieldSelector: fields.Set{"involvedObject.name": pod.Name, "involvedObject.uid": string(pod.UID)}.AsSelector().String(),
+			})
+			if err != nil {
+				return err
+			}
+
+			status := &podStatus{
+				Namespace:   pod.Namespace,
+				Pod:         pod.Name,
+				Node:        pod.Spec.NodeName,
+				MountedGPUs: mountedGPUs,
+				Events:      []*podEvent{},
+			}
+			if status.MountedGPUs == nil {
+				status.MountedGPUs = []*gpu.MountedGPU{}
+			}
+			for _, item := range events.Items {
+				if item.Source.Component != event.Component {
+					continue
+				}
+				status.Events = append(status.Events, &podEvent{
+					Type:     item.Type,
+					Reason:   item.Reason,
+					Message:  item.Message,
+					Count:    item.Count,
+					LastSeen: item.LastTimestamp,
+				})
+			}
+			sort.SliceStable(status.Events, func(i, j int) bool {
+				return status.Events[i].LastSeen.Before(&status.Events[j].LastSeen)
+			})
+			return printObject(cmd.OutOrStdout(), o.output, status, func(w io.Writer) {
+				printPodStatus(w, status)
+			})
+		},
+	}
+}
+
+func printPodStatus(w io.Writer, status *podStatus) {
+	printRow(w, "UUID", "SLAVE POD", "CONTAINERS", "LEASE EXPIRES")
+	for _, mountedGPU := range status.MountedGPUs {
+		printRow(w, mountedGPU.UUID, mountedGPU.SlavePod, fmt.Sprint(mountedGPU.Containers), orNone(mountedGPU.LeaseExpiresAt))
+	}
+	printRow(w)
+	printRow(w, "LAST SEEN", "TYPE", "REASON", "MESSAGE")
+	for _, e := range status.Events {
+		printRow(w, e.LastSeen.Format(time.RFC3339), e.Type, e.Reason, e.Message)
+	}
+}
+
+func newNodesCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "nodes [NODE...]",
+		Short: "List the GPUs of nodes and the pods they are mounted into, default to all nodes with GPUs",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			nodeNames := args
+			if len(nodeNames) == 0 {
+				nodes, err := o.clientset.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
+				if err != nil {
+					return err
+				}
+				for _, node := range nodes.Items {
+					for resourceName, quantity := range node.Status.Allocatable {
+						if gpu.IsGPUResource(string(resourceName)) && !quantity.IsZero() {
+							nodeNames = append(nodeNames, node.Name)
+							break
+						}
+					}
+				}
+			}
+			master, stop, err := o.master()
+			if err != nil {
+				return err
+			}
+			defer stop()
+
+			nodes := []*rest.NodeGPUsResponse{}
+			for _, nodeName := range nodeNames {
+				response, err := master.ListNodeGPUs(context.Background(), nodeName)
+				if err != nil {
+					if client.IsWorkerNotFound(err) && len(args) == 0 {
+						// nodes with gpus may not run a worker
+						continue
+					}
+					return err
+				}
+				nodes = append(nodes, response)
+			}
+			return printObject(cmd.OutOrStdout(), o.output, nodes, func(w io.Writer) {
+				printNodeGPUs(w, nodes)
+			})
+		},
+	}
+}
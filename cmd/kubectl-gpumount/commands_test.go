@@ -0,0 +1,214 @@
+package main
+
+import (
+	"GPUMounter/pkg/api/rest"
+	"GPUMounter/pkg/client/fake"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+)
+
+// nativeGPUClient reports a gpu the pod is started with besides the hot mounted ones
+type nativeGPUClient struct {
+	*fake.Client
+}
+
+func (c nativeGPUClient) ListPodGPUs(ctx context.Context, namespace string, podName string) (*rest.PodGPUsResponse, error) {
+	response, err := c.Client.ListPodGPUs(ctx, namespace, podName)
+	if err != nil {
+		return nil, err
+	}
+	response.GPUs = append([]*rest.GPU{{UUID: "GPU-native", State: "GPU_ALLOCATED_STATE"}}, response.GPUs...)
+	return response, nil
+}
+
+func newTestOptions(master *fake.Client) *options {
+	master.AddNode("gpu-node", "GPU-a", "GPU-b")
+	master.AddPod("default", "gpu-pod", "gpu-node")
+	return &options{namespace: "default", output: outputTable, timeout: time.Second, masterClient: nativeGPUClient{master}}
+}
+
+// newTestRootCommand returns the root command without loading the kubeconfig
+func newTestRootCommand(o *options) *cobra.Command {
+	root := &cobra.Command{Use: "kubectl-gpumount", SilenceUsage: true, SilenceErrors: true}
+	root.AddCommand(newAddCommand(o), newRemoveCommand(o), newListCommand(o))
+	root.SetOut(ioutil.Discard)
+	return root
+}
+
+func removeRequests(master *fake.Client) []*rest.RemoveGPURequest {
+	var requests []*rest.RemoveGPURequest
+	for _, action := range master.Actions() {
+		if action.Verb == "RemoveGPU" {
+			requests = append(requests, action.Request.(*rest.RemoveGPURequest))
+		}
+	}
+	return requests
+}
+
+func TestCommandArgs(t *testing.T) {
+	for _, c := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"add", ""}, errNoPod.Error()},
+		{[]string{"add", "gpu-pod", "--gpus", "0"}, "--gpus should be greater than 0"},
+		{[]string{"add", "gpu-pod", "--shared"}, "--shared and --memory-limit should be given together"},
+		{[]string{"remove", "gpu-pod"}, "either UUIDs or --all should be given"},
+		{[]string{"remove", "gpu-pod", "GPU-a", "--all"}, "either UUIDs or --all should be given"},
+		{[]string{"remove"}, "requires at least 1 arg"},
+	} {
+		master := fake.NewClient()
+		root := newTestRootCommand(newTestOptions(master))
+		root.SetArgs(c.args)
+		err := root.Execute()
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%v: expected error %q, got %v", c.args, c.err, err)
+		}
+		if len(master.Actions()) != 0 {
+			t.Errorf("%v: master should not be called: %+v", c.args, master.Actions())
+		}
+	}
+}
+
+func TestRemoveAll(t *testing.T) {
+	master := fake.NewClient()
+	o := newTestOptions(master)
+	if _, err := master.AddGPU(context.TODO(), "default", "gpu-pod", &rest.AddGPURequest{GPUNum: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	root := newTestRootCommand(o)
+	root.SetArgs([]string{"remove", "gpu-pod", "--all"})
+	if err := root.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the native gpu is not removed
+	requests := removeRequests(master)
+	if len(requests) != 1 || len(requests[0].UUIDs) != 1 || requests[0].UUIDs[0] != "GPU-a" {
+		t.Fatalf("unexpected remove requests: %+v", requests)
+	}
+
+	// only the native gpu is left
+	root = newTestRootCommand(o)
+	root.SetArgs([]string{"remove", "gpu-pod", "--all"})
+	if err := root.Execute(); err == nil || !strings.Contains(err.Error(), "no GPU is mounted") {
+		t.Errorf("expected no GPU error, got %v", err)
+	}
+	if len(removeRequests(master)) != 1 {
+		t.Errorf("remove should not be called without hot mounted gpus")
+	}
+}
+
+func TestHotMountedUUIDs(t *testing.T) {
+	uuids := hotMountedUUIDs([]*rest.GPU{
+		{UUID: "GPU-native"},
+		{UUID: "GPU-a", SlavePod: "gpu-pod-slave-pod-2b1c9e", MountType: "single-mount"},
+		{UUID: "GPU-shared", MountType: "shared-mount"},
+	})
+	if strings.Join(uuids, ",") != "GPU-a,GPU-shared" {
+		t.Errorf("unexpected uuids: %v", uuids)
+	}
+}
+
+func TestRetryWhile(t *testing.T) {
+	defer func(interval time.Duration) { retryInterval = interval }(retryInterval)
+	retryInterval = time.Millisecond
+	busy := fake.NewError(rest.ErrGPUBusy, "GPU is busy")
+
+	calls := 0
+	err := retryWhile(context.TODO(), rest.ErrGPUBusy, time.Second, func() error {
+		calls++
+		if calls < 3 {
+			return busy
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("expected success after 3 calls, got %v after %d calls", err, calls)
+	}
+
+	// other errors are not retried
+	calls = 0
+	notFound := fake.NewError(rest.ErrGPUNotFound, "no such GPU")
+	if err := retryWhile(context.TODO(), rest.ErrGPUBusy, time.Second, func() error {
+		calls++
+		return notFound
+	}); err != notFound || calls != 1 {
+		t.Errorf("expected %v after 1 call, got %v after %d calls", notFound, err, calls)
+	}
+
+	// the last error is returned once the timeout is reached
+	if err := retryWhile(context.TODO(), rest.ErrGPUBusy, 10*time.Millisecond, func() error {
+		return busy
+	}); err != busy {
+		t.Errorf("expected %v, got %v", busy, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := retryWhile(ctx, rest.ErrGPUBusy, time.Second, func() error {
+		return busy
+	}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context canceled, got %v", err)
+	}
+}
+
+func TestListOutput(t *testing.T) {
+	for _, output := range []string{outputTable, outputJSON, outputYAML} {
+		master := fake.NewClient()
+		o := newTestOptions(master)
+		o.output = output
+		if _, err := master.AddGPU(context.TODO(), "default", "gpu-pod", &rest.AddGPURequest{GPUNum: 1}); err != nil {
+			t.Fatal(err)
+		}
+		out := &bytes.Buffer{}
+		root := newTestRootCommand(o)
+		root.SetOut(out)
+		root.SetArgs([]string{"list", "gpu-pod"})
+		if err := root.Execute(); err != nil {
+			t.Fatalf("%s: unexpected error: %v", output, err)
+		}
+
+		if output == outputTable {
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			if len(lines) != 3 || !strings.HasPrefix(lines[0], "UUID") {
+				t.Fatalf("unexpected table:\n%s", out.String())
+			}
+			if fields := strings.Fields(lines[1]); fields[0] != "GPU-native" || fields[4] != "<none>" {
+				t.Errorf("unexpected row of native gpu: %s", lines[1])
+			}
+			if fields := strings.Fields(lines[2]); fields[0] != "GPU-a" || fields[4] != "gpu-pod-slave-pod-GPU-a" {
+				t.Errorf("unexpected row of mounted gpu: %s", lines[2])
+			}
+			continue
+		}
+		response := &rest.PodGPUsResponse{}
+		var err error
+		if output == outputJSON {
+			err = json.Unmarshal(out.Bytes(), response)
+		} else {
+			err = yaml.Unmarshal(out.Bytes(), response)
+		}
+		if err != nil {
+			t.Fatalf("%s: failed to parse output: %v\n%s", output, err, out.String())
+		}
+		if response.Pod != "gpu-pod" || response.Node != "gpu-node" || len(response.GPUs) != 2 || response.GPUs[1].SlavePod != "gpu-pod-slave-pod-GPU-a" {
+			t.Errorf("%s: unexpected response: %s", output, out.String())
+		}
+	}
+}
+
+func TestValidateOutput(t *testing.T) {
+	if err := validateOutput("wide"); err == nil {
+		t.Errorf("expected error for unknown output format")
+	}
+}
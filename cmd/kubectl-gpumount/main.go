@@ -0,0 +1,137 @@
+// kubectl-gpumount is a kubectl plugin calling gpu mounter master, installed as "kubectl gpumount" once on the PATH
+package main
+
+import (
+	"GPUMounter/pkg/client"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+	restclient "k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	defaultMasterNamespace = "kube-system"
+	defaultMasterService   = "gpu-mounter-service"
+)
+
+// options are the global flags shared by the subcommands
+type options struct {
+	kubeconfig      string
+	context         string
+	namespace       string
+	output          string
+	portForward     bool
+	masterNamespace string
+	masterService   string
+	timeout         time.Duration
+
+	clientConfig clientcmd.ClientConfig
+	restConfig   *restclient.Config
+	clientset    kubernetes.Interface
+	// masterClient is used instead of calling the master if set, e.g. in tests
+	masterClient client.Interface
+}
+
+func (o *options) complete() error {
+	if err := validateOutput(o.output); err != nil {
+		return err
+	}
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = o.kubeconfig
+	o.clientConfig = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{CurrentContext: o.context})
+	if o.namespace == "" {
+		// the namespace of the current context
+		namespace, _, err := o.clientConfig.Namespace()
+		if err != nil {
+			return err
+		}
+		o.namespace = namespace
+	}
+	restConfig, err := o.clientConfig.ClientConfig()
+	if err != nil {
+		return err
+	}
+	o.restConfig = restConfig
+	o.clientset, err = kubernetes.NewForConfig(restConfig)
+	return err
+}
+
+// master returns the client of gpu mounter master, the caller should call stop when done
+func (o *options) master() (master client.Interface, stop func(), err error) {
+	if o.masterClient != nil {
+		return o.masterClient, func() {}, nil
+	}
+	if !o.portForward {
+		master, err = client.NewForServiceProxy(o.restConfig, o.masterNamespace, o.masterService)
+		return master, func() {}, err
+	}
+	var kubectlArgs []string
+	if o.kubeconfig != "" {
+		kubectlArgs = append(kubectlArgs, "--kubeconfig", o.kubeconfig)
+	}
+	if o.context != "" {
+		kubectlArgs = append(kubectlArgs, "--context", o.context)
+	}
+	forward, err := startPortForward(kubectlArgs, o.masterNamespace, o.masterService)
+	if err != nil {
+		return nil, nil, err
+	}
+	master, err = client.New("http://127.0.0.1:"+forward.port, bearerTokenOption(o.restConfig))
+	if err != nil {
+		forward.stop()
+		return nil, nil, err
+	}
+	return master, forward.stop, nil
+}
+
+func newRootCommand() *cobra.Command {
+	o := &options{}
+	cmd := &cobra.Command{
+		Use:           "kubectl-gpumount",
+		Short:         "Hot mount GPUs into running pods through gpu mounter master",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return o.complete()
+		},
+	}
+	flags := cmd.PersistentFlags()
+	flags.StringVar(&o.kubeconfig, "kubeconfig", "", "path to the kubeconfig file, default to $KUBECONFIG or ~/.kube/config")
+	flags.StringVar(&o.context, "context", "", "kubeconfig context to use, default to the current context")
+	flags.StringVarP(&o.namespace, "namespace", "n", "", "namespace of the pod, default to the namespace of the context")
+	flags.StringVarP(&o.output, "output", "o", outputTable, "output format: table, json or yaml")
+	flags.BoolVar(&o.portForward, "port-forward", false, "reach the master by kubectl port-forward instead of the api server service proxy, needed when API_AUTH is enabled")
+	flags.StringVar(&o.masterNamespace, "master-namespace", defaultMasterNamespace, "namespace of the gpu mounter master service")
+	flags.StringVar(&o.masterService, "master-service", defaultMasterService, "name of the gpu mounter master service")
+	flags.DurationVar(&o.timeout, "timeout", 5*time.Minute, "how long to wait with --wait")
+
+	cmd.AddCommand(
+		newAddCommand(o),
+		newRemoveCommand(o),
+		newListCommand(o),
+		newStatusCommand(o),
+		newNodesCommand(o),
+	)
+	return cmd
+}
+
+func main() {
+	if err := newRootCommand().Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+var errNoPod = errors.New("pod name is required")
+
+func podArg(args []string) (string, error) {
+	if len(args) == 0 || args[0] == "" {
+		return "", errNoPod
+	}
+	return args[0], nil
+}
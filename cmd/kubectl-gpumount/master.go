@@ -0,0 +1,89 @@
+package main
+
+import (
+	"GPUMounter/pkg/client"
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"time"
+
+	restclient "k8s.io/client-go/rest"
+)
+
+var forwardingRegexp = regexp.MustCompile(`Forwarding from 127\.0\.0\.1:(\d+)`)
+
+// parseForwardedPort returns the local port in the output of kubectl port-forward, "" if the line has none
+func parseForwardedPort(line string) string {
+	match := forwardingRegexp.FindStringSubmatch(line)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+// portForward runs kubectl port-forward to the master service
+type portForward struct {
+	cmd  *exec.Cmd
+	port string
+}
+
+func startPortForward(kubectlArgs []string, namespace string, service string) (*portForward, error) {
+	args := append([]string{"port-forward", "--namespace", namespace, "service/" + service, ":80"}, kubectlArgs...)
+	cmd := exec.Command("kubectl", args...)
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start kubectl port-forward: %v", err)
+	}
+
+	ports := make(chan string, 1)
+	go func() {
+		// keep reading, kubectl blocks once the pipe is full
+		scanner := bufio.NewScanner(stdout)
+		sent := false
+		for scanner.Scan() {
+			if port := parseForwardedPort(scanner.Text()); port != "" && !sent {
+				ports <- port
+				sent = true
+			}
+		}
+		if !sent {
+			close(ports)
+		}
+	}()
+
+	forward := &portForward{cmd: cmd}
+	select {
+	case port, ok := <-ports:
+		if !ok {
+			forward.stop()
+			return nil, errors.New("kubectl port-forward exited before forwarding")
+		}
+		forward.port = port
+	case <-time.After(30 * time.Second):
+		forward.stop()
+		return nil, errors.New("timeout waiting for kubectl port-forward")
+	}
+	return forward, nil
+}
+
+func (forward *portForward) stop() {
+	if forward.cmd.Process != nil {
+		forward.cmd.Process.Kill()
+		forward.cmd.Wait()
+	}
+}
+
+// bearerTokenOption sends the token of the kubeconfig to the master, which the service proxy of the api server strips
+func bearerTokenOption(config *restclient.Config) client.Option {
+	if config.BearerToken == "" && config.BearerTokenFile != "" {
+		return client.WithBearerTokenFile(config.BearerTokenFile)
+	}
+	return client.WithBearerToken(config.BearerToken)
+}
@@ -0,0 +1,12 @@
+package main
+
+import "testing"
+
+func TestParseForwardedPort(t *testing.T) {
+	if port := parseForwardedPort("Forwarding from 127.0.0.1:41235 -> 8080"); port != "41235" {
+		t.Errorf("expected port 41235, got %q", port)
+	}
+	if port := parseForwardedPort("Forwarding from [::1]:41235 -> 8080"); port != "" {
+		t.Errorf("expected no port, got %q", port)
+	}
+}
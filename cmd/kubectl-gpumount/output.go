@@ -0,0 +1,88 @@
+package main
+
+import (
+	"GPUMounter/pkg/api/rest"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+func validateOutput(output string) error {
+	switch output {
+	case outputTable, outputJSON, outputYAML:
+		return nil
+	}
+	return fmt.Errorf("unknown output format: %s, should be table, json or yaml", output)
+}
+
+// printObject prints obj as json or yaml, or calls printTable for the table output
+func printObject(w io.Writer, output string, obj interface{}, printTable func(w io.Writer)) error {
+	switch output {
+	case outputJSON:
+		data, err := json.MarshalIndent(obj, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	case outputYAML:
+		data, err := yaml.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 3, ' ', 0)
+	printTable(tw)
+	return tw.Flush()
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "<none>"
+	}
+	return value
+}
+
+func printRow(w io.Writer, columns ...string) {
+	fmt.Fprintln(w, strings.Join(columns, "\t"))
+}
+
+func printMountedGPUs(w io.Writer, gpus []*rest.MountedGPU) {
+	printRow(w, "UUID", "MINOR", "DEVICE", "SLAVE POD", "LEASE EXPIRES")
+	for _, gpu := range gpus {
+		printRow(w, gpu.UUID, strconv.Itoa(int(gpu.MinorNumber)), gpu.DeviceFilePath, gpu.SlavePod, orNone(gpu.LeaseExpiresAt))
+	}
+}
+
+func printPodGPUs(w io.Writer, gpus []*rest.GPU) {
+	printRow(w, "UUID", "MINOR", "DEVICE", "STATE", "SLAVE POD", "MOUNT TYPE", "LEASE EXPIRES")
+	for _, gpu := range gpus {
+		printRow(w, gpu.UUID, strconv.Itoa(int(gpu.MinorNumber)), gpu.DeviceFilePath, gpu.State, orNone(gpu.SlavePod), orNone(gpu.MountType), orNone(gpu.LeaseExpiresAt))
+	}
+}
+
+func printNodeGPUs(w io.Writer, nodes []*rest.NodeGPUsResponse) {
+	printRow(w, "NODE", "UUID", "MINOR", "STATE", "OWNER", "SLAVE POD", "LEASE EXPIRES")
+	for _, node := range nodes {
+		for _, gpu := range node.GPUs {
+			owner := ""
+			if gpu.OwnerPod != "" {
+				owner = gpu.OwnerNamespace + "/" + gpu.OwnerPod
+			}
+			printRow(w, node.Node, gpu.UUID, strconv.Itoa(int(gpu.MinorNumber)), gpu.State, orNone(owner), orNone(gpu.SlavePod), orNone(gpu.LeaseExpiresAt))
+		}
+	}
+}
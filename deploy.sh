@@ -9,6 +9,8 @@ if [ "$1" = "deploy" ]; then
   kubectl create -f deploy/namespace.yaml
   kubectl create -f deploy/service-account.yaml
   kubectl create -f deploy/cluster-role-binding.yaml
+  kubectl create -f deploy/gpumount-crd.yaml
+  kubectl create -f deploy/gpumount-user-role.yaml
   kubectl create -f deploy/gpu-mounter-workers.yaml
   kubectl create -f deploy/gpu-mounter-master.yaml
   kubectl create -f deploy/gpu-mounter-svc.yaml
@@ -17,6 +19,8 @@ elif [ "$1" = "redeploy" ]; then
   kubectl delete -f deploy/namespace.yaml
   kubectl delete -f deploy/service-account.yaml
   kubectl delete -f deploy/cluster-role-binding.yaml
+  kubectl delete -f deploy/gpumount-crd.yaml
+  kubectl delete -f deploy/gpumount-user-role.yaml
   kubectl delete -f deploy/gpu-mounter-workers.yaml
   kubectl delete -f deploy/gpu-mounter-master.yaml
   kubectl delete -f deploy/gpu-mounter-svc.yaml
@@ -24,6 +28,8 @@ elif [ "$1" = "redeploy" ]; then
   kubectl create -f deploy/namespace.yaml
   kubectl create -f deploy/service-account.yaml
   kubectl create -f deploy/cluster-role-binding.yaml
+  kubectl create -f deploy/gpumount-crd.yaml
+  kubectl create -f deploy/gpumount-user-role.yaml
   kubectl create -f deploy/gpu-mounter-workers.yaml
   kubectl create -f deploy/gpu-mounter-master.yaml
   kubectl create -f deploy/gpu-mounter-svc.yaml
@@ -32,6 +38,8 @@ elif [ "$1" = "uninstall" ]; then
   kubectl delete -f deploy/namespace.yaml
   kubectl delete -f deploy/service-account.yaml
   kubectl delete -f deploy/cluster-role-binding.yaml
+  kubectl delete -f deploy/gpumount-crd.yaml
+  kubectl delete -f deploy/gpumount-user-role.yaml
   kubectl delete -f deploy/gpu-mounter-workers.yaml
   kubectl delete -f deploy/gpu-mounter-master.yaml
   kubectl delete -f deploy/gpu-mounter-svc.yaml
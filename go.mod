@@ -3,15 +3,21 @@ module GPUMounter
 go 1.14
 
 require (
+	github.com/cilium/ebpf v0.0.0-20200702112145-1c8d4c9ef775
 	github.com/golang/protobuf v1.4.2
 	github.com/julienschmidt/httprouter v1.3.0
 	github.com/opencontainers/runc v1.0.0-rc92
+	github.com/opencontainers/runtime-spec v1.0.3-0.20200728170252-4d89ac9fbff6
+	github.com/prometheus/client_golang v1.7.1
+	github.com/spf13/cobra v1.0.0
 	go.uber.org/zap v1.16.0
+	golang.org/x/sys v0.0.0-20200728102440-3e129f6d46b1
 	google.golang.org/grpc v1.26.0
 	k8s.io/api v0.18.6
 	k8s.io/apimachinery v0.18.6
 	k8s.io/client-go v0.18.6
 	k8s.io/kubernetes v1.18.6
+	sigs.k8s.io/yaml v1.2.0
 )
 
 replace (
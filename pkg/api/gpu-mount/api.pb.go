@@ -27,21 +27,45 @@ const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 type AddGPUResponse_AddGPUResult int32
 
 const (
-	AddGPUResponse_Success         AddGPUResponse_AddGPUResult = 0
-	AddGPUResponse_InsufficientGPU AddGPUResponse_AddGPUResult = 1
-	AddGPUResponse_PodNotFound     AddGPUResponse_AddGPUResult = 2
+	AddGPUResponse_Success                  AddGPUResponse_AddGPUResult = 0
+	AddGPUResponse_InsufficientGPU          AddGPUResponse_AddGPUResult = 1
+	AddGPUResponse_PodNotFound              AddGPUResponse_AddGPUResult = 2
+	AddGPUResponse_SlavePodImagePullFailed  AddGPUResponse_AddGPUResult = 3
+	AddGPUResponse_SlavePodCrashLoopBackOff AddGPUResponse_AddGPUResult = 4
+	AddGPUResponse_SlavePodEvicted          AddGPUResponse_AddGPUResult = 5
+	AddGPUResponse_SlavePodFailed           AddGPUResponse_AddGPUResult = 6
+	AddGPUResponse_SlavePodTimeout          AddGPUResponse_AddGPUResult = 7
+	AddGPUResponse_ContainerNotFound        AddGPUResponse_AddGPUResult = 8
+	AddGPUResponse_MountFailed              AddGPUResponse_AddGPUResult = 9
+	AddGPUResponse_QuotaExceeded            AddGPUResponse_AddGPUResult = 10
 )
 
 var AddGPUResponse_AddGPUResult_name = map[int32]string{
-	0: "Success",
-	1: "InsufficientGPU",
-	2: "PodNotFound",
+	0:  "Success",
+	1:  "InsufficientGPU",
+	2:  "PodNotFound",
+	3:  "SlavePodImagePullFailed",
+	4:  "SlavePodCrashLoopBackOff",
+	5:  "SlavePodEvicted",
+	6:  "SlavePodFailed",
+	7:  "SlavePodTimeout",
+	8:  "ContainerNotFound",
+	9:  "MountFailed",
+	10: "QuotaExceeded",
 }
 
 var AddGPUResponse_AddGPUResult_value = map[string]int32{
-	"Success":         0,
-	"InsufficientGPU": 1,
-	"PodNotFound":     2,
+	"Success":                  0,
+	"InsufficientGPU":          1,
+	"PodNotFound":              2,
+	"SlavePodImagePullFailed":  3,
+	"SlavePodCrashLoopBackOff": 4,
+	"SlavePodEvicted":          5,
+	"SlavePodFailed":           6,
+	"SlavePodTimeout":          7,
+	"ContainerNotFound":        8,
+	"MountFailed":              9,
+	"QuotaExceeded":            10,
 }
 
 func (x AddGPUResponse_AddGPUResult) String() string {
@@ -49,16 +73,18 @@ func (x AddGPUResponse_AddGPUResult) String() string {
 }
 
 func (AddGPUResponse_AddGPUResult) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptor_00212fb1f9d3bf1c, []int{1, 0}
+	return fileDescriptor_00212fb1f9d3bf1c, []int{3, 0}
 }
 
 type RemoveGPUResponse_RemoveGPUResult int32
 
 const (
-	RemoveGPUResponse_Success     RemoveGPUResponse_RemoveGPUResult = 0
-	RemoveGPUResponse_GPUBusy     RemoveGPUResponse_RemoveGPUResult = 1
-	RemoveGPUResponse_PodNotFound RemoveGPUResponse_RemoveGPUResult = 2
-	RemoveGPUResponse_GPUNotFound RemoveGPUResponse_RemoveGPUResult = 4
+	RemoveGPUResponse_Success           RemoveGPUResponse_RemoveGPUResult = 0
+	RemoveGPUResponse_GPUBusy           RemoveGPUResponse_RemoveGPUResult = 1
+	RemoveGPUResponse_PodNotFound       RemoveGPUResponse_RemoveGPUResult = 2
+	RemoveGPUResponse_GPUNotFound       RemoveGPUResponse_RemoveGPUResult = 4
+	RemoveGPUResponse_ContainerNotFound RemoveGPUResponse_RemoveGPUResult = 5
+	RemoveGPUResponse_UnmountFailed     RemoveGPUResponse_RemoveGPUResult = 6
 )
 
 var RemoveGPUResponse_RemoveGPUResult_name = map[int32]string{
@@ -66,13 +92,17 @@ var RemoveGPUResponse_RemoveGPUResult_name = map[int32]string{
 	1: "GPUBusy",
 	2: "PodNotFound",
 	4: "GPUNotFound",
+	5: "ContainerNotFound",
+	6: "UnmountFailed",
 }
 
 var RemoveGPUResponse_RemoveGPUResult_value = map[string]int32{
-	"Success":     0,
-	"GPUBusy":     1,
-	"PodNotFound": 2,
-	"GPUNotFound": 4,
+	"Success":           0,
+	"GPUBusy":           1,
+	"PodNotFound":       2,
+	"GPUNotFound":       4,
+	"ContainerNotFound": 5,
+	"UnmountFailed":     6,
 }
 
 func (x RemoveGPUResponse_RemoveGPUResult) String() string {
@@ -80,7 +110,63 @@ func (x RemoveGPUResponse_RemoveGPUResult) String() string {
 }
 
 func (RemoveGPUResponse_RemoveGPUResult) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptor_00212fb1f9d3bf1c, []int{3, 0}
+	return fileDescriptor_00212fb1f9d3bf1c, []int{5, 0}
+}
+
+type GetPodGPUsResponse_GetPodGPUsResult int32
+
+const (
+	GetPodGPUsResponse_Success     GetPodGPUsResponse_GetPodGPUsResult = 0
+	GetPodGPUsResponse_PodNotFound GetPodGPUsResponse_GetPodGPUsResult = 2
+)
+
+var GetPodGPUsResponse_GetPodGPUsResult_name = map[int32]string{
+	0: "Success",
+	2: "PodNotFound",
+}
+
+var GetPodGPUsResponse_GetPodGPUsResult_value = map[string]int32{
+	"Success":     0,
+	"PodNotFound": 2,
+}
+
+func (x GetPodGPUsResponse_GetPodGPUsResult) String() string {
+	return proto.EnumName(GetPodGPUsResponse_GetPodGPUsResult_name, int32(x))
+}
+
+func (GetPodGPUsResponse_GetPodGPUsResult) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_00212fb1f9d3bf1c, []int{9, 0}
+}
+
+type ExtendLeaseResponse_ExtendLeaseResult int32
+
+const (
+	ExtendLeaseResponse_Success     ExtendLeaseResponse_ExtendLeaseResult = 0
+	ExtendLeaseResponse_PodNotFound ExtendLeaseResponse_ExtendLeaseResult = 1
+	ExtendLeaseResponse_GPUNotFound ExtendLeaseResponse_ExtendLeaseResult = 2
+	ExtendLeaseResponse_NoLease     ExtendLeaseResponse_ExtendLeaseResult = 3
+)
+
+var ExtendLeaseResponse_ExtendLeaseResult_name = map[int32]string{
+	0: "Success",
+	1: "PodNotFound",
+	2: "GPUNotFound",
+	3: "NoLease",
+}
+
+var ExtendLeaseResponse_ExtendLeaseResult_value = map[string]int32{
+	"Success":     0,
+	"PodNotFound": 1,
+	"GPUNotFound": 2,
+	"NoLease":     3,
+}
+
+func (x ExtendLeaseResponse_ExtendLeaseResult) String() string {
+	return proto.EnumName(ExtendLeaseResponse_ExtendLeaseResult_name, int32(x))
+}
+
+func (ExtendLeaseResponse_ExtendLeaseResult) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_00212fb1f9d3bf1c, []int{11, 0}
 }
 
 type AddGPURequest struct {
@@ -88,6 +174,14 @@ type AddGPURequest struct {
 	Namespace            string   `protobuf:"bytes,2,opt,name=namespace,proto3" json:"namespace,omitempty"`
 	GpuNum               int32    `protobuf:"varint,3,opt,name=gpu_num,json=gpuNum,proto3" json:"gpu_num,omitempty"`
 	IsEntireMount        bool     `protobuf:"varint,4,opt,name=is_entire_mount,json=isEntireMount,proto3" json:"is_entire_mount,omitempty"`
+	ContainerName        string   `protobuf:"bytes,5,opt,name=container_name,json=containerName,proto3" json:"container_name,omitempty"`
+	AllContainers        bool     `protobuf:"varint,6,opt,name=all_containers,json=allContainers,proto3" json:"all_containers,omitempty"`
+	LeaseSeconds         int64    `protobuf:"varint,7,opt,name=lease_seconds,json=leaseSeconds,proto3" json:"lease_seconds,omitempty"`
+	ForceReclaim         bool     `protobuf:"varint,8,opt,name=force_reclaim,json=forceReclaim,proto3" json:"force_reclaim,omitempty"`
+	PlacementStrategy    string   `protobuf:"bytes,9,opt,name=placement_strategy,json=placementStrategy,proto3" json:"placement_strategy,omitempty"`
+	Shared               bool     `protobuf:"varint,10,opt,name=shared,proto3" json:"shared,omitempty"`
+	MemoryLimitMib       uint64   `protobuf:"varint,11,opt,name=memory_limit_mib,json=memoryLimitMib,proto3" json:"memory_limit_mib,omitempty"`
+	MigProfile           string   `protobuf:"bytes,12,opt,name=mig_profile,json=migProfile,proto3" json:"mig_profile,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -146,8 +240,257 @@ func (m *AddGPURequest) GetIsEntireMount() bool {
 	return false
 }
 
+func (m *AddGPURequest) GetContainerName() string {
+	if m != nil {
+		return m.ContainerName
+	}
+	return ""
+}
+
+func (m *AddGPURequest) GetAllContainers() bool {
+	if m != nil {
+		return m.AllContainers
+	}
+	return false
+}
+
+func (m *AddGPURequest) GetLeaseSeconds() int64 {
+	if m != nil {
+		return m.LeaseSeconds
+	}
+	return 0
+}
+
+func (m *AddGPURequest) GetForceReclaim() bool {
+	if m != nil {
+		return m.ForceReclaim
+	}
+	return false
+}
+
+func (m *AddGPURequest) GetPlacementStrategy() string {
+	if m != nil {
+		return m.PlacementStrategy
+	}
+	return ""
+}
+
+func (m *AddGPURequest) GetShared() bool {
+	if m != nil {
+		return m.Shared
+	}
+	return false
+}
+
+func (m *AddGPURequest) GetMemoryLimitMib() uint64 {
+	if m != nil {
+		return m.MemoryLimitMib
+	}
+	return 0
+}
+
+func (m *AddGPURequest) GetMigProfile() string {
+	if m != nil {
+		return m.MigProfile
+	}
+	return ""
+}
+
+type GPUDevice struct {
+	Uuid                 string   `protobuf:"bytes,1,opt,name=uuid,proto3" json:"uuid,omitempty"`
+	MinorNumber          int32    `protobuf:"varint,2,opt,name=minor_number,json=minorNumber,proto3" json:"minor_number,omitempty"`
+	DeviceFilePath       string   `protobuf:"bytes,3,opt,name=device_file_path,json=deviceFilePath,proto3" json:"device_file_path,omitempty"`
+	SlavePodName         string   `protobuf:"bytes,4,opt,name=slave_pod_name,json=slavePodName,proto3" json:"slave_pod_name,omitempty"`
+	State                string   `protobuf:"bytes,5,opt,name=state,proto3" json:"state,omitempty"`
+	OwnerPodName         string   `protobuf:"bytes,6,opt,name=owner_pod_name,json=ownerPodName,proto3" json:"owner_pod_name,omitempty"`
+	OwnerNamespace       string   `protobuf:"bytes,7,opt,name=owner_namespace,json=ownerNamespace,proto3" json:"owner_namespace,omitempty"`
+	MountType            string   `protobuf:"bytes,8,opt,name=mount_type,json=mountType,proto3" json:"mount_type,omitempty"`
+	LeaseExpiresAt       string   `protobuf:"bytes,9,opt,name=lease_expires_at,json=leaseExpiresAt,proto3" json:"lease_expires_at,omitempty"`
+	MemoryLimitMib       uint64   `protobuf:"varint,10,opt,name=memory_limit_mib,json=memoryLimitMib,proto3" json:"memory_limit_mib,omitempty"`
+	MigProfile           string   `protobuf:"bytes,11,opt,name=mig_profile,json=migProfile,proto3" json:"mig_profile,omitempty"`
+	ParentUuid           string   `protobuf:"bytes,12,opt,name=parent_uuid,json=parentUuid,proto3" json:"parent_uuid,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GPUDevice) Reset()         { *m = GPUDevice{} }
+func (m *GPUDevice) String() string { return proto.CompactTextString(m) }
+func (*GPUDevice) ProtoMessage()    {}
+func (*GPUDevice) Descriptor() ([]byte, []int) {
+	return fileDescriptor_00212fb1f9d3bf1c, []int{1}
+}
+
+func (m *GPUDevice) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GPUDevice.Unmarshal(m, b)
+}
+func (m *GPUDevice) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GPUDevice.Marshal(b, m, deterministic)
+}
+func (m *GPUDevice) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GPUDevice.Merge(m, src)
+}
+func (m *GPUDevice) XXX_Size() int {
+	return xxx_messageInfo_GPUDevice.Size(m)
+}
+func (m *GPUDevice) XXX_DiscardUnknown() {
+	xxx_messageInfo_GPUDevice.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GPUDevice proto.InternalMessageInfo
+
+func (m *GPUDevice) GetUuid() string {
+	if m != nil {
+		return m.Uuid
+	}
+	return ""
+}
+
+func (m *GPUDevice) GetMinorNumber() int32 {
+	if m != nil {
+		return m.MinorNumber
+	}
+	return 0
+}
+
+func (m *GPUDevice) GetDeviceFilePath() string {
+	if m != nil {
+		return m.DeviceFilePath
+	}
+	return ""
+}
+
+func (m *GPUDevice) GetSlavePodName() string {
+	if m != nil {
+		return m.SlavePodName
+	}
+	return ""
+}
+
+func (m *GPUDevice) GetState() string {
+	if m != nil {
+		return m.State
+	}
+	return ""
+}
+
+func (m *GPUDevice) GetOwnerPodName() string {
+	if m != nil {
+		return m.OwnerPodName
+	}
+	return ""
+}
+
+func (m *GPUDevice) GetOwnerNamespace() string {
+	if m != nil {
+		return m.OwnerNamespace
+	}
+	return ""
+}
+
+func (m *GPUDevice) GetMountType() string {
+	if m != nil {
+		return m.MountType
+	}
+	return ""
+}
+
+func (m *GPUDevice) GetLeaseExpiresAt() string {
+	if m != nil {
+		return m.LeaseExpiresAt
+	}
+	return ""
+}
+
+func (m *GPUDevice) GetMemoryLimitMib() uint64 {
+	if m != nil {
+		return m.MemoryLimitMib
+	}
+	return 0
+}
+
+func (m *GPUDevice) GetMigProfile() string {
+	if m != nil {
+		return m.MigProfile
+	}
+	return ""
+}
+
+func (m *GPUDevice) GetParentUuid() string {
+	if m != nil {
+		return m.ParentUuid
+	}
+	return ""
+}
+
+type ContainerResult struct {
+	ContainerName        string   `protobuf:"bytes,1,opt,name=container_name,json=containerName,proto3" json:"container_name,omitempty"`
+	ContainerId          string   `protobuf:"bytes,2,opt,name=container_id,json=containerId,proto3" json:"container_id,omitempty"`
+	Success              bool     `protobuf:"varint,3,opt,name=success,proto3" json:"success,omitempty"`
+	Message              string   `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ContainerResult) Reset()         { *m = ContainerResult{} }
+func (m *ContainerResult) String() string { return proto.CompactTextString(m) }
+func (*ContainerResult) ProtoMessage()    {}
+func (*ContainerResult) Descriptor() ([]byte, []int) {
+	return fileDescriptor_00212fb1f9d3bf1c, []int{2}
+}
+
+func (m *ContainerResult) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ContainerResult.Unmarshal(m, b)
+}
+func (m *ContainerResult) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ContainerResult.Marshal(b, m, deterministic)
+}
+func (m *ContainerResult) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ContainerResult.Merge(m, src)
+}
+func (m *ContainerResult) XXX_Size() int {
+	return xxx_messageInfo_ContainerResult.Size(m)
+}
+func (m *ContainerResult) XXX_DiscardUnknown() {
+	xxx_messageInfo_ContainerResult.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ContainerResult proto.InternalMessageInfo
+
+func (m *ContainerResult) GetContainerName() string {
+	if m != nil {
+		return m.ContainerName
+	}
+	return ""
+}
+
+func (m *ContainerResult) GetContainerId() string {
+	if m != nil {
+		return m.ContainerId
+	}
+	return ""
+}
+
+func (m *ContainerResult) GetSuccess() bool {
+	if m != nil {
+		return m.Success
+	}
+	return false
+}
+
+func (m *ContainerResult) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
 type AddGPUResponse struct {
 	AddGpuResult         AddGPUResponse_AddGPUResult `protobuf:"varint,1,opt,name=add_gpu_result,json=addGpuResult,proto3,enum=gpu_mount.AddGPUResponse_AddGPUResult" json:"add_gpu_result,omitempty"`
+	Gpus                 []*GPUDevice                `protobuf:"bytes,2,rep,name=gpus,proto3" json:"gpus,omitempty"`
+	ContainerResults     []*ContainerResult          `protobuf:"bytes,3,rep,name=container_results,json=containerResults,proto3" json:"container_results,omitempty"`
+	Message              string                      `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}                    `json:"-"`
 	XXX_unrecognized     []byte                      `json:"-"`
 	XXX_sizecache        int32                       `json:"-"`
@@ -157,7 +500,7 @@ func (m *AddGPUResponse) Reset()         { *m = AddGPUResponse{} }
 func (m *AddGPUResponse) String() string { return proto.CompactTextString(m) }
 func (*AddGPUResponse) ProtoMessage()    {}
 func (*AddGPUResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_00212fb1f9d3bf1c, []int{1}
+	return fileDescriptor_00212fb1f9d3bf1c, []int{3}
 }
 
 func (m *AddGPUResponse) XXX_Unmarshal(b []byte) error {
@@ -185,11 +528,34 @@ func (m *AddGPUResponse) GetAddGpuResult() AddGPUResponse_AddGPUResult {
 	return AddGPUResponse_Success
 }
 
+func (m *AddGPUResponse) GetGpus() []*GPUDevice {
+	if m != nil {
+		return m.Gpus
+	}
+	return nil
+}
+
+func (m *AddGPUResponse) GetContainerResults() []*ContainerResult {
+	if m != nil {
+		return m.ContainerResults
+	}
+	return nil
+}
+
+func (m *AddGPUResponse) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
 type RemoveGPURequest struct {
 	PodName              string   `protobuf:"bytes,1,opt,name=pod_name,json=podName,proto3" json:"pod_name,omitempty"`
 	Namespace            string   `protobuf:"bytes,2,opt,name=namespace,proto3" json:"namespace,omitempty"`
 	Uuids                []string `protobuf:"bytes,3,rep,name=uuids,proto3" json:"uuids,omitempty"`
 	Force                bool     `protobuf:"varint,4,opt,name=force,proto3" json:"force,omitempty"`
+	ContainerName        string   `protobuf:"bytes,5,opt,name=container_name,json=containerName,proto3" json:"container_name,omitempty"`
+	AllContainers        bool     `protobuf:"varint,6,opt,name=all_containers,json=allContainers,proto3" json:"all_containers,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -199,7 +565,7 @@ func (m *RemoveGPURequest) Reset()         { *m = RemoveGPURequest{} }
 func (m *RemoveGPURequest) String() string { return proto.CompactTextString(m) }
 func (*RemoveGPURequest) ProtoMessage()    {}
 func (*RemoveGPURequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_00212fb1f9d3bf1c, []int{2}
+	return fileDescriptor_00212fb1f9d3bf1c, []int{4}
 }
 
 func (m *RemoveGPURequest) XXX_Unmarshal(b []byte) error {
@@ -248,8 +614,23 @@ func (m *RemoveGPURequest) GetForce() bool {
 	return false
 }
 
+func (m *RemoveGPURequest) GetContainerName() string {
+	if m != nil {
+		return m.ContainerName
+	}
+	return ""
+}
+
+func (m *RemoveGPURequest) GetAllContainers() bool {
+	if m != nil {
+		return m.AllContainers
+	}
+	return false
+}
+
 type RemoveGPUResponse struct {
 	RemoveGpuResult      RemoveGPUResponse_RemoveGPUResult `protobuf:"varint,1,opt,name=remove_gpu_result,json=removeGpuResult,proto3,enum=gpu_mount.RemoveGPUResponse_RemoveGPUResult" json:"remove_gpu_result,omitempty"`
+	ContainerResults     []*ContainerResult                `protobuf:"bytes,2,rep,name=container_results,json=containerResults,proto3" json:"container_results,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}                          `json:"-"`
 	XXX_unrecognized     []byte                            `json:"-"`
 	XXX_sizecache        int32                             `json:"-"`
@@ -259,7 +640,7 @@ func (m *RemoveGPUResponse) Reset()         { *m = RemoveGPUResponse{} }
 func (m *RemoveGPUResponse) String() string { return proto.CompactTextString(m) }
 func (*RemoveGPUResponse) ProtoMessage()    {}
 func (*RemoveGPUResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_00212fb1f9d3bf1c, []int{3}
+	return fileDescriptor_00212fb1f9d3bf1c, []int{5}
 }
 
 func (m *RemoveGPUResponse) XXX_Unmarshal(b []byte) error {
@@ -287,45 +668,403 @@ func (m *RemoveGPUResponse) GetRemoveGpuResult() RemoveGPUResponse_RemoveGPUResu
 	return RemoveGPUResponse_Success
 }
 
+func (m *RemoveGPUResponse) GetContainerResults() []*ContainerResult {
+	if m != nil {
+		return m.ContainerResults
+	}
+	return nil
+}
+
+type ListNodeGPUsRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListNodeGPUsRequest) Reset()         { *m = ListNodeGPUsRequest{} }
+func (m *ListNodeGPUsRequest) String() string { return proto.CompactTextString(m) }
+func (*ListNodeGPUsRequest) ProtoMessage()    {}
+func (*ListNodeGPUsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_00212fb1f9d3bf1c, []int{6}
+}
+
+func (m *ListNodeGPUsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListNodeGPUsRequest.Unmarshal(m, b)
+}
+func (m *ListNodeGPUsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListNodeGPUsRequest.Marshal(b, m, deterministic)
+}
+func (m *ListNodeGPUsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListNodeGPUsRequest.Merge(m, src)
+}
+func (m *ListNodeGPUsRequest) XXX_Size() int {
+	return xxx_messageInfo_ListNodeGPUsRequest.Size(m)
+}
+func (m *ListNodeGPUsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListNodeGPUsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListNodeGPUsRequest proto.InternalMessageInfo
+
+type ListNodeGPUsResponse struct {
+	NodeName             string       `protobuf:"bytes,1,opt,name=node_name,json=nodeName,proto3" json:"node_name,omitempty"`
+	Gpus                 []*GPUDevice `protobuf:"bytes,2,rep,name=gpus,proto3" json:"gpus,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}     `json:"-"`
+	XXX_unrecognized     []byte       `json:"-"`
+	XXX_sizecache        int32        `json:"-"`
+}
+
+func (m *ListNodeGPUsResponse) Reset()         { *m = ListNodeGPUsResponse{} }
+func (m *ListNodeGPUsResponse) String() string { return proto.CompactTextString(m) }
+func (*ListNodeGPUsResponse) ProtoMessage()    {}
+func (*ListNodeGPUsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_00212fb1f9d3bf1c, []int{7}
+}
+
+func (m *ListNodeGPUsResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListNodeGPUsResponse.Unmarshal(m, b)
+}
+func (m *ListNodeGPUsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListNodeGPUsResponse.Marshal(b, m, deterministic)
+}
+func (m *ListNodeGPUsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListNodeGPUsResponse.Merge(m, src)
+}
+func (m *ListNodeGPUsResponse) XXX_Size() int {
+	return xxx_messageInfo_ListNodeGPUsResponse.Size(m)
+}
+func (m *ListNodeGPUsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListNodeGPUsResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListNodeGPUsResponse proto.InternalMessageInfo
+
+func (m *ListNodeGPUsResponse) GetNodeName() string {
+	if m != nil {
+		return m.NodeName
+	}
+	return ""
+}
+
+func (m *ListNodeGPUsResponse) GetGpus() []*GPUDevice {
+	if m != nil {
+		return m.Gpus
+	}
+	return nil
+}
+
+type GetPodGPUsRequest struct {
+	PodName              string   `protobuf:"bytes,1,opt,name=pod_name,json=podName,proto3" json:"pod_name,omitempty"`
+	Namespace            string   `protobuf:"bytes,2,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetPodGPUsRequest) Reset()         { *m = GetPodGPUsRequest{} }
+func (m *GetPodGPUsRequest) String() string { return proto.CompactTextString(m) }
+func (*GetPodGPUsRequest) ProtoMessage()    {}
+func (*GetPodGPUsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_00212fb1f9d3bf1c, []int{8}
+}
+
+func (m *GetPodGPUsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetPodGPUsRequest.Unmarshal(m, b)
+}
+func (m *GetPodGPUsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetPodGPUsRequest.Marshal(b, m, deterministic)
+}
+func (m *GetPodGPUsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetPodGPUsRequest.Merge(m, src)
+}
+func (m *GetPodGPUsRequest) XXX_Size() int {
+	return xxx_messageInfo_GetPodGPUsRequest.Size(m)
+}
+func (m *GetPodGPUsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetPodGPUsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetPodGPUsRequest proto.InternalMessageInfo
+
+func (m *GetPodGPUsRequest) GetPodName() string {
+	if m != nil {
+		return m.PodName
+	}
+	return ""
+}
+
+func (m *GetPodGPUsRequest) GetNamespace() string {
+	if m != nil {
+		return m.Namespace
+	}
+	return ""
+}
+
+type GetPodGPUsResponse struct {
+	GetPodGpusResult     GetPodGPUsResponse_GetPodGPUsResult `protobuf:"varint,1,opt,name=get_pod_gpus_result,json=getPodGpusResult,proto3,enum=gpu_mount.GetPodGPUsResponse_GetPodGPUsResult" json:"get_pod_gpus_result,omitempty"`
+	MountType            string                              `protobuf:"bytes,2,opt,name=mount_type,json=mountType,proto3" json:"mount_type,omitempty"`
+	Gpus                 []*GPUDevice                        `protobuf:"bytes,3,rep,name=gpus,proto3" json:"gpus,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}                            `json:"-"`
+	XXX_unrecognized     []byte                              `json:"-"`
+	XXX_sizecache        int32                               `json:"-"`
+}
+
+func (m *GetPodGPUsResponse) Reset()         { *m = GetPodGPUsResponse{} }
+func (m *GetPodGPUsResponse) String() string { return proto.CompactTextString(m) }
+func (*GetPodGPUsResponse) ProtoMessage()    {}
+func (*GetPodGPUsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_00212fb1f9d3bf1c, []int{9}
+}
+
+func (m *GetPodGPUsResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetPodGPUsResponse.Unmarshal(m, b)
+}
+func (m *GetPodGPUsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetPodGPUsResponse.Marshal(b, m, deterministic)
+}
+func (m *GetPodGPUsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetPodGPUsResponse.Merge(m, src)
+}
+func (m *GetPodGPUsResponse) XXX_Size() int {
+	return xxx_messageInfo_GetPodGPUsResponse.Size(m)
+}
+func (m *GetPodGPUsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetPodGPUsResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetPodGPUsResponse proto.InternalMessageInfo
+
+func (m *GetPodGPUsResponse) GetGetPodGpusResult() GetPodGPUsResponse_GetPodGPUsResult {
+	if m != nil {
+		return m.GetPodGpusResult
+	}
+	return GetPodGPUsResponse_Success
+}
+
+func (m *GetPodGPUsResponse) GetMountType() string {
+	if m != nil {
+		return m.MountType
+	}
+	return ""
+}
+
+func (m *GetPodGPUsResponse) GetGpus() []*GPUDevice {
+	if m != nil {
+		return m.Gpus
+	}
+	return nil
+}
+
+type ExtendLeaseRequest struct {
+	PodName              string   `protobuf:"bytes,1,opt,name=pod_name,json=podName,proto3" json:"pod_name,omitempty"`
+	Namespace            string   `protobuf:"bytes,2,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Uuids                []string `protobuf:"bytes,3,rep,name=uuids,proto3" json:"uuids,omitempty"`
+	LeaseSeconds         int64    `protobuf:"varint,4,opt,name=lease_seconds,json=leaseSeconds,proto3" json:"lease_seconds,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ExtendLeaseRequest) Reset()         { *m = ExtendLeaseRequest{} }
+func (m *ExtendLeaseRequest) String() string { return proto.CompactTextString(m) }
+func (*ExtendLeaseRequest) ProtoMessage()    {}
+func (*ExtendLeaseRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_00212fb1f9d3bf1c, []int{10}
+}
+
+func (m *ExtendLeaseRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ExtendLeaseRequest.Unmarshal(m, b)
+}
+func (m *ExtendLeaseRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ExtendLeaseRequest.Marshal(b, m, deterministic)
+}
+func (m *ExtendLeaseRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ExtendLeaseRequest.Merge(m, src)
+}
+func (m *ExtendLeaseRequest) XXX_Size() int {
+	return xxx_messageInfo_ExtendLeaseRequest.Size(m)
+}
+func (m *ExtendLeaseRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ExtendLeaseRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ExtendLeaseRequest proto.InternalMessageInfo
+
+func (m *ExtendLeaseRequest) GetPodName() string {
+	if m != nil {
+		return m.PodName
+	}
+	return ""
+}
+
+func (m *ExtendLeaseRequest) GetNamespace() string {
+	if m != nil {
+		return m.Namespace
+	}
+	return ""
+}
+
+func (m *ExtendLeaseRequest) GetUuids() []string {
+	if m != nil {
+		return m.Uuids
+	}
+	return nil
+}
+
+func (m *ExtendLeaseRequest) GetLeaseSeconds() int64 {
+	if m != nil {
+		return m.LeaseSeconds
+	}
+	return 0
+}
+
+type ExtendLeaseResponse struct {
+	ExtendLeaseResult    ExtendLeaseResponse_ExtendLeaseResult `protobuf:"varint,1,opt,name=extend_lease_result,json=extendLeaseResult,proto3,enum=gpu_mount.ExtendLeaseResponse_ExtendLeaseResult" json:"extend_lease_result,omitempty"`
+	Gpus                 []*GPUDevice                          `protobuf:"bytes,2,rep,name=gpus,proto3" json:"gpus,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}                              `json:"-"`
+	XXX_unrecognized     []byte                                `json:"-"`
+	XXX_sizecache        int32                                 `json:"-"`
+}
+
+func (m *ExtendLeaseResponse) Reset()         { *m = ExtendLeaseResponse{} }
+func (m *ExtendLeaseResponse) String() string { return proto.CompactTextString(m) }
+func (*ExtendLeaseResponse) ProtoMessage()    {}
+func (*ExtendLeaseResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_00212fb1f9d3bf1c, []int{11}
+}
+
+func (m *ExtendLeaseResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ExtendLeaseResponse.Unmarshal(m, b)
+}
+func (m *ExtendLeaseResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ExtendLeaseResponse.Marshal(b, m, deterministic)
+}
+func (m *ExtendLeaseResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ExtendLeaseResponse.Merge(m, src)
+}
+func (m *ExtendLeaseResponse) XXX_Size() int {
+	return xxx_messageInfo_ExtendLeaseResponse.Size(m)
+}
+func (m *ExtendLeaseResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ExtendLeaseResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ExtendLeaseResponse proto.InternalMessageInfo
+
+func (m *ExtendLeaseResponse) GetExtendLeaseResult() ExtendLeaseResponse_ExtendLeaseResult {
+	if m != nil {
+		return m.ExtendLeaseResult
+	}
+	return ExtendLeaseResponse_Success
+}
+
+func (m *ExtendLeaseResponse) GetGpus() []*GPUDevice {
+	if m != nil {
+		return m.Gpus
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterEnum("gpu_mount.AddGPUResponse_AddGPUResult", AddGPUResponse_AddGPUResult_name, AddGPUResponse_AddGPUResult_value)
 	proto.RegisterEnum("gpu_mount.RemoveGPUResponse_RemoveGPUResult", RemoveGPUResponse_RemoveGPUResult_name, RemoveGPUResponse_RemoveGPUResult_value)
+	proto.RegisterEnum("gpu_mount.GetPodGPUsResponse_GetPodGPUsResult", GetPodGPUsResponse_GetPodGPUsResult_name, GetPodGPUsResponse_GetPodGPUsResult_value)
+	proto.RegisterEnum("gpu_mount.ExtendLeaseResponse_ExtendLeaseResult", ExtendLeaseResponse_ExtendLeaseResult_name, ExtendLeaseResponse_ExtendLeaseResult_value)
 	proto.RegisterType((*AddGPURequest)(nil), "gpu_mount.AddGPURequest")
+	proto.RegisterType((*GPUDevice)(nil), "gpu_mount.GPUDevice")
+	proto.RegisterType((*ContainerResult)(nil), "gpu_mount.ContainerResult")
 	proto.RegisterType((*AddGPUResponse)(nil), "gpu_mount.AddGPUResponse")
 	proto.RegisterType((*RemoveGPURequest)(nil), "gpu_mount.RemoveGPURequest")
 	proto.RegisterType((*RemoveGPUResponse)(nil), "gpu_mount.RemoveGPUResponse")
+	proto.RegisterType((*ListNodeGPUsRequest)(nil), "gpu_mount.ListNodeGPUsRequest")
+	proto.RegisterType((*ListNodeGPUsResponse)(nil), "gpu_mount.ListNodeGPUsResponse")
+	proto.RegisterType((*GetPodGPUsRequest)(nil), "gpu_mount.GetPodGPUsRequest")
+	proto.RegisterType((*GetPodGPUsResponse)(nil), "gpu_mount.GetPodGPUsResponse")
+	proto.RegisterType((*ExtendLeaseRequest)(nil), "gpu_mount.ExtendLeaseRequest")
+	proto.RegisterType((*ExtendLeaseResponse)(nil), "gpu_mount.ExtendLeaseResponse")
 }
 
 func init() { proto.RegisterFile("api.proto", fileDescriptor_00212fb1f9d3bf1c) }
 
 var fileDescriptor_00212fb1f9d3bf1c = []byte{
-	// 415 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x93, 0xc1, 0x6e, 0xd3, 0x40,
-	0x10, 0x86, 0xb3, 0x4d, 0x1b, 0xd7, 0x93, 0x36, 0x76, 0x17, 0x24, 0x5c, 0xe8, 0xc1, 0xf2, 0xa1,
-	0xf2, 0x01, 0xe5, 0x10, 0x1e, 0x00, 0x15, 0x09, 0x0c, 0x12, 0x8d, 0x2c, 0x57, 0x96, 0x38, 0x20,
-	0x59, 0xc6, 0x3b, 0xa9, 0x56, 0xaa, 0x77, 0x17, 0xef, 0x6e, 0x11, 0x8f, 0xc0, 0x63, 0xf0, 0x1e,
-	0x3c, 0x1c, 0xb2, 0xdd, 0x24, 0x0e, 0x0d, 0x9c, 0x38, 0xce, 0xb7, 0xde, 0x7f, 0xc6, 0xdf, 0xd8,
-	0xe0, 0x96, 0x8a, 0xcf, 0x55, 0x23, 0x8d, 0xa4, 0xee, 0xad, 0xb2, 0x45, 0x2d, 0xad, 0x30, 0xd1,
-	0x0f, 0x02, 0xa7, 0x57, 0x8c, 0x25, 0x69, 0x9e, 0xe1, 0x57, 0x8b, 0xda, 0xd0, 0x73, 0x38, 0x56,
-	0x92, 0x15, 0xa2, 0xac, 0x31, 0x20, 0x21, 0x89, 0xdd, 0xcc, 0x51, 0x92, 0x2d, 0xcb, 0x1a, 0xe9,
-	0x05, 0xb8, 0x2d, 0xd6, 0xaa, 0xac, 0x30, 0x38, 0xe8, 0xce, 0xb6, 0x80, 0x3e, 0x03, 0xa7, 0xcd,
-	0x15, 0xb6, 0x0e, 0xc6, 0x21, 0x89, 0x8f, 0xb2, 0xc9, 0xad, 0xb2, 0x4b, 0x5b, 0xd3, 0x4b, 0xf0,
-	0xb8, 0x2e, 0x50, 0x18, 0xde, 0x60, 0xdf, 0x36, 0x38, 0x0c, 0x49, 0x7c, 0x9c, 0x9d, 0x72, 0xfd,
-	0xb6, 0xa3, 0xd7, 0xdd, 0x2c, 0x3f, 0x09, 0xcc, 0xd6, 0xb3, 0x68, 0x25, 0x85, 0x46, 0xfa, 0x11,
-	0x66, 0x25, 0x63, 0x45, 0x9b, 0xdb, 0xa0, 0xb6, 0x77, 0xa6, 0x1b, 0x69, 0xb6, 0xb8, 0x9c, 0x6f,
-	0x5e, 0x61, 0xbe, 0x7b, 0x65, 0x5b, 0xda, 0x3b, 0x93, 0x9d, 0x94, 0x8c, 0x25, 0xca, 0xf6, 0x55,
-	0x74, 0x05, 0x27, 0xc3, 0x53, 0x3a, 0x05, 0xe7, 0xc6, 0x56, 0x15, 0x6a, 0xed, 0x8f, 0xe8, 0x13,
-	0xf0, 0x3e, 0x08, 0x6d, 0x57, 0x2b, 0x5e, 0x71, 0x14, 0x26, 0x49, 0x73, 0x9f, 0x50, 0x0f, 0xa6,
-	0xa9, 0x64, 0x4b, 0x69, 0xde, 0x49, 0x2b, 0x98, 0x7f, 0x10, 0x7d, 0x03, 0x3f, 0xc3, 0x5a, 0xde,
-	0xe3, 0xff, 0x30, 0xf6, 0x14, 0x8e, 0xac, 0xe5, 0x4c, 0x07, 0xe3, 0x70, 0x1c, 0xbb, 0x59, 0x5f,
-	0xb4, 0x74, 0x25, 0x9b, 0x0a, 0x1f, 0x24, 0xf5, 0x45, 0xf4, 0x8b, 0xc0, 0xd9, 0xa0, 0xf3, 0x83,
-	0x9f, 0x4f, 0x70, 0xd6, 0x74, 0xf0, 0xb1, 0xa2, 0x97, 0x03, 0x45, 0x8f, 0x2e, 0xee, 0x90, 0x56,
-	0x94, 0xd7, 0xc7, 0x6c, 0x5d, 0x5d, 0x83, 0xf7, 0xc7, 0x33, 0xbb, 0xba, 0xa6, 0xe0, 0x24, 0x69,
-	0xfe, 0xc6, 0xea, 0xef, 0x7b, 0x34, 0xb5, 0x20, 0x49, 0xf3, 0x0d, 0x38, 0x5c, 0xa4, 0xeb, 0xcf,
-	0xec, 0x06, 0x9b, 0x7b, 0x5e, 0x21, 0x7d, 0x0d, 0x93, 0x1e, 0xd0, 0x60, 0xcf, 0x2e, 0x3b, 0xb1,
-	0xcf, 0xcf, 0xff, 0xba, 0xe5, 0x68, 0xb4, 0xf8, 0x3c, 0xd8, 0xc4, 0x3a, 0xf4, 0x3d, 0xb8, 0x1b,
-	0x46, 0x5f, 0xec, 0x17, 0xd0, 0x47, 0x5f, 0xfc, 0xcb, 0x4e, 0x34, 0xfa, 0x32, 0xe9, 0xfe, 0x94,
-	0x57, 0xbf, 0x03, 0x00, 0x00, 0xff, 0xff, 0xb4, 0xfe, 0x20, 0xcc, 0x36, 0x03, 0x00, 0x00,
+	// 1223 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x97, 0xdb, 0x72, 0xdb, 0x44,
+	0x18, 0xc7, 0x23, 0x1f, 0xe3, 0xcf, 0x8e, 0x2d, 0x6f, 0x52, 0xaa, 0xb6, 0x69, 0x9b, 0x0a, 0x5a,
+	0x7c, 0x01, 0x99, 0x4e, 0x78, 0x00, 0xa6, 0x2d, 0xa9, 0xe9, 0xe0, 0xba, 0xae, 0x52, 0xcf, 0x70,
+	0x41, 0x11, 0x1b, 0xe9, 0x8b, 0xa3, 0x41, 0x27, 0xb4, 0xab, 0x34, 0x79, 0x03, 0xee, 0x78, 0x11,
+	0x66, 0x18, 0x86, 0x67, 0xe0, 0x49, 0xb8, 0xe7, 0x82, 0x19, 0xee, 0x99, 0xdd, 0x95, 0x64, 0xf9,
+	0x90, 0xd0, 0x32, 0xe5, 0x2e, 0xfb, 0xdb, 0xfd, 0x0e, 0xbb, 0xff, 0xff, 0xae, 0x1c, 0x68, 0xd1,
+	0xd8, 0xdb, 0x8f, 0x93, 0x88, 0x47, 0xa4, 0x35, 0x8b, 0x53, 0x3b, 0x88, 0xd2, 0x90, 0x9b, 0xbf,
+	0x55, 0x61, 0xeb, 0x91, 0xeb, 0x0e, 0x27, 0x53, 0x0b, 0x7f, 0x48, 0x91, 0x71, 0x72, 0x03, 0x36,
+	0xe3, 0xc8, 0xb5, 0x43, 0x1a, 0xa0, 0xa1, 0xed, 0x69, 0x83, 0x96, 0xd5, 0x8c, 0x23, 0x77, 0x4c,
+	0x03, 0x24, 0xbb, 0xd0, 0x12, 0x98, 0xc5, 0xd4, 0x41, 0xa3, 0x22, 0xe7, 0xe6, 0x80, 0x5c, 0x87,
+	0xa6, 0xc8, 0x1b, 0xa6, 0x81, 0x51, 0xdd, 0xd3, 0x06, 0x75, 0xab, 0x31, 0x8b, 0xd3, 0x71, 0x1a,
+	0x90, 0x07, 0xd0, 0xf3, 0x98, 0x8d, 0x21, 0xf7, 0x12, 0x54, 0x65, 0x8d, 0xda, 0x9e, 0x36, 0xd8,
+	0xb4, 0xb6, 0x3c, 0x76, 0x28, 0xe9, 0x73, 0x01, 0xc9, 0x7d, 0xe8, 0x3a, 0x51, 0xc8, 0xa9, 0x17,
+	0x62, 0xa2, 0xea, 0xd7, 0x65, 0x8d, 0xad, 0x82, 0xca, 0x2e, 0xee, 0x43, 0x97, 0xfa, 0xbe, 0x5d,
+	0x40, 0x66, 0x34, 0x54, 0x36, 0xea, 0xfb, 0x4f, 0x0a, 0x48, 0x3e, 0x84, 0x2d, 0x1f, 0x29, 0x43,
+	0x9b, 0xa1, 0x13, 0x85, 0x2e, 0x33, 0x9a, 0x7b, 0xda, 0xa0, 0x6a, 0x75, 0x24, 0x3c, 0x52, 0x4c,
+	0x2c, 0x3a, 0x89, 0x12, 0x07, 0xed, 0x04, 0x1d, 0x9f, 0x7a, 0x81, 0xb1, 0x29, 0x53, 0x75, 0x24,
+	0xb4, 0x14, 0x23, 0x9f, 0x02, 0x89, 0x7d, 0xea, 0x60, 0x80, 0x21, 0xb7, 0x19, 0x4f, 0x28, 0xc7,
+	0xd9, 0x85, 0xd1, 0x92, 0xbd, 0xf5, 0x8b, 0x99, 0xa3, 0x6c, 0x82, 0x7c, 0x00, 0x0d, 0x76, 0x4a,
+	0x13, 0x74, 0x0d, 0x90, 0xc9, 0xb2, 0x11, 0x19, 0x80, 0x1e, 0x60, 0x10, 0x25, 0x17, 0xb6, 0xef,
+	0x05, 0x1e, 0xb7, 0x03, 0xef, 0xd8, 0x68, 0xef, 0x69, 0x83, 0x9a, 0xd5, 0x55, 0x7c, 0x24, 0xf0,
+	0x73, 0xef, 0x98, 0xdc, 0x85, 0x76, 0xe0, 0xcd, 0xec, 0x38, 0x89, 0x4e, 0x3c, 0x1f, 0x8d, 0x8e,
+	0xac, 0x04, 0x81, 0x37, 0x9b, 0x28, 0x62, 0xfe, 0x5c, 0x85, 0xd6, 0x70, 0x32, 0xfd, 0x02, 0xcf,
+	0x3c, 0x07, 0x09, 0x81, 0x5a, 0x9a, 0x7a, 0x6e, 0xa6, 0x96, 0xfc, 0x9b, 0xdc, 0x83, 0x4e, 0xe0,
+	0x85, 0x51, 0x22, 0xe4, 0x38, 0xc6, 0x44, 0xaa, 0x55, 0xb7, 0xda, 0x92, 0x8d, 0x25, 0x12, 0xfd,
+	0xb8, 0x32, 0x81, 0x2d, 0x72, 0xda, 0x31, 0xe5, 0xa7, 0x52, 0xb8, 0x96, 0xd5, 0x55, 0xfc, 0xa9,
+	0xe7, 0xe3, 0x84, 0xf2, 0x53, 0xf2, 0x11, 0x74, 0x99, 0x4f, 0xcf, 0xd0, 0x2e, 0x8c, 0x51, 0x93,
+	0xeb, 0x3a, 0x92, 0x4e, 0x32, 0x77, 0xec, 0x40, 0x9d, 0x71, 0xca, 0x73, 0xd5, 0xd4, 0x40, 0xc4,
+	0x46, 0x6f, 0x84, 0xa0, 0x45, 0x6c, 0x43, 0xc5, 0x4a, 0x9a, 0xc7, 0x7e, 0x0c, 0x3d, 0xb5, 0x6a,
+	0xee, 0xaf, 0xa6, 0x6a, 0x45, 0xe2, 0x71, 0x4e, 0xc9, 0x6d, 0x00, 0xe9, 0x20, 0x9b, 0x5f, 0xc4,
+	0x28, 0xd5, 0x6a, 0x59, 0x2d, 0x49, 0x5e, 0x5d, 0xc4, 0x28, 0xf6, 0xa4, 0x44, 0xc7, 0xf3, 0xd8,
+	0x4b, 0x90, 0xd9, 0x94, 0x67, 0x42, 0x75, 0x25, 0x3f, 0x54, 0xf8, 0x11, 0x5f, 0xab, 0x06, 0xbc,
+	0x8d, 0x1a, 0xed, 0x65, 0x35, 0xc4, 0x82, 0x98, 0x26, 0xc2, 0x1c, 0x52, 0x86, 0x4c, 0x2e, 0x85,
+	0xa6, 0xa9, 0xe7, 0x9a, 0x3f, 0x69, 0xd0, 0x2b, 0x9c, 0x69, 0x21, 0x4b, 0xfd, 0x75, 0x66, 0xd7,
+	0xd6, 0x99, 0xfd, 0x1e, 0x74, 0xe6, 0xcb, 0x3c, 0x37, 0xbb, 0x75, 0xed, 0x82, 0x3d, 0x73, 0x89,
+	0x01, 0x4d, 0x96, 0x3a, 0x0e, 0x32, 0x26, 0xe5, 0xdb, 0xb4, 0xf2, 0xa1, 0x98, 0x09, 0x90, 0x31,
+	0x3a, 0xcb, 0x05, 0xcb, 0x87, 0xe6, 0x1f, 0x55, 0xe8, 0xe6, 0xd7, 0x9e, 0xc5, 0x51, 0xc8, 0x90,
+	0x8c, 0xa0, 0x4b, 0x5d, 0xd7, 0x16, 0x57, 0x38, 0x91, 0x2d, 0xca, 0x86, 0xba, 0x07, 0x0f, 0xf6,
+	0x8b, 0xd7, 0x62, 0x7f, 0x31, 0x64, 0x3e, 0x4c, 0x7d, 0x6e, 0x75, 0xa8, 0xeb, 0x0e, 0xe3, 0x34,
+	0xdb, 0xde, 0x00, 0x6a, 0xb3, 0x38, 0x65, 0x46, 0x65, 0xaf, 0x3a, 0x68, 0x1f, 0xec, 0x94, 0x72,
+	0x14, 0xbe, 0xb5, 0xe4, 0x0a, 0x32, 0x84, 0xfe, 0x7c, 0x87, 0xaa, 0xb2, 0xd8, 0x88, 0x08, 0xbb,
+	0x59, 0x0a, 0x5b, 0x3a, 0x3f, 0x4b, 0x77, 0x16, 0xc1, 0x55, 0xbb, 0xfd, 0x5b, 0x83, 0x4e, 0xb9,
+	0x57, 0xd2, 0x86, 0xe6, 0x91, 0x3a, 0x23, 0x7d, 0x83, 0x6c, 0x43, 0xef, 0x59, 0xc8, 0xd2, 0x93,
+	0x13, 0xcf, 0xf1, 0x30, 0xe4, 0xc3, 0xc9, 0x54, 0xd7, 0x48, 0x0f, 0xda, 0xc2, 0x9b, 0x11, 0x7f,
+	0x1a, 0xa5, 0xa1, 0xab, 0x57, 0xc8, 0x2d, 0xb8, 0x7e, 0x94, 0xb9, 0xfd, 0x59, 0x40, 0x67, 0x38,
+	0x49, 0x7d, 0xff, 0x29, 0xf5, 0x7c, 0x74, 0xf5, 0x2a, 0xd9, 0x05, 0x23, 0x9f, 0x7c, 0x92, 0x50,
+	0x76, 0x3a, 0x8a, 0xa2, 0xf8, 0x31, 0x75, 0xbe, 0x7f, 0x71, 0x72, 0xa2, 0xd7, 0x44, 0x81, 0x7c,
+	0xf6, 0xf0, 0xcc, 0x73, 0x38, 0xba, 0x7a, 0x9d, 0x10, 0xe8, 0xe6, 0x30, 0x4b, 0xd3, 0x28, 0x2f,
+	0x7c, 0xe5, 0x05, 0x18, 0xa5, 0x5c, 0x6f, 0x92, 0x6b, 0xd0, 0x2f, 0xf6, 0x5e, 0xf4, 0xb3, 0x29,
+	0x1a, 0x94, 0xaf, 0x66, 0x16, 0xdc, 0x22, 0x7d, 0xd8, 0x7a, 0x99, 0x46, 0x9c, 0x1e, 0x9e, 0x3b,
+	0x88, 0x2e, 0xba, 0x3a, 0x98, 0xbf, 0x6b, 0xa0, 0x5b, 0x18, 0x44, 0x67, 0xf8, 0x3e, 0xde, 0xf7,
+	0x1d, 0xa8, 0x0b, 0x7f, 0x2b, 0x71, 0x5a, 0x96, 0x1a, 0x08, 0x2a, 0x1f, 0xcb, 0xec, 0x49, 0x57,
+	0x83, 0xf7, 0xfb, 0x94, 0x9b, 0xbf, 0x54, 0xa0, 0x5f, 0xda, 0x47, 0x66, 0xd8, 0xaf, 0xa1, 0x9f,
+	0x48, 0xb8, 0xea, 0xd9, 0x4f, 0x4a, 0xc6, 0x59, 0x09, 0x5c, 0x20, 0xc2, 0x4a, 0x3d, 0x95, 0x66,
+	0x6e, 0xde, 0xb5, 0x96, 0xac, 0xbc, 0xbb, 0x25, 0xcd, 0x37, 0xd0, 0x5b, 0x2a, 0xb6, 0x68, 0xbd,
+	0x36, 0x34, 0x87, 0x93, 0xe9, 0xe3, 0x94, 0x5d, 0xac, 0xb3, 0x5c, 0x0f, 0xda, 0xc3, 0xc9, 0xb4,
+	0x00, 0xb5, 0xf5, 0x56, 0xa8, 0x0b, 0xe5, 0xa7, 0x61, 0x50, 0x32, 0x43, 0xc3, 0xbc, 0x06, 0xdb,
+	0x23, 0x8f, 0xf1, 0x71, 0xe4, 0x8a, 0xd2, 0x2c, 0xd3, 0xde, 0x7c, 0x0d, 0x3b, 0x8b, 0x38, 0x3b,
+	0xca, 0x5b, 0xd0, 0x0a, 0x23, 0x17, 0xcb, 0xa6, 0xd8, 0x14, 0x40, 0x8a, 0xf4, 0xd6, 0x57, 0xd9,
+	0x1c, 0x41, 0x7f, 0x88, 0x7c, 0x12, 0xb9, 0xa5, 0x9a, 0xff, 0xd9, 0x6f, 0xe6, 0x9f, 0x1a, 0x90,
+	0x72, 0xba, 0xac, 0xd7, 0xd7, 0xb0, 0x3d, 0x43, 0x2e, 0x3f, 0x27, 0xa2, 0xe8, 0xa2, 0xf0, 0xfb,
+	0xe5, 0xee, 0x56, 0x62, 0x17, 0x91, 0x94, 0x6c, 0xa6, 0x48, 0x9c, 0x66, 0x64, 0xe9, 0x03, 0x53,
+	0x59, 0xfd, 0xc0, 0xa8, 0xc3, 0xa8, 0xfe, 0xeb, 0x61, 0x3c, 0x04, 0x7d, 0xb9, 0xdc, 0xa2, 0xf8,
+	0xcb, 0x7a, 0x9b, 0x3f, 0x6a, 0x40, 0x0e, 0xcf, 0x39, 0x86, 0xee, 0x08, 0x29, 0xc3, 0xff, 0xe9,
+	0xc2, 0xae, 0xfc, 0x2e, 0xaa, 0xad, 0xfe, 0x2e, 0x32, 0xff, 0xd2, 0x60, 0x7b, 0xa1, 0x95, 0xec,
+	0xf0, 0xbf, 0x83, 0x6d, 0x94, 0xd8, 0x56, 0x39, 0x16, 0x0e, 0xff, 0x61, 0xe9, 0x34, 0xd6, 0x04,
+	0x2f, 0x31, 0x71, 0xfc, 0x7d, 0x5c, 0x46, 0xef, 0xe0, 0xb6, 0x17, 0xd0, 0x5f, 0xc9, 0x78, 0xe5,
+	0x09, 0x6b, 0xcb, 0x37, 0xaa, 0x22, 0x96, 0x8f, 0x23, 0x19, 0xaf, 0x57, 0x0f, 0x26, 0xf9, 0x4f,
+	0xe1, 0x23, 0x4c, 0x44, 0x1d, 0xf2, 0x39, 0x34, 0x14, 0x20, 0xc6, 0x9a, 0x8f, 0xa0, 0x54, 0xe7,
+	0xe6, 0x8d, 0x4b, 0x3f, 0x8f, 0xe6, 0xc6, 0xc1, 0x37, 0xa5, 0xf7, 0x37, 0x4f, 0xfa, 0x25, 0xb4,
+	0x0a, 0x46, 0x6e, 0xad, 0x7f, 0xa8, 0x54, 0xea, 0xdd, 0xab, 0x5e, 0x31, 0x73, 0xe3, 0xe0, 0x57,
+	0x0d, 0x7a, 0xc3, 0xc9, 0xf4, 0x65, 0x8a, 0xc9, 0x45, 0x9e, 0xfd, 0x25, 0x74, 0xca, 0x37, 0x9c,
+	0xdc, 0x29, 0xe5, 0x58, 0xf3, 0x22, 0xdc, 0xbc, 0x7b, 0xe9, 0x7c, 0x5e, 0x86, 0x7c, 0x05, 0x30,
+	0x37, 0x32, 0xd9, 0xbd, 0xe4, 0x86, 0xa9, 0x74, 0xb7, 0xaf, 0xbc, 0x7f, 0xe6, 0xc6, 0xc1, 0xb7,
+	0xd0, 0x19, 0x29, 0xa3, 0xa9, 0x7e, 0xc7, 0xd0, 0x2e, 0x89, 0x48, 0x6e, 0x5f, 0x66, 0x21, 0x95,
+	0xfe, 0xce, 0xd5, 0x0e, 0x33, 0x37, 0x8e, 0x1b, 0xf2, 0x3f, 0x9c, 0xcf, 0xfe, 0x19, 0x00, 0x0a,
+	0xe5, 0x84, 0x5c, 0xee, 0x0c, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -479,3 +1218,183 @@ var _RemoveGPUService_serviceDesc = grpc.ServiceDesc{
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "api.proto",
 }
+
+// GPUQueryServiceClient is the client API for GPUQueryService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type GPUQueryServiceClient interface {
+	ListNodeGPUs(ctx context.Context, in *ListNodeGPUsRequest, opts ...grpc.CallOption) (*ListNodeGPUsResponse, error)
+	GetPodGPUs(ctx context.Context, in *GetPodGPUsRequest, opts ...grpc.CallOption) (*GetPodGPUsResponse, error)
+}
+
+type gPUQueryServiceClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewGPUQueryServiceClient(cc *grpc.ClientConn) GPUQueryServiceClient {
+	return &gPUQueryServiceClient{cc}
+}
+
+func (c *gPUQueryServiceClient) ListNodeGPUs(ctx context.Context, in *ListNodeGPUsRequest, opts ...grpc.CallOption) (*ListNodeGPUsResponse, error) {
+	out := new(ListNodeGPUsResponse)
+	err := c.cc.Invoke(ctx, "/gpu_mount.GPUQueryService/ListNodeGPUs", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gPUQueryServiceClient) GetPodGPUs(ctx context.Context, in *GetPodGPUsRequest, opts ...grpc.CallOption) (*GetPodGPUsResponse, error) {
+	out := new(GetPodGPUsResponse)
+	err := c.cc.Invoke(ctx, "/gpu_mount.GPUQueryService/GetPodGPUs", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GPUQueryServiceServer is the server API for GPUQueryService service.
+type GPUQueryServiceServer interface {
+	ListNodeGPUs(context.Context, *ListNodeGPUsRequest) (*ListNodeGPUsResponse, error)
+	GetPodGPUs(context.Context, *GetPodGPUsRequest) (*GetPodGPUsResponse, error)
+}
+
+// UnimplementedGPUQueryServiceServer can be embedded to have forward compatible implementations.
+type UnimplementedGPUQueryServiceServer struct {
+}
+
+func (*UnimplementedGPUQueryServiceServer) ListNodeGPUs(ctx context.Context, req *ListNodeGPUsRequest) (*ListNodeGPUsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListNodeGPUs not implemented")
+}
+func (*UnimplementedGPUQueryServiceServer) GetPodGPUs(ctx context.Context, req *GetPodGPUsRequest) (*GetPodGPUsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPodGPUs not implemented")
+}
+
+func RegisterGPUQueryServiceServer(s *grpc.Server, srv GPUQueryServiceServer) {
+	s.RegisterService(&_GPUQueryService_serviceDesc, srv)
+}
+
+func _GPUQueryService_ListNodeGPUs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListNodeGPUsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GPUQueryServiceServer).ListNodeGPUs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gpu_mount.GPUQueryService/ListNodeGPUs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GPUQueryServiceServer).ListNodeGPUs(ctx, req.(*ListNodeGPUsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GPUQueryService_GetPodGPUs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPodGPUsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GPUQueryServiceServer).GetPodGPUs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gpu_mount.GPUQueryService/GetPodGPUs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GPUQueryServiceServer).GetPodGPUs(ctx, req.(*GetPodGPUsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _GPUQueryService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "gpu_mount.GPUQueryService",
+	HandlerType: (*GPUQueryServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListNodeGPUs",
+			Handler:    _GPUQueryService_ListNodeGPUs_Handler,
+		},
+		{
+			MethodName: "GetPodGPUs",
+			Handler:    _GPUQueryService_GetPodGPUs_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "api.proto",
+}
+
+// LeaseServiceClient is the client API for LeaseService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type LeaseServiceClient interface {
+	ExtendLease(ctx context.Context, in *ExtendLeaseRequest, opts ...grpc.CallOption) (*ExtendLeaseResponse, error)
+}
+
+type leaseServiceClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewLeaseServiceClient(cc *grpc.ClientConn) LeaseServiceClient {
+	return &leaseServiceClient{cc}
+}
+
+func (c *leaseServiceClient) ExtendLease(ctx context.Context, in *ExtendLeaseRequest, opts ...grpc.CallOption) (*ExtendLeaseResponse, error) {
+	out := new(ExtendLeaseResponse)
+	err := c.cc.Invoke(ctx, "/gpu_mount.LeaseService/ExtendLease", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// LeaseServiceServer is the server API for LeaseService service.
+type LeaseServiceServer interface {
+	ExtendLease(context.Context, *ExtendLeaseRequest) (*ExtendLeaseResponse, error)
+}
+
+// UnimplementedLeaseServiceServer can be embedded to have forward compatible implementations.
+type UnimplementedLeaseServiceServer struct {
+}
+
+func (*UnimplementedLeaseServiceServer) ExtendLease(ctx context.Context, req *ExtendLeaseRequest) (*ExtendLeaseResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExtendLease not implemented")
+}
+
+func RegisterLeaseServiceServer(s *grpc.Server, srv LeaseServiceServer) {
+	s.RegisterService(&_LeaseService_serviceDesc, srv)
+}
+
+func _LeaseService_ExtendLease_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExtendLeaseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LeaseServiceServer).ExtendLease(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gpu_mount.LeaseService/ExtendLease",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LeaseServiceServer).ExtendLease(ctx, req.(*ExtendLeaseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _LeaseService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "gpu_mount.LeaseService",
+	HandlerType: (*LeaseServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ExtendLease",
+			Handler:    _LeaseService_ExtendLease_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "api.proto",
+}
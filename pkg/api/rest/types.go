@@ -0,0 +1,303 @@
+// Package rest defines the JSON documents served by gpu mounter master under /api/v2
+package rest
+
+import (
+	gpu_mount "GPUMounter/pkg/api/gpu-mount"
+	"net/http"
+	"strings"
+)
+
+const (
+	PathPrefix = "/api/v2"
+)
+
+// ErrorCode is the stable error identifier returned in Error.Code
+type ErrorCode string
+
+const (
+	ErrInvalidRequest  ErrorCode = "InvalidRequest"
+	ErrPodNotFound     ErrorCode = "PodNotFound"
+	ErrWorkerNotFound  ErrorCode = "WorkerNotFound"
+	ErrInsufficientGPU ErrorCode = "InsufficientGPU"
+	ErrGPUBusy         ErrorCode = "GPUBusy"
+	ErrGPUNotFound     ErrorCode = "GPUNotFound"
+	ErrQuotaExceeded   ErrorCode = "QuotaExceeded"
+	ErrNoLease         ErrorCode = "NoLease"
+	ErrInternal        ErrorCode = "InternalError"
+
+	ErrUnauthorized ErrorCode = "Unauthorized"
+	ErrForbidden    ErrorCode = "Forbidden"
+
+	ErrContainerNotFound ErrorCode = "ContainerNotFound"
+	ErrMountFailed       ErrorCode = "MountFailed"
+	ErrUnmountFailed     ErrorCode = "UnmountFailed"
+
+	ErrSlavePodImagePullFailed  ErrorCode = "SlavePodImagePullFailed"
+	ErrSlavePodCrashLoopBackOff ErrorCode = "SlavePodCrashLoopBackOff"
+	ErrSlavePodEvicted          ErrorCode = "SlavePodEvicted"
+	ErrSlavePodFailed           ErrorCode = "SlavePodFailed"
+	ErrSlavePodTimeout          ErrorCode = "SlavePodTimeout"
+)
+
+// HTTPStatus returns the http status code the master answers with for the error code
+func (code ErrorCode) HTTPStatus() int {
+	switch code {
+	case ErrInvalidRequest:
+		return http.StatusBadRequest
+	case ErrUnauthorized:
+		return http.StatusUnauthorized
+	case ErrForbidden, ErrQuotaExceeded:
+		return http.StatusForbidden
+	case ErrPodNotFound, ErrGPUNotFound, ErrContainerNotFound:
+		return http.StatusNotFound
+	case ErrInsufficientGPU, ErrGPUBusy, ErrNoLease:
+		return http.StatusConflict
+	case ErrWorkerNotFound:
+		return http.StatusServiceUnavailable
+	case ErrSlavePodTimeout:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Error is the body of every non 2xx response
+type Error struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return string(e.Code) + ": " + e.Message
+}
+
+type ErrorResponse struct {
+	Error *Error `json:"error"`
+}
+
+type AddGPURequest struct {
+	GPUNum        int32  `json:"gpuNum"`
+	IsEntireMount bool   `json:"isEntireMount"`
+	Container     string `json:"container,omitempty"`
+	AllContainers bool   `json:"allContainers,omitempty"`
+	// LeaseSeconds is the duration after which the gpus are removed, 0 for no lease
+	LeaseSeconds int64 `json:"leaseSeconds,omitempty"`
+	// ForceReclaim kills the processes on the gpus when the lease expires
+	ForceReclaim bool `json:"forceReclaim,omitempty"`
+	// Placement is the placement strategy of the gpus: first-fit, topology, numa or spread,
+	// empty for the default strategy of the worker
+	Placement string `json:"placement,omitempty"`
+	// Shared mounts a gpu shared with other pods, GPUNum should be 1
+	Shared bool `json:"shared,omitempty"`
+	// MemoryLimitMiB is the gpu memory the pod may use on the shared gpu, required if Shared
+	MemoryLimitMiB uint64 `json:"memoryLimitMiB,omitempty"`
+	// MIGProfile mounts MIG devices of the profile, e.g. 1g.5gb, instead of whole gpus
+	MIGProfile string `json:"migProfile,omitempty"`
+}
+
+type ContainerResult struct {
+	Name        string `json:"name"`
+	ContainerID string `json:"containerID"`
+	Success     bool   `json:"success"`
+	Message     string `json:"message,omitempty"`
+}
+
+type MountedGPU struct {
+	UUID           string `json:"uuid"`
+	MinorNumber    int32  `json:"minorNumber"`
+	DeviceFilePath string `json:"deviceFilePath"`
+	SlavePod       string `json:"slavePod"`
+	LeaseExpiresAt string `json:"leaseExpiresAt,omitempty"`
+	MemoryLimitMiB uint64 `json:"memoryLimitMiB,omitempty"`
+	MIGProfile     string `json:"migProfile,omitempty"`
+	ParentUUID     string `json:"parentUUID,omitempty"`
+}
+
+type AddGPUResponse struct {
+	Namespace  string             `json:"namespace"`
+	Pod        string             `json:"pod"`
+	Node       string             `json:"node"`
+	GPUs       []*MountedGPU      `json:"gpus"`
+	Containers []*ContainerResult `json:"containers"`
+}
+
+type RemoveGPURequest struct {
+	UUIDs         []string `json:"uuids"`
+	Force         bool     `json:"force"`
+	Container     string   `json:"container,omitempty"`
+	AllContainers bool     `json:"allContainers,omitempty"`
+}
+
+type RemoveGPUResponse struct {
+	Namespace  string             `json:"namespace"`
+	Pod        string             `json:"pod"`
+	Node       string             `json:"node"`
+	UUIDs      []string           `json:"uuids"`
+	Containers []*ContainerResult `json:"containers"`
+}
+
+type ExtendLeaseRequest struct {
+	UUIDs        []string `json:"uuids"`
+	LeaseSeconds int64    `json:"leaseSeconds"`
+}
+
+type Lease struct {
+	UUID      string `json:"uuid"`
+	ExpiresAt string `json:"expiresAt"`
+}
+
+type ExtendLeaseResponse struct {
+	Namespace string   `json:"namespace"`
+	Pod       string   `json:"pod"`
+	Node      string   `json:"node"`
+	Leases    []*Lease `json:"leases"`
+}
+
+type GPU struct {
+	UUID           string `json:"uuid"`
+	MinorNumber    int32  `json:"minorNumber"`
+	DeviceFilePath string `json:"deviceFilePath"`
+	State          string `json:"state"`
+	OwnerPod       string `json:"ownerPod,omitempty"`
+	OwnerNamespace string `json:"ownerNamespace,omitempty"`
+	SlavePod       string `json:"slavePod,omitempty"`
+	MountType      string `json:"mountType,omitempty"`
+	LeaseExpiresAt string `json:"leaseExpiresAt,omitempty"`
+	MemoryLimitMiB uint64 `json:"memoryLimitMiB,omitempty"`
+	MIGProfile     string `json:"migProfile,omitempty"`
+	ParentUUID     string `json:"parentUUID,omitempty"`
+}
+
+type NodeGPUsResponse struct {
+	Node string `json:"node"`
+	GPUs []*GPU `json:"gpus"`
+}
+
+type PodGPUsResponse struct {
+	Namespace string `json:"namespace"`
+	Pod       string `json:"pod"`
+	Node      string `json:"node"`
+	MountType string `json:"mountType"`
+	GPUs      []*GPU `json:"gpus"`
+}
+
+// NewGPU converts the gpu device reported by worker to its json document
+func NewGPU(gpuDevice *gpu_mount.GPUDevice) *GPU {
+	return &GPU{
+		UUID:           gpuDevice.Uuid,
+		MinorNumber:    gpuDevice.MinorNumber,
+		DeviceFilePath: gpuDevice.DeviceFilePath,
+		State:          gpuDevice.State,
+		OwnerPod:       gpuDevice.OwnerPodName,
+		OwnerNamespace: gpuDevice.OwnerNamespace,
+		SlavePod:       gpuDevice.SlavePodName,
+		MountType:      gpuDevice.MountType,
+		LeaseExpiresAt: gpuDevice.LeaseExpiresAt,
+		MemoryLimitMiB: gpuDevice.MemoryLimitMib,
+		MIGProfile:     gpuDevice.MigProfile,
+		ParentUUID:     gpuDevice.ParentUuid,
+	}
+}
+
+// NewContainerResults converts the per container results reported by worker to their json documents
+func NewContainerResults(results []*gpu_mount.ContainerResult) []*ContainerResult {
+	containers := []*ContainerResult{}
+	for _, result := range results {
+		containers = append(containers, &ContainerResult{
+			Name:        result.ContainerName,
+			ContainerID: result.ContainerId,
+			Success:     result.Success,
+			Message:     result.Message,
+		})
+	}
+	return containers
+}
+
+// FailedContainersMessage describes the containers failing in the per container results
+func FailedContainersMessage(results []*gpu_mount.ContainerResult) string {
+	var messages []string
+	for _, result := range results {
+		if !result.Success {
+			messages = append(messages, "container "+result.ContainerName+": "+result.Message)
+		}
+	}
+	return strings.Join(messages, "; ")
+}
+
+// AddGPUResultCode maps the worker add gpu result to its error code, Success maps to ""
+func AddGPUResultCode(result gpu_mount.AddGPUResponse_AddGPUResult) ErrorCode {
+	switch result {
+	case gpu_mount.AddGPUResponse_Success:
+		return ""
+	case gpu_mount.AddGPUResponse_InsufficientGPU:
+		return ErrInsufficientGPU
+	case gpu_mount.AddGPUResponse_PodNotFound:
+		return ErrPodNotFound
+	case gpu_mount.AddGPUResponse_SlavePodImagePullFailed:
+		return ErrSlavePodImagePullFailed
+	case gpu_mount.AddGPUResponse_SlavePodCrashLoopBackOff:
+		return ErrSlavePodCrashLoopBackOff
+	case gpu_mount.AddGPUResponse_SlavePodEvicted:
+		return ErrSlavePodEvicted
+	case gpu_mount.AddGPUResponse_SlavePodFailed:
+		return ErrSlavePodFailed
+	case gpu_mount.AddGPUResponse_SlavePodTimeout:
+		return ErrSlavePodTimeout
+	case gpu_mount.AddGPUResponse_ContainerNotFound:
+		return ErrContainerNotFound
+	case gpu_mount.AddGPUResponse_MountFailed:
+		return ErrMountFailed
+	case gpu_mount.AddGPUResponse_QuotaExceeded:
+		return ErrQuotaExceeded
+	default:
+		return ErrInternal
+	}
+}
+
+// GetPodGPUsResultCode maps the worker get pod gpus result to its error code, Success maps to ""
+func GetPodGPUsResultCode(result gpu_mount.GetPodGPUsResponse_GetPodGPUsResult) ErrorCode {
+	switch result {
+	case gpu_mount.GetPodGPUsResponse_Success:
+		return ""
+	case gpu_mount.GetPodGPUsResponse_PodNotFound:
+		return ErrPodNotFound
+	default:
+		return ErrInternal
+	}
+}
+
+// RemoveGPUResultCode maps the worker remove gpu result to its error code, Success maps to ""
+func RemoveGPUResultCode(result gpu_mount.RemoveGPUResponse_RemoveGPUResult) ErrorCode {
+	switch result {
+	case gpu_mount.RemoveGPUResponse_Success:
+		return ""
+	case gpu_mount.RemoveGPUResponse_GPUBusy:
+		return ErrGPUBusy
+	case gpu_mount.RemoveGPUResponse_PodNotFound:
+		return ErrPodNotFound
+	case gpu_mount.RemoveGPUResponse_GPUNotFound:
+		return ErrGPUNotFound
+	case gpu_mount.RemoveGPUResponse_ContainerNotFound:
+		return ErrContainerNotFound
+	case gpu_mount.RemoveGPUResponse_UnmountFailed:
+		return ErrUnmountFailed
+	default:
+		return ErrInternal
+	}
+}
+
+// ExtendLeaseResultCode maps the worker extend lease result to its error code, Success maps to ""
+func ExtendLeaseResultCode(result gpu_mount.ExtendLeaseResponse_ExtendLeaseResult) ErrorCode {
+	switch result {
+	case gpu_mount.ExtendLeaseResponse_Success:
+		return ""
+	case gpu_mount.ExtendLeaseResponse_PodNotFound:
+		return ErrPodNotFound
+	case gpu_mount.ExtendLeaseResponse_GPUNotFound:
+		return ErrGPUNotFound
+	case gpu_mount.ExtendLeaseResponse_NoLease:
+		return ErrNoLease
+	default:
+		return ErrInternal
+	}
+}
@@ -0,0 +1,99 @@
+package rest
+
+import (
+	gpu_mount "GPUMounter/pkg/api/gpu-mount"
+	"net/http"
+	"testing"
+)
+
+func TestAddGPUResultCode(t *testing.T) {
+	cases := []struct {
+		result gpu_mount.AddGPUResponse_AddGPUResult
+		code   ErrorCode
+		status int
+	}{
+		{gpu_mount.AddGPUResponse_InsufficientGPU, ErrInsufficientGPU, http.StatusConflict},
+		{gpu_mount.AddGPUResponse_PodNotFound, ErrPodNotFound, http.StatusNotFound},
+		{gpu_mount.AddGPUResponse_SlavePodImagePullFailed, ErrSlavePodImagePullFailed, http.StatusInternalServerError},
+		{gpu_mount.AddGPUResponse_SlavePodTimeout, ErrSlavePodTimeout, http.StatusGatewayTimeout},
+		{gpu_mount.AddGPUResponse_ContainerNotFound, ErrContainerNotFound, http.StatusNotFound},
+		{gpu_mount.AddGPUResponse_MountFailed, ErrMountFailed, http.StatusInternalServerError},
+		{gpu_mount.AddGPUResponse_QuotaExceeded, ErrQuotaExceeded, http.StatusForbidden},
+		{gpu_mount.AddGPUResponse_AddGPUResult(99), ErrInternal, http.StatusInternalServerError},
+	}
+	if code := AddGPUResultCode(gpu_mount.AddGPUResponse_Success); code != "" {
+		t.Errorf("Success should map to no error code, got %s", code)
+	}
+	for _, c := range cases {
+		code := AddGPUResultCode(c.result)
+		if code != c.code {
+			t.Errorf("%s: expected code %s, got %s", c.result, c.code, code)
+		}
+		if code.HTTPStatus() != c.status {
+			t.Errorf("%s: expected status %d, got %d", c.result, c.status, code.HTTPStatus())
+		}
+	}
+}
+
+func TestRemoveGPUResultCode(t *testing.T) {
+	cases := []struct {
+		result gpu_mount.RemoveGPUResponse_RemoveGPUResult
+		code   ErrorCode
+		status int
+	}{
+		{gpu_mount.RemoveGPUResponse_GPUBusy, ErrGPUBusy, http.StatusConflict},
+		{gpu_mount.RemoveGPUResponse_PodNotFound, ErrPodNotFound, http.StatusNotFound},
+		{gpu_mount.RemoveGPUResponse_GPUNotFound, ErrGPUNotFound, http.StatusNotFound},
+		{gpu_mount.RemoveGPUResponse_ContainerNotFound, ErrContainerNotFound, http.StatusNotFound},
+		{gpu_mount.RemoveGPUResponse_UnmountFailed, ErrUnmountFailed, http.StatusInternalServerError},
+	}
+	if code := RemoveGPUResultCode(gpu_mount.RemoveGPUResponse_Success); code != "" {
+		t.Errorf("Success should map to no error code, got %s", code)
+	}
+	for _, c := range cases {
+		code := RemoveGPUResultCode(c.result)
+		if code != c.code {
+			t.Errorf("%s: expected code %s, got %s", c.result, c.code, code)
+		}
+		if code.HTTPStatus() != c.status {
+			t.Errorf("%s: expected status %d, got %d", c.result, c.status, code.HTTPStatus())
+		}
+	}
+}
+
+func TestExtendLeaseResultCode(t *testing.T) {
+	cases := []struct {
+		result gpu_mount.ExtendLeaseResponse_ExtendLeaseResult
+		code   ErrorCode
+		status int
+	}{
+		{gpu_mount.ExtendLeaseResponse_PodNotFound, ErrPodNotFound, http.StatusNotFound},
+		{gpu_mount.ExtendLeaseResponse_GPUNotFound, ErrGPUNotFound, http.StatusNotFound},
+		{gpu_mount.ExtendLeaseResponse_NoLease, ErrNoLease, http.StatusConflict},
+	}
+	if code := ExtendLeaseResultCode(gpu_mount.ExtendLeaseResponse_Success); code != "" {
+		t.Errorf("Success should map to no error code, got %s", code)
+	}
+	for _, c := range cases {
+		code := ExtendLeaseResultCode(c.result)
+		if code != c.code {
+			t.Errorf("%s: expected code %s, got %s", c.result, c.code, code)
+		}
+		if code.HTTPStatus() != c.status {
+			t.Errorf("%s: expected status %d, got %d", c.result, c.status, code.HTTPStatus())
+		}
+	}
+}
+
+func TestFailedContainersMessage(t *testing.T) {
+	results := []*gpu_mount.ContainerResult{
+		{ContainerName: "istio-proxy", Success: true},
+		{ContainerName: "trainer", Success: false, Message: "Mount GPU: GPU-1 failed"},
+	}
+	if message := FailedContainersMessage(results); message != "container trainer: Mount GPU: GPU-1 failed" {
+		t.Errorf("unexpected message: %s", message)
+	}
+	if containers := NewContainerResults(nil); containers == nil || len(containers) != 0 {
+		t.Errorf("no container results should be converted to an empty list")
+	}
+}
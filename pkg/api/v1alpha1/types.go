@@ -0,0 +1,114 @@
+// Package v1alpha1 defines the GPUMount custom resource, which declares the extra gpus a pod should have
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	GroupName = "gpumounter.io"
+	Version   = "v1alpha1"
+	Kind      = "GPUMount"
+	Resource  = "gpumounts"
+
+	// Finalizer keeps the GPUMount until its gpus are unmounted
+	Finalizer = "gpumounter.io/unmount"
+)
+
+var SchemeGroupVersion = schema.GroupVersion{Group: GroupName, Version: Version}
+
+var GroupVersionResource = SchemeGroupVersion.WithResource(Resource)
+
+// GPUMount declares gpus hot mounted into a pod in the same namespace
+type GPUMount struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GPUMountSpec   `json:"spec"`
+	Status GPUMountStatus `json:"status,omitempty"`
+}
+
+type GPUMountSpec struct {
+	// PodName is the name of the target pod
+	PodName string `json:"podName"`
+	// GPUNum is the number of gpus mounted into the pod
+	GPUNum int32 `json:"gpuNum"`
+	// IsEntireMount mounts all gpus by one slave pod, an entire mount can not be resized
+	IsEntireMount bool `json:"isEntireMount,omitempty"`
+	// Container is the target container, the first container of the pod by default
+	Container string `json:"container,omitempty"`
+	// AllContainers mounts gpus into every container of the pod
+	AllContainers bool `json:"allContainers,omitempty"`
+	// ForceUnmount kills the processes running on the gpus when unmounting
+	ForceUnmount bool `json:"forceUnmount,omitempty"`
+}
+
+type GPUMountPhase string
+
+const (
+	// the gpus are not mounted yet, e.g. the pod is not scheduled
+	GPUMountPending GPUMountPhase = "Pending"
+	// all gpus in spec are mounted
+	GPUMountMounted GPUMountPhase = "Mounted"
+	// the last mount or unmount failed, and will be retried
+	GPUMountFailed GPUMountPhase = "Failed"
+	// the GPUMount is deleted and the gpus are being unmounted
+	GPUMountUnmounting GPUMountPhase = "Unmounting"
+)
+
+type GPUMountConditionType string
+
+const (
+	// GPUMountReady is true when the mounted gpus match the spec
+	GPUMountReady GPUMountConditionType = "Ready"
+)
+
+type GPUMountCondition struct {
+	Type               GPUMountConditionType  `json:"type"`
+	Status             corev1.ConditionStatus `json:"status"`
+	Reason             string                 `json:"reason,omitempty"`
+	Message            string                 `json:"message,omitempty"`
+	LastTransitionTime metav1.Time            `json:"lastTransitionTime,omitempty"`
+}
+
+type GPUMountStatus struct {
+	Phase GPUMountPhase `json:"phase,omitempty"`
+	// NodeName is the node of the pod
+	NodeName string `json:"nodeName,omitempty"`
+	// PodUID is the uid of the pod the gpus are mounted into
+	PodUID string `json:"podUID,omitempty"`
+	// UUIDs are the uuid of mounted gpus
+	UUIDs []string `json:"uuids,omitempty"`
+	// SlavePods are the slave pods reserving the mounted gpus
+	SlavePods []string `json:"slavePods,omitempty"`
+	// ObservedGeneration is the generation of spec the status is computed from
+	ObservedGeneration int64               `json:"observedGeneration,omitempty"`
+	Conditions         []GPUMountCondition `json:"conditions,omitempty"`
+}
+
+// SetCondition adds or updates the condition, the transition time is kept if the status is not changed
+func (status *GPUMountStatus) SetCondition(condition GPUMountCondition) {
+	for i := range status.Conditions {
+		if status.Conditions[i].Type != condition.Type {
+			continue
+		}
+		if status.Conditions[i].Status == condition.Status {
+			condition.LastTransitionTime = status.Conditions[i].LastTransitionTime
+		}
+		status.Conditions[i] = condition
+		return
+	}
+	status.Conditions = append(status.Conditions, condition)
+}
+
+// GetCondition returns the condition of the type, nil if there is no such condition
+func (status *GPUMountStatus) GetCondition(conditionType GPUMountConditionType) *GPUMountCondition {
+	for i := range status.Conditions {
+		if status.Conditions[i].Type == conditionType {
+			return &status.Conditions[i]
+		}
+	}
+	return nil
+}
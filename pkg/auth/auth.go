@@ -0,0 +1,158 @@
+// Package auth authenticates the callers of gpu mounter master by bearer tokens with TokenReview,
+// and authorizes them with SubjectAccessReview against the virtual resource gpumounts.gpumounter.io
+package auth
+
+import (
+	"GPUMounter/pkg/api/rest"
+	"GPUMounter/pkg/api/v1alpha1"
+	. "GPUMounter/pkg/util/log"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// verbs on gpumounts.gpumounter.io checked for each api
+const (
+	VerbCreate = "create"
+	VerbUpdate = "update"
+	VerbDelete = "delete"
+	VerbGet    = "get"
+	VerbList   = "list"
+)
+
+// ErrUnauthenticated is returned if the token is missing or invalid
+var ErrUnauthenticated = errors.New("invalid bearer token")
+
+// Enabled reports whether authentication and authorization are enabled by env API_AUTH
+func Enabled() bool {
+	return os.Getenv("API_AUTH") == "true"
+}
+
+type Authorizer struct {
+	client  kubernetes.Interface
+	enabled bool
+}
+
+// NewAuthorizer creates an authorizer, all calls are allowed if it is not enabled
+func NewAuthorizer(client kubernetes.Interface, enabled bool) *Authorizer {
+	return &Authorizer{client: client, enabled: enabled}
+}
+
+// bearerToken returns the token in the Authorization header, "" if there is no bearer token
+func bearerToken(r *http.Request) string {
+	parts := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(parts[1])
+}
+
+// Authenticate validates the token by TokenReview and returns the user of the token
+func (authorizer *Authorizer) Authenticate(ctx context.Context, token string) (*authenticationv1.UserInfo, error) {
+	if token == "" {
+		return nil, ErrUnauthenticated
+	}
+	review, err := authorizer.client.AuthenticationV1().TokenReviews().Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		Logger.Error("Failed to create TokenReview")
+		return nil, err
+	}
+	if !review.Status.Authenticated {
+		Logger.Warn("Token is not authenticated: ", review.Status.Error)
+		return nil, ErrUnauthenticated
+	}
+	return &review.Status.User, nil
+}
+
+// Authorize checks whether the user can do the verb on gpumounts.gpumounter.io in the namespace by SubjectAccessReview,
+// the reason of denial is returned if it is not allowed
+func (authorizer *Authorizer) Authorize(ctx context.Context, user *authenticationv1.UserInfo, verb string, namespace string, podName string) (bool, string, error) {
+	extra := make(map[string]authorizationv1.ExtraValue)
+	for key, value := range user.Extra {
+		extra[key] = authorizationv1.ExtraValue(value)
+	}
+	review, err := authorizer.client.AuthorizationV1().SubjectAccessReviews().Create(ctx, &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      verb,
+				Group:     v1alpha1.GroupName,
+				Resource:  v1alpha1.Resource,
+				Name:      podName,
+			},
+			User:   user.Username,
+			Groups: user.Groups,
+			Extra:  extra,
+			UID:    user.UID,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		Logger.Error("Failed to create SubjectAccessReview")
+		return false, "", err
+	}
+	return review.Status.Allowed, review.Status.Reason, nil
+}
+
+// Protect authenticates the caller of the handle and authorizes the verb on the namespace and pod in the path,
+// the namespace is empty for cluster wide apis
+func (authorizer *Authorizer) Protect(verb string, handle httprouter.Handle) httprouter.Handle {
+	if !authorizer.enabled {
+		return handle
+	}
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		user, err := authorizer.Authenticate(r.Context(), bearerToken(r))
+		if err == ErrUnauthenticated {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, rest.ErrUnauthorized, "A valid bearer token is required")
+			return
+		} else if err != nil {
+			Logger.Error(err)
+			writeError(w, rest.ErrInternal, "Failed to authenticate: "+err.Error())
+			return
+		}
+
+		namespace := ps.ByName("namespace")
+		podName := ps.ByName("pod")
+		allowed, reason, err := authorizer.Authorize(r.Context(), user, verb, namespace, podName)
+		if err != nil {
+			Logger.Error(err)
+			writeError(w, rest.ErrInternal, "Failed to authorize: "+err.Error())
+			return
+		}
+		resource := v1alpha1.Resource + "." + v1alpha1.GroupName
+		if !allowed {
+			Logger.Warn("User: ", user.Username, " is not allowed to ", verb, " ", resource, " in Namespace: ", namespace, " Pod: ", podName)
+			message := "User " + user.Username + " cannot " + verb + " " + resource
+			if namespace != "" {
+				message += " in namespace " + namespace
+			}
+			if reason != "" {
+				message += ": " + reason
+			}
+			writeError(w, rest.ErrForbidden, message)
+			return
+		}
+		Logger.Info("User: ", user.Username, " is allowed to ", verb, " ", resource, " in Namespace: ", namespace, " Pod: ", podName)
+		handle(w, r, ps)
+	}
+}
+
+func writeError(w http.ResponseWriter, code rest.ErrorCode, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code.HTTPStatus())
+	if err := json.NewEncoder(w).Encode(&rest.ErrorResponse{Error: &rest.Error{Code: code, Message: message}}); err != nil {
+		Logger.Error("Failed to write response")
+		Logger.Error(err)
+	}
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"GPUMounter/pkg/api/rest"
+	. "GPUMounter/pkg/util/log"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := ioutil.TempDir("", "auth")
+	if err != nil {
+		panic(err)
+	}
+	InitLogger(dir+"/", "log")
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// newFakeClient authenticates token "tenant-token" as user tenant, who can create gpumounts in namespace tenant only
+func newFakeClient(t *testing.T) *kubefake.Clientset {
+	client := kubefake.NewSimpleClientset()
+	client.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		if review.Spec.Token == "tenant-token" {
+			review.Status.Authenticated = true
+			review.Status.User = authenticationv1.UserInfo{Username: "tenant", Groups: []string{"tenants"}}
+		}
+		return true, review, nil
+	})
+	client.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		attributes := review.Spec.ResourceAttributes
+		if attributes.Group != "gpumounter.io" || attributes.Resource != "gpumounts" {
+			t.Errorf("unexpected resource: %+v", attributes)
+		}
+		review.Status.Allowed = review.Spec.User == "tenant" && attributes.Namespace == "tenant" && attributes.Verb == VerbCreate
+		return true, review, nil
+	})
+	return client
+}
+
+func TestProtect(t *testing.T) {
+	authorizer := NewAuthorizer(newFakeClient(t), true)
+	router := httprouter.New()
+	handled := false
+	router.POST("/namespace/:namespace/pod/:pod/addgpu", authorizer.Protect(VerbCreate, func(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+		handled = true
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	testCases := []struct {
+		token     string
+		namespace string
+		status    int
+		code      rest.ErrorCode
+	}{
+		{"", "tenant", http.StatusUnauthorized, rest.ErrUnauthorized},
+		{"invalid-token", "tenant", http.StatusUnauthorized, rest.ErrUnauthorized},
+		{"tenant-token", "other", http.StatusForbidden, rest.ErrForbidden},
+		{"tenant-token", "tenant", http.StatusOK, ""},
+	}
+	for _, testCase := range testCases {
+		handled = false
+		request := httptest.NewRequest(http.MethodPost, "/namespace/"+testCase.namespace+"/pod/gpu-pod/addgpu", nil)
+		if testCase.token != "" {
+			request.Header.Set("Authorization", "Bearer "+testCase.token)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		if recorder.Code != testCase.status {
+			t.Errorf("token %q namespace %s: expected status %d, got %d", testCase.token, testCase.namespace, testCase.status, recorder.Code)
+		}
+		if handled != (testCase.status == http.StatusOK) {
+			t.Errorf("token %q namespace %s: unexpected handled %v", testCase.token, testCase.namespace, handled)
+		}
+		if testCase.code != "" {
+			var response rest.ErrorResponse
+			if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil || response.Error.Code != testCase.code {
+				t.Errorf("token %q namespace %s: expected error %s, got %+v", testCase.token, testCase.namespace, testCase.code, response.Error)
+			}
+		}
+	}
+}
+
+func TestProtectDisabled(t *testing.T) {
+	authorizer := NewAuthorizer(newFakeClient(t), false)
+	handled := false
+	handle := authorizer.Protect(VerbDelete, func(http.ResponseWriter, *http.Request, httprouter.Params) {
+		handled = true
+	})
+	handle(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", nil), nil)
+	if !handled {
+		t.Errorf("all calls should be allowed if auth is disabled")
+	}
+}
@@ -0,0 +1,220 @@
+// Package client is the Go client of the gpu mounter master api under /api/v2
+package client
+
+import (
+	"GPUMounter/pkg/api/rest"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	restclient "k8s.io/client-go/rest"
+)
+
+// Interface is implemented by Client, and by fake.Client for unit tests of its consumers
+type Interface interface {
+	AddGPU(ctx context.Context, namespace string, podName string, request *rest.AddGPURequest) (*rest.AddGPUResponse, error)
+	RemoveGPU(ctx context.Context, namespace string, podName string, request *rest.RemoveGPURequest) (*rest.RemoveGPUResponse, error)
+	ExtendLease(ctx context.Context, namespace string, podName string, request *rest.ExtendLeaseRequest) (*rest.ExtendLeaseResponse, error)
+	ListPodGPUs(ctx context.Context, namespace string, podName string) (*rest.PodGPUsResponse, error)
+	ListNodeGPUs(ctx context.Context, nodeName string) (*rest.NodeGPUsResponse, error)
+}
+
+// DefaultBackoff retries 4 times in about 3 seconds
+var DefaultBackoff = wait.Backoff{
+	Duration: 200 * time.Millisecond,
+	Factor:   2,
+	Jitter:   0.1,
+	Steps:    5,
+}
+
+// Client calls gpu mounter master, it is safe for concurrent use
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	token      string
+	tokenFile  string
+	backoff    wait.Backoff
+}
+
+var _ Interface = &Client{}
+
+// New returns the client of the master at baseURL, e.g. "http://gpu-mounter-service.kube-system"
+func New(baseURL string, options ...Option) (*Client, error) {
+	if _, err := url.Parse(baseURL); err != nil {
+		return nil, fmt.Errorf("invalid master url %s: %v", baseURL, err)
+	}
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 5 * time.Minute},
+		backoff:    DefaultBackoff,
+	}
+	for _, option := range options {
+		if err := option(c); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// NewForServiceProxy returns the client reaching the master service through the service proxy of the api server,
+// authenticated by config. The api server strips the bearer token when proxying, so the master should not enable API_AUTH.
+func NewForServiceProxy(config *restclient.Config, namespace string, service string, options ...Option) (*Client, error) {
+	transport, err := restclient.TransportFor(config)
+	if err != nil {
+		return nil, err
+	}
+	host := strings.TrimSuffix(config.Host, "/")
+	if !strings.Contains(host, "://") {
+		host = "https://" + host
+	}
+	baseURL := host + "/api/v1/namespaces/" + namespace + "/services/" + service + "/proxy"
+	options = append([]Option{WithHTTPClient(&http.Client{Transport: transport, Timeout: 5 * time.Minute})}, options...)
+	return New(baseURL, options...)
+}
+
+func podPath(namespace string, podName string, action string) string {
+	return rest.PathPrefix + "/namespace/" + url.PathEscape(namespace) + "/pod/" + url.PathEscape(podName) + "/" + action
+}
+
+func (c *Client) AddGPU(ctx context.Context, namespace string, podName string, request *rest.AddGPURequest) (*rest.AddGPUResponse, error) {
+	response := &rest.AddGPUResponse{}
+	if err := c.do(ctx, http.MethodPost, podPath(namespace, podName, "addgpu"), request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (c *Client) RemoveGPU(ctx context.Context, namespace string, podName string, request *rest.RemoveGPURequest) (*rest.RemoveGPUResponse, error) {
+	response := &rest.RemoveGPUResponse{}
+	if err := c.do(ctx, http.MethodPost, podPath(namespace, podName, "removegpu"), request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (c *Client) ExtendLease(ctx context.Context, namespace string, podName string, request *rest.ExtendLeaseRequest) (*rest.ExtendLeaseResponse, error) {
+	response := &rest.ExtendLeaseResponse{}
+	if err := c.do(ctx, http.MethodPost, podPath(namespace, podName, "extendlease"), request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (c *Client) ListPodGPUs(ctx context.Context, namespace string, podName string) (*rest.PodGPUsResponse, error) {
+	response := &rest.PodGPUsResponse{}
+	if err := c.do(ctx, http.MethodGet, podPath(namespace, podName, "gpus"), nil, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (c *Client) ListNodeGPUs(ctx context.Context, nodeName string) (*rest.NodeGPUsResponse, error) {
+	response := &rest.NodeGPUsResponse{}
+	if err := c.do(ctx, http.MethodGet, rest.PathPrefix+"/nodes/"+url.PathEscape(nodeName)+"/gpus", nil, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// do sends the request, and retries with backoff while the error is retryable for the method
+func (c *Client) do(ctx context.Context, method string, path string, body interface{}, out interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	backoff := c.backoff
+	for {
+		err := c.send(ctx, method, path, data, out)
+		if err == nil || !retryable(method, err) || backoff.Steps <= 1 {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff.Step()):
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, method string, path string, data []byte, out interface{}) error {
+	var reader io.Reader
+	if data != nil {
+		reader = bytes.NewReader(data)
+	}
+	request, err := http.NewRequest(method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	request = request.WithContext(ctx)
+	if data != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	token, err := c.bearerToken()
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newError(resp.StatusCode, raw)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(raw, out)
+}
+
+// bearerToken reads the token file on every request, so rotated service account tokens are picked up
+func (c *Client) bearerToken() (string, error) {
+	if c.tokenFile == "" {
+		return c.token, nil
+	}
+	token, err := ioutil.ReadFile(c.tokenFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(token)), nil
+}
+
+// retryable reports whether the request surely did not change anything, so sending it again is safe
+func retryable(method string, err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.Code {
+		case rest.ErrWorkerNotFound:
+			// the worker of the node is restarting
+			return true
+		case rest.ErrInternal:
+			return method == http.MethodGet
+		}
+		return statusErr.Code == "" && statusErr.StatusCode >= http.StatusInternalServerError && method == http.MethodGet
+	}
+	// the master is unreachable, mounting requests are retried only if they were not sent
+	return method == http.MethodGet || isDialError(err)
+}
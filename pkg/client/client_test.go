@@ -0,0 +1,138 @@
+package client
+
+import (
+	"GPUMounter/pkg/api/rest"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+var testBackoff = wait.Backoff{Duration: time.Millisecond, Factor: 1, Steps: 3}
+
+func writeError(w http.ResponseWriter, code rest.ErrorCode) {
+	w.WriteHeader(code.HTTPStatus())
+	json.NewEncoder(w).Encode(&rest.ErrorResponse{Error: &rest.Error{Code: code, Message: "failed"}})
+}
+
+func TestAddGPU(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v2/namespace/default/pod/gpu-pod/addgpu" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("unexpected authorization: %q", r.Header.Get("Authorization"))
+		}
+		var request rest.AddGPURequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.GPUNum != 2 {
+			t.Errorf("unexpected request body: %+v, %v", request, err)
+		}
+		json.NewEncoder(w).Encode(&rest.AddGPUResponse{Namespace: "default", Pod: "gpu-pod", GPUs: []*rest.MountedGPU{{UUID: "GPU-0"}, {UUID: "GPU-1"}}})
+	}))
+	defer server.Close()
+
+	c, err := New(server.URL, WithBearerToken("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := c.AddGPU(context.TODO(), "default", "gpu-pod", &rest.AddGPURequest{GPUNum: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(response.GPUs) != 2 || response.GPUs[1].UUID != "GPU-1" {
+		t.Errorf("unexpected response: %+v", response)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		writeError(w, rest.ErrInsufficientGPU)
+	}))
+	defer server.Close()
+
+	c, err := New(server.URL, WithBackoff(testBackoff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.AddGPU(context.TODO(), "default", "gpu-pod", &rest.AddGPURequest{GPUNum: 1})
+	if !IsInsufficientGPU(err) || IsGPUBusy(err) {
+		t.Errorf("expected InsufficientGPU error, got %v", err)
+	}
+	if statusErr, ok := err.(*StatusError); !ok || statusErr.StatusCode != http.StatusConflict {
+		t.Errorf("expected status error with 409, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected no retry of InsufficientGPU, got %d calls", calls)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name          string
+		method        string
+		code          rest.ErrorCode
+		expectedCalls int32
+	}{
+		{name: "restarting worker", method: http.MethodPost, code: rest.ErrWorkerNotFound, expectedCalls: 3},
+		// the gpus may be mounted already
+		{name: "internal error of mounting", method: http.MethodPost, code: rest.ErrInternal, expectedCalls: 1},
+		{name: "internal error of query", method: http.MethodGet, code: rest.ErrInternal, expectedCalls: 3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calls int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&calls, 1)
+				writeError(w, test.code)
+			}))
+			defer server.Close()
+
+			c, err := New(server.URL, WithBackoff(testBackoff))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.method == http.MethodGet {
+				_, err = c.ListNodeGPUs(context.TODO(), "gpu-node-1")
+			} else {
+				_, err = c.RemoveGPU(context.TODO(), "default", "gpu-pod", &rest.RemoveGPURequest{UUIDs: []string{"GPU-0"}})
+			}
+			if Code(err) != test.code {
+				t.Errorf("expected %s, got %v", test.code, err)
+			}
+			if calls != test.expectedCalls {
+				t.Errorf("expected %d calls, got %d", test.expectedCalls, calls)
+			}
+		})
+	}
+}
+
+func TestRetryUntilSuccess(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			writeError(w, rest.ErrWorkerNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(&rest.PodGPUsResponse{Pod: "gpu-pod", GPUs: []*rest.GPU{{UUID: "GPU-0"}}})
+	}))
+	defer server.Close()
+
+	c, err := New(server.URL, WithBackoff(testBackoff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := c.ListPodGPUs(context.TODO(), "default", "gpu-pod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(response.GPUs) != 1 || calls != 2 {
+		t.Errorf("unexpected response %+v after %d calls", response, calls)
+	}
+}
@@ -0,0 +1,96 @@
+package client
+
+import (
+	"GPUMounter/pkg/api/rest"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// StatusError is returned for the non 2xx responses, Code is empty if the response is not from the master,
+// e.g. the service proxy of the api server failed
+type StatusError struct {
+	StatusCode int
+	Code       rest.ErrorCode
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("gpu mounter master answered %d: %s", e.StatusCode, e.Message)
+	}
+	return string(e.Code) + ": " + e.Message
+}
+
+func newError(statusCode int, body []byte) *StatusError {
+	var errResp rest.ErrorResponse
+	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error != nil {
+		return &StatusError{StatusCode: statusCode, Code: errResp.Error.Code, Message: errResp.Error.Message}
+	}
+	return &StatusError{StatusCode: statusCode, Message: strings.TrimSpace(string(body))}
+}
+
+// Code returns the error code of the master in err, empty if err is not from the master
+func Code(err error) rest.ErrorCode {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code
+	}
+	return ""
+}
+
+func IsPodNotFound(err error) bool {
+	return Code(err) == rest.ErrPodNotFound
+}
+
+func IsContainerNotFound(err error) bool {
+	return Code(err) == rest.ErrContainerNotFound
+}
+
+func IsGPUNotFound(err error) bool {
+	return Code(err) == rest.ErrGPUNotFound
+}
+
+func IsInsufficientGPU(err error) bool {
+	return Code(err) == rest.ErrInsufficientGPU
+}
+
+func IsGPUBusy(err error) bool {
+	return Code(err) == rest.ErrGPUBusy
+}
+
+func IsQuotaExceeded(err error) bool {
+	return Code(err) == rest.ErrQuotaExceeded
+}
+
+func IsNoLease(err error) bool {
+	return Code(err) == rest.ErrNoLease
+}
+
+func IsUnauthorized(err error) bool {
+	return Code(err) == rest.ErrUnauthorized
+}
+
+func IsForbidden(err error) bool {
+	return Code(err) == rest.ErrForbidden
+}
+
+func IsWorkerNotFound(err error) bool {
+	return Code(err) == rest.ErrWorkerNotFound
+}
+
+// IsSlavePodFailure reports whether the slave pods reserving the gpus failed, e.g. timeout or evicted
+func IsSlavePodFailure(err error) bool {
+	switch Code(err) {
+	case rest.ErrSlavePodImagePullFailed, rest.ErrSlavePodCrashLoopBackOff, rest.ErrSlavePodEvicted, rest.ErrSlavePodFailed, rest.ErrSlavePodTimeout:
+		return true
+	}
+	return false
+}
+
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
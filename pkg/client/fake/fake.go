@@ -0,0 +1,292 @@
+// Package fake is an in-memory client.Interface for unit tests of the consumers of gpu mounter master
+package fake
+
+import (
+	"GPUMounter/pkg/api/rest"
+	"GPUMounter/pkg/client"
+	"GPUMounter/pkg/util/gpu"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Action is a call received by the fake client
+type Action struct {
+	Verb      string
+	Namespace string
+	Pod       string
+	Node      string
+	Request   interface{}
+}
+
+// Reactor returns the error for the action, or nil to let the fake client handle it
+type Reactor func(action Action) error
+
+// the gpu states reported by workers
+const (
+	freeState      = "GPU_FREE_STATE"
+	allocatedState = "GPU_ALLOCATED_STATE"
+)
+
+type fakeGPU struct {
+	info  *rest.GPU
+	owner types.NamespacedName
+}
+
+// Client mounts the gpus of the nodes added by AddNode into the pods added by AddPod, answering like the master
+type Client struct {
+	mu       sync.Mutex
+	nodes    map[string][]*fakeGPU
+	pods     map[types.NamespacedName]string
+	actions  []Action
+	reactors []Reactor
+	now      func() time.Time
+}
+
+var _ client.Interface = &Client{}
+
+func NewClient() *Client {
+	return &Client{
+		nodes: make(map[string][]*fakeGPU),
+		pods:  make(map[types.NamespacedName]string),
+		now:   time.Now,
+	}
+}
+
+// AddNode adds the free gpus of the node
+func (c *Client) AddNode(nodeName string, uuids ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, uuid := range uuids {
+		minorNumber := int32(len(c.nodes[nodeName]))
+		c.nodes[nodeName] = append(c.nodes[nodeName], &fakeGPU{
+			info: &rest.GPU{
+				UUID:           uuid,
+				MinorNumber:    minorNumber,
+				DeviceFilePath: fmt.Sprintf("/dev/nvidia%d", minorNumber),
+				State:          freeState,
+			},
+		})
+	}
+}
+
+// AddPod adds the running pod on the node
+func (c *Client) AddPod(namespace string, podName string, nodeName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pods[types.NamespacedName{Namespace: namespace, Name: podName}] = nodeName
+}
+
+// PrependReactor makes reactor handle the actions before the fake client, e.g. to return errors
+func (c *Client) PrependReactor(reactor Reactor) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reactors = append([]Reactor{reactor}, c.reactors...)
+}
+
+// Actions returns the calls received so far
+func (c *Client) Actions() []Action {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Action(nil), c.actions...)
+}
+
+// NewError returns the error the real client returns for code
+func NewError(code rest.ErrorCode, message string) error {
+	return &client.StatusError{StatusCode: code.HTTPStatus(), Code: code, Message: message}
+}
+
+// invoke records the action and runs the reactors, the caller should hold the lock
+func (c *Client) invoke(action Action) error {
+	c.actions = append(c.actions, action)
+	for _, reactor := range c.reactors {
+		if err := reactor(action); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) podNode(namespace string, podName string) (string, error) {
+	nodeName, ok := c.pods[types.NamespacedName{Namespace: namespace, Name: podName}]
+	if !ok {
+		return "", NewError(rest.ErrPodNotFound, "No pod: "+podName+" in namespace: "+namespace)
+	}
+	if _, ok := c.nodes[nodeName]; !ok {
+		return "", NewError(rest.ErrWorkerNotFound, "No gpu mounter worker on Node: "+nodeName)
+	}
+	return nodeName, nil
+}
+
+func (c *Client) podGPUs(owner types.NamespacedName) []*fakeGPU {
+	var gpus []*fakeGPU
+	for _, dev := range c.nodes[c.pods[owner]] {
+		if dev.owner == owner {
+			gpus = append(gpus, dev)
+		}
+	}
+	return gpus
+}
+
+func (c *Client) AddGPU(_ context.Context, namespace string, podName string, request *rest.AddGPURequest) (*rest.AddGPUResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.invoke(Action{Verb: "AddGPU", Namespace: namespace, Pod: podName, Request: request}); err != nil {
+		return nil, err
+	}
+	nodeName, err := c.podNode(namespace, podName)
+	if err != nil {
+		return nil, err
+	}
+	var free []*fakeGPU
+	for _, dev := range c.nodes[nodeName] {
+		if dev.owner.Name == "" {
+			free = append(free, dev)
+		}
+	}
+	if int(request.GPUNum) > len(free) {
+		return nil, NewError(rest.ErrInsufficientGPU, "Failed to add gpu for Pod: "+podName+" on Node: "+nodeName+" (InsufficientGPU)")
+	}
+
+	owner := types.NamespacedName{Namespace: namespace, Name: podName}
+	var leaseExpiresAt string
+	if request.LeaseSeconds > 0 {
+		leaseExpiresAt = c.now().Add(time.Duration(request.LeaseSeconds) * time.Second).UTC().Format(time.RFC3339)
+	}
+	mountType := gpu.SingleMount
+	if request.IsEntireMount {
+		mountType = gpu.EntireMount
+	}
+	response := &rest.AddGPUResponse{Namespace: namespace, Pod: podName, Node: nodeName, GPUs: []*rest.MountedGPU{}, Containers: []*rest.ContainerResult{}}
+	for _, dev := range free[:request.GPUNum] {
+		dev.owner = owner
+		dev.info.State = allocatedState
+		dev.info.OwnerPod = podName
+		dev.info.OwnerNamespace = namespace
+		dev.info.SlavePod = podName + "-slave-pod-" + dev.info.UUID
+		dev.info.MountType = string(mountType)
+		dev.info.LeaseExpiresAt = leaseExpiresAt
+		response.GPUs = append(response.GPUs, &rest.MountedGPU{
+			UUID:           dev.info.UUID,
+			MinorNumber:    dev.info.MinorNumber,
+			DeviceFilePath: dev.info.DeviceFilePath,
+			SlavePod:       dev.info.SlavePod,
+			LeaseExpiresAt: leaseExpiresAt,
+		})
+	}
+	return response, nil
+}
+
+func (c *Client) RemoveGPU(_ context.Context, namespace string, podName string, request *rest.RemoveGPURequest) (*rest.RemoveGPUResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.invoke(Action{Verb: "RemoveGPU", Namespace: namespace, Pod: podName, Request: request}); err != nil {
+		return nil, err
+	}
+	nodeName, err := c.podNode(namespace, podName)
+	if err != nil {
+		return nil, err
+	}
+	mounted := make(map[string]*fakeGPU)
+	for _, dev := range c.podGPUs(types.NamespacedName{Namespace: namespace, Name: podName}) {
+		mounted[dev.info.UUID] = dev
+	}
+	for _, uuid := range request.UUIDs {
+		if _, ok := mounted[uuid]; !ok {
+			return nil, NewError(rest.ErrGPUNotFound, "Failed to remove GPU: "+uuid+" from Pod: "+podName+" (GPUNotFound)")
+		}
+	}
+	for _, uuid := range request.UUIDs {
+		mounted[uuid].owner = types.NamespacedName{}
+		mounted[uuid].info = &rest.GPU{
+			UUID:           uuid,
+			MinorNumber:    mounted[uuid].info.MinorNumber,
+			DeviceFilePath: mounted[uuid].info.DeviceFilePath,
+			State:          freeState,
+		}
+	}
+	return &rest.RemoveGPUResponse{Namespace: namespace, Pod: podName, Node: nodeName, UUIDs: request.UUIDs, Containers: []*rest.ContainerResult{}}, nil
+}
+
+func (c *Client) ExtendLease(_ context.Context, namespace string, podName string, request *rest.ExtendLeaseRequest) (*rest.ExtendLeaseResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.invoke(Action{Verb: "ExtendLease", Namespace: namespace, Pod: podName, Request: request}); err != nil {
+		return nil, err
+	}
+	nodeName, err := c.podNode(namespace, podName)
+	if err != nil {
+		return nil, err
+	}
+	if request.LeaseSeconds <= 0 {
+		return nil, NewError(rest.ErrInvalidRequest, "leaseSeconds should be greater than 0")
+	}
+	mounted := make(map[string]*fakeGPU)
+	for _, dev := range c.podGPUs(types.NamespacedName{Namespace: namespace, Name: podName}) {
+		mounted[dev.info.UUID] = dev
+	}
+	for _, uuid := range request.UUIDs {
+		dev, ok := mounted[uuid]
+		if !ok {
+			return nil, NewError(rest.ErrGPUNotFound, "Failed to extend lease of GPU: "+uuid+" of Pod: "+podName+" (GPUNotFound)")
+		}
+		if dev.info.LeaseExpiresAt == "" {
+			return nil, NewError(rest.ErrNoLease, "Failed to extend lease of GPU: "+uuid+" of Pod: "+podName+" (NoLease)")
+		}
+	}
+	response := &rest.ExtendLeaseResponse{Namespace: namespace, Pod: podName, Node: nodeName, Leases: []*rest.Lease{}}
+	now := c.now()
+	for _, uuid := range request.UUIDs {
+		dev := mounted[uuid]
+		expiresAt, err := time.Parse(time.RFC3339, dev.info.LeaseExpiresAt)
+		if err != nil || expiresAt.Before(now) {
+			expiresAt = now
+		}
+		dev.info.LeaseExpiresAt = expiresAt.Add(time.Duration(request.LeaseSeconds) * time.Second).UTC().Format(time.RFC3339)
+		response.Leases = append(response.Leases, &rest.Lease{UUID: uuid, ExpiresAt: dev.info.LeaseExpiresAt})
+	}
+	return response, nil
+}
+
+func copyGPU(info *rest.GPU) *rest.GPU {
+	copied := *info
+	return &copied
+}
+
+func (c *Client) ListPodGPUs(_ context.Context, namespace string, podName string) (*rest.PodGPUsResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.invoke(Action{Verb: "ListPodGPUs", Namespace: namespace, Pod: podName}); err != nil {
+		return nil, err
+	}
+	nodeName, err := c.podNode(namespace, podName)
+	if err != nil {
+		return nil, err
+	}
+	response := &rest.PodGPUsResponse{Namespace: namespace, Pod: podName, Node: nodeName, GPUs: []*rest.GPU{}}
+	for _, dev := range c.podGPUs(types.NamespacedName{Namespace: namespace, Name: podName}) {
+		response.MountType = dev.info.MountType
+		response.GPUs = append(response.GPUs, copyGPU(dev.info))
+	}
+	return response, nil
+}
+
+func (c *Client) ListNodeGPUs(_ context.Context, nodeName string) (*rest.NodeGPUsResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.invoke(Action{Verb: "ListNodeGPUs", Node: nodeName}); err != nil {
+		return nil, err
+	}
+	gpus, ok := c.nodes[nodeName]
+	if !ok {
+		return nil, NewError(rest.ErrWorkerNotFound, "No gpu mounter worker on Node: "+nodeName)
+	}
+	response := &rest.NodeGPUsResponse{Node: nodeName, GPUs: []*rest.GPU{}}
+	for _, dev := range gpus {
+		response.GPUs = append(response.GPUs, copyGPU(dev.info))
+	}
+	return response, nil
+}
@@ -0,0 +1,57 @@
+package fake
+
+import (
+	"GPUMounter/pkg/api/rest"
+	"GPUMounter/pkg/client"
+	"context"
+	"testing"
+)
+
+func TestClient(t *testing.T) {
+	c := NewClient()
+	c.AddNode("gpu-node-1", "GPU-0", "GPU-1")
+	c.AddPod("default", "gpu-pod", "gpu-node-1")
+	ctx := context.TODO()
+
+	if _, err := c.AddGPU(ctx, "default", "gpu-pod", &rest.AddGPURequest{GPUNum: 3}); !client.IsInsufficientGPU(err) {
+		t.Errorf("expected InsufficientGPU, got %v", err)
+	}
+	if _, err := c.AddGPU(ctx, "default", "other-pod", &rest.AddGPURequest{GPUNum: 1}); !client.IsPodNotFound(err) {
+		t.Errorf("expected PodNotFound, got %v", err)
+	}
+	added, err := c.AddGPU(ctx, "default", "gpu-pod", &rest.AddGPURequest{GPUNum: 1, LeaseSeconds: 3600})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added.GPUs) != 1 || added.GPUs[0].UUID != "GPU-0" || added.GPUs[0].LeaseExpiresAt == "" {
+		t.Fatalf("unexpected added gpus: %+v", added.GPUs)
+	}
+
+	pod, err := c.ListPodGPUs(ctx, "default", "gpu-pod")
+	if err != nil || len(pod.GPUs) != 1 || pod.GPUs[0].OwnerPod != "gpu-pod" {
+		t.Fatalf("unexpected pod gpus: %+v, %v", pod, err)
+	}
+	if _, err := c.ExtendLease(ctx, "default", "gpu-pod", &rest.ExtendLeaseRequest{UUIDs: []string{"GPU-1"}, LeaseSeconds: 60}); !client.IsGPUNotFound(err) {
+		t.Errorf("expected GPUNotFound, got %v", err)
+	}
+	if _, err := c.RemoveGPU(ctx, "default", "gpu-pod", &rest.RemoveGPURequest{UUIDs: []string{"GPU-0"}}); err != nil {
+		t.Fatal(err)
+	}
+	node, err := c.ListNodeGPUs(ctx, "gpu-node-1")
+	if err != nil || len(node.GPUs) != 2 || node.GPUs[0].OwnerPod != "" {
+		t.Errorf("expected free gpus, got %+v, %v", node, err)
+	}
+
+	c.PrependReactor(func(action Action) error {
+		if action.Verb == "RemoveGPU" {
+			return NewError(rest.ErrGPUBusy, "busy")
+		}
+		return nil
+	})
+	if _, err := c.RemoveGPU(ctx, "default", "gpu-pod", &rest.RemoveGPURequest{UUIDs: []string{"GPU-0"}}); !client.IsGPUBusy(err) {
+		t.Errorf("expected GPUBusy from reactor, got %v", err)
+	}
+	if actions := c.Actions(); len(actions) != 8 || actions[2].Verb != "AddGPU" {
+		t.Errorf("unexpected actions: %+v", actions)
+	}
+}
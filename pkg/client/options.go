@@ -0,0 +1,66 @@
+package client
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// Option configures a Client
+type Option func(c *Client) error
+
+// WithHTTPClient sends the requests by httpClient, e.g. with a custom transport
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) error {
+		if httpClient == nil {
+			return errors.New("nil http client")
+		}
+		c.httpClient = httpClient
+		return nil
+	}
+}
+
+// WithTLSConfig calls the master over https with tlsConfig, e.g. the CA of the master certificate
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(c *Client) error {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		c.httpClient = &http.Client{Transport: transport, Timeout: c.httpClient.Timeout}
+		return nil
+	}
+}
+
+// WithBearerToken authenticates the requests by token when the master enables API_AUTH
+func WithBearerToken(token string) Option {
+	return func(c *Client) error {
+		c.token = token
+		return nil
+	}
+}
+
+// WithBearerTokenFile authenticates the requests by the token in file, e.g.
+// /var/run/secrets/kubernetes.io/serviceaccount/token, which is read again for every request
+func WithBearerTokenFile(file string) Option {
+	return func(c *Client) error {
+		c.tokenFile = file
+		return nil
+	}
+}
+
+// WithBackoff retries the failed requests with backoff, backoff.Steps is the number of attempts
+func WithBackoff(backoff wait.Backoff) Option {
+	return func(c *Client) error {
+		if backoff.Steps < 1 {
+			return errors.New("backoff steps should be at least 1")
+		}
+		c.backoff = backoff
+		return nil
+	}
+}
+
+// WithoutRetry sends each request only once
+func WithoutRetry() Option {
+	return WithBackoff(wait.Backoff{Steps: 1})
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"flag"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -43,3 +44,22 @@ func GetClientSet() (*kubernetes.Clientset, error) {
 	return clientset, nil
 
 }
+
+var dynamicClient dynamic.Interface
+var dynamicOnce sync.Once
+
+func GetDynamicClient() (dynamic.Interface, error) {
+	inCluster := true
+	dynamicOnce.Do(func() {
+		config, err := GetKubeConfig(inCluster)
+		if err != nil {
+			panic(err)
+		}
+		dynamicClient, err = dynamic.NewForConfig(config)
+		if err != nil {
+			panic(err)
+		}
+	})
+
+	return dynamicClient, nil
+}
@@ -0,0 +1,461 @@
+// Package controller reconciles GPUMount resources through the AddGPU and RemoveGPU services of gpu mounter workers
+package controller
+
+import (
+	gpu_mount "GPUMounter/pkg/api/gpu-mount"
+	"GPUMounter/pkg/api/v1alpha1"
+	"GPUMounter/pkg/util/gpu"
+	. "GPUMounter/pkg/util/log"
+	"GPUMounter/pkg/util/worker"
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	k8s_error "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const (
+	resyncPeriod = 5 * time.Minute
+	// failed mounts are retried with exponential backoff between minRetryDelay and maxRetryDelay
+	minRetryDelay = 5 * time.Second
+	maxRetryDelay = 5 * time.Minute
+	// callTimeout is the deadline of a call to worker
+	callTimeout = 5 * time.Minute
+)
+
+// Mounter mounts and unmounts gpus through the gpu mounter worker on the node
+type Mounter interface {
+	AddGPU(ctx context.Context, nodeName string, request *gpu_mount.AddGPURequest) (*gpu_mount.AddGPUResponse, error)
+	RemoveGPU(ctx context.Context, nodeName string, request *gpu_mount.RemoveGPURequest) (*gpu_mount.RemoveGPUResponse, error)
+	GetPodGPUs(ctx context.Context, nodeName string, request *gpu_mount.GetPodGPUsRequest) (*gpu_mount.GetPodGPUsResponse, error)
+}
+
+// WorkerMounter calls the services of gpu mounter worker on the node
+type WorkerMounter struct{}
+
+func (WorkerMounter) AddGPU(ctx context.Context, nodeName string, request *gpu_mount.AddGPURequest) (*gpu_mount.AddGPUResponse, error) {
+	conn, err := worker.GetConn(nodeName)
+	if err != nil {
+		return nil, err
+	}
+	return gpu_mount.NewAddGPUServiceClient(conn).AddGPU(ctx, request)
+}
+
+func (WorkerMounter) RemoveGPU(ctx context.Context, nodeName string, request *gpu_mount.RemoveGPURequest) (*gpu_mount.RemoveGPUResponse, error) {
+	conn, err := worker.GetConn(nodeName)
+	if err != nil {
+		return nil, err
+	}
+	return gpu_mount.NewRemoveGPUServiceClient(conn).RemoveGPU(ctx, request)
+}
+
+func (WorkerMounter) GetPodGPUs(ctx context.Context, nodeName string, request *gpu_mount.GetPodGPUsRequest) (*gpu_mount.GetPodGPUsResponse, error) {
+	conn, err := worker.GetConn(nodeName)
+	if err != nil {
+		return nil, err
+	}
+	return gpu_mount.NewGPUQueryServiceClient(conn).GetPodGPUs(ctx, request)
+}
+
+type GPUMountController struct {
+	kubeClient    kubernetes.Interface
+	dynamicClient dynamic.Interface
+	informer      cache.SharedIndexInformer
+	queue         workqueue.RateLimitingInterface
+	mounter       Mounter
+}
+
+func NewGPUMountController(kubeClient kubernetes.Interface, dynamicClient dynamic.Interface, mounter Mounter) *GPUMountController {
+	factory := dynamicinformer.NewDynamicSharedInformerFactory(dynamicClient, resyncPeriod)
+	controller := &GPUMountController{
+		kubeClient:    kubeClient,
+		dynamicClient: dynamicClient,
+		informer:      factory.ForResource(v1alpha1.GroupVersionResource).Informer(),
+		queue: workqueue.NewNamedRateLimitingQueue(
+			workqueue.NewItemExponentialFailureRateLimiter(minRetryDelay, maxRetryDelay), "gpumount"),
+		mounter: mounter,
+	}
+	controller.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    controller.enqueue,
+		UpdateFunc: func(_, obj interface{}) { controller.enqueue(obj) },
+		DeleteFunc: controller.enqueue,
+	})
+	return controller
+}
+
+func (controller *GPUMountController) enqueue(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		Logger.Error("Failed to get key of GPUMount")
+		Logger.Error(err)
+		return
+	}
+	controller.queue.Add(key)
+}
+
+// Run starts the workers reconciling GPUMounts until stopCh is closed
+func (controller *GPUMountController) Run(workers int, stopCh <-chan struct{}) {
+	defer utilruntime.HandleCrash()
+	defer controller.queue.ShutDown()
+
+	Logger.Info("Starting GPUMount controller")
+	go controller.informer.Run(stopCh)
+	if !cache.WaitForCacheSync(stopCh, controller.informer.HasSynced) {
+		Logger.Error("Failed to sync GPUMount cache")
+		return
+	}
+	for i := 0; i < workers; i++ {
+		go wait.Until(controller.runWorker, time.Second, stopCh)
+	}
+	<-stopCh
+	Logger.Info("Stopping GPUMount controller")
+}
+
+func (controller *GPUMountController) runWorker() {
+	for controller.processNextItem() {
+	}
+}
+
+func (controller *GPUMountController) processNextItem() bool {
+	key, quit := controller.queue.Get()
+	if quit {
+		return false
+	}
+	defer controller.queue.Done(key)
+
+	if err := controller.sync(key.(string)); err != nil {
+		Logger.Error("Failed to sync GPUMount: ", key, ", retrying")
+		Logger.Error(err)
+		controller.queue.AddRateLimited(key)
+		return true
+	}
+	controller.queue.Forget(key)
+	return true
+}
+
+func (controller *GPUMountController) sync(key string) error {
+	obj, exists, err := controller.informer.GetIndexer().GetByKey(key)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return nil
+	}
+	content := obj.(*unstructured.Unstructured).UnstructuredContent()
+	gpuMount := &v1alpha1.GPUMount{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(content, gpuMount); err != nil {
+		Logger.Error("Invalid GPUMount: ", key)
+		return err
+	}
+	original := &v1alpha1.GPUMount{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(content, original); err != nil {
+		return err
+	}
+
+	if gpuMount.DeletionTimestamp != nil {
+		return controller.finalize(gpuMount)
+	}
+	if !hasFinalizer(gpuMount) {
+		// the update triggers another sync
+		gpuMount.Finalizers = append(gpuMount.Finalizers, v1alpha1.Finalizer)
+		return controller.update(gpuMount)
+	}
+
+	syncErr := controller.reconcile(gpuMount)
+	gpuMount.Status.ObservedGeneration = gpuMount.Generation
+	if !reflect.DeepEqual(original.Status, gpuMount.Status) {
+		if err := controller.updateStatus(gpuMount); err != nil {
+			return err
+		}
+	}
+	return syncErr
+}
+
+// reconcile mounts or unmounts gpus until the mounted gpus match the spec, the status is updated in place
+func (controller *GPUMountController) reconcile(gpuMount *v1alpha1.GPUMount) error {
+	status := &gpuMount.Status
+	pod, err := controller.getPod(gpuMount)
+	if err != nil {
+		return err
+	}
+	if pod == nil || pod.Spec.NodeName == "" || pod.Status.Phase != corev1.PodRunning {
+		reason := "PodNotRunning"
+		if pod == nil {
+			// gpus are released with the pod
+			reason = "PodNotFound"
+			status.NodeName = ""
+			status.PodUID = ""
+			status.UUIDs = nil
+			status.SlavePods = nil
+		}
+		status.Phase = v1alpha1.GPUMountPending
+		setReady(status, corev1.ConditionFalse, reason, "Pod: "+gpuMount.Spec.PodName+" is not running")
+		return errors.New(reason)
+	}
+	if status.PodUID != "" && status.PodUID != string(pod.UID) {
+		// the pod is recreated, gpus were released with the old pod
+		Logger.Info("Pod: ", pod.Name, " Namespace: ", pod.Namespace, " is recreated, mounting gpus again")
+		status.UUIDs = nil
+		status.SlavePods = nil
+	}
+	status.PodUID = string(pod.UID)
+	status.NodeName = pod.Spec.NodeName
+
+	// the worker is the record of mounted gpus, the status may be stale if the last update failed
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+	uuids, slavePods, err := controller.getMountedGPUs(ctx, pod, status.UUIDs)
+	if err != nil {
+		status.Phase = v1alpha1.GPUMountFailed
+		setReady(status, corev1.ConditionFalse, "InternalError", err.Error())
+		return err
+	}
+	status.UUIDs = uuids
+	status.SlavePods = slavePods
+
+	current := len(status.UUIDs)
+	desired := int(gpuMount.Spec.GPUNum)
+	switch {
+	case current < desired && gpuMount.Spec.IsEntireMount && current > 0:
+		status.Phase = v1alpha1.GPUMountFailed
+		setReady(status, corev1.ConditionFalse, "EntireMountResize", "Entire mount can not be resized, recreate the GPUMount instead")
+		// retrying does not help
+		return nil
+	case current < desired:
+		resp, err := controller.mounter.AddGPU(ctx, pod.Spec.NodeName, &gpu_mount.AddGPURequest{
+			PodName:       pod.Name,
+			Namespace:     pod.Namespace,
+			GpuNum:        int32(desired - current),
+			IsEntireMount: gpuMount.Spec.IsEntireMount,
+			ContainerName: gpuMount.Spec.Container,
+			AllContainers: gpuMount.Spec.AllContainers,
+		})
+		if err != nil {
+			status.Phase = v1alpha1.GPUMountFailed
+			setReady(status, corev1.ConditionFalse, "InternalError", err.Error())
+			return err
+		}
+		if resp.AddGpuResult != gpu_mount.AddGPUResponse_Success {
+			status.Phase = v1alpha1.GPUMountFailed
+			message := "Failed to add gpu for Pod: " + pod.Name
+			if resp.Message != "" {
+				message += ": " + resp.Message
+			}
+			setReady(status, corev1.ConditionFalse, resp.AddGpuResult.String(), message)
+			return errors.New(resp.AddGpuResult.String())
+		}
+		var uuids, slavePods []string
+		uuids = append(uuids, status.UUIDs...)
+		slavePods = append(slavePods, status.SlavePods...)
+		for _, gpuDev := range resp.Gpus {
+			uuids = append(uuids, gpuDev.Uuid)
+			slavePods = append(slavePods, gpuDev.SlavePodName)
+		}
+		status.UUIDs = uuids
+		status.SlavePods = slavePods
+		Logger.Info("Successfully mount ", len(resp.Gpus), " gpus for GPUMount: ", gpuMount.Namespace, "/", gpuMount.Name)
+	case current > desired:
+		resp, err := controller.mounter.RemoveGPU(ctx, pod.Spec.NodeName, &gpu_mount.RemoveGPURequest{
+			PodName:       pod.Name,
+			Namespace:     pod.Namespace,
+			Uuids:         status.UUIDs[desired:],
+			Force:         gpuMount.Spec.ForceUnmount,
+			ContainerName: gpuMount.Spec.Container,
+			AllContainers: gpuMount.Spec.AllContainers,
+		})
+		if err != nil {
+			status.Phase = v1alpha1.GPUMountFailed
+			setReady(status, corev1.ConditionFalse, "InternalError", err.Error())
+			return err
+		}
+		if !isRemoved(resp.RemoveGpuResult) {
+			status.Phase = v1alpha1.GPUMountFailed
+			setReady(status, corev1.ConditionFalse, resp.RemoveGpuResult.String(), "Failed to remove gpu from Pod: "+pod.Name)
+			return errors.New(resp.RemoveGpuResult.String())
+		}
+		status.UUIDs = append([]string{}, status.UUIDs[:desired]...)
+		status.SlavePods = append([]string{}, status.SlavePods[:desired]...)
+		Logger.Info("Successfully unmount ", current-desired, " gpus for GPUMount: ", gpuMount.Namespace, "/", gpuMount.Name)
+	}
+
+	status.Phase = v1alpha1.GPUMountMounted
+	setReady(status, corev1.ConditionTrue, "Mounted", "")
+	return nil
+}
+
+// finalize unmounts the gpus of the deleted GPUMount and removes its finalizer
+func (controller *GPUMountController) finalize(gpuMount *v1alpha1.GPUMount) error {
+	if !hasFinalizer(gpuMount) {
+		return nil
+	}
+	if gpuMount.Status.PodUID != "" {
+		pod, err := controller.getPod(gpuMount)
+		if err != nil {
+			return err
+		}
+		// gpus are released with the pod if the pod is gone or recreated
+		if pod != nil && string(pod.UID) == gpuMount.Status.PodUID {
+			ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+			defer cancel()
+			uuids, _, err := controller.getMountedGPUs(ctx, pod, gpuMount.Status.UUIDs)
+			if err == nil && len(uuids) > 0 {
+				var resp *gpu_mount.RemoveGPUResponse
+				resp, err = controller.mounter.RemoveGPU(ctx, pod.Spec.NodeName, &gpu_mount.RemoveGPURequest{
+					PodName:       pod.Name,
+					Namespace:     pod.Namespace,
+					Uuids:         uuids,
+					Force:         gpuMount.Spec.ForceUnmount,
+					ContainerName: gpuMount.Spec.Container,
+					AllContainers: gpuMount.Spec.AllContainers,
+				})
+				if err == nil && !isRemoved(resp.RemoveGpuResult) {
+					err = errors.New(resp.RemoveGpuResult.String())
+				}
+			}
+			if err != nil {
+				gpuMount.Status.Phase = v1alpha1.GPUMountUnmounting
+				setReady(&gpuMount.Status, corev1.ConditionFalse, "UnmountFailed", err.Error())
+				if updateErr := controller.updateStatus(gpuMount); updateErr != nil {
+					Logger.Error("Failed to update status of GPUMount: ", gpuMount.Namespace, "/", gpuMount.Name)
+					Logger.Error(updateErr)
+				}
+				return err
+			}
+			Logger.Info("Successfully unmount gpus of deleted GPUMount: ", gpuMount.Namespace, "/", gpuMount.Name)
+		}
+	}
+
+	var finalizers []string
+	for _, finalizer := range gpuMount.Finalizers {
+		if finalizer != v1alpha1.Finalizer {
+			finalizers = append(finalizers, finalizer)
+		}
+	}
+	gpuMount.Finalizers = finalizers
+	return controller.update(gpuMount)
+}
+
+// getPod returns the target pod of the GPUMount, nil if the pod does not exist
+func (controller *GPUMountController) getPod(gpuMount *v1alpha1.GPUMount) (*corev1.Pod, error) {
+	pod, err := controller.kubeClient.CoreV1().Pods(gpuMount.Namespace).Get(context.TODO(), gpuMount.Spec.PodName, metav1.GetOptions{})
+	if err != nil {
+		if k8s_error.IsNotFound(err) {
+			return nil, nil
+		}
+		Logger.Error("Failed to get Pod: ", gpuMount.Spec.PodName, " Namespace: ", gpuMount.Namespace)
+		return nil, err
+	}
+	return pod, nil
+}
+
+// getMountedGPUs returns the gpus the worker has hot-mounted to the pod and their slave pods,
+// in the order of known, gpus missing from known come last. The gpus the pod is started with have no slave pod
+// and are not counted.
+func (controller *GPUMountController) getMountedGPUs(ctx context.Context, pod *corev1.Pod, known []string) ([]string, []string, error) {
+	resp, err := controller.mounter.GetPodGPUs(ctx, pod.Spec.NodeName, &gpu_mount.GetPodGPUsRequest{
+		PodName:   pod.Name,
+		Namespace: pod.Namespace,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.GetPodGpusResult != gpu_mount.GetPodGPUsResponse_Success {
+		return nil, nil, errors.New(resp.GetPodGpusResult.String())
+	}
+	order := make(map[string]int, len(known))
+	for i, uuid := range known {
+		order[uuid] = i
+	}
+	var gpus []*gpu_mount.GPUDevice
+	for _, gpuDev := range resp.Gpus {
+		if gpuDev.SlavePodName != "" || gpuDev.MountType == string(gpu.SharedMount) {
+			gpus = append(gpus, gpuDev)
+		}
+	}
+	sort.SliceStable(gpus, func(i, j int) bool {
+		indexI, okI := order[gpus[i].Uuid]
+		indexJ, okJ := order[gpus[j].Uuid]
+		if okI && okJ {
+			return indexI < indexJ
+		}
+		return okI && !okJ
+	})
+	var uuids, slavePods []string
+	for _, gpuDev := range gpus {
+		uuids = append(uuids, gpuDev.Uuid)
+		slavePods = append(slavePods, gpuDev.SlavePodName)
+	}
+	return uuids, slavePods, nil
+}
+
+func (controller *GPUMountController) update(gpuMount *v1alpha1.GPUMount) error {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(gpuMount)
+	if err != nil {
+		return err
+	}
+	_, err = controller.dynamicClient.Resource(v1alpha1.GroupVersionResource).Namespace(gpuMount.Namespace).
+		Update(context.TODO(), &unstructured.Unstructured{Object: content}, metav1.UpdateOptions{})
+	return err
+}
+
+// updateStatus writes the status to the latest GPUMount, the status is owned by the controller only
+func (controller *GPUMountController) updateStatus(gpuMount *v1alpha1.GPUMount) error {
+	status, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&gpuMount.Status)
+	if err != nil {
+		return err
+	}
+	client := controller.dynamicClient.Resource(v1alpha1.GroupVersionResource).Namespace(gpuMount.Namespace)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest, err := client.Get(context.TODO(), gpuMount.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		latest.Object["status"] = status
+		_, err = client.UpdateStatus(context.TODO(), latest, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+func hasFinalizer(gpuMount *v1alpha1.GPUMount) bool {
+	for _, finalizer := range gpuMount.Finalizers {
+		if finalizer == v1alpha1.Finalizer {
+			return true
+		}
+	}
+	return false
+}
+
+// isRemoved reports whether the gpus are no longer mounted after the remove gpu result,
+// the gpus are read from the worker so GPUNotFound is a failure to be retried with the gpus read again
+func isRemoved(result gpu_mount.RemoveGPUResponse_RemoveGPUResult) bool {
+	switch result {
+	case gpu_mount.RemoveGPUResponse_Success, gpu_mount.RemoveGPUResponse_PodNotFound:
+		return true
+	default:
+		return false
+	}
+}
+
+func setReady(status *v1alpha1.GPUMountStatus, conditionStatus corev1.ConditionStatus, reason string, message string) {
+	status.SetCondition(v1alpha1.GPUMountCondition{
+		Type:               v1alpha1.GPUMountReady,
+		Status:             conditionStatus,
+		Reason:             reason,
+		Message:            message,
+		LastTransitionTime: metav1.Now(),
+	})
+}
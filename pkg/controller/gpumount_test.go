@@ -0,0 +1,285 @@
+package controller
+
+import (
+	gpu_mount "GPUMounter/pkg/api/gpu-mount"
+	"GPUMounter/pkg/api/v1alpha1"
+	. "GPUMounter/pkg/util/log"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8s_testing "k8s.io/client-go/testing"
+)
+
+type fakeMounter struct {
+	addRequests    []*gpu_mount.AddGPURequest
+	removeRequests []*gpu_mount.RemoveGPURequest
+	addResult      gpu_mount.AddGPUResponse_AddGPUResult
+	removeResult   gpu_mount.RemoveGPUResponse_RemoveGPUResult
+	// mounted is the gpus of the pod on the worker
+	mounted []*gpu_mount.GPUDevice
+}
+
+func (mounter *fakeMounter) AddGPU(_ context.Context, _ string, request *gpu_mount.AddGPURequest) (*gpu_mount.AddGPUResponse, error) {
+	mounter.addRequests = append(mounter.addRequests, request)
+	if mounter.addResult != gpu_mount.AddGPUResponse_Success {
+		return &gpu_mount.AddGPUResponse{AddGpuResult: mounter.addResult}, nil
+	}
+	resp := &gpu_mount.AddGPUResponse{AddGpuResult: gpu_mount.AddGPUResponse_Success}
+	for i := 0; i < int(request.GpuNum); i++ {
+		resp.Gpus = append(resp.Gpus, &gpu_mount.GPUDevice{
+			Uuid:         fmt.Sprintf("GPU-%d-%d", len(mounter.addRequests), i),
+			SlavePodName: "slave-pod",
+		})
+	}
+	mounter.mounted = append(mounter.mounted, resp.Gpus...)
+	return resp, nil
+}
+
+func (mounter *fakeMounter) RemoveGPU(_ context.Context, _ string, request *gpu_mount.RemoveGPURequest) (*gpu_mount.RemoveGPUResponse, error) {
+	mounter.removeRequests = append(mounter.removeRequests, request)
+	if mounter.removeResult == gpu_mount.RemoveGPUResponse_Success {
+		removed := make(map[string]bool)
+		for _, uuid := range request.Uuids {
+			removed[uuid] = true
+		}
+		var mounted []*gpu_mount.GPUDevice
+		for _, gpuDev := range mounter.mounted {
+			if !removed[gpuDev.Uuid] {
+				mounted = append(mounted, gpuDev)
+			}
+		}
+		mounter.mounted = mounted
+	}
+	return &gpu_mount.RemoveGPUResponse{RemoveGpuResult: mounter.removeResult}, nil
+}
+
+func (mounter *fakeMounter) GetPodGPUs(_ context.Context, _ string, _ *gpu_mount.GetPodGPUsRequest) (*gpu_mount.GetPodGPUsResponse, error) {
+	return &gpu_mount.GetPodGPUsResponse{GetPodGpusResult: gpu_mount.GetPodGPUsResponse_Success, Gpus: mounter.mounted}, nil
+}
+
+func newTestController(t *testing.T, gpuMount *v1alpha1.GPUMount, mounter Mounter) *GPUMountController {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "gpu-pod", Namespace: "default", UID: "pod-uid"},
+		Spec:       corev1.PodSpec{NodeName: "gpu-node"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(gpuMount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := &unstructured.Unstructured{Object: content}
+	controller := NewGPUMountController(kubefake.NewSimpleClientset(pod), dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), obj), mounter)
+	if err := controller.informer.GetIndexer().Add(obj); err != nil {
+		t.Fatal(err)
+	}
+	return controller
+}
+
+// refresh puts the latest GPUMount from api server into the informer cache, and returns it
+func refresh(t *testing.T, controller *GPUMountController) *v1alpha1.GPUMount {
+	obj, err := controller.dynamicClient.Resource(v1alpha1.GroupVersionResource).Namespace("default").Get(context.TODO(), "gpu-mount", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := controller.informer.GetIndexer().Update(obj); err != nil {
+		t.Fatal(err)
+	}
+	gpuMount := &v1alpha1.GPUMount{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, gpuMount); err != nil {
+		t.Fatal(err)
+	}
+	return gpuMount
+}
+
+func newGPUMount(gpuNum int32) *v1alpha1.GPUMount {
+	return &v1alpha1.GPUMount{
+		TypeMeta:   metav1.TypeMeta{APIVersion: v1alpha1.SchemeGroupVersion.String(), Kind: v1alpha1.Kind},
+		ObjectMeta: metav1.ObjectMeta{Name: "gpu-mount", Namespace: "default"},
+		Spec:       v1alpha1.GPUMountSpec{PodName: "gpu-pod", GPUNum: gpuNum},
+	}
+}
+
+func TestMain(m *testing.M) {
+	dir, err := ioutil.TempDir("", "controller")
+	if err != nil {
+		panic(err)
+	}
+	InitLogger(dir+"/", "log")
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestSyncMountAndResize(t *testing.T) {
+	mounter := &fakeMounter{}
+	controller := newTestController(t, newGPUMount(2), mounter)
+
+	// first sync adds the finalizer
+	if err := controller.sync("default/gpu-mount"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gpuMount := refresh(t, controller); !hasFinalizer(gpuMount) {
+		t.Fatalf("finalizer is not added")
+	}
+
+	if err := controller.sync("default/gpu-mount"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	gpuMount := refresh(t, controller)
+	if gpuMount.Status.Phase != v1alpha1.GPUMountMounted || len(gpuMount.Status.UUIDs) != 2 || len(gpuMount.Status.SlavePods) != 2 {
+		t.Fatalf("unexpected status after mounting: %+v", gpuMount.Status)
+	}
+	if gpuMount.Status.NodeName != "gpu-node" || gpuMount.Status.PodUID != "pod-uid" {
+		t.Errorf("unexpected pod in status: %+v", gpuMount.Status)
+	}
+	if ready := gpuMount.Status.GetCondition(v1alpha1.GPUMountReady); ready == nil || ready.Status != corev1.ConditionTrue {
+		t.Errorf("GPUMount should be ready: %+v", ready)
+	}
+
+	// synced GPUMount does not call worker again
+	if err := controller.sync("default/gpu-mount"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(mounter.addRequests) != 1 {
+		t.Errorf("expected 1 add request, got %d", len(mounter.addRequests))
+	}
+
+	// shrink to 1 gpu
+	gpuMount.Spec.GPUNum = 1
+	content, _ := runtime.DefaultUnstructuredConverter.ToUnstructured(gpuMount)
+	if _, err := controller.dynamicClient.Resource(v1alpha1.GroupVersionResource).Namespace("default").Update(context.TODO(), &unstructured.Unstructured{Object: content}, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	removed := gpuMount.Status.UUIDs[1]
+	refresh(t, controller)
+	if err := controller.sync("default/gpu-mount"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(mounter.removeRequests) != 1 || len(mounter.removeRequests[0].Uuids) != 1 || mounter.removeRequests[0].Uuids[0] != removed {
+		t.Fatalf("unexpected remove requests: %+v", mounter.removeRequests)
+	}
+	if gpuMount := refresh(t, controller); len(gpuMount.Status.UUIDs) != 1 {
+		t.Errorf("unexpected status after shrinking: %+v", gpuMount.Status)
+	}
+}
+
+func TestSyncStatusUpdateFailed(t *testing.T) {
+	mounter := &fakeMounter{}
+	gpuMount := newGPUMount(2)
+	gpuMount.Finalizers = []string{v1alpha1.Finalizer}
+	controller := newTestController(t, gpuMount, mounter)
+	failed := false
+	controller.dynamicClient.(*dynamicfake.FakeDynamicClient).PrependReactor("update", "*", func(action k8s_testing.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "status" || failed {
+			return false, nil, nil
+		}
+		failed = true
+		return true, nil, errors.New("status update failed")
+	})
+
+	if err := controller.sync("default/gpu-mount"); err == nil {
+		t.Fatalf("failed status update should be retried")
+	}
+	// the informer cache still has the stale status
+	if err := controller.sync("default/gpu-mount"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(mounter.addRequests) != 1 {
+		t.Fatalf("expected 1 add request, got %d", len(mounter.addRequests))
+	}
+	if gpuMount := refresh(t, controller); len(gpuMount.Status.UUIDs) != 2 {
+		t.Errorf("unexpected status after retry: %+v", gpuMount.Status)
+	}
+}
+
+func TestSyncPodWithNativeGPU(t *testing.T) {
+	// the pod is started with a gpu from the device plugin, which has no slave pod
+	mounter := &fakeMounter{mounted: []*gpu_mount.GPUDevice{{Uuid: "GPU-native"}}}
+	gpuMount := newGPUMount(1)
+	gpuMount.Finalizers = []string{v1alpha1.Finalizer}
+	controller := newTestController(t, gpuMount, mounter)
+
+	if err := controller.sync("default/gpu-mount"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(mounter.addRequests) != 1 {
+		t.Fatalf("expected 1 add request, got %d", len(mounter.addRequests))
+	}
+	gpuMount = refresh(t, controller)
+	if len(gpuMount.Status.UUIDs) != 1 || gpuMount.Status.UUIDs[0] == "GPU-native" {
+		t.Fatalf("native gpu should not be counted: %+v", gpuMount.Status)
+	}
+
+	// the worker does not find the hot-mounted gpu, the GPUMount is not finalized
+	mounter.removeResult = gpu_mount.RemoveGPUResponse_GPUNotFound
+	if err := controller.finalize(gpuMount); err == nil {
+		t.Fatalf("gpu not found should be retried")
+	}
+	if len(mounter.removeRequests) != 1 || len(mounter.removeRequests[0].Uuids) != 1 || mounter.removeRequests[0].Uuids[0] != gpuMount.Status.UUIDs[0] {
+		t.Errorf("unexpected remove requests: %+v", mounter.removeRequests)
+	}
+	if gpuMount := refresh(t, controller); !hasFinalizer(gpuMount) {
+		t.Errorf("finalizer should be kept until gpus are unmounted")
+	}
+}
+
+func TestSyncMountFailed(t *testing.T) {
+	mounter := &fakeMounter{addResult: gpu_mount.AddGPUResponse_InsufficientGPU}
+	gpuMount := newGPUMount(1)
+	gpuMount.Finalizers = []string{v1alpha1.Finalizer}
+	controller := newTestController(t, gpuMount, mounter)
+
+	if err := controller.sync("default/gpu-mount"); err == nil {
+		t.Fatalf("failed mount should be retried")
+	}
+	gpuMount = refresh(t, controller)
+	ready := gpuMount.Status.GetCondition(v1alpha1.GPUMountReady)
+	if gpuMount.Status.Phase != v1alpha1.GPUMountFailed || ready == nil || ready.Reason != "InsufficientGPU" {
+		t.Errorf("unexpected status after failed mount: %+v", gpuMount.Status)
+	}
+}
+
+func TestFinalize(t *testing.T) {
+	mounter := &fakeMounter{removeResult: gpu_mount.RemoveGPUResponse_GPUBusy}
+	gpuMount := newGPUMount(1)
+	gpuMount.Finalizers = []string{v1alpha1.Finalizer}
+	now := metav1.Now()
+	gpuMount.DeletionTimestamp = &now
+	gpuMount.Status = v1alpha1.GPUMountStatus{
+		Phase:     v1alpha1.GPUMountMounted,
+		PodUID:    "pod-uid",
+		UUIDs:     []string{"GPU-a"},
+		SlavePods: []string{"slave-pod"},
+	}
+	mounter.mounted = []*gpu_mount.GPUDevice{{Uuid: "GPU-a", SlavePodName: "slave-pod"}}
+	controller := newTestController(t, gpuMount, mounter)
+
+	if err := controller.sync("default/gpu-mount"); err == nil {
+		t.Fatalf("busy gpu should be retried")
+	}
+	gpuMount = refresh(t, controller)
+	if !hasFinalizer(gpuMount) || gpuMount.Status.Phase != v1alpha1.GPUMountUnmounting {
+		t.Fatalf("finalizer should be kept until gpus are unmounted: %+v", gpuMount)
+	}
+
+	mounter.removeResult = gpu_mount.RemoveGPUResponse_Success
+	if err := controller.sync("default/gpu-mount"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gpuMount := refresh(t, controller); hasFinalizer(gpuMount) {
+		t.Errorf("finalizer should be removed after gpus are unmounted")
+	}
+	if len(mounter.removeRequests) != 2 || mounter.removeRequests[1].Uuids[0] != "GPU-a" {
+		t.Errorf("unexpected remove requests: %+v", mounter.removeRequests)
+	}
+}
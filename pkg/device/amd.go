@@ -0,0 +1,278 @@
+package device
+
+import (
+	"GPUMounter/pkg/util/gpu"
+	. "GPUMounter/pkg/util/log"
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	AMD_KFD_DEVICE_NAME           = "kfd"
+	AMD_KFD_DEVICE_FILE           = "/dev/kfd"
+	AMD_DRI_MAJOR_NUMBER          = 226
+	AMD_RENDER_DEVICE_FILE_PREFIX = "/dev/dri/renderD"
+	AMD_PCI_VENDOR_ID             = "0x1002"
+)
+
+var (
+	// DRMSysfsPath lists the render nodes of the gpus
+	DRMSysfsPath = "/sys/class/drm"
+	// PCISysfsPath reports the utilization and memory of the gpus by their pci address
+	PCISysfsPath = "/sys/bus/pci/devices"
+	// KFDSysfsPath reports the gpus known to the kfd driver and the processes using them
+	KFDSysfsPath = "/sys/class/kfd/kfd"
+)
+
+// AMDDevice describes how an amd gpu is accessed through ROCm, the render node of the gpu
+// together with /dev/kfd shared by all gpus
+type AMDDevice struct {
+	KFDMajorNumber int
+	// GPUID identifies the gpu in the kfd topology and process accounting
+	GPUID int
+}
+
+// amdBackend accesses the amd gpus through sysfs, the uuid of a gpu is its pci address reported by the ROCm device plugin
+type amdBackend struct{}
+
+func (amdBackend) ResourceName() string {
+	return gpu.AMDResourceName
+}
+
+func (amdBackend) IsResource(resourceName string) bool {
+	return resourceName == gpu.AMDResourceName
+}
+
+// New returns the amd gpu of the render node minor number, the kfd details are left empty if they can not be read,
+// and read again when the gpu is used
+func (amdBackend) New(minorNumber int, uuid string) *GPU {
+	amd := &AMDDevice{}
+	if major, err := GetKFDMajorNumber(); err != nil {
+		Logger.Warn("Failed to get major number of ", AMD_KFD_DEVICE_NAME, ": ", err)
+	} else {
+		amd.KFDMajorNumber = major
+	}
+	if gpuID, err := GetKFDGPUID(minorNumber); err != nil {
+		Logger.Warn("Failed to get kfd gpu id of render node: ", minorNumber, ": ", err)
+	} else {
+		amd.GPUID = gpuID
+	}
+	return newAMD(minorNumber, uuid, amd)
+}
+
+func newAMD(minorNumber int, uuid string, amd *AMDDevice) *GPU {
+	return &GPU{
+		Vendor:         VendorAMD,
+		MinorNumber:    minorNumber,
+		DeviceFilePath: AMD_RENDER_DEVICE_FILE_PREFIX + strconv.Itoa(minorNumber),
+		UUID:           uuid,
+		State:          GPU_FREE_STATE,
+		AMD:            amd,
+	}
+}
+
+// Discover lists the render nodes of amd gpus
+func (amdBackend) Discover() ([]*GPU, error) {
+	kfdMajor, err := GetKFDMajorNumber()
+	if err != nil {
+		Logger.Error("Failed to get major number of ", AMD_KFD_DEVICE_NAME)
+		return nil, err
+	}
+	renderNodes, err := filepath.Glob(filepath.Join(DRMSysfsPath, "renderD*"))
+	if err != nil {
+		return nil, err
+	}
+	var gpus []*GPU
+	for _, renderNode := range renderNodes {
+		vendor, err := readSysfsString(filepath.Join(renderNode, "device", "vendor"))
+		if err != nil {
+			Logger.Error("Failed to get vendor of render node: ", renderNode)
+			return nil, err
+		}
+		if vendor != AMD_PCI_VENDOR_ID {
+			continue
+		}
+		minorNumber, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(renderNode), "renderD"))
+		if err != nil {
+			Logger.Error("Invalid render node: ", renderNode)
+			return nil, err
+		}
+		pciDevice, err := filepath.EvalSymlinks(filepath.Join(renderNode, "device"))
+		if err != nil {
+			Logger.Error("Failed to get pci device of render node: ", renderNode)
+			return nil, err
+		}
+		gpuID, err := GetKFDGPUID(minorNumber)
+		if err != nil {
+			Logger.Error("Failed to get kfd gpu id of render node: ", renderNode)
+			return nil, err
+		}
+		gpuDev := newAMD(minorNumber, filepath.Base(pciDevice), &AMDDevice{KFDMajorNumber: kfdMajor, GPUID: gpuID})
+		Logger.Info("AMD GPU: ", gpuDev.UUID, " render node: ", gpuDev.DeviceFilePath)
+		gpus = append(gpus, gpuDev)
+	}
+	Logger.Info("GPU Num: ", len(gpus))
+	return gpus, nil
+}
+
+// DeviceFiles returns /dev/kfd followed by the render node of the gpu,
+// the major number of /dev/kfd is 0 if kfd is not loaded, which CheckHealth refuses
+func (amdBackend) DeviceFiles(gpu *GPU) []DeviceFile {
+	kfdMajor, _ := kfdMajorOf(gpu)
+	return []DeviceFile{
+		{Path: AMD_KFD_DEVICE_FILE, MajorNumber: kfdMajor, MinorNumber: 0},
+		{Path: gpu.DeviceFilePath, MajorNumber: AMD_DRI_MAJOR_NUMBER, MinorNumber: gpu.MinorNumber},
+	}
+}
+
+// GetRunningProcess lists the processes with vram allocated on the gpu, as accounted by kfd
+func (amdBackend) GetRunningProcess(gpu *GPU) ([]*Process, error) {
+	gpuID, err := kfdGPUIDOf(gpu)
+	if err != nil {
+		return nil, err
+	}
+	vramFiles, err := filepath.Glob(filepath.Join(KFDSysfsPath, "proc", "*", "vram_"+strconv.Itoa(gpuID)))
+	if err != nil {
+		return nil, err
+	}
+	var processes []*Process
+	for _, vramFile := range vramFiles {
+		pid, err := strconv.ParseUint(filepath.Base(filepath.Dir(vramFile)), 10, 32)
+		if err != nil {
+			continue
+		}
+		vram, err := readSysfsUint(vramFile)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// the process exited meanwhile
+				continue
+			}
+			Logger.Error("Failed to get vram usage of process: ", pid, " on GPU: ", gpu.DeviceFilePath)
+			return nil, err
+		}
+		processes = append(processes, &Process{Pid: uint(pid), UsedGPUMemory: vram})
+	}
+	return processes, nil
+}
+
+// CheckHealth checks that the render node is registered, and kfd is loaded and knows the gpu
+func (amdBackend) CheckHealth(gpu *GPU) error {
+	if _, err := os.Stat(filepath.Join(DRMSysfsPath, filepath.Base(gpu.DeviceFilePath))); err != nil {
+		return err
+	}
+	if _, err := kfdMajorOf(gpu); err != nil {
+		return err
+	}
+	_, err := GetKFDGPUID(gpu.MinorNumber)
+	return err
+}
+
+func (amdBackend) GetUtilization(gpu *GPU) (uint, error) {
+	busy, err := readSysfsUint(filepath.Join(PCISysfsPath, gpu.UUID, "gpu_busy_percent"))
+	if err != nil {
+		Logger.Error("Failed to get utilization of GPU: ", gpu.DeviceFilePath)
+		return 0, err
+	}
+	return uint(busy), nil
+}
+
+func (amdBackend) GetTotalMemoryMiB(gpu *GPU) (uint64, error) {
+	total, err := readSysfsUint(filepath.Join(PCISysfsPath, gpu.UUID, "mem_info_vram_total"))
+	if err != nil {
+		Logger.Error("Failed to get memory info of GPU: ", gpu.DeviceFilePath)
+		return 0, err
+	}
+	return total / MiB, nil
+}
+
+// GetKFDMajorNumber returns the major number of /dev/kfd
+func GetKFDMajorNumber() (int, error) {
+	f, err := os.Open(ProcDevicesPath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return parseMajorNumber(f, AMD_KFD_DEVICE_NAME)
+}
+
+// GetKFDGPUID returns the gpu id of the kfd topology node with the render node minor number
+func GetKFDGPUID(renderMinor int) (int, error) {
+	nodes, err := filepath.Glob(filepath.Join(KFDSysfsPath, "topology", "nodes", "*"))
+	if err != nil {
+		return 0, err
+	}
+	for _, node := range nodes {
+		minor, err := readKFDProperty(filepath.Join(node, "properties"), "drm_render_minor")
+		if err != nil {
+			return 0, err
+		}
+		if minor != renderMinor {
+			continue
+		}
+		gpuID, err := readSysfsUint(filepath.Join(node, "gpu_id"))
+		if err != nil {
+			return 0, err
+		}
+		return int(gpuID), nil
+	}
+	return 0, fmt.Errorf("no kfd topology node with render minor %d", renderMinor)
+}
+
+func kfdMajorOf(gpu *GPU) (int, error) {
+	if gpu.AMD != nil && gpu.AMD.KFDMajorNumber != 0 {
+		return gpu.AMD.KFDMajorNumber, nil
+	}
+	return GetKFDMajorNumber()
+}
+
+func kfdGPUIDOf(gpu *GPU) (int, error) {
+	if gpu.AMD != nil && gpu.AMD.GPUID != 0 {
+		return gpu.AMD.GPUID, nil
+	}
+	return GetKFDGPUID(gpu.MinorNumber)
+}
+
+// readKFDProperty returns the value of the property in a kfd topology properties file, -1 if missing
+func readKFDProperty(path string, name string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == name {
+			return strconv.Atoi(fields[1])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return -1, nil
+}
+
+func readSysfsString(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+func readSysfsUint(path string) (uint64, error) {
+	content, err := readSysfsString(path)
+	if err != nil {
+		return 0, err
+	}
+	if content == "" {
+		return 0, errors.New("empty " + path)
+	}
+	return strconv.ParseUint(content, 10, 64)
+}
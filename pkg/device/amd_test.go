@@ -0,0 +1,148 @@
+package device
+
+import (
+	. "GPUMounter/pkg/util/log"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := ioutil.TempDir("", "device")
+	if err != nil {
+		panic(err)
+	}
+	InitLogger(dir+"/", "log")
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// fakeAMDSysfs lays out the sysfs of an amd gpu at render minor 128 and a non amd render node
+func fakeAMDSysfs(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "sysfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := []*string{&DRMSysfsPath, &PCISysfsPath, &KFDSysfsPath, &ProcDevicesPath}
+	saved := make([]string, len(paths))
+	for i, path := range paths {
+		saved[i] = *path
+	}
+	DRMSysfsPath = filepath.Join(dir, "class", "drm")
+	PCISysfsPath = filepath.Join(dir, "bus", "pci", "devices")
+	KFDSysfsPath = filepath.Join(dir, "class", "kfd", "kfd")
+	ProcDevicesPath = filepath.Join(dir, "devices")
+
+	write := func(path string, content string) {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("devices", "Character devices:\n226 drm\n238 kfd\n")
+	write("bus/pci/devices/0000:03:00.0/vendor", "0x1002\n")
+	write("bus/pci/devices/0000:03:00.0/gpu_busy_percent", "42\n")
+	write("bus/pci/devices/0000:03:00.0/mem_info_vram_total", "68702699520\n")
+	write("bus/pci/devices/0000:05:00.0/vendor", "0x1a03\n")
+	write("class/kfd/kfd/topology/nodes/0/properties", "cpu_cores_count 64\ndrm_render_minor 0\n")
+	write("class/kfd/kfd/topology/nodes/0/gpu_id", "0\n")
+	write("class/kfd/kfd/topology/nodes/1/properties", "simd_count 440\ndrm_render_minor 128\n")
+	write("class/kfd/kfd/topology/nodes/1/gpu_id", "53322\n")
+	write("class/kfd/kfd/proc/2331/vram_53322", "1073741824\n")
+	write("class/kfd/kfd/proc/2401/vram_1234", "1073741824\n")
+	for renderNode, pciAddress := range map[string]string{"renderD128": "0000:03:00.0", "renderD129": "0000:05:00.0"} {
+		if err := os.MkdirAll(filepath.Join(DRMSysfsPath, renderNode), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(filepath.Join(PCISysfsPath, pciAddress), filepath.Join(DRMSysfsPath, renderNode, "device")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return func() {
+		for i, path := range paths {
+			*path = saved[i]
+		}
+		os.RemoveAll(dir)
+	}
+}
+
+func TestAMDDiscover(t *testing.T) {
+	defer fakeAMDSysfs(t)()
+
+	gpus, err := amdBackend{}.Discover()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gpus) != 1 {
+		t.Fatalf("expected 1 amd gpu, got %d", len(gpus))
+	}
+	gpuDev := gpus[0]
+	if gpuDev.UUID != "0000:03:00.0" || gpuDev.MinorNumber != 128 || gpuDev.DeviceFilePath != "/dev/dri/renderD128" {
+		t.Errorf("unexpected gpu: %s", gpuDev)
+	}
+	expected := []DeviceFile{
+		{Path: AMD_KFD_DEVICE_FILE, MajorNumber: 238, MinorNumber: 0},
+		{Path: "/dev/dri/renderD128", MajorNumber: AMD_DRI_MAJOR_NUMBER, MinorNumber: 128},
+	}
+	if files := gpuDev.DeviceFiles(); !reflect.DeepEqual(files, expected) {
+		t.Errorf("expected %v, got %v", expected, files)
+	}
+
+	processes, err := gpuDev.GetRunningProcess()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(processes) != 1 || processes[0].Pid != 2331 || processes[0].UsedGPUMemory != 1073741824 {
+		t.Errorf("expected process 2331 using 1GiB, got %v", processes)
+	}
+	if utilization, err := gpuDev.GetUtilization(); err != nil || utilization != 42 {
+		t.Errorf("expected utilization 42, got %d, %v", utilization, err)
+	}
+	if total, err := gpuDev.GetTotalMemoryMiB(); err != nil || total != 65520 {
+		t.Errorf("expected 65520 MiB, got %d, %v", total, err)
+	}
+}
+
+func TestGetKFDGPUID(t *testing.T) {
+	defer fakeAMDSysfs(t)()
+
+	if gpuID, err := GetKFDGPUID(128); err != nil || gpuID != 53322 {
+		t.Errorf("expected gpu id 53322, got %d, %v", gpuID, err)
+	}
+	if _, err := GetKFDGPUID(130); err == nil {
+		t.Errorf("expected error for unknown render minor")
+	}
+}
+
+func TestAMDMissingKFD(t *testing.T) {
+	defer fakeAMDSysfs(t)()
+	// the kfd module is not loaded
+	if err := ioutil.WriteFile(ProcDevicesPath, []byte("Character devices:\n226 drm\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	gpuDev := amdBackend{}.New(128, "0000:03:00.0")
+	if gpuDev.AMD.KFDMajorNumber != 0 {
+		t.Fatalf("expected no kfd major number, got %d", gpuDev.AMD.KFDMajorNumber)
+	}
+	if err := gpuDev.CheckHealth(); err == nil {
+		t.Errorf("gpu without kfd should be unhealthy")
+	}
+
+	// kfd is loaded later
+	if err := ioutil.WriteFile(ProcDevicesPath, []byte("Character devices:\n226 drm\n238 kfd\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := gpuDev.CheckHealth(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if files := gpuDev.DeviceFiles(); files[0].MajorNumber != 238 {
+		t.Errorf("expected kfd major 238, got %d", files[0].MajorNumber)
+	}
+}
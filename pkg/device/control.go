@@ -0,0 +1,34 @@
+package device
+
+import (
+	. "GPUMounter/pkg/util/log"
+	"os"
+)
+
+const (
+	NVIDIA_CTL_DEVICE_FILE       = "/dev/nvidiactl"
+	NVIDIA_CTL_MINOR_NUMBER      = 255
+	NVIDIA_UVM_DEVICE_NAME       = "nvidia-uvm"
+	NVIDIA_UVM_DEVICE_FILE       = "/dev/nvidia-uvm"
+	NVIDIA_UVM_TOOLS_DEVICE_FILE = "/dev/nvidia-uvm-tools"
+)
+
+// GetControlDeviceFiles returns the device files of the driver needed besides the gpu device files,
+// the uvm device files are left out if the nvidia-uvm module is not loaded
+func GetControlDeviceFiles() ([]DeviceFile, error) {
+	files := []DeviceFile{{Path: NVIDIA_CTL_DEVICE_FILE, MajorNumber: DEFAULT_NVIDA_MAJOR_NUMBER, MinorNumber: NVIDIA_CTL_MINOR_NUMBER}}
+	f, err := os.Open(ProcDevicesPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	uvmMajor, err := parseMajorNumber(f, NVIDIA_UVM_DEVICE_NAME)
+	if err != nil {
+		Logger.Warn("Module ", NVIDIA_UVM_DEVICE_NAME, " is not loaded, skip its device files: ", err)
+		return files, nil
+	}
+	return append(files,
+		DeviceFile{Path: NVIDIA_UVM_DEVICE_FILE, MajorNumber: uvmMajor, MinorNumber: 0},
+		DeviceFile{Path: NVIDIA_UVM_TOOLS_DEVICE_FILE, MajorNumber: uvmMajor, MinorNumber: 1},
+	), nil
+}
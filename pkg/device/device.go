@@ -0,0 +1,150 @@
+package device
+
+import (
+	. "GPUMounter/pkg/util/log"
+	"encoding/json"
+	"os"
+)
+
+// Vendor of the gpus on the node, set by env GPU_VENDOR
+type Vendor string
+
+const (
+	VendorNvidia Vendor = "nvidia"
+	VendorAMD    Vendor = "amd"
+)
+
+// GetVendor returns the vendor set by env GPU_VENDOR, nvidia by default
+func GetVendor() Vendor {
+	if vendor := Vendor(os.Getenv("GPU_VENDOR")); vendor == VendorAMD {
+		return VendorAMD
+	}
+	return VendorNvidia
+}
+
+// Device is a gpu whose device files can be mounted into containers
+type Device interface {
+	// GetUUID identifies the gpu by the device id the device plugin reports
+	GetUUID() string
+	// DeviceFiles returns the device files needed to access the gpu
+	DeviceFiles() []DeviceFile
+	// GetRunningProcess returns the processes of all containers running on the gpu
+	GetRunningProcess() ([]*Process, error)
+	// CheckHealth returns an error if the gpu can not be used
+	CheckHealth() error
+}
+
+// Process is a process running on a gpu, Pid is in the host PID namespace
+type Process struct {
+	Pid           uint
+	UsedGPUMemory uint64
+}
+
+// Backend is the vendor specific part of the gpus
+type Backend interface {
+	// ResourceName is the extended resource of a gpu requested by slave pods
+	ResourceName() string
+	// IsResource reports whether the extended resource is a gpu of the vendor
+	IsResource(resourceName string) bool
+	// Discover lists the gpus on the node
+	Discover() ([]*GPU, error)
+	New(minorNumber int, uuid string) *GPU
+	DeviceFiles(gpu *GPU) []DeviceFile
+	GetRunningProcess(gpu *GPU) ([]*Process, error)
+	CheckHealth(gpu *GPU) error
+	GetUtilization(gpu *GPU) (uint, error)
+	GetTotalMemoryMiB(gpu *GPU) (uint64, error)
+}
+
+var backends = map[Vendor]Backend{
+	VendorNvidia: nvidiaBackend{},
+	VendorAMD:    amdBackend{},
+}
+
+// GetBackend returns the backend of the gpus on the node
+func GetBackend() Backend {
+	return backends[GetVendor()]
+}
+
+type GPU struct {
+	// Vendor is nvidia if empty
+	Vendor         Vendor `json:",omitempty"`
+	MinorNumber    int
+	DeviceFilePath string
+	UUID           string
+	State          Devicestate
+	PodName        string
+	Namespace      string
+	// MIG is nil unless the gpu is a MIG device
+	MIG *MIGDevice `json:",omitempty"`
+	// AMD is nil unless the gpu is an amd gpu
+	AMD *AMDDevice `json:",omitempty"`
+}
+type Devicestate string
+
+const (
+	GPU_FREE_STATE      = "GPU_FREE_STATE"
+	GPU_ALLOCATED_STATE = "GPU_ALLOCATED_STATE"
+)
+
+// New returns the gpu of the node's vendor
+func New(minorNumber int, uuid string) *GPU {
+	return GetBackend().New(minorNumber, uuid)
+}
+
+const (
+	DEFAULT_CGROUP_PERMISSION      = "rw"
+	DEFAULT_DEVICE_FILE_PERMISSION = "666"
+	// MiB is the bytes of a MiB
+	MiB = 1024 * 1024
+)
+
+func (gpu *GPU) String() string {
+	out, err := json.Marshal(gpu)
+	if err != nil {
+		Logger.Error("Failed to parse gpu object to json")
+		return "Failed to parse gpu object to json"
+	}
+	return string(out)
+}
+
+func (gpu *GPU) ResetState() {
+	gpu.PodName = ""
+	gpu.Namespace = ""
+	gpu.State = GPU_FREE_STATE
+}
+
+func (gpu *GPU) backend() Backend {
+	if backend, ok := backends[gpu.Vendor]; ok {
+		return backend
+	}
+	return backends[VendorNvidia]
+}
+
+func (gpu *GPU) GetUUID() string {
+	return gpu.UUID
+}
+
+// DeviceFiles returns the device files needed to access the gpu, the gpu device file comes first
+// and the device file checked for the gpu being mounted comes last
+func (gpu *GPU) DeviceFiles() []DeviceFile {
+	return gpu.backend().DeviceFiles(gpu)
+}
+
+func (gpu *GPU) GetRunningProcess() ([]*Process, error) {
+	return gpu.backend().GetRunningProcess(gpu)
+}
+
+func (gpu *GPU) CheckHealth() error {
+	return gpu.backend().CheckHealth(gpu)
+}
+
+// GetUtilization returns the percent of time over the past sample period during which kernels were running on the gpu
+func (gpu *GPU) GetUtilization() (uint, error) {
+	return gpu.backend().GetUtilization(gpu)
+}
+
+// GetTotalMemoryMiB returns the total memory of the gpu in MiB
+func (gpu *GPU) GetTotalMemoryMiB() (uint64, error) {
+	return gpu.backend().GetTotalMemoryMiB(gpu)
+}
@@ -0,0 +1,176 @@
+package device
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	NVIDIA_CAPS_DEVICE_NAME        = "nvidia-caps"
+	NVIDIA_CAPS_DEVICE_FILE_PREFIX = "/dev/nvidia-caps/nvidia-cap"
+)
+
+var (
+	// NvidiaCapsProcPath is where the driver reports the minor numbers of the MIG capability device files
+	NvidiaCapsProcPath = "/proc/driver/nvidia/capabilities"
+	// ProcDevicesPath reports the major numbers of the character devices
+	ProcDevicesPath = "/proc/devices"
+)
+
+// MIGDevice describes the MIG compute instance a GPU stands for, access to it is granted by
+// the device file of its parent gpu together with the capability device files of its gpu instance and compute instance
+type MIGDevice struct {
+	ParentUUID        string
+	Profile           string
+	GPUInstanceID     int
+	ComputeInstanceID int
+	CapsMajorNumber   int
+	GICapMinorNumber  int
+	CICapMinorNumber  int
+}
+
+// DeviceFile is a character device file to create in the container and allow in its devices cgroup
+type DeviceFile struct {
+	Path        string
+	MajorNumber int
+	MinorNumber int
+}
+
+// NewMIG returns the MIG device with the uuid created on the gpu of minorNumber
+func NewMIG(minorNumber int, uuid string, mig *MIGDevice) *GPU {
+	gpu := nvidiaBackend{}.New(minorNumber, uuid)
+	gpu.MIG = mig
+	return gpu
+}
+
+// IsMIG reports whether the gpu is a MIG device
+func (gpu *GPU) IsMIG() bool {
+	return gpu.MIG != nil
+}
+
+// GICapDeviceFile returns the capability device file of the gpu instance, shared by its compute instances
+func (mig *MIGDevice) GICapDeviceFile() DeviceFile {
+	return CapDeviceFile(mig.CapsMajorNumber, mig.GICapMinorNumber)
+}
+
+// CICapDeviceFile returns the capability device file of the compute instance
+func (mig *MIGDevice) CICapDeviceFile() DeviceFile {
+	return CapDeviceFile(mig.CapsMajorNumber, mig.CICapMinorNumber)
+}
+
+func CapDeviceFile(major, minor int) DeviceFile {
+	return DeviceFile{Path: NVIDIA_CAPS_DEVICE_FILE_PREFIX + strconv.Itoa(minor), MajorNumber: major, MinorNumber: minor}
+}
+
+// ParseMIGProfile returns the profile in the name of a MIG device, e.g. 1g.5gb of "NVIDIA A100-SXM4-40GB MIG 1g.5gb"
+func ParseMIGProfile(name string) string {
+	idx := strings.LastIndex(name, "MIG ")
+	if idx < 0 {
+		return ""
+	}
+	return strings.TrimSpace(name[idx+len("MIG "):])
+}
+
+// GetCapsMajorNumber returns the major number of the nvidia-caps character devices
+func GetCapsMajorNumber() (int, error) {
+	f, err := os.Open(ProcDevicesPath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return parseMajorNumber(f, NVIDIA_CAPS_DEVICE_NAME)
+}
+
+// parseMajorNumber finds the major number of the character device in the content of /proc/devices
+func parseMajorNumber(r io.Reader, name string) (int, error) {
+	scanner := bufio.NewScanner(r)
+	charDevices := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasSuffix(line, ":") {
+			charDevices = line == "Character devices:"
+			continue
+		}
+		fields := strings.Fields(line)
+		if charDevices && len(fields) == 2 && fields[1] == name {
+			return strconv.Atoi(fields[0])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("no character device %s", name)
+}
+
+// GetGICapMinorNumber returns the minor number of the capability device file of the gpu instance
+func GetGICapMinorNumber(minorNumber, gpuInstanceID int) (int, error) {
+	return readCapMinorNumber(filepath.Join(giCapPath(minorNumber, gpuInstanceID), "access"))
+}
+
+// GetCICapMinorNumber returns the minor number of the capability device file of the compute instance
+func GetCICapMinorNumber(minorNumber, gpuInstanceID, computeInstanceID int) (int, error) {
+	return readCapMinorNumber(filepath.Join(giCapPath(minorNumber, gpuInstanceID), "ci"+strconv.Itoa(computeInstanceID), "access"))
+}
+
+// GetGICapMinorNumbers returns the minor numbers of the capability device files of all gpu instances on the gpu
+func GetGICapMinorNumbers(minorNumber int) ([]int, error) {
+	return globCapMinorNumbers(filepath.Join(NvidiaCapsProcPath, "gpu"+strconv.Itoa(minorNumber), "mig", "gi*", "access"))
+}
+
+// GetCICapMinorNumbers returns the minor numbers of the capability device files of all compute instances in the gpu instance
+func GetCICapMinorNumbers(minorNumber, gpuInstanceID int) ([]int, error) {
+	return globCapMinorNumbers(filepath.Join(giCapPath(minorNumber, gpuInstanceID), "ci*", "access"))
+}
+
+func giCapPath(minorNumber, gpuInstanceID int) string {
+	return filepath.Join(NvidiaCapsProcPath, "gpu"+strconv.Itoa(minorNumber), "mig", "gi"+strconv.Itoa(gpuInstanceID))
+}
+
+func globCapMinorNumbers(pattern string) ([]int, error) {
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	var minors []int
+	for _, path := range paths {
+		minor, err := readCapMinorNumber(path)
+		if err != nil {
+			return nil, err
+		}
+		minors = append(minors, minor)
+	}
+	return minors, nil
+}
+
+func readCapMinorNumber(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	minor, err := parseCapMinorNumber(f)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", path, err)
+	}
+	return minor, nil
+}
+
+// parseCapMinorNumber finds the DeviceFileMinor entry in a capability access file
+func parseCapMinorNumber(r io.Reader) (int, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), ":", 2)
+		if len(fields) == 2 && strings.TrimSpace(fields[0]) == "DeviceFileMinor" {
+			return strconv.Atoi(strings.TrimSpace(fields[1]))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("no DeviceFileMinor entry")
+}
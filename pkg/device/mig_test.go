@@ -0,0 +1,103 @@
+package device
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseMajorNumber(t *testing.T) {
+	devices := `Character devices:
+  1 mem
+195 nvidia-frontend
+236 nvidia-caps
+237 nvidia-uvm
+
+Block devices:
+236 nvidia-caps
+`
+	major, err := parseMajorNumber(strings.NewReader(devices), NVIDIA_CAPS_DEVICE_NAME)
+	if err != nil || major != 236 {
+		t.Errorf("expected major 236, got %d, %v", major, err)
+	}
+	if _, err := parseMajorNumber(strings.NewReader("Block devices:\n236 nvidia-caps\n"), NVIDIA_CAPS_DEVICE_NAME); err == nil {
+		t.Errorf("expected error for block device only")
+	}
+}
+
+func TestParseMIGProfile(t *testing.T) {
+	for name, expected := range map[string]string{
+		"NVIDIA A100-SXM4-40GB MIG 1g.5gb":  "1g.5gb",
+		"NVIDIA H100 80GB HBM3 MIG 1g.10gb": "1g.10gb",
+		"A100-SXM4-40GB MIG 1c.3g.20gb":     "1c.3g.20gb",
+		"Tesla V100-SXM2-16GB":              "",
+	} {
+		if profile := ParseMIGProfile(name); profile != expected {
+			t.Errorf("%q: expected %q, got %q", name, expected, profile)
+		}
+	}
+}
+
+func TestCapMinorNumbers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "capabilities")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(path string) { NvidiaCapsProcPath = path }(NvidiaCapsProcPath)
+	NvidiaCapsProcPath = dir
+
+	writeAccess := func(path string, minor string) {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+		content := "DeviceFileMinor: " + minor + "\nDeviceFileMode: 292\nDeviceFileModify: 1\n"
+		if err := ioutil.WriteFile(filepath.Join(path, "access"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeAccess("gpu0/mig/gi1", "12")
+	writeAccess("gpu0/mig/gi1/ci0", "13")
+	writeAccess("gpu0/mig/gi1/ci1", "14")
+	writeAccess("gpu0/mig/gi2", "21")
+
+	if minor, err := GetGICapMinorNumber(0, 1); err != nil || minor != 12 {
+		t.Errorf("expected gi minor 12, got %d, %v", minor, err)
+	}
+	if minor, err := GetCICapMinorNumber(0, 1, 1); err != nil || minor != 14 {
+		t.Errorf("expected ci minor 14, got %d, %v", minor, err)
+	}
+	if _, err := GetCICapMinorNumber(0, 2, 0); err == nil {
+		t.Errorf("expected error for missing compute instance")
+	}
+	giMinors, err := GetGICapMinorNumbers(0)
+	sort.Ints(giMinors)
+	if err != nil || !reflect.DeepEqual(giMinors, []int{12, 21}) {
+		t.Errorf("expected gi minors [12 21], got %v, %v", giMinors, err)
+	}
+	ciMinors, err := GetCICapMinorNumbers(0, 1)
+	sort.Ints(ciMinors)
+	if err != nil || !reflect.DeepEqual(ciMinors, []int{13, 14}) {
+		t.Errorf("expected ci minors [13 14], got %v, %v", ciMinors, err)
+	}
+}
+
+func TestDeviceFiles(t *testing.T) {
+	if files := New(3, "GPU-test").DeviceFiles(); len(files) != 1 || files[0].Path != "/dev/nvidia3" || files[0].MajorNumber != DEFAULT_NVIDA_MAJOR_NUMBER {
+		t.Errorf("unexpected device files of gpu: %+v", files)
+	}
+	mig := NewMIG(3, "MIG-test", &MIGDevice{CapsMajorNumber: 236, GICapMinorNumber: 30, CICapMinorNumber: 31})
+	expected := []DeviceFile{
+		{Path: "/dev/nvidia3", MajorNumber: DEFAULT_NVIDA_MAJOR_NUMBER, MinorNumber: 3},
+		{Path: "/dev/nvidia-caps/nvidia-cap30", MajorNumber: 236, MinorNumber: 30},
+		{Path: "/dev/nvidia-caps/nvidia-cap31", MajorNumber: 236, MinorNumber: 31},
+	}
+	if files := mig.DeviceFiles(); !reflect.DeepEqual(files, expected) {
+		t.Errorf("expected %+v, got %+v", expected, files)
+	}
+}
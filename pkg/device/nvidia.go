@@ -1,29 +1,46 @@
 package device
 
 import (
+	"GPUMounter/pkg/util/gpu"
 	"GPUMounter/pkg/util/gpu/collector/nvml"
 	. "GPUMounter/pkg/util/log"
-	"encoding/json"
 	"strconv"
 )
 
-type NvidiaGPU struct {
-	MinorNumber    int
-	DeviceFilePath string
-	UUID           string
-	State          Devicestate
-	PodName        string
-	Namespace      string
-}
-type Devicestate string
-
 const (
-	GPU_FREE_STATE      = "GPU_FREE_STATE"
-	GPU_ALLOCATED_STATE = "GPU_ALLOCATED_STATE"
+	DEFAULT_NVIDA_MAJOR_NUMBER = 195
+	NVIDIA_DEVICE_FILE_PREFIX  = "/dev/nvidia"
 )
 
-func New(minorNumber int, uuid string) *NvidiaGPU {
-	return &NvidiaGPU{
+// nvidiaBackend accesses the nvidia gpus through nvml
+type nvidiaBackend struct{}
+
+// openNVML returns the initialized nvml of the process, which should be shut down after use
+func openNVML() (nvml.Interface, error) {
+	lib, err := nvml.New()
+	if err != nil {
+		Logger.Error("Failed to load nvml")
+		return nil, err
+	}
+	if err := lib.Init(); err != nil {
+		Logger.Errorf("nvml error: %+v", err)
+		return nil, err
+	}
+	return lib, nil
+}
+
+func (nvidiaBackend) ResourceName() string {
+	return gpu.NvidiaResourceName
+}
+
+// IsResource reports whether the resource is a whole gpu or a MIG device
+func (nvidiaBackend) IsResource(resourceName string) bool {
+	return gpu.IsNvidiaResource(resourceName)
+}
+
+func (nvidiaBackend) New(minorNumber int, uuid string) *GPU {
+	return &GPU{
+		Vendor:         VendorNvidia,
 		MinorNumber:    minorNumber,
 		DeviceFilePath: NVIDIA_DEVICE_FILE_PREFIX + strconv.Itoa(minorNumber),
 		UUID:           uuid,
@@ -33,35 +50,135 @@ func New(minorNumber int, uuid string) *NvidiaGPU {
 	}
 }
 
-const (
-	DEFAULT_NVIDA_MAJOR_NUMBER     = 195
-	DEFAULT_CGROUP_PERMISSION      = "rw"
-	DEFAULT_DEVICE_FILE_PERMISSION = "666"
-	NVIDIA_DEVICE_FILE_PREFIX      = "/dev/nvidia"
-)
+func (backend nvidiaBackend) Discover() ([]*GPU, error) {
+	lib, err := openNVML()
+	if err != nil {
+		return nil, err
+	}
+	defer lib.Shutdown()
 
-func (gpu *NvidiaGPU) String() string {
-	out, err := json.Marshal(gpu)
+	num, err := lib.GetDeviceCount()
 	if err != nil {
-		Logger.Error("Failed to parse gpu object to json")
-		return "Failed to parse gpu object to json"
+		Logger.Error("Failed to get GPU num")
+	} else {
+		Logger.Info("GPU Num: ", num)
+	}
+
+	var gpus []*GPU
+	for i := uint(0); i < num; i++ {
+		dev, err := lib.DeviceGetHandleByIndex(i)
+		if err != nil {
+			Logger.Error("Failed to get GPU ", i)
+			return nil, err
+		}
+
+		minorNum, err := dev.DeviceGetMinorNumber()
+		if err != nil {
+			Logger.Error("Failed to get minor number of GPU ", i)
+			return nil, err
+		}
+
+		uuid, err := dev.DeviceGetUUID()
+		if err != nil {
+			Logger.Error("Failed to get uuid of GPU ", i)
+			return nil, err
+		}
+
+		migEnabled, err := dev.DeviceGetMigEnabled()
+		if err != nil {
+			Logger.Error("Failed to get MIG mode of GPU ", i)
+			return nil, err
+		}
+		if migEnabled {
+			// the device plugin exposes the MIG devices instead of the gpu
+			migDevs, err := getMIGDevices(dev, int(minorNum), uuid)
+			if err != nil {
+				Logger.Error("Failed to get MIG devices of GPU ", i)
+				return nil, err
+			}
+			gpus = append(gpus, migDevs...)
+			continue
+		}
+
+		gpus = append(gpus, backend.New(int(minorNum), uuid))
 	}
-	return string(out)
+	return gpus, nil
 }
 
-func (gpu *NvidiaGPU) ResetState() {
-	gpu.PodName = ""
-	gpu.Namespace = ""
-	gpu.State = GPU_FREE_STATE
+// getMIGDevices enumerates the compute instances created on the MIG enabled gpu
+func getMIGDevices(dev nvml.DeviceHandle, minorNum int, parentUUID string) ([]*GPU, error) {
+	capsMajor, err := GetCapsMajorNumber()
+	if err != nil {
+		Logger.Error("Failed to get major number of ", NVIDIA_CAPS_DEVICE_NAME)
+		return nil, err
+	}
+	migHandles, err := dev.DeviceGetMigDeviceHandles()
+	if err != nil {
+		return nil, err
+	}
+	var migDevs []*GPU
+	for _, migHandle := range migHandles {
+		uuid, err := migHandle.DeviceGetUUID()
+		if err != nil {
+			Logger.Error("Failed to get uuid of MIG device on GPU: ", parentUUID)
+			return nil, err
+		}
+		name, err := migHandle.DeviceGetName()
+		if err != nil {
+			Logger.Error("Failed to get name of MIG device: ", uuid)
+			return nil, err
+		}
+		gi, err := migHandle.DeviceGetGpuInstanceId()
+		if err != nil {
+			Logger.Error("Failed to get gpu instance id of MIG device: ", uuid)
+			return nil, err
+		}
+		ci, err := migHandle.DeviceGetComputeInstanceId()
+		if err != nil {
+			Logger.Error("Failed to get compute instance id of MIG device: ", uuid)
+			return nil, err
+		}
+		giMinor, err := GetGICapMinorNumber(minorNum, int(gi))
+		if err != nil {
+			Logger.Error("Failed to get capability of gpu instance: ", gi, " on GPU: ", parentUUID)
+			return nil, err
+		}
+		ciMinor, err := GetCICapMinorNumber(minorNum, int(gi), int(ci))
+		if err != nil {
+			Logger.Error("Failed to get capability of compute instance: ", ci, " on GPU: ", parentUUID)
+			return nil, err
+		}
+		migDev := NewMIG(minorNum, uuid, &MIGDevice{
+			ParentUUID:        parentUUID,
+			Profile:           ParseMIGProfile(name),
+			GPUInstanceID:     int(gi),
+			ComputeInstanceID: int(ci),
+			CapsMajorNumber:   capsMajor,
+			GICapMinorNumber:  giMinor,
+			CICapMinorNumber:  ciMinor,
+		})
+		Logger.Info("MIG device: ", uuid, " profile: ", migDev.MIG.Profile, " on GPU: ", parentUUID)
+		migDevs = append(migDevs, migDev)
+	}
+	return migDevs, nil
 }
 
-func (gpu *NvidiaGPU) GetRunningProcess() ([]*nvml.ProcessInfo, error) {
-	if err := nvml.Init(); err != nil {
-		Logger.Error("nvml error: %+v", err)
+// DeviceFiles returns the gpu device file, followed by the capabilities of the gpu instance and compute instance for MIG devices
+func (nvidiaBackend) DeviceFiles(gpu *GPU) []DeviceFile {
+	files := []DeviceFile{{Path: gpu.DeviceFilePath, MajorNumber: DEFAULT_NVIDA_MAJOR_NUMBER, MinorNumber: gpu.MinorNumber}}
+	if gpu.MIG != nil {
+		files = append(files, gpu.MIG.GICapDeviceFile(), gpu.MIG.CICapDeviceFile())
+	}
+	return files
+}
+
+func (nvidiaBackend) GetRunningProcess(gpu *GPU) ([]*Process, error) {
+	lib, err := openNVML()
+	if err != nil {
 		return nil, err
 	}
-	defer nvml.Shutdown()
-	handle, err := nvml.DeviceGetHandleByUUID(gpu.UUID)
+	defer lib.Shutdown()
+	handle, err := lib.DeviceGetHandleByUUID(gpu.UUID)
 	if err != nil {
 		Logger.Error(err)
 		return nil, err
@@ -83,5 +200,64 @@ func (gpu *NvidiaGPU) GetRunningProcess() ([]*nvml.ProcessInfo, error) {
 		Logger.Error(err)
 		return nil, err
 	}
-	return append(graphicsProcesses, computeProcesses...), nil
+	var processes []*Process
+	for _, processInfo := range append(graphicsProcesses, computeProcesses...) {
+		processes = append(processes, &Process{Pid: processInfo.Pid, UsedGPUMemory: processInfo.UsedGPUMemory})
+	}
+	return processes, nil
+}
+
+// CheckHealth checks that nvml can query the memory of the gpu, which fails after the gpu fell off the bus
+func (nvidiaBackend) CheckHealth(gpu *GPU) error {
+	lib, err := openNVML()
+	if err != nil {
+		return err
+	}
+	defer lib.Shutdown()
+	handle, err := lib.DeviceGetHandleByUUID(gpu.UUID)
+	if err != nil {
+		return err
+	}
+	_, _, err = handle.DeviceGetMemoryInfo()
+	return err
+}
+
+func (nvidiaBackend) GetUtilization(gpu *GPU) (uint, error) {
+	lib, err := openNVML()
+	if err != nil {
+		return 0, err
+	}
+	defer lib.Shutdown()
+	handle, err := lib.DeviceGetHandleByUUID(gpu.UUID)
+	if err != nil {
+		Logger.Error(err)
+		return 0, err
+	}
+	utilization, _, err := handle.DeviceGetUtilizationRates()
+	if err != nil {
+		Logger.Error("Failed to get utilization of GPU: ", gpu.DeviceFilePath)
+		Logger.Error(err)
+		return 0, err
+	}
+	return utilization, nil
+}
+
+func (nvidiaBackend) GetTotalMemoryMiB(gpu *GPU) (uint64, error) {
+	lib, err := openNVML()
+	if err != nil {
+		return 0, err
+	}
+	defer lib.Shutdown()
+	handle, err := lib.DeviceGetHandleByUUID(gpu.UUID)
+	if err != nil {
+		Logger.Error(err)
+		return 0, err
+	}
+	total, _, err := handle.DeviceGetMemoryInfo()
+	if err != nil {
+		Logger.Error("Failed to get memory info of GPU: ", gpu.DeviceFilePath)
+		Logger.Error(err)
+		return 0, err
+	}
+	return total / MiB, nil
 }
@@ -0,0 +1,121 @@
+// Package metrics defines the prometheus metrics exposed by gpu mounter master and worker
+package metrics
+
+import (
+	gpu_mount "GPUMounter/pkg/api/gpu-mount"
+	"context"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+)
+
+const namespace = "gpumounter"
+
+// phases of mounting and unmounting gpus
+const (
+	PhaseSlavePodCreate   = "slave_pod_create"
+	PhaseSlavePodSchedule = "slave_pod_schedule"
+	PhaseSlavePodDelete   = "slave_pod_delete"
+	PhaseCgroupUpdate     = "cgroup_update"
+	PhaseMknod            = "mknod"
+)
+
+// ResultError is the result of a call failed with an error instead of a result in response
+const ResultError = "Error"
+
+var (
+	// Requests counts the calls of worker services by method and result, e.g. AddGPU and InsufficientGPU
+	Requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "requests_total",
+		Help:      "Number of gpu mounter service calls by method and result.",
+	}, []string{"method", "result"})
+
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "request_duration_seconds",
+		Help:      "Duration of gpu mounter service calls by method.",
+		Buckets:   []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	}, []string{"method"})
+
+	PhaseDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "phase_duration_seconds",
+		Help:      "Duration of each phase of mounting and unmounting gpus.",
+		Buckets:   []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 120},
+	}, []string{"phase"})
+
+	MountedGPUs = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "mounted_gpus",
+		Help:      "Number of gpus mounted by gpu mounter by node and namespace of the owner pods.",
+	}, []string{"node", "namespace"})
+
+	OrphanedSlavePods = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "orphaned_slave_pods",
+		Help:      "Number of slave pods whose owner pod no longer exists by node.",
+	}, []string{"node"})
+)
+
+func init() {
+	prometheus.MustRegister(Requests, RequestDuration, PhaseDuration, MountedGPUs, OrphanedSlavePods)
+}
+
+// Handler serves the registered metrics
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObservePhase records the duration of the phase started at start
+func ObservePhase(phase string, start time.Time) {
+	PhaseDuration.WithLabelValues(phase).Observe(time.Since(start).Seconds())
+}
+
+// SetMountedGPUs replaces the mounted gpu numbers of the node by the numbers of each namespace
+func SetMountedGPUs(node string, gpuNums map[string]int) {
+	MountedGPUs.Reset()
+	for ns, gpuNum := range gpuNums {
+		MountedGPUs.WithLabelValues(node, ns).Set(float64(gpuNum))
+	}
+}
+
+// resultOf returns the result of the service call
+func resultOf(resp interface{}, err error) string {
+	if err != nil {
+		return ResultError
+	}
+	switch resp := resp.(type) {
+	case *gpu_mount.AddGPUResponse:
+		return resp.AddGpuResult.String()
+	case *gpu_mount.RemoveGPUResponse:
+		return resp.RemoveGpuResult.String()
+	}
+	return "Success"
+}
+
+func observeRequest(fullMethod string, resp interface{}, err error, start time.Time) {
+	method := path.Base(fullMethod)
+	Requests.WithLabelValues(method, resultOf(resp, err)).Inc()
+	RequestDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+// UnaryServerInterceptor records the calls served by gpu mounter worker
+func UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	observeRequest(info.FullMethod, resp, err, start)
+	return resp, err
+}
+
+// UnaryClientInterceptor records the calls from gpu mounter master to workers
+func UnaryClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	start := time.Now()
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	observeRequest(method, reply, err, start)
+	return err
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	gpu_mount "GPUMounter/pkg/api/gpu-mount"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/gpu_mount.AddGPUService/AddGPU"}
+	calls := []struct {
+		resp interface{}
+		err  error
+	}{
+		{&gpu_mount.AddGPUResponse{AddGpuResult: gpu_mount.AddGPUResponse_Success}, nil},
+		{&gpu_mount.AddGPUResponse{AddGpuResult: gpu_mount.AddGPUResponse_InsufficientGPU}, nil},
+		{&gpu_mount.AddGPUResponse{AddGpuResult: gpu_mount.AddGPUResponse_InsufficientGPU}, nil},
+		{nil, errors.New("Service Internal Error ")},
+	}
+	for _, call := range calls {
+		_, err := UnaryServerInterceptor(context.TODO(), nil, info, func(context.Context, interface{}) (interface{}, error) {
+			return call.resp, call.err
+		})
+		if err != call.err {
+			t.Errorf("expected error %v, got %v", call.err, err)
+		}
+	}
+
+	for result, expected := range map[string]float64{"Success": 1, "InsufficientGPU": 2, ResultError: 1} {
+		if count := testutil.ToFloat64(Requests.WithLabelValues("AddGPU", result)); count != expected {
+			t.Errorf("result %s: expected %v calls, got %v", result, expected, count)
+		}
+	}
+}
+
+func TestResultOf(t *testing.T) {
+	testCases := []struct {
+		resp     interface{}
+		err      error
+		expected string
+	}{
+		{&gpu_mount.RemoveGPUResponse{RemoveGpuResult: gpu_mount.RemoveGPUResponse_GPUBusy}, nil, "GPUBusy"},
+		{&gpu_mount.ListNodeGPUsResponse{}, nil, "Success"},
+		{&gpu_mount.RemoveGPUResponse{}, errors.New("failed"), ResultError},
+	}
+	for _, testCase := range testCases {
+		if result := resultOf(testCase.resp, testCase.err); result != testCase.expected {
+			t.Errorf("expected %s, got %s", testCase.expected, result)
+		}
+	}
+}
+
+func TestSetMountedGPUs(t *testing.T) {
+	SetMountedGPUs("gpu-node", map[string]int{"default": 2, "team-a": 1})
+	SetMountedGPUs("gpu-node", map[string]int{"default": 1})
+	if count := testutil.CollectAndCount(MountedGPUs); count != 1 {
+		t.Errorf("expected 1 series, got %d", count)
+	}
+	if gpuNum := testutil.ToFloat64(MountedGPUs.WithLabelValues("gpu-node", "default")); gpuNum != 1 {
+		t.Errorf("expected 1 gpu, got %v", gpuNum)
+	}
+}
@@ -0,0 +1,56 @@
+package gpu_mount
+
+import (
+	"GPUMounter/pkg/device"
+	"GPUMounter/pkg/util"
+	"GPUMounter/pkg/util/driver"
+	"GPUMounter/pkg/util/ledger"
+	. "GPUMounter/pkg/util/log"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// isBootstrapped reports whether the driver is bootstrapped into the container by a recorded mount
+func (gpuMountImpl GPUMountImpl) isBootstrapped(namespace string, podName string, containerName string) bool {
+	for _, record := range gpuMountImpl.Ledger.List() {
+		if record.Bootstrapped && record.Namespace == namespace && record.PodName == podName && record.ContainerName == containerName {
+			return true
+		}
+	}
+	return false
+}
+
+// needsBootstrap reports whether the driver has to be bootstrapped into the container before mounting gpus,
+// i.e. bootstrap is enabled for nvidia gpus and the container is started without gpus
+func needsBootstrap(pod *corev1.Pod, container corev1.ContainerStatus) (bool, error) {
+	if !driver.IsBootstrapEnabled() || device.GetVendor() != device.VendorNvidia {
+		return false, nil
+	}
+	hasControlDevices, err := util.HasControlDevices(pod, container)
+	if err != nil {
+		return false, err
+	}
+	return !hasControlDevices, nil
+}
+
+// releaseBootstrap undoes the driver bootstrap of the container once the records of its last gpus are dropped
+func (gpuMountImpl GPUMountImpl) releaseBootstrap(pod *corev1.Pod, container corev1.ContainerStatus, dropped []*ledger.Record) {
+	bootstrapped := false
+	for _, record := range dropped {
+		if record.Bootstrapped && record.ContainerName == container.Name {
+			bootstrapped = true
+		}
+	}
+	if !bootstrapped {
+		return
+	}
+	for _, record := range gpuMountImpl.Ledger.List() {
+		if record.Namespace == pod.Namespace && record.PodName == pod.Name && record.ContainerName == container.Name {
+			return
+		}
+	}
+	if err := util.UndoDriverBootstrap(pod, container); err != nil {
+		Logger.Error("Failed to undo driver bootstrap of Pod: ", pod.Name, " Namespace: ", pod.Namespace, " Container: ", container.Name)
+		Logger.Error(err)
+	}
+}
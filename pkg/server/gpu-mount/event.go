@@ -0,0 +1,88 @@
+package gpu_mount
+
+import (
+	"GPUMounter/pkg/config"
+	"GPUMounter/pkg/util"
+	"GPUMounter/pkg/util/gpu"
+	"GPUMounter/pkg/util/ledger"
+	. "GPUMounter/pkg/util/log"
+	"context"
+	"encoding/json"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// recordEvent records an event on the owner pod
+func (gpuMountImpl GPUMountImpl) recordEvent(pod *corev1.Pod, eventType string, reason string, messageFmt string, args ...interface{}) {
+	if gpuMountImpl.Recorder == nil {
+		return
+	}
+	gpuMountImpl.Recorder.Eventf(pod, eventType, reason, messageFmt, args...)
+}
+
+// mountedGPUsOf returns the gpus mounted into the pod in the records, ordered like the records
+func mountedGPUsOf(records []*ledger.Record, pod *corev1.Pod) []*gpu.MountedGPU {
+	var mountedGPUs []*gpu.MountedGPU
+	byUUID := make(map[string]*gpu.MountedGPU)
+	for _, record := range records {
+		if record.Namespace != pod.Namespace || record.PodName != pod.Name || record.PodUID != string(pod.UID) || record.State != ledger.StateMounted {
+			continue
+		}
+		mountedGPU, ok := byUUID[record.UUID]
+		if !ok {
+			mountedGPU = &gpu.MountedGPU{
+				UUID:           record.UUID,
+				SlavePod:       record.SlavePodName,
+				LeaseExpiresAt: formatLeaseExpiresAt(record.LeaseExpiresAt),
+				MemoryLimitMiB: record.MemoryLimitMiB,
+			}
+			byUUID[record.UUID] = mountedGPU
+			mountedGPUs = append(mountedGPUs, mountedGPU)
+		}
+		if !util.ContainString(mountedGPU.Containers, record.ContainerName) {
+			mountedGPU.Containers = append(mountedGPU.Containers, record.ContainerName)
+		}
+	}
+	return mountedGPUs
+}
+
+// updateMountedGPUsAnnotation sets the annotation of the hot mounted gpus on the owner pod from the ledger,
+// the annotation is removed if no gpu is mounted
+func (gpuMountImpl GPUMountImpl) updateMountedGPUsAnnotation(pod *corev1.Pod) {
+	var value interface{}
+	if mountedGPUs := mountedGPUsOf(gpuMountImpl.Ledger.List(), pod); len(mountedGPUs) != 0 {
+		data, err := json.Marshal(mountedGPUs)
+		if err != nil {
+			Logger.Error("Failed to marshal mounted gpus of Pod: ", pod.Name, " Namespace: ", pod.Namespace)
+			Logger.Error(err)
+			return
+		}
+		value = string(data)
+	}
+	if _, ok := pod.Annotations[gpu.MountedGPUsAnnotation]; !ok && value == nil {
+		return
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"uid":         pod.UID,
+			"annotations": map[string]interface{}{gpu.MountedGPUsAnnotation: value},
+		},
+	})
+	if err != nil {
+		Logger.Error(err)
+		return
+	}
+	clientset, err := config.GetClientSet()
+	if err != nil {
+		Logger.Error("Connect to k8s failed")
+		return
+	}
+	if _, err := clientset.CoreV1().Pods(pod.Namespace).Patch(context.TODO(), pod.Name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		Logger.Error("Failed to annotate mounted gpus of Pod: ", pod.Name, " Namespace: ", pod.Namespace)
+		Logger.Error(err)
+		return
+	}
+	Logger.Info("Annotated mounted gpus of Pod: ", pod.Name, " Namespace: ", pod.Namespace, ": ", value)
+}
@@ -0,0 +1,55 @@
+package gpu_mount
+
+import (
+	"GPUMounter/pkg/util/gpu"
+	"GPUMounter/pkg/util/ledger"
+	"encoding/json"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMountedGPUsOf(t *testing.T) {
+	expiresAt := time.Date(2021, 1, 1, 8, 0, 0, 0, time.UTC)
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "gpu-pod", UID: "uid-1"}}
+	records := []*ledger.Record{
+		// mounted into two containers
+		{Namespace: "default", PodName: "gpu-pod", PodUID: "uid-1", ContainerName: "trainer", UUID: "GPU-0", SlavePodName: "gpu-pod-slave-pod-a", State: ledger.StateMounted, LeaseExpiresAt: expiresAt},
+		{Namespace: "default", PodName: "gpu-pod", PodUID: "uid-1", ContainerName: "sidecar", UUID: "GPU-0", SlavePodName: "gpu-pod-slave-pod-a", State: ledger.StateMounted, LeaseExpiresAt: expiresAt},
+		{Namespace: "default", PodName: "gpu-pod", PodUID: "uid-1", ContainerName: "trainer", UUID: "GPU-1", SlavePodName: "gpu-pod-slave-pod-b", State: ledger.StateMounted},
+		// being unmounted
+		{Namespace: "default", PodName: "gpu-pod", PodUID: "uid-1", ContainerName: "trainer", UUID: "GPU-2", SlavePodName: "gpu-pod-slave-pod-c", State: ledger.StateUnmounting},
+		// the pod before recreated
+		{Namespace: "default", PodName: "gpu-pod", PodUID: "uid-0", ContainerName: "trainer", UUID: "GPU-3", SlavePodName: "gpu-pod-slave-pod-d", State: ledger.StateMounted},
+		{Namespace: "tenant", PodName: "gpu-pod", PodUID: "uid-2", ContainerName: "trainer", UUID: "GPU-4", SlavePodName: "gpu-pod-slave-pod-e", State: ledger.StateMounted},
+	}
+
+	mountedGPUs := mountedGPUsOf(records, pod)
+	if len(mountedGPUs) != 2 {
+		t.Fatalf("expected 2 mounted gpus, got %d", len(mountedGPUs))
+	}
+	if mountedGPUs[0].UUID != "GPU-0" || len(mountedGPUs[0].Containers) != 2 || mountedGPUs[0].LeaseExpiresAt != "2021-01-01T08:00:00Z" {
+		t.Errorf("unexpected mounted gpu: %+v", mountedGPUs[0])
+	}
+	if mountedGPUs[1].UUID != "GPU-1" || mountedGPUs[1].SlavePod != "gpu-pod-slave-pod-b" || mountedGPUs[1].LeaseExpiresAt != "" {
+		t.Errorf("unexpected mounted gpu: %+v", mountedGPUs[1])
+	}
+
+	// the annotation written from the records is read back by its readers
+	data, err := json.Marshal(mountedGPUs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := gpu.GetMountedGPUs(map[string]string{gpu.MountedGPUsAnnotation: string(data)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed) != 2 || parsed[0].UUID != "GPU-0" || parsed[0].Containers[1] != "sidecar" {
+		t.Errorf("unexpected parsed mounted gpus: %+v", parsed)
+	}
+	if parsed, err := gpu.GetMountedGPUs(nil); err != nil || parsed != nil {
+		t.Errorf("expected no mounted gpus without the annotation, got %+v, %v", parsed, err)
+	}
+}
@@ -0,0 +1,206 @@
+package gpu_mount
+
+import (
+	gpu_mount "GPUMounter/pkg/api/gpu-mount"
+	"GPUMounter/pkg/config"
+	"GPUMounter/pkg/util"
+	"GPUMounter/pkg/util/event"
+	"GPUMounter/pkg/util/ledger"
+	. "GPUMounter/pkg/util/log"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	k8s_error "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// DefaultIdleSampleInterval is the interval of sampling the processes on mounted gpus, can be set by IDLE_SAMPLE_INTERVAL
+const DefaultIdleSampleInterval = time.Minute
+
+// GetIdleWindow returns the idle window set by env IDLE_UNMOUNT_WINDOW, e.g. "30m", 0 if idle gpus are never unmounted
+func GetIdleWindow() (time.Duration, error) {
+	return parsePositiveDuration("IDLE_UNMOUNT_WINDOW", 0)
+}
+
+// GetIdleSampleInterval returns the sample interval set by env IDLE_SAMPLE_INTERVAL
+func GetIdleSampleInterval() (time.Duration, error) {
+	return parsePositiveDuration("IDLE_SAMPLE_INTERVAL", DefaultIdleSampleInterval)
+}
+
+func parsePositiveDuration(env string, defaultDuration time.Duration) (time.Duration, error) {
+	durationStr := os.Getenv(env)
+	if durationStr == "" {
+		return defaultDuration, nil
+	}
+	duration, err := time.ParseDuration(durationStr)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("invalid %s: %s", env, durationStr)
+	}
+	return duration, nil
+}
+
+// IdleDetector tracks when each mounted gpu was last used by a process of its owner pod
+type IdleDetector struct {
+	Window   time.Duration
+	Interval time.Duration
+
+	mu         sync.Mutex
+	lastActive map[string]time.Time
+}
+
+func NewIdleDetector(window time.Duration, interval time.Duration) *IdleDetector {
+	return &IdleDetector{
+		Window:     window,
+		Interval:   interval,
+		lastActive: make(map[string]time.Time),
+	}
+}
+
+// observe records whether the gpu is used at now, and returns how long it has been idle.
+// A gpu is considered used when it is first observed, so the window starts after mounting or worker restarting.
+func (detector *IdleDetector) observe(key string, active bool, now time.Time) time.Duration {
+	detector.mu.Lock()
+	defer detector.mu.Unlock()
+	lastActive, ok := detector.lastActive[key]
+	if active || !ok {
+		detector.lastActive[key] = now
+		return 0
+	}
+	return now.Sub(lastActive)
+}
+
+// retain forgets the gpus no longer mounted
+func (detector *IdleDetector) retain(keys map[string]bool) {
+	detector.mu.Lock()
+	defer detector.mu.Unlock()
+	for key := range detector.lastActive {
+		if !keys[key] {
+			delete(detector.lastActive, key)
+		}
+	}
+}
+
+func idleKey(owner types.NamespacedName, uuid string) string {
+	return owner.Namespace + "/" + owner.Name + "/" + uuid
+}
+
+// UnmountIdleGPUs samples the processes on the mounted gpus, and unmounts the gpus on which no process of the owner pod
+// ran for the idle window. An Event explaining the unmount is recorded on the owner pod.
+func (gpuMountImpl GPUMountImpl) UnmountIdleGPUs() {
+	detector := gpuMountImpl.Idle
+	clientset, err := config.GetClientSet()
+	if err != nil {
+		Logger.Error("Connect to k8s failed")
+		return
+	}
+	now := time.Now()
+	mounted := make(map[string]bool)
+	pods := groupByPod(gpuMountImpl.Ledger.List(), func(*ledger.Record) bool { return true })
+	for owner, mounts := range pods {
+		for _, uuid := range mounts.uuids {
+			mounted[idleKey(owner, uuid)] = true
+		}
+		pod, err := clientset.CoreV1().Pods(owner.Namespace).Get(context.TODO(), owner.Name, metav1.GetOptions{})
+		if err != nil {
+			if !k8s_error.IsNotFound(err) {
+				Logger.Error("Failed to get Pod: ", owner.Name, " Namespace: ", owner.Namespace)
+				Logger.Error(err)
+			}
+			continue
+		}
+		if string(pod.UID) != mounts.records[0].PodUID {
+			// the owner pod is recreated, its records are dropped by reconciling
+			continue
+		}
+
+		idle := &podMounts{}
+		utilization := make(map[string]uint)
+		for _, uuid := range mounts.uuids {
+			var records []*ledger.Record
+			for _, record := range mounts.records {
+				if record.UUID == uuid {
+					records = append(records, record)
+				}
+			}
+			active, sampled := samplePodGPU(pod, records)
+			if !sampled {
+				continue
+			}
+			if detector.observe(idleKey(owner, uuid), active, now) < detector.Window {
+				continue
+			}
+			idle.records = append(idle.records, records...)
+			idle.uuids = append(idle.uuids, uuid)
+			for _, record := range records {
+				if !util.ContainString(idle.containers, record.ContainerName) {
+					idle.containers = append(idle.containers, record.ContainerName)
+				}
+			}
+			if value, err := records[0].GPU().GetUtilization(); err == nil {
+				utilization[uuid] = value
+			}
+		}
+		if len(idle.uuids) == 0 {
+			continue
+		}
+		gpuMountImpl.unmountIdle(pod, owner, idle, utilization)
+	}
+	detector.retain(mounted)
+}
+
+// samplePodGPU reports whether any process of the pod runs on the gpu of the records, which are its mounts into
+// the containers of the pod. sampled is false if the processes can not be listed, e.g. a container is not running.
+func samplePodGPU(pod *corev1.Pod, records []*ledger.Record) (active bool, sampled bool) {
+	gpuDev := records[0].GPU()
+	for _, record := range records {
+		containers, err := util.GetTargetContainers(pod, record.ContainerName, false)
+		if err != nil {
+			return false, false
+		}
+		processes, err := util.GetPodGPUProcesses(pod, containers[0], gpuDev)
+		if err != nil {
+			Logger.Error("Failed to get processes on GPU: ", record.UUID, " of Pod: ", pod.Name, " Namespace: ", pod.Namespace)
+			Logger.Error(err)
+			return false, false
+		}
+		if len(processes) != 0 {
+			return true, true
+		}
+	}
+	return false, true
+}
+
+func (gpuMountImpl GPUMountImpl) unmountIdle(pod *corev1.Pod, owner types.NamespacedName, idle *podMounts, utilization map[string]uint) {
+	window := gpuMountImpl.Idle.Window
+	Logger.Info("GPU: ", strings.Join(idle.uuids, ", "), " of Pod: ", owner.Name, " Namespace: ", owner.Namespace, " is idle for ", window, ", unmounting")
+	// processes started since sampling make the gpus busy, they are kept in that case
+	for _, request := range idle.removeRequests(owner, false) {
+		resp, err := gpuMountImpl.RemoveGPU(context.TODO(), request)
+		if err != nil {
+			Logger.Error("Failed to unmount idle GPU: ", strings.Join(request.Uuids, ", "), " of Pod: ", owner.Name, " Namespace: ", owner.Namespace, " Container: ", request.ContainerName)
+			Logger.Error(err)
+			return
+		}
+		if resp.RemoveGpuResult != gpu_mount.RemoveGPUResponse_Success {
+			Logger.Warn("Failed to unmount idle GPU: ", strings.Join(request.Uuids, ", "), " of Pod: ", owner.Name, " Namespace: ", owner.Namespace, " Container: ", request.ContainerName, " result: ", resp.RemoveGpuResult.String())
+			return
+		}
+	}
+	var details []string
+	for _, uuid := range idle.uuids {
+		if value, ok := utilization[uuid]; ok {
+			details = append(details, fmt.Sprintf("%s (utilization %d%%)", uuid, value))
+		} else {
+			details = append(details, uuid)
+		}
+	}
+	Logger.Info("Successfully unmounted idle GPU: ", strings.Join(idle.uuids, ", "), " of Pod: ", owner.Name, " Namespace: ", owner.Namespace)
+	gpuMountImpl.recordEvent(pod, corev1.EventTypeNormal, event.ReasonIdleGPUUnmounted,
+		"Unmounted GPU %s, no process of the pod used it for %s", strings.Join(details, ", "), window)
+}
@@ -0,0 +1,53 @@
+package gpu_mount
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestGetIdleWindow(t *testing.T) {
+	defer os.Unsetenv("IDLE_UNMOUNT_WINDOW")
+	os.Unsetenv("IDLE_UNMOUNT_WINDOW")
+	if window, err := GetIdleWindow(); err != nil || window != 0 {
+		t.Errorf("expected idle unmount to be disabled by default, got %v %v", window, err)
+	}
+	os.Setenv("IDLE_UNMOUNT_WINDOW", "30m")
+	if window, err := GetIdleWindow(); err != nil || window != 30*time.Minute {
+		t.Errorf("expected 30m, got %v %v", window, err)
+	}
+	for _, invalid := range []string{"-1m", "0s", "forever"} {
+		os.Setenv("IDLE_UNMOUNT_WINDOW", invalid)
+		if _, err := GetIdleWindow(); err == nil {
+			t.Errorf("expected error of idle window %s", invalid)
+		}
+	}
+}
+
+func TestIdleDetector(t *testing.T) {
+	detector := NewIdleDetector(10*time.Minute, time.Minute)
+	start := time.Now()
+
+	// the window starts when the gpu is first observed
+	if idleFor := detector.observe("default/gpu-pod/GPU-0", false, start); idleFor != 0 {
+		t.Errorf("expected first observation to be active, got idle for %v", idleFor)
+	}
+	if idleFor := detector.observe("default/gpu-pod/GPU-0", false, start.Add(5*time.Minute)); idleFor != 5*time.Minute {
+		t.Errorf("expected idle for 5m, got %v", idleFor)
+	}
+	if idleFor := detector.observe("default/gpu-pod/GPU-0", true, start.Add(6*time.Minute)); idleFor != 0 {
+		t.Errorf("expected active gpu not to be idle, got %v", idleFor)
+	}
+	if idleFor := detector.observe("default/gpu-pod/GPU-0", false, start.Add(16*time.Minute)); idleFor != 10*time.Minute {
+		t.Errorf("expected idle since last active, got %v", idleFor)
+	}
+
+	detector.observe("default/gpu-pod/GPU-1", false, start)
+	detector.retain(map[string]bool{"default/gpu-pod/GPU-1": true})
+	if idleFor := detector.observe("default/gpu-pod/GPU-0", false, start.Add(20*time.Minute)); idleFor != 0 {
+		t.Errorf("expected forgotten gpu to be observed again, got idle for %v", idleFor)
+	}
+	if idleFor := detector.observe("default/gpu-pod/GPU-1", false, start.Add(20*time.Minute)); idleFor != 20*time.Minute {
+		t.Errorf("expected retained gpu to stay idle, got %v", idleFor)
+	}
+}
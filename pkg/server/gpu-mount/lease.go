@@ -0,0 +1,268 @@
+package gpu_mount
+
+import (
+	gpu_mount "GPUMounter/pkg/api/gpu-mount"
+	"GPUMounter/pkg/config"
+	"GPUMounter/pkg/util"
+	"GPUMounter/pkg/util/event"
+	"GPUMounter/pkg/util/ledger"
+	. "GPUMounter/pkg/util/log"
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	k8s_error "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func formatLeaseExpiresAt(expiresAt time.Time) string {
+	if expiresAt.IsZero() {
+		return ""
+	}
+	return expiresAt.UTC().Format(time.RFC3339)
+}
+
+// leaseExpiresAt returns the lease expiry of the gpu mounted into the pod, zero if it has no lease
+func (gpuMountImpl GPUMountImpl) leaseExpiresAt(namespace string, podName string, uuid string) time.Time {
+	var expiresAt time.Time
+	for _, record := range gpuMountImpl.Ledger.List() {
+		if record.Namespace == namespace && record.PodName == podName && record.UUID == uuid && record.LeaseExpiresAt.After(expiresAt) {
+			expiresAt = record.LeaseExpiresAt
+		}
+	}
+	return expiresAt
+}
+
+func (gpuMountImpl GPUMountImpl) ExtendLease(_ context.Context, request *gpu_mount.ExtendLeaseRequest) (*gpu_mount.ExtendLeaseResponse, error) {
+	Logger.Info("ExtendLease Service Called")
+	Logger.Info("request: ", request)
+
+	if request.LeaseSeconds <= 0 {
+		Logger.Error("Invalid lease seconds: ", request.LeaseSeconds)
+		return nil, errors.New("lease seconds should be greater than 0")
+	}
+	clientset, err := config.GetClientSet()
+	if err != nil {
+		Logger.Error("Connect to k8s failed")
+		return nil, errors.New("Service Internal Error ")
+	}
+	targetPod, err := clientset.CoreV1().Pods(request.Namespace).Get(context.TODO(), request.PodName, metav1.GetOptions{})
+	if err != nil {
+		if k8s_error.IsNotFound(err) {
+			Logger.Error("No such Pod: " + request.PodName + " in Namepsace: " + request.Namespace)
+			return &gpu_mount.ExtendLeaseResponse{ExtendLeaseResult: gpu_mount.ExtendLeaseResponse_PodNotFound}, nil
+		}
+		Logger.Error("Get Pod: " + request.PodName + " in Namespace: " + request.Namespace + " failed")
+		Logger.Error(err)
+		return nil, errors.New("Service Internal Error ")
+	}
+
+	matches := func(record *ledger.Record) bool {
+		return record.Namespace == targetPod.Namespace && record.PodName == targetPod.Name && record.PodUID == string(targetPod.UID) &&
+			record.State == ledger.StateMounted && util.ContainString(request.Uuids, record.UUID)
+	}
+	// the gpus mounted into several containers have a record per container, which share the same lease
+	expiresAt := make(map[string]time.Time)
+	for _, record := range gpuMountImpl.Ledger.List() {
+		if !matches(record) {
+			continue
+		}
+		if record.LeaseExpiresAt.IsZero() {
+			Logger.Error("GPU: ", record.UUID, " of Pod: ", record.PodName, " Namespace: ", record.Namespace, " has no lease")
+			return &gpu_mount.ExtendLeaseResponse{ExtendLeaseResult: gpu_mount.ExtendLeaseResponse_NoLease}, nil
+		}
+		if record.LeaseExpiresAt.After(expiresAt[record.UUID]) {
+			expiresAt[record.UUID] = record.LeaseExpiresAt
+		}
+	}
+	if len(request.Uuids) == 0 || len(expiresAt) != len(request.Uuids) {
+		Logger.Error("Invalid UUIDs: ", request.Uuids)
+		return &gpu_mount.ExtendLeaseResponse{ExtendLeaseResult: gpu_mount.ExtendLeaseResponse_GPUNotFound}, nil
+	}
+	now := time.Now()
+	for uuid, current := range expiresAt {
+		if current.Before(now) {
+			current = now
+		}
+		expiresAt[uuid] = current.Add(time.Duration(request.LeaseSeconds) * time.Second)
+	}
+
+	updated, err := gpuMountImpl.Ledger.Update(func(record *ledger.Record) bool {
+		if !matches(record) {
+			return false
+		}
+		record.LeaseExpiresAt = expiresAt[record.UUID]
+		return true
+	})
+	if err != nil {
+		Logger.Error("Failed to record extended leases of Pod: ", targetPod.Name, " Namespace: ", targetPod.Namespace)
+		Logger.Error(err)
+		return nil, errors.New("Service Internal Error ")
+	}
+	gpuMountImpl.updateMountedGPUsAnnotation(targetPod)
+
+	var gpus []*gpu_mount.GPUDevice
+	extended := make(map[string]bool)
+	for _, record := range updated {
+		if extended[record.UUID] {
+			continue
+		}
+		extended[record.UUID] = true
+		gpus = append(gpus, &gpu_mount.GPUDevice{
+			Uuid:           record.UUID,
+			MinorNumber:    int32(record.MinorNumber),
+			SlavePodName:   record.SlavePodName,
+			OwnerPodName:   record.PodName,
+			OwnerNamespace: record.Namespace,
+			LeaseExpiresAt: formatLeaseExpiresAt(record.LeaseExpiresAt),
+		})
+		Logger.Info("Extended lease of GPU: ", record.UUID, " of Pod: ", record.PodName, " Namespace: ", record.Namespace, " to ", record.LeaseExpiresAt)
+	}
+	return &gpu_mount.ExtendLeaseResponse{
+		ExtendLeaseResult: gpu_mount.ExtendLeaseResponse_Success,
+		Gpus:              gpus,
+	}, nil
+}
+
+// podMounts is the mounted gpus of a pod, a gpu mounted into several containers has a record per container
+type podMounts struct {
+	records      []*ledger.Record
+	uuids        []string
+	containers   []string
+	forceReclaim bool
+}
+
+// groupByPod groups the mounted records accepted by filter by their owner pod
+func groupByPod(records []*ledger.Record, filter func(record *ledger.Record) bool) map[types.NamespacedName]*podMounts {
+	pods := make(map[types.NamespacedName]*podMounts)
+	for _, record := range records {
+		if record.State != ledger.StateMounted || !filter(record) {
+			continue
+		}
+		owner := types.NamespacedName{Namespace: record.Namespace, Name: record.PodName}
+		mounts, ok := pods[owner]
+		if !ok {
+			mounts = &podMounts{}
+			pods[owner] = mounts
+		}
+		mounts.records = append(mounts.records, record)
+		if !util.ContainString(mounts.uuids, record.UUID) {
+			mounts.uuids = append(mounts.uuids, record.UUID)
+		}
+		if !util.ContainString(mounts.containers, record.ContainerName) {
+			mounts.containers = append(mounts.containers, record.ContainerName)
+		}
+		mounts.forceReclaim = mounts.forceReclaim || record.ForceReclaim
+	}
+	for _, mounts := range pods {
+		sort.Strings(mounts.uuids)
+		sort.Strings(mounts.containers)
+	}
+	return pods
+}
+
+// removeRequests removes the gpus from the containers they are mounted into, a request per container
+// with the gpus mounted into the container
+func (mounts *podMounts) removeRequests(owner types.NamespacedName, force bool) []*gpu_mount.RemoveGPURequest {
+	var requests []*gpu_mount.RemoveGPURequest
+	for _, container := range mounts.containers {
+		request := &gpu_mount.RemoveGPURequest{
+			PodName:       owner.Name,
+			Namespace:     owner.Namespace,
+			Force:         force,
+			ContainerName: container,
+		}
+		for _, uuid := range mounts.uuids {
+			for _, record := range mounts.records {
+				if record.ContainerName == container && record.UUID == uuid {
+					request.Uuids = append(request.Uuids, uuid)
+					break
+				}
+			}
+		}
+		requests = append(requests, request)
+	}
+	return requests
+}
+
+// expiredLeases groups the mounted gpus whose lease expired before now by their owner pod
+func expiredLeases(records []*ledger.Record, now time.Time) map[types.NamespacedName]*podMounts {
+	return groupByPod(records, func(record *ledger.Record) bool {
+		return !record.LeaseExpiresAt.IsZero() && !record.LeaseExpiresAt.After(now)
+	})
+}
+
+// ReclaimExpiredLeases removes the gpus whose lease expired. Busy gpus without force reclaim are kept,
+// and are removed on a later round once they are idle.
+func (gpuMountImpl GPUMountImpl) ReclaimExpiredLeases() {
+	for owner, lease := range expiredLeases(gpuMountImpl.Ledger.List(), time.Now()) {
+		Logger.Info("Lease of GPU: ", strings.Join(lease.uuids, ", "), " of Pod: ", owner.Name, " Namespace: ", owner.Namespace, " expired, reclaiming")
+		reclaimed := true
+		for _, request := range lease.removeRequests(owner, lease.forceReclaim) {
+			if !gpuMountImpl.reclaimLease(owner, lease, request) {
+				reclaimed = false
+			}
+		}
+		if reclaimed {
+			Logger.Info("Successfully reclaimed GPU: ", strings.Join(lease.uuids, ", "), " of Pod: ", owner.Name, " Namespace: ", owner.Namespace)
+			gpuMountImpl.recordLeaseExpired(owner, lease)
+		}
+	}
+}
+
+// reclaimLease removes the expired gpus of the request from its container, and reports whether they are removed
+func (gpuMountImpl GPUMountImpl) reclaimLease(owner types.NamespacedName, lease *podMounts, request *gpu_mount.RemoveGPURequest) bool {
+	uuids := strings.Join(request.Uuids, ", ")
+	resp, err := gpuMountImpl.RemoveGPU(context.TODO(), request)
+	if err != nil {
+		Logger.Error("Failed to reclaim GPU: ", uuids, " of Pod: ", owner.Name, " Namespace: ", owner.Namespace, " Container: ", request.ContainerName)
+		Logger.Error(err)
+		return false
+	}
+	switch resp.RemoveGpuResult {
+	case gpu_mount.RemoveGPUResponse_Success:
+		return true
+	case gpu_mount.RemoveGPUResponse_GPUBusy:
+		Logger.Warn("GPU: ", uuids, " of Pod: ", owner.Name, " Namespace: ", owner.Namespace, " Container: ", request.ContainerName, " is busy, reclaiming later")
+	case gpu_mount.RemoveGPUResponse_PodNotFound, gpu_mount.RemoveGPUResponse_GPUNotFound:
+		// the owner pod is deleted or recreated, release the slave pods and drop the records
+		for _, record := range lease.records {
+			if record.ContainerName == request.ContainerName {
+				gpuMountImpl.reconcileRecord(record, nil)
+			}
+		}
+	default:
+		Logger.Error("Failed to reclaim GPU: ", uuids, " of Pod: ", owner.Name, " Namespace: ", owner.Namespace, " Container: ", request.ContainerName, " result: ", resp.RemoveGpuResult.String())
+	}
+	return false
+}
+
+// recordLeaseExpired records an event explaining the reclaim on the owner pod
+func (gpuMountImpl GPUMountImpl) recordLeaseExpired(owner types.NamespacedName, lease *podMounts) {
+	if gpuMountImpl.Recorder == nil {
+		return
+	}
+	clientset, err := config.GetClientSet()
+	if err != nil {
+		Logger.Error("Connect to k8s failed")
+		return
+	}
+	pod, err := clientset.CoreV1().Pods(owner.Namespace).Get(context.TODO(), owner.Name, metav1.GetOptions{})
+	if err != nil {
+		Logger.Error("Failed to get Pod: ", owner.Name, " Namespace: ", owner.Namespace)
+		Logger.Error(err)
+		return
+	}
+	var expiresAt time.Time
+	for _, record := range lease.records {
+		if record.LeaseExpiresAt.After(expiresAt) {
+			expiresAt = record.LeaseExpiresAt
+		}
+	}
+	gpuMountImpl.recordEvent(pod, corev1.EventTypeNormal, event.ReasonGPULeaseExpired, "Reclaimed GPU %s, its lease expired at %s",
+		strings.Join(lease.uuids, ", "), formatLeaseExpiresAt(expiresAt))
+}
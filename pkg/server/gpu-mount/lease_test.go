@@ -0,0 +1,110 @@
+package gpu_mount
+
+import (
+	"GPUMounter/pkg/util/ledger"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestExpiredLeases(t *testing.T) {
+	now := time.Now()
+	records := []*ledger.Record{
+		// mounted into two containers, one of them asks for force reclaim
+		{Namespace: "default", PodName: "gpu-pod", ContainerName: "trainer", UUID: "GPU-0", State: ledger.StateMounted, LeaseExpiresAt: now.Add(-time.Minute)},
+		{Namespace: "default", PodName: "gpu-pod", ContainerName: "sidecar", UUID: "GPU-0", State: ledger.StateMounted, LeaseExpiresAt: now.Add(-time.Minute), ForceReclaim: true},
+		{Namespace: "default", PodName: "gpu-pod", ContainerName: "trainer", UUID: "GPU-1", State: ledger.StateMounted, LeaseExpiresAt: now.Add(-time.Second)},
+		// not expired yet
+		{Namespace: "default", PodName: "gpu-pod", ContainerName: "trainer", UUID: "GPU-2", State: ledger.StateMounted, LeaseExpiresAt: now.Add(time.Minute)},
+		// no lease
+		{Namespace: "default", PodName: "other-pod", ContainerName: "trainer", UUID: "GPU-3", State: ledger.StateMounted},
+		// being unmounted
+		{Namespace: "default", PodName: "other-pod", ContainerName: "trainer", UUID: "GPU-4", State: ledger.StateUnmounting, LeaseExpiresAt: now.Add(-time.Minute)},
+		{Namespace: "tenant", PodName: "gpu-pod", ContainerName: "trainer", UUID: "GPU-5", State: ledger.StateMounted, LeaseExpiresAt: now.Add(-time.Hour)},
+	}
+
+	leases := expiredLeases(records, now)
+	if len(leases) != 2 {
+		t.Fatalf("expected expired leases of 2 pods, got %d", len(leases))
+	}
+	lease := leases[types.NamespacedName{Namespace: "default", Name: "gpu-pod"}]
+	if lease == nil || len(lease.uuids) != 2 || lease.uuids[0] != "GPU-0" || lease.uuids[1] != "GPU-1" {
+		t.Fatalf("unexpected expired gpus of default/gpu-pod: %+v", lease)
+	}
+	if len(lease.containers) != 2 || !lease.forceReclaim || len(lease.records) != 3 {
+		t.Errorf("unexpected expired lease of default/gpu-pod: %+v", lease)
+	}
+	lease = leases[types.NamespacedName{Namespace: "tenant", Name: "gpu-pod"}]
+	if lease == nil || len(lease.uuids) != 1 || len(lease.containers) != 1 || lease.forceReclaim {
+		t.Errorf("unexpected expired lease of tenant/gpu-pod: %+v", lease)
+	}
+}
+
+func TestRemoveRequests(t *testing.T) {
+	now := time.Now()
+	records := []*ledger.Record{
+		{Namespace: "default", PodName: "gpu-pod", ContainerName: "trainer", UUID: "GPU-0", State: ledger.StateMounted, LeaseExpiresAt: now},
+		{Namespace: "default", PodName: "gpu-pod", ContainerName: "sidecar", UUID: "GPU-0", State: ledger.StateMounted, LeaseExpiresAt: now},
+		{Namespace: "default", PodName: "gpu-pod", ContainerName: "trainer", UUID: "GPU-1", State: ledger.StateMounted, LeaseExpiresAt: now},
+	}
+	owner := types.NamespacedName{Namespace: "default", Name: "gpu-pod"}
+
+	// each container is asked to remove only the gpus mounted into it
+	requests := expiredLeases(records, now)[owner].removeRequests(owner, true)
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(requests))
+	}
+	if requests[0].ContainerName != "sidecar" || strings.Join(requests[0].Uuids, ",") != "GPU-0" {
+		t.Errorf("unexpected request of sidecar: %+v", requests[0])
+	}
+	if requests[1].ContainerName != "trainer" || strings.Join(requests[1].Uuids, ",") != "GPU-0,GPU-1" {
+		t.Errorf("unexpected request of trainer: %+v", requests[1])
+	}
+	for _, request := range requests {
+		if request.AllContainers || !request.Force || request.PodName != "gpu-pod" {
+			t.Errorf("unexpected request: %+v", request)
+		}
+	}
+}
+
+func TestMountedContainers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	mountLedger, err := ledger.NewLedger(filepath.Join(dir, "ledger.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mountLedger.Put(
+		&ledger.Record{Namespace: "default", PodName: "gpu-pod", PodUID: "pod-uid", ContainerName: "trainer", UUID: "GPU-0", State: ledger.StateMounted},
+		&ledger.Record{Namespace: "default", PodName: "gpu-pod", PodUID: "pod-uid", ContainerName: "sidecar", UUID: "GPU-0", State: ledger.StateMounted},
+		&ledger.Record{Namespace: "default", PodName: "gpu-pod", PodUID: "pod-uid", ContainerName: "trainer", UUID: "GPU-1", State: ledger.StateMounted},
+	); err != nil {
+		t.Fatal(err)
+	}
+	gpuMountImpl := GPUMountImpl{Ledger: mountLedger}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "gpu-pod", Namespace: "default", UID: "pod-uid"}}
+	containers := []corev1.ContainerStatus{{Name: "sidecar"}, {Name: "trainer"}}
+
+	// GPU-1 is not unmounted from sidecar it was never mounted into
+	if mounted, kept := gpuMountImpl.mountedContainers(pod, containers, "GPU-1"); len(mounted) != 1 || mounted[0].Name != "trainer" || kept {
+		t.Errorf("unexpected containers of GPU-1: %+v, kept %v", mounted, kept)
+	}
+	// GPU-0 stays mounted into trainer, so its slave pod is kept
+	if mounted, kept := gpuMountImpl.mountedContainers(pod, containers[:1], "GPU-0"); len(mounted) != 1 || mounted[0].Name != "sidecar" || !kept {
+		t.Errorf("unexpected containers of GPU-0: %+v, kept %v", mounted, kept)
+	}
+	// gpus without records are unmounted from all the containers
+	if mounted, kept := gpuMountImpl.mountedContainers(pod, containers, "GPU-2"); len(mounted) != 2 || kept {
+		t.Errorf("unexpected containers of GPU-2: %+v, kept %v", mounted, kept)
+	}
+}
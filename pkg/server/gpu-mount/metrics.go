@@ -0,0 +1,72 @@
+package gpu_mount
+
+import (
+	"GPUMounter/pkg/config"
+	"GPUMounter/pkg/device"
+	"GPUMounter/pkg/metrics"
+	"GPUMounter/pkg/util/gpu"
+	. "GPUMounter/pkg/util/log"
+	"context"
+	"os"
+
+	k8s_error "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// UpdateMetrics refreshes the gauges of gpus mounted by slave pods and slave pods whose owner pod is gone
+func (gpuMountImpl GPUMountImpl) UpdateMetrics() {
+	nodeName := os.Getenv("NODE_NAME")
+	slavePodOwners, err := getSlavePodOwners(nodeName)
+	if err != nil {
+		Logger.Error("Failed to get owners of slave pods on Node: ", nodeName)
+		Logger.Error(err)
+		return
+	}
+	clientset, err := config.GetClientSet()
+	if err != nil {
+		Logger.Error("Connect to k8s failed")
+		Logger.Error(err)
+		return
+	}
+
+	ownerExists := make(map[types.NamespacedName]bool)
+	orphaned := 0
+	for slavePodName, owner := range slavePodOwners {
+		if owner.Name == "" || owner.Namespace == "" {
+			continue
+		}
+		exists, ok := ownerExists[owner]
+		if !ok {
+			_, err := clientset.CoreV1().Pods(owner.Namespace).Get(context.TODO(), owner.Name, metav1.GetOptions{})
+			if err != nil && !k8s_error.IsNotFound(err) {
+				Logger.Error("Failed to get owner Pod: ", owner.Name, " Namespace: ", owner.Namespace)
+				Logger.Error(err)
+				return
+			}
+			exists = err == nil
+			ownerExists[owner] = exists
+		}
+		if !exists {
+			Logger.Warn("Owner Pod: ", owner.Name, " Namespace: ", owner.Namespace, " of Slave Pod: ", slavePodName, " no longer exists")
+			orphaned++
+		}
+	}
+	metrics.OrphanedSlavePods.WithLabelValues(nodeName).Set(float64(orphaned))
+
+	if err := gpuMountImpl.UpdateGPUStatus(); err != nil {
+		Logger.Error("Failed to update gpu status")
+		Logger.Error(err)
+		return
+	}
+	gpuNums := make(map[string]int)
+	for _, gpuDev := range gpuMountImpl.GetGPUs() {
+		if gpuDev.State != device.GPU_ALLOCATED_STATE || gpuDev.Namespace != gpu.GPUPoolNamespace {
+			continue
+		}
+		if owner, ok := slavePodOwners[gpuDev.PodName]; ok && ownerExists[owner] {
+			gpuNums[owner.Namespace]++
+		}
+	}
+	metrics.SetMountedGPUs(nodeName, gpuNums)
+}
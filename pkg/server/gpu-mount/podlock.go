@@ -0,0 +1,40 @@
+package gpu_mount
+
+import "sync"
+
+// podLock serializes the mounts and unmounts of an owner pod, refs is guarded by podLocks.mu
+type podLock struct {
+	sync.Mutex
+	refs int
+}
+
+// podLocks serializes AddGPU and RemoveGPU of each owner pod, including the calls of lease reclaiming
+// and idle unmounting, so that they never work on the same gpus of the pod at the same time
+var podLocks = struct {
+	mu    sync.Mutex
+	locks map[string]*podLock
+}{locks: make(map[string]*podLock)}
+
+// lockPod locks the owner pod and returns the function unlocking it
+func lockPod(namespace string, podName string) func() {
+	key := namespace + "/" + podName
+	podLocks.mu.Lock()
+	lock, ok := podLocks.locks[key]
+	if !ok {
+		lock = &podLock{}
+		podLocks.locks[key] = lock
+	}
+	lock.refs++
+	podLocks.mu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		podLocks.mu.Lock()
+		defer podLocks.mu.Unlock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(podLocks.locks, key)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package gpu_mount
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockPod(t *testing.T) {
+	unlock := lockPod("default", "gpu-pod")
+	// other pods are not blocked
+	lockPod("default", "other-pod")()
+
+	locked := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		unlock := lockPod("default", "gpu-pod")
+		close(locked)
+		unlock()
+		close(done)
+	}()
+	select {
+	case <-locked:
+		t.Fatalf("pod is locked twice")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatalf("pod is not unlocked")
+	}
+
+	<-done
+	podLocks.mu.Lock()
+	defer podLocks.mu.Unlock()
+	if len(podLocks.locks) != 0 {
+		t.Errorf("unused locks are kept: %v", podLocks.locks)
+	}
+}
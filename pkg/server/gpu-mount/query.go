@@ -0,0 +1,185 @@
+package gpu_mount
+
+import (
+	gpu_mount "GPUMounter/pkg/api/gpu-mount"
+	"GPUMounter/pkg/config"
+	"GPUMounter/pkg/device"
+	"GPUMounter/pkg/util/gpu"
+	"GPUMounter/pkg/util/gpu/allocator"
+	. "GPUMounter/pkg/util/log"
+	"context"
+	"errors"
+	"os"
+	"sort"
+
+	k8s_error "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func (gpuMountImpl GPUMountImpl) ListNodeGPUs(_ context.Context, _ *gpu_mount.ListNodeGPUsRequest) (*gpu_mount.ListNodeGPUsResponse, error) {
+	Logger.Info("ListNodeGPUs Service Called")
+
+	nodeName := os.Getenv("NODE_NAME")
+	slavePodOwners, err := getSlavePodOwners(nodeName)
+	if err != nil {
+		Logger.Error("Failed to get owners of slave pods on Node: ", nodeName)
+		Logger.Error(err)
+		return nil, errors.New("Service Internal Error ")
+	}
+
+	if err := gpuMountImpl.UpdateGPUStatus(); err != nil {
+		Logger.Error("Failed to update gpu status")
+		Logger.Error(err)
+		return nil, errors.New("Service Internal Error ")
+	}
+
+	gpuList := gpuMountImpl.GetGPUs()
+	// group gpu by the owner pod, so the mount type of each owner can be figured out
+	ownerGPUs := make(map[types.NamespacedName][]*device.GPU)
+	gpuOwners := make(map[*device.GPU]types.NamespacedName)
+	for _, gpuDev := range gpuList {
+		if gpuDev.State != device.GPU_ALLOCATED_STATE {
+			continue
+		}
+		owner := types.NamespacedName{Name: gpuDev.PodName, Namespace: gpuDev.Namespace}
+		if gpuDev.Namespace == gpu.GPUPoolNamespace {
+			owner = slavePodOwners[gpuDev.PodName]
+		}
+		gpuOwners[gpuDev] = owner
+		ownerGPUs[owner] = append(ownerGPUs[owner], gpuDev)
+	}
+
+	var gpus []*gpu_mount.GPUDevice
+	for _, gpuDev := range gpuList {
+		gpuDevice := newGPUDevice(gpuDev)
+		if gpuDev.Namespace == gpu.GPUPoolNamespace && gpu.IsSharedSlavePod(gpuDev.PodName) {
+			// a shared gpu has several owner pods, see GetPodGPUs of each
+			gpuDevice.MountType = string(gpu.SharedMount)
+		} else if owner, ok := gpuOwners[gpuDev]; ok {
+			gpuDevice.OwnerPodName = owner.Name
+			gpuDevice.OwnerNamespace = owner.Namespace
+			if owner.Name != "" {
+				gpuDevice.MountType = string(allocator.MountTypeOf(owner.Name, ownerGPUs[owner]))
+				gpuDevice.LeaseExpiresAt = formatLeaseExpiresAt(gpuMountImpl.leaseExpiresAt(owner.Namespace, owner.Name, gpuDev.UUID))
+			}
+		}
+		gpus = append(gpus, gpuDevice)
+	}
+
+	return &gpu_mount.ListNodeGPUsResponse{
+		NodeName: nodeName,
+		Gpus:     gpus,
+	}, nil
+}
+
+func (gpuMountImpl GPUMountImpl) GetPodGPUs(_ context.Context, request *gpu_mount.GetPodGPUsRequest) (*gpu_mount.GetPodGPUsResponse, error) {
+	Logger.Info("GetPodGPUs Service Called")
+	Logger.Info("request: ", request)
+
+	clientset, err := config.GetClientSet()
+	if err != nil {
+		Logger.Error("Connect to k8s failed")
+		return nil, errors.New("Service Internal Error ")
+	}
+	pod, err := clientset.CoreV1().Pods(request.Namespace).Get(context.TODO(), request.PodName, metav1.GetOptions{})
+	if err != nil {
+		if k8s_error.IsNotFound(err) {
+			Logger.Error("No such Pod: " + request.PodName + " in Namepsace: " + request.Namespace)
+			return &gpu_mount.GetPodGPUsResponse{GetPodGpusResult: gpu_mount.GetPodGPUsResponse_PodNotFound}, nil
+		}
+		Logger.Error("Get Pod: " + request.PodName + " in Namespace: " + request.Namespace + " failed")
+		Logger.Error(err)
+		return nil, errors.New("Service Internal Error ")
+	}
+
+	gpuResources, err := gpuMountImpl.GetPodGPUResources(request.PodName, request.Namespace)
+	if err != nil {
+		Logger.Error("Failed to get gpu resources of Pod: ", request.PodName, " Namespace: ", request.Namespace)
+		Logger.Error(err)
+		return nil, errors.New("Service Internal Error ")
+	}
+	mountType := allocator.MountTypeOf(request.PodName, gpuResources)
+
+	var gpus []*gpu_mount.GPUDevice
+	for _, gpuDev := range gpuResources {
+		gpuDevice := newGPUDevice(gpuDev)
+		gpuDevice.OwnerPodName = request.PodName
+		gpuDevice.OwnerNamespace = request.Namespace
+		gpuDevice.MountType = string(mountType)
+		gpuDevice.LeaseExpiresAt = formatLeaseExpiresAt(gpuMountImpl.leaseExpiresAt(request.Namespace, request.PodName, gpuDev.UUID))
+		gpus = append(gpus, gpuDevice)
+	}
+	sharedGPUs := gpuMountImpl.sharedGPUsOfPod(pod)
+	var sharedUUIDs []string
+	for uuid := range sharedGPUs {
+		sharedUUIDs = append(sharedUUIDs, uuid)
+	}
+	sort.Strings(sharedUUIDs)
+	for _, uuid := range sharedUUIDs {
+		gpuDevice := newGPUDevice(sharedGPUs[uuid])
+		gpuDevice.OwnerPodName = request.PodName
+		gpuDevice.OwnerNamespace = request.Namespace
+		gpuDevice.MountType = string(gpu.SharedMount)
+		gpuDevice.LeaseExpiresAt = formatLeaseExpiresAt(gpuMountImpl.leaseExpiresAt(request.Namespace, request.PodName, uuid))
+		gpuDevice.MemoryLimitMib = gpuMountImpl.memoryLimitOf(request.Namespace, request.PodName, uuid)
+		gpus = append(gpus, gpuDevice)
+	}
+	if mountType == gpu.NoMount && len(sharedUUIDs) != 0 {
+		mountType = gpu.SharedMount
+	}
+	return &gpu_mount.GetPodGPUsResponse{
+		GetPodGpusResult: gpu_mount.GetPodGPUsResponse_Success,
+		MountType:        string(mountType),
+		Gpus:             gpus,
+	}, nil
+}
+
+func newGPUDevice(gpuDev *device.GPU) *gpu_mount.GPUDevice {
+	gpuDevice := &gpu_mount.GPUDevice{
+		Uuid:           gpuDev.UUID,
+		MinorNumber:    int32(gpuDev.MinorNumber),
+		DeviceFilePath: gpuDev.DeviceFilePath,
+		State:          string(gpuDev.State),
+	}
+	if gpuDev.Namespace == gpu.GPUPoolNamespace {
+		gpuDevice.SlavePodName = gpuDev.PodName
+	}
+	if gpuDev.IsMIG() {
+		gpuDevice.MigProfile = gpuDev.MIG.Profile
+		gpuDevice.ParentUuid = gpuDev.MIG.ParentUUID
+	}
+	return gpuDevice
+}
+
+// getSlavePodOwners returns the owner pod of each slave pod on the node, all slave pods if nodeName is empty
+func getSlavePodOwners(nodeName string) (map[string]types.NamespacedName, error) {
+	clientset, err := config.GetClientSet()
+	if err != nil {
+		Logger.Error("Connect to k8s failed")
+		return nil, err
+	}
+	listOptions := metav1.ListOptions{LabelSelector: "app=gpu-pool"}
+	if nodeName != "" {
+		listOptions.FieldSelector = fields.OneTermEqualSelector("spec.nodeName", nodeName).String()
+	}
+	slavePods, err := clientset.CoreV1().Pods(gpu.GPUPoolNamespace).List(context.TODO(), listOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	owners := make(map[string]types.NamespacedName)
+	for _, slavePod := range slavePods.Items {
+		owner := types.NamespacedName{
+			Name:      slavePod.Annotations[gpu.OwnerPodAnnotation],
+			Namespace: slavePod.Annotations[gpu.OwnerNamespaceAnnotation],
+		}
+		// slave pods created before owner annotations only know the owner name
+		if owner.Name == "" && len(slavePod.OwnerReferences) > 0 {
+			owner.Name = slavePod.OwnerReferences[0].Name
+		}
+		owners[slavePod.Name] = owner
+	}
+	return owners, nil
+}
@@ -0,0 +1,319 @@
+package gpu_mount
+
+import (
+	"GPUMounter/pkg/config"
+	"GPUMounter/pkg/device"
+	"GPUMounter/pkg/util"
+	"GPUMounter/pkg/util/gpu"
+	"GPUMounter/pkg/util/ledger"
+	. "GPUMounter/pkg/util/log"
+	"context"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	k8s_error "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func newRecord(pod *corev1.Pod, container corev1.ContainerStatus, gpuDev *device.GPU, state ledger.State) *ledger.Record {
+	return &ledger.Record{
+		Namespace:     pod.Namespace,
+		PodName:       pod.Name,
+		PodUID:        string(pod.UID),
+		ContainerName: container.Name,
+		ContainerID:   container.ContainerID,
+		UUID:          gpuDev.UUID,
+		MinorNumber:   gpuDev.MinorNumber,
+		MIG:           gpuDev.MIG,
+		SlavePodName:  gpuDev.PodName,
+		State:         state,
+	}
+}
+
+// releaseSlavePods deletes the slave pods of the gpus without waiting,
+// shared slave pods are skipped since other pods may use their gpu
+func releaseSlavePods(gpuResources []*device.GPU) {
+	clientset, err := config.GetClientSet()
+	if err != nil {
+		Logger.Error("Connect to k8s failed")
+		return
+	}
+	for _, freeGPU := range gpuResources {
+		if gpu.IsSharedSlavePod(freeGPU.PodName) {
+			continue
+		}
+		err = clientset.CoreV1().Pods(gpu.GPUPoolNamespace).Delete(context.TODO(), freeGPU.PodName, *metav1.NewDeleteOptions(0))
+		if err != nil && !k8s_error.IsNotFound(err) {
+			Logger.Error("Failed to release GPU: ", freeGPU.String())
+			Logger.Error(err)
+		}
+	}
+}
+
+// unmountIfMounted unmounts the gpu from the container if its device file exists in the container
+func unmountIfMounted(pod *corev1.Pod, container corev1.ContainerStatus, gpuDev *device.GPU) error {
+	mounted, err := util.IsGPUMounted(pod, container, gpuDev)
+	if err != nil {
+		return err
+	}
+	if !mounted {
+		return nil
+	}
+	return util.UnmountGPU(pod, container, gpuDev, true)
+}
+
+// rollbackMount unmounts the gpus mounted by a failed mount, releases its slave pods and drops its records
+func (gpuMountImpl GPUMountImpl) rollbackMount(pod *corev1.Pod, containers []corev1.ContainerStatus, gpuResources []*device.GPU, records []*ledger.Record) {
+	Logger.Info("Rolling back mount of Pod: ", pod.Name, " Namespace: ", pod.Namespace)
+	for _, container := range containers {
+		for _, gpuDev := range gpuResources {
+			if err := unmountIfMounted(pod, container, gpuDev); err != nil {
+				Logger.Error("Failed to unmount GPU: ", gpuDev.String(), " from Pod: ", pod.Name, " Container: ", container.Name)
+				Logger.Error(err)
+			}
+		}
+	}
+	releaseSlavePods(gpuResources)
+	if err := gpuMountImpl.Ledger.Delete(records...); err != nil {
+		Logger.Error("Failed to drop records of Pod: ", pod.Name, " Namespace: ", pod.Namespace)
+		Logger.Error(err)
+		return
+	}
+	for _, container := range containers {
+		gpuMountImpl.releaseBootstrap(pod, container, records)
+	}
+}
+
+// Reconcile compares the mount ledger with the gpus reserved by slave pods on the node and the device files in containers,
+// it repairs the mounted gpus and rolls back or completes the mounts and unmounts interrupted by worker restarting
+func (gpuMountImpl GPUMountImpl) Reconcile() error {
+	Logger.Info("Start reconciling mount ledger")
+	nodeName := os.Getenv("NODE_NAME")
+	slavePodOwners, err := getSlavePodOwners(nodeName)
+	if err != nil {
+		Logger.Error("Failed to get owners of slave pods on Node: ", nodeName)
+		return err
+	}
+	if err := gpuMountImpl.UpdateGPUStatus(); err != nil {
+		Logger.Error("Failed to update gpu status")
+		return err
+	}
+	slavePodGPUs := make(map[string][]*device.GPU)
+	for _, gpuDev := range gpuMountImpl.GetGPUs() {
+		if gpuDev.State == device.GPU_ALLOCATED_STATE && gpuDev.Namespace == gpu.GPUPoolNamespace {
+			slavePodGPUs[gpuDev.PodName] = append(slavePodGPUs[gpuDev.PodName], gpuDev)
+		}
+	}
+
+	recordedSlavePods := make(map[string]bool)
+	for _, record := range gpuMountImpl.Ledger.List() {
+		recordedSlavePods[record.SlavePodName] = true
+		gpuMountImpl.reconcileRecord(record, slavePodGPUs[record.SlavePodName])
+	}
+
+	// slave pods without record are created but never mounted, or mounted before the ledger exists
+	for slavePodName, owner := range slavePodOwners {
+		if recordedSlavePods[slavePodName] || gpu.IsSharedSlavePod(slavePodName) {
+			continue
+		}
+		gpuMountImpl.reconcileUnrecordedSlavePod(slavePodName, owner, slavePodGPUs[slavePodName])
+	}
+	gpuMountImpl.releaseUnusedSharedSlavePods()
+	Logger.Info("Finished reconciling mount ledger")
+	return nil
+}
+
+// getRunningContainer returns the owner pod of the record and the status of its container,
+// nil pod if the owner pod no longer exists, nil container if the container is not running
+func getRunningContainer(namespace string, podName string, podUID string, containerName string) (*corev1.Pod, *corev1.ContainerStatus, error) {
+	clientset, err := config.GetClientSet()
+	if err != nil {
+		Logger.Error("Connect to k8s failed")
+		return nil, nil, err
+	}
+	pod, err := clientset.CoreV1().Pods(namespace).Get(context.TODO(), podName, metav1.GetOptions{})
+	if err != nil {
+		if k8s_error.IsNotFound(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	if podUID != "" && string(pod.UID) != podUID {
+		// the owner pod is recreated with the same name
+		return nil, nil, nil
+	}
+	containers, err := util.GetTargetContainers(pod, containerName, false)
+	if err != nil {
+		return pod, nil, nil
+	}
+	return pod, &containers[0], nil
+}
+
+func findGPU(gpuResources []*device.GPU, uuid string) *device.GPU {
+	for _, gpuDev := range gpuResources {
+		if gpuDev.UUID == uuid {
+			return gpuDev
+		}
+	}
+	return nil
+}
+
+func (gpuMountImpl GPUMountImpl) reconcileRecord(record *ledger.Record, slavePodGPUs []*device.GPU) {
+	Logger.Info("Reconciling ", record.State, " GPU: ", record.UUID, " of Pod: ", record.PodName, " Namespace: ", record.Namespace, " Container: ", record.ContainerName)
+	pod, container, err := getRunningContainer(record.Namespace, record.PodName, record.PodUID, record.ContainerName)
+	if err != nil {
+		Logger.Error("Failed to get Pod: ", record.PodName, " Namespace: ", record.Namespace)
+		Logger.Error(err)
+		return
+	}
+	gpuDev := findGPU(slavePodGPUs, record.UUID)
+	if gpuDev == nil {
+		// the slave pod is gone, the gpu may be reserved by others now
+		gpuDev = record.GPU()
+	}
+
+	if pod == nil {
+		Logger.Info("Owner Pod: ", record.PodName, " Namespace: ", record.Namespace, " no longer exists, dropping record")
+		releaseSlavePods([]*device.GPU{{PodName: record.SlavePodName}})
+		gpuMountImpl.dropRecord(record)
+		return
+	}
+
+	if record.State == ledger.StateMounted && findGPU(slavePodGPUs, record.UUID) != nil {
+		if container == nil {
+			// the mount will be repaired once the container is running again
+			Logger.Warn("Container: ", record.ContainerName, " of Pod: ", record.PodName, " is not running, skip repairing")
+			return
+		}
+		gpuMountImpl.repairMount(pod, *container, gpuDev, record)
+		return
+	}
+
+	// roll back interrupted mounts, complete interrupted unmounts, and unmount gpus no longer reserved
+	if container != nil {
+		if err := unmountIfMounted(pod, *container, gpuDev); err != nil {
+			Logger.Error("Failed to unmount GPU: ", record.UUID, " from Pod: ", record.PodName, " Container: ", record.ContainerName)
+			Logger.Error(err)
+			return
+		}
+	}
+	releaseSlavePods([]*device.GPU{{PodName: record.SlavePodName}})
+	gpuMountImpl.dropRecord(record)
+	if container != nil {
+		gpuMountImpl.releaseBootstrap(pod, *container, []*ledger.Record{record})
+	}
+	gpuMountImpl.updateMountedGPUsAnnotation(pod)
+}
+
+// repairMount mounts the gpu again if its device file is missing, e.g. the container restarted,
+// otherwise restores the devices cgroup rule of the gpu
+func (gpuMountImpl GPUMountImpl) repairMount(pod *corev1.Pod, container corev1.ContainerStatus, gpuDev *device.GPU, record *ledger.Record) {
+	if record.Bootstrapped {
+		// a restarted container loses the bootstrapped driver too
+		hasControlDevices, err := util.HasControlDevices(pod, container)
+		if err == nil && !hasControlDevices {
+			Logger.Info("Control devices are missing in Pod: ", pod.Name, " Container: ", container.Name, ", bootstrapping driver again")
+			err = util.BootstrapDriver(pod, container)
+		}
+		if err != nil {
+			Logger.Error("Failed to repair driver bootstrap in Pod: ", pod.Name, " Container: ", container.Name)
+			Logger.Error(err)
+			return
+		}
+	}
+	mounted, err := util.IsGPUMounted(pod, container, gpuDev)
+	if err != nil {
+		Logger.Error("Failed to check GPU: ", gpuDev.UUID, " in Pod: ", pod.Name, " Container: ", container.Name)
+		Logger.Error(err)
+		return
+	}
+	if mounted {
+		err = util.RestoreGPUDevicePermission(pod, container, gpuDev)
+	} else {
+		Logger.Info("Device file of GPU: ", gpuDev.UUID, " is missing in Pod: ", pod.Name, " Container: ", container.Name, ", mounting again")
+		err = util.MountGPU(pod, container, gpuDev)
+	}
+	if err != nil {
+		Logger.Error("Failed to repair GPU: ", gpuDev.UUID, " in Pod: ", pod.Name, " Container: ", container.Name)
+		Logger.Error(err)
+		return
+	}
+	if record.ContainerID != container.ContainerID {
+		record.ContainerID = container.ContainerID
+		if err := gpuMountImpl.Ledger.Put(record); err != nil {
+			Logger.Error("Failed to update record of GPU: ", gpuDev.UUID)
+			Logger.Error(err)
+		}
+	}
+}
+
+func (gpuMountImpl GPUMountImpl) reconcileUnrecordedSlavePod(slavePodName string, owner types.NamespacedName, gpuResources []*device.GPU) {
+	if owner.Name == "" || owner.Namespace == "" {
+		Logger.Warn("Unknown owner of Slave Pod: ", slavePodName, ", skip reconciling")
+		return
+	}
+	clientset, err := config.GetClientSet()
+	if err != nil {
+		Logger.Error("Connect to k8s failed")
+		return
+	}
+	pod, err := clientset.CoreV1().Pods(owner.Namespace).Get(context.TODO(), owner.Name, metav1.GetOptions{})
+	if err != nil {
+		if !k8s_error.IsNotFound(err) {
+			Logger.Error("Failed to get Pod: ", owner.Name, " Namespace: ", owner.Namespace)
+			Logger.Error(err)
+			return
+		}
+		pod = nil
+	}
+
+	// adopt the gpus mounted into running containers
+	var records []*ledger.Record
+	if pod != nil {
+		containers, err := util.GetTargetContainers(pod, "", true)
+		if err == nil {
+			for _, container := range containers {
+				for _, gpuDev := range gpuResources {
+					mounted, err := util.IsGPUMounted(pod, container, gpuDev)
+					if err != nil {
+						Logger.Error("Failed to check GPU: ", gpuDev.UUID, " in Pod: ", pod.Name, " Container: ", container.Name)
+						Logger.Error(err)
+						return
+					}
+					if mounted {
+						records = append(records, newRecord(pod, container, gpuDev, ledger.StateMounted))
+					}
+				}
+			}
+		}
+	}
+	if len(records) == 0 {
+		Logger.Info("GPUs of Slave Pod: ", slavePodName, " are not mounted, releasing")
+		releaseSlavePods([]*device.GPU{{PodName: slavePodName}})
+		return
+	}
+	Logger.Info("Adopting ", len(records), " mounts of Slave Pod: ", slavePodName, " Owner Pod: ", owner.Name, " Namespace: ", owner.Namespace)
+	if err := gpuMountImpl.Ledger.Put(records...); err != nil {
+		Logger.Error("Failed to record mounts of Slave Pod: ", slavePodName)
+		Logger.Error(err)
+		return
+	}
+	gpuMountImpl.updateMountedGPUsAnnotation(pod)
+	for _, record := range records {
+		gpuDev := findGPU(gpuResources, record.UUID)
+		if err := util.RestoreGPUDevicePermission(pod, corev1.ContainerStatus{Name: record.ContainerName, ContainerID: record.ContainerID}, gpuDev); err != nil {
+			Logger.Error("Failed to restore permission of GPU: ", record.UUID, " in Pod: ", pod.Name, " Container: ", record.ContainerName)
+			Logger.Error(err)
+		}
+	}
+}
+
+func (gpuMountImpl GPUMountImpl) dropRecord(record *ledger.Record) {
+	if err := gpuMountImpl.Ledger.Delete(record); err != nil {
+		Logger.Error("Failed to drop record of GPU: ", record.UUID, " Pod: ", record.PodName, " Namespace: ", record.Namespace)
+		Logger.Error(err)
+		return
+	}
+	Logger.Info("Dropped record of GPU: ", record.UUID, " Pod: ", record.PodName, " Namespace: ", record.Namespace, " Container: ", record.ContainerName)
+}
@@ -3,19 +3,32 @@ package gpu_mount
 import (
 	gpu_mount "GPUMounter/pkg/api/gpu-mount"
 	"GPUMounter/pkg/config"
+	"GPUMounter/pkg/device"
 	"GPUMounter/pkg/util"
+	"GPUMounter/pkg/util/event"
 	"GPUMounter/pkg/util/gpu"
 	"GPUMounter/pkg/util/gpu/allocator"
+	"GPUMounter/pkg/util/ledger"
 	. "GPUMounter/pkg/util/log"
+	"GPUMounter/pkg/util/quota"
 	"context"
 	"errors"
+	"strings"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	k8s_error "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
 )
 
 type GPUMountImpl struct {
 	*allocator.GPUAllocator
+	Ledger *ledger.Ledger
+	// Recorder records events on the owner pods
+	Recorder record.EventRecorder
+	// Idle unmounts idle gpus, nil if it is disabled
+	Idle *IdleDetector
 }
 
 func NewGPUMounter() (*GPUMountImpl, error) {
@@ -28,12 +41,51 @@ func NewGPUMounter() (*GPUMountImpl, error) {
 	}
 	Logger.Info("Successfully created gpu allocator")
 	gpuMounter.GPUAllocator = tmp
+
+	gpuMounter.Ledger, err = ledger.NewLedger(ledger.GetLedgerPath())
+	if err != nil {
+		Logger.Error("Failed to load mount ledger")
+		return nil, err
+	}
+	Logger.Info("Successfully loaded mount ledger")
+
+	gpuMounter.Recorder, err = event.GetRecorder()
+	if err != nil {
+		Logger.Error("Failed to create event recorder")
+		return nil, err
+	}
+
+	idleWindow, err := GetIdleWindow()
+	if err != nil {
+		Logger.Error("Invalid idle unmount window")
+		return nil, err
+	}
+	if idleWindow > 0 {
+		idleSampleInterval, err := GetIdleSampleInterval()
+		if err != nil {
+			Logger.Error("Invalid idle sample interval")
+			return nil, err
+		}
+		gpuMounter.Idle = NewIdleDetector(idleWindow, idleSampleInterval)
+		Logger.Info("Idle gpus are unmounted after ", idleWindow, ", sampled every ", idleSampleInterval)
+	}
 	return gpuMounter, nil
 }
 
-func (gpuMountImpl GPUMountImpl) AddGPU(_ context.Context, request *gpu_mount.AddGPURequest) (*gpu_mount.AddGPUResponse, error) {
+// slavePodFailures maps the reason of slave pod failing to run to add gpu result
+var slavePodFailures = map[string]gpu_mount.AddGPUResponse_AddGPUResult{
+	gpu.InsufficientGPU:          gpu_mount.AddGPUResponse_InsufficientGPU,
+	gpu.SlavePodImagePullFailed:  gpu_mount.AddGPUResponse_SlavePodImagePullFailed,
+	gpu.SlavePodCrashLoopBackOff: gpu_mount.AddGPUResponse_SlavePodCrashLoopBackOff,
+	gpu.SlavePodEvicted:          gpu_mount.AddGPUResponse_SlavePodEvicted,
+	gpu.SlavePodFailed:           gpu_mount.AddGPUResponse_SlavePodFailed,
+	gpu.SlavePodTimeout:          gpu_mount.AddGPUResponse_SlavePodTimeout,
+}
+
+func (gpuMountImpl GPUMountImpl) AddGPU(ctx context.Context, request *gpu_mount.AddGPURequest) (*gpu_mount.AddGPUResponse, error) {
 	Logger.Info("AddGPU Service Called")
 	Logger.Info("request: ", request)
+	defer lockPod(request.Namespace, request.PodName)()
 
 	clientset, err := config.GetClientSet()
 	if err != nil {
@@ -55,52 +107,209 @@ func (gpuMountImpl GPUMountImpl) AddGPU(_ context.Context, request *gpu_mount.Ad
 	Logger.Info("Successfully get Pod: " + request.Namespace + " in cluster")
 
 	if !util.CanMount(gpuMountImpl.GetMountType(targetPod), request) {
+		gpuMountImpl.recordEvent(targetPod, corev1.EventTypeWarning, event.ReasonMountFailed, "Can not mount %d GPUs with isEntireMount %t into the pod in %s mode", request.GpuNum, request.IsEntireMount, gpuMountImpl.GetMountType(targetPod))
 		return nil, errors.New(gpu.FailedCreated)
 	}
 
+	containers, err := util.GetTargetContainers(targetPod, request.ContainerName, request.AllContainers)
+	if err != nil {
+		Logger.Error("No target container: ", request.ContainerName, " in Pod: ", request.PodName, " Namespace: ", request.Namespace)
+		gpuMountImpl.recordEvent(targetPod, corev1.EventTypeWarning, event.ReasonContainerNotFound, "Failed to mount GPUs: container %q is not running", request.ContainerName)
+		return &gpu_mount.AddGPUResponse{AddGpuResult: gpu_mount.AddGPUResponse_ContainerNotFound}, nil
+	}
+	// containers started without gpus get the driver bootstrapped before the first mount
+	bootstrapping := make(map[string]bool)
+	for _, container := range containers {
+		bootstrapping[container.Name], err = needsBootstrap(targetPod, container)
+		if err != nil {
+			Logger.Error("Failed to check control devices in Pod: ", request.PodName, " Container: ", container.Name)
+			Logger.Error(err)
+			return nil, errors.New("Service Internal Error ")
+		}
+	}
+
 	gpuNum := int(request.GpuNum)
-	gpuNumPerPod := 1
-	if request.IsEntireMount {
-		gpuNumPerPod = gpuNum
+	if request.MigProfile != "" && device.GetVendor() != device.VendorNvidia {
+		err := errors.New("MIG devices are not supported on " + string(device.GetVendor()) + " gpus")
+		Logger.Error(err)
+		gpuMountImpl.recordEvent(targetPod, corev1.EventTypeWarning, event.ReasonMountFailed, "Failed to mount %d GPUs: %s", gpuNum, err.Error())
+		return nil, err
+	}
+	// the mounts are recorded before mounting, so that half-finished mounts can be rolled back after the worker restarts
+	var leaseExpiresAt time.Time
+	if request.LeaseSeconds > 0 {
+		leaseExpiresAt = time.Now().Add(time.Duration(request.LeaseSeconds) * time.Second)
+	}
+	newRecords := func(gpuResources []*device.GPU) []*ledger.Record {
+		var records []*ledger.Record
+		for _, container := range containers {
+			for _, targetGPU := range gpuResources {
+				record := newRecord(targetPod, container, targetGPU, ledger.StateMounting)
+				record.LeaseExpiresAt = leaseExpiresAt
+				record.ForceReclaim = request.ForceReclaim
+				record.Shared = request.Shared
+				record.MemoryLimitMiB = request.MemoryLimitMib
+				record.Bootstrapped = bootstrapping[container.Name] || gpuMountImpl.isBootstrapped(targetPod.Namespace, targetPod.Name, container.Name)
+				records = append(records, record)
+			}
+		}
+		return records
+	}
+	var gpuResources []*device.GPU
+	var records []*ledger.Record
+	if request.Shared {
+		gpuResources, records, err = gpuMountImpl.getSharedGPU(ctx, targetPod, request, newRecords)
+	} else if request.MigProfile != "" {
+		gpuResources, err = gpuMountImpl.GetAvailableMIG(ctx, targetPod, request.MigProfile, gpuNum)
+	} else {
+		gpuNumPerPod := 1
+		if request.IsEntireMount {
+			gpuNumPerPod = gpuNum
+		}
+		var strategy allocator.Strategy
+		if request.PlacementStrategy != "" {
+			strategy, err = allocator.GetStrategy(request.PlacementStrategy)
+			if err != nil {
+				Logger.Error(err)
+				gpuMountImpl.recordEvent(targetPod, corev1.EventTypeWarning, event.ReasonMountFailed, "Failed to mount %d GPUs: %s", gpuNum, err.Error())
+				return nil, err
+			}
+		}
+		gpuResources, err = gpuMountImpl.GetAvailableGPU(ctx, targetPod, gpuNum, gpuNumPerPod, strategy)
 	}
-	gpuResources, err := gpuMountImpl.GetAvailableGPU(targetPod, gpuNum, gpuNumPerPod)
 
 	if err != nil {
-		if err.Error() == gpu.InsufficientGPU {
-			Logger.Error("Insufficient gpu for Pod: ", targetPod.Name, " Namespace: "+targetPod.Namespace)
-			return &gpu_mount.AddGPUResponse{AddGpuResult: gpu_mount.AddGPUResponse_InsufficientGPU}, nil
+		if insufficient, ok := err.(*insufficientMemoryError); ok {
+			Logger.Error("Failed to get shared gpu for Pod: ", targetPod.Name, " Namespace: "+targetPod.Namespace, " reason: ", err.Error())
+			gpuMountImpl.recordEvent(targetPod, corev1.EventTypeWarning, gpu_mount.AddGPUResponse_InsufficientGPU.String(), "Failed to mount a shared GPU: %s", insufficient.Error())
+			return &gpu_mount.AddGPUResponse{AddGpuResult: gpu_mount.AddGPUResponse_InsufficientGPU, Message: insufficient.Error()}, nil
+		}
+		if exceeded, ok := err.(*quota.ExceededError); ok {
+			Logger.Error("Failed to get gpu for Pod: ", targetPod.Name, " Namespace: "+targetPod.Namespace, " reason: ", err.Error())
+			gpuMountImpl.recordEvent(targetPod, corev1.EventTypeWarning, event.ReasonQuotaExceeded, "Failed to mount %d GPUs: %s", gpuNum, exceeded.Error())
+			return &gpu_mount.AddGPUResponse{AddGpuResult: gpu_mount.AddGPUResponse_QuotaExceeded, Message: exceeded.Error()}, nil
+		}
+		if result, ok := slavePodFailures[err.Error()]; ok {
+			Logger.Error("Failed to get gpu for Pod: ", targetPod.Name, " Namespace: "+targetPod.Namespace, " reason: ", err.Error())
+			gpuMountImpl.recordEvent(targetPod, corev1.EventTypeWarning, result.String(), "Failed to mount %d GPUs: slave pods failed with %s", gpuNum, err.Error())
+			return &gpu_mount.AddGPUResponse{AddGpuResult: result}, nil
+		} else if err.Error() == gpu.SlavePodCanceled {
+			Logger.Error("Request of Pod: ", targetPod.Name, " Namespace: "+targetPod.Namespace, " was canceled while creating slave pods")
+			return nil, errors.New(gpu.SlavePodCanceled)
 		} else if err.Error() == gpu.FailedCreated {
 			Logger.Error("Failed to create slave pod for Pod: ", targetPod.Name, " Namespace: "+targetPod.Namespace)
+			gpuMountImpl.recordEvent(targetPod, corev1.EventTypeWarning, event.ReasonMountFailed, "Failed to mount %d GPUs: failed to create slave pods", gpuNum)
 			return nil, errors.New("Service Internal Error ")
 		}
 		Logger.Error("Can not get available gpu")
+		gpuMountImpl.recordEvent(targetPod, corev1.EventTypeWarning, event.ReasonMountFailed, "Failed to mount %d GPUs: %s", gpuNum, err.Error())
 		return nil, errors.New("Service Internal Error ")
 	}
 
-	for idx, targetGPU := range gpuResources {
-		Logger.Info("Start mounting, Total: ", gpuNum, " Current: ", idx+1)
-		err = util.MountGPU(targetPod, targetGPU)
-		if err != nil {
-			Logger.Error("Mount GPU: " + targetGPU.String() + " to Pod: " + request.PodName + " in Namespace: " + request.Namespace + " failed")
+	if !request.Shared {
+		// shared mounts are recorded by getSharedGPU
+		records = newRecords(gpuResources)
+		if err := gpuMountImpl.Ledger.Put(records...); err != nil {
+			Logger.Error("Failed to record mounting GPUs of Pod: ", targetPod.Name, " Namespace: ", targetPod.Namespace)
 			Logger.Error(err)
-			for _, freeGPU := range gpuResources {
-				err = clientset.CoreV1().Pods(gpu.GPUPoolNamespace).Delete(context.TODO(), freeGPU.PodName, *metav1.NewDeleteOptions(0))
-				if err != nil {
-					Logger.Error("Failed to release GPU: ", freeGPU.String())
-				}
-			}
+			releaseSlavePods(gpuResources)
 			return nil, errors.New("Service Internal Error ")
 		}
-		Logger.Info("Mount GPU: " + targetGPU.String() + " to Pod: " + request.PodName + " in Namespace: " + request.Namespace + " successfully")
+	}
+
+	var containerResults []*gpu_mount.ContainerResult
+	for _, container := range containers {
+		containerResult := &gpu_mount.ContainerResult{
+			ContainerName: container.Name,
+			ContainerId:   container.ContainerID,
+			Success:       true,
+		}
+		containerResults = append(containerResults, containerResult)
+		if bootstrapping[container.Name] {
+			if err := util.BootstrapDriver(targetPod, container); err != nil {
+				Logger.Error("Bootstrap driver into Pod: " + request.PodName + " Container: " + container.Name + " in Namespace: " + request.Namespace + " failed")
+				Logger.Error(err)
+				containerResult.Success = false
+				containerResult.Message = "Bootstrap driver failed: " + err.Error()
+			}
+		}
+		for idx, targetGPU := range gpuResources {
+			if !containerResult.Success {
+				break
+			}
+			Logger.Info("Start mounting, Total: ", gpuNum, " Current: ", idx+1, " Container: ", container.Name)
+			err = util.MountGPU(targetPod, container, targetGPU)
+			if err != nil {
+				Logger.Error("Mount GPU: " + targetGPU.String() + " to Pod: " + request.PodName + " Container: " + container.Name + " in Namespace: " + request.Namespace + " failed")
+				Logger.Error(err)
+				containerResult.Success = false
+				containerResult.Message = "Mount GPU: " + targetGPU.UUID + " failed: " + err.Error()
+				break
+			}
+			Logger.Info("Mount GPU: " + targetGPU.String() + " to Pod: " + request.PodName + " Container: " + container.Name + " in Namespace: " + request.Namespace + " successfully")
+		}
+		if !containerResult.Success {
+			gpuMountImpl.recordEvent(targetPod, corev1.EventTypeWarning, event.ReasonMountFailed, "Failed to mount GPUs into container %s: %s", container.Name, containerResult.Message)
+			gpuMountImpl.rollbackMount(targetPod, containers, gpuResources, records)
+			return &gpu_mount.AddGPUResponse{
+				AddGpuResult:     gpu_mount.AddGPUResponse_MountFailed,
+				ContainerResults: containerResults,
+			}, nil
+		}
+	}
+
+	for _, record := range records {
+		record.State = ledger.StateMounted
+	}
+	if err := gpuMountImpl.Ledger.Put(records...); err != nil {
+		Logger.Error("Failed to record mounted GPUs of Pod: ", targetPod.Name, " Namespace: ", targetPod.Namespace)
+		Logger.Error(err)
+		gpuMountImpl.rollbackMount(targetPod, containers, gpuResources, records)
+		return nil, errors.New("Service Internal Error ")
 	}
 
 	Logger.Info("Successfully mount all GPU to Pod: " + request.PodName + " in Namespace: " + request.Namespace)
-	return &gpu_mount.AddGPUResponse{AddGpuResult: gpu_mount.AddGPUResponse_Success}, nil
+	gpuMountImpl.updateMountedGPUsAnnotation(targetPod)
+	var mountedGPUs []*gpu_mount.GPUDevice
+	var mountedUUIDs, slavePodNames, containerNames []string
+	for _, container := range containers {
+		containerNames = append(containerNames, container.Name)
+	}
+	for _, mountedGPU := range gpuResources {
+		mountedUUIDs = append(mountedUUIDs, mountedGPU.UUID)
+		slavePodNames = append(slavePodNames, mountedGPU.PodName)
+		mountedGPUDevice := &gpu_mount.GPUDevice{
+			Uuid:           mountedGPU.UUID,
+			MinorNumber:    int32(mountedGPU.MinorNumber),
+			DeviceFilePath: mountedGPU.DeviceFilePath,
+			SlavePodName:   mountedGPU.PodName,
+			LeaseExpiresAt: formatLeaseExpiresAt(leaseExpiresAt),
+			MemoryLimitMib: request.MemoryLimitMib,
+		}
+		if mountedGPU.IsMIG() {
+			mountedGPUDevice.MigProfile = mountedGPU.MIG.Profile
+			mountedGPUDevice.ParentUuid = mountedGPU.MIG.ParentUUID
+		}
+		mountedGPUs = append(mountedGPUs, mountedGPUDevice)
+	}
+	if request.Shared {
+		gpuMountImpl.recordEvent(targetPod, corev1.EventTypeNormal, event.ReasonGPUMounted, "Mounted shared GPU %s into container %s with a memory limit of %d MiB, reserved by slave pod %s",
+			strings.Join(mountedUUIDs, ", "), strings.Join(containerNames, ", "), request.MemoryLimitMib, strings.Join(slavePodNames, ", "))
+	} else {
+		gpuMountImpl.recordEvent(targetPod, corev1.EventTypeNormal, event.ReasonGPUMounted, "Mounted GPU %s into container %s, reserved by slave pod %s",
+			strings.Join(mountedUUIDs, ", "), strings.Join(containerNames, ", "), strings.Join(slavePodNames, ", "))
+	}
+	return &gpu_mount.AddGPUResponse{
+		AddGpuResult:     gpu_mount.AddGPUResponse_Success,
+		Gpus:             mountedGPUs,
+		ContainerResults: containerResults,
+	}, nil
 }
 
-func (gpuMountImpl GPUMountImpl) RemoveGPU(_ context.Context, request *gpu_mount.RemoveGPURequest) (*gpu_mount.RemoveGPUResponse, error) {
+func (gpuMountImpl GPUMountImpl) RemoveGPU(ctx context.Context, request *gpu_mount.RemoveGPURequest) (*gpu_mount.RemoveGPUResponse, error) {
 	Logger.Info("RemoveGPU Service Called")
 	Logger.Info("request: ", request)
+	defer lockPod(request.Namespace, request.PodName)()
 
 	clientset, err := config.GetClientSet()
 	if err != nil {
@@ -121,59 +330,205 @@ func (gpuMountImpl GPUMountImpl) RemoveGPU(_ context.Context, request *gpu_mount
 	}
 	Logger.Info("Successfully get Pod: ", request.PodName, "in Namespace: ", request.Namespace)
 
-	removeGPUs, err := gpuMountImpl.GetRemoveGPU(targetPod, request.Uuids)
-	if err != nil {
-		Logger.Error("Failed to get remove gpu of Pod: ", targetPod.Name)
-		Logger.Error(err)
-		return nil, err
+	// shared gpus are reserved by shared slave pods, so they are only known by the ledger
+	sharedGPUs := gpuMountImpl.sharedGPUsOfPod(targetPod)
+	var removeGPUs []*device.GPU
+	var exclusiveUUIDs []string
+	for _, uuid := range request.Uuids {
+		if sharedGPU, ok := sharedGPUs[uuid]; ok {
+			removeGPUs = append(removeGPUs, sharedGPU)
+		} else {
+			exclusiveUUIDs = append(exclusiveUUIDs, uuid)
+		}
+	}
+	// nothing is removed if any of the uuids is not mounted into the pod, GetRemoveGPU returns no gpu in that case
+	var exclusiveGPUs []*device.GPU
+	if len(exclusiveUUIDs) != 0 {
+		exclusiveGPUs, err = gpuMountImpl.GetRemoveGPU(targetPod, exclusiveUUIDs)
+		if err != nil {
+			Logger.Error("Failed to get remove gpu of Pod: ", targetPod.Name)
+			Logger.Error(err)
+			return nil, err
+		}
 	}
-	if len(removeGPUs) == 0 {
+	if len(request.Uuids) == 0 || (len(exclusiveUUIDs) != 0 && len(exclusiveGPUs) == 0) {
 		Logger.Error("Invalid UUIDs: ", request.Uuids)
+		gpuMountImpl.recordEvent(targetPod, corev1.EventTypeWarning, event.ReasonGPUNotFound, "Failed to unmount GPU %s: not mounted into the pod", strings.Join(request.Uuids, ", "))
 		return &gpu_mount.RemoveGPUResponse{
 			RemoveGpuResult: gpu_mount.RemoveGPUResponse_GPUNotFound,
 		}, nil
 	}
+	removeGPUs = append(removeGPUs, exclusiveGPUs...)
 
-	// check all gpu status
+	containers, err := util.GetTargetContainers(targetPod, request.ContainerName, request.AllContainers)
+	if err != nil {
+		Logger.Error("No target container: ", request.ContainerName, " in Pod: ", request.PodName, " Namespace: ", request.Namespace)
+		gpuMountImpl.recordEvent(targetPod, corev1.EventTypeWarning, event.ReasonContainerNotFound, "Failed to unmount GPUs: container %q is not running", request.ContainerName)
+		return &gpu_mount.RemoveGPUResponse{RemoveGpuResult: gpu_mount.RemoveGPUResponse_ContainerNotFound}, nil
+	}
+
+	// each gpu is unmounted from the containers it is mounted into, its slave pod is kept while other containers use it
+	gpuContainers := make(map[string][]corev1.ContainerStatus)
 	var slavePodNames []string
 	for _, removeGPU := range removeGPUs {
-		slavePodNames = append(slavePodNames, removeGPU.PodName)
-		gpuProc, err := util.GetPodGPUProcesses(targetPod, removeGPU)
-		if err != nil {
-			Logger.Error("Failed to get process info on GPU: ", removeGPU.DeviceFilePath)
-			Logger.Error(err)
-			return nil, err
-		}
-		if gpuProc != nil && !request.Force {
-			Logger.Info("GPU: ", removeGPU.DeviceFilePath, " status in Pod: ", targetPod.Name, " in Namespace: ", targetPod.Namespace, " is busy")
+		targets, kept := gpuMountImpl.mountedContainers(targetPod, containers, removeGPU.UUID)
+		if len(targets) == 0 {
+			Logger.Error("GPU: ", removeGPU.UUID, " is not mounted into the target containers of Pod: ", targetPod.Name)
+			gpuMountImpl.recordEvent(targetPod, corev1.EventTypeWarning, event.ReasonGPUNotFound, "Failed to unmount GPU %s: not mounted into the container", removeGPU.UUID)
 			return &gpu_mount.RemoveGPUResponse{
-				RemoveGpuResult: gpu_mount.RemoveGPUResponse_GPUBusy,
+				RemoveGpuResult: gpu_mount.RemoveGPUResponse_GPUNotFound,
 			}, nil
 		}
+		gpuContainers[removeGPU.UUID] = targets
+		if _, ok := sharedGPUs[removeGPU.UUID]; !ok && !kept {
+			slavePodNames = append(slavePodNames, removeGPU.PodName)
+		}
 	}
 
+	// check all gpu status
 	for _, removeGPU := range removeGPUs {
-		err := util.UnmountGPU(targetPod, removeGPU, request.Force)
-		if err != nil {
-			if err.Error() == string(gpu_mount.RemoveGPUResponse_GPUBusy) {
+		for _, container := range gpuContainers[removeGPU.UUID] {
+			gpuProc, err := util.GetPodGPUProcesses(targetPod, container, removeGPU)
+			if err != nil {
+				Logger.Error("Failed to get process info on GPU: ", removeGPU.DeviceFilePath)
+				Logger.Error(err)
+				return nil, err
+			}
+			if gpuProc != nil && !request.Force {
+				Logger.Info("GPU: ", removeGPU.DeviceFilePath, " status in Pod: ", targetPod.Name, " Container: ", container.Name, " in Namespace: ", targetPod.Namespace, " is busy")
+				gpuMountImpl.recordEvent(targetPod, corev1.EventTypeWarning, event.ReasonGPUBusy, "Failed to unmount GPU %s: used by processes %s in container %s", removeGPU.UUID, strings.Join(gpuProc, ", "), container.Name)
 				return &gpu_mount.RemoveGPUResponse{
 					RemoveGpuResult: gpu_mount.RemoveGPUResponse_GPUBusy,
 				}, nil
 			}
-			Logger.Error("Failed unmount GPU: ", removeGPU.DeviceFilePath, " on Pod: ", removeGPU.PodName, " in Namespace: ", removeGPU.Namespace)
+		}
+	}
+
+	// record the unmounts before unmounting, so that half-finished unmounts can be completed after the worker restarts
+	var records []*ledger.Record
+	for _, removeGPU := range removeGPUs {
+		for _, container := range gpuContainers[removeGPU.UUID] {
+			record := newRecord(targetPod, container, removeGPU, ledger.StateUnmounting)
+			if _, ok := sharedGPUs[removeGPU.UUID]; ok {
+				record.Shared = true
+				record.MemoryLimitMiB = gpuMountImpl.memoryLimitOf(targetPod.Namespace, targetPod.Name, removeGPU.UUID)
+			}
+			if mounted := gpuMountImpl.Ledger.Get(targetPod.Namespace, targetPod.Name, container.Name, removeGPU.UUID); mounted != nil {
+				record.Bootstrapped = mounted.Bootstrapped
+			}
+			records = append(records, record)
+		}
+	}
+	if err := gpuMountImpl.Ledger.Put(records...); err != nil {
+		Logger.Error("Failed to record unmounting GPUs of Pod: ", targetPod.Name, " Namespace: ", targetPod.Namespace)
+		Logger.Error(err)
+		return nil, errors.New("Service Internal Error ")
+	}
+
+	var containerResults []*gpu_mount.ContainerResult
+	for _, container := range containers {
+		containerResult := &gpu_mount.ContainerResult{
+			ContainerName: container.Name,
+			ContainerId:   container.ContainerID,
+			Success:       true,
+		}
+		containerResults = append(containerResults, containerResult)
+		for _, removeGPU := range removeGPUs {
+			if !hasContainer(gpuContainers[removeGPU.UUID], container.Name) {
+				continue
+			}
+			err := util.UnmountGPU(targetPod, container, removeGPU, request.Force)
+			if err != nil {
+				if err.Error() == string(gpu_mount.RemoveGPUResponse_GPUBusy) {
+					gpuMountImpl.recordEvent(targetPod, corev1.EventTypeWarning, event.ReasonGPUBusy, "Failed to unmount GPU %s: used by processes in container %s", removeGPU.UUID, container.Name)
+					return &gpu_mount.RemoveGPUResponse{
+						RemoveGpuResult: gpu_mount.RemoveGPUResponse_GPUBusy,
+					}, nil
+				}
+				Logger.Error("Failed unmount GPU: ", removeGPU.DeviceFilePath, " on Pod: ", targetPod.Name, " Container: ", container.Name, " in Namespace: ", targetPod.Namespace)
+				Logger.Error(err)
+				containerResult.Success = false
+				containerResult.Message = "Unmount GPU: " + removeGPU.UUID + " failed: " + err.Error()
+				gpuMountImpl.recordEvent(targetPod, corev1.EventTypeWarning, event.ReasonUnmountFailed, "Failed to unmount GPU %s from container %s: %s", removeGPU.UUID, container.Name, err.Error())
+				return &gpu_mount.RemoveGPUResponse{
+					RemoveGpuResult:  gpu_mount.RemoveGPUResponse_UnmountFailed,
+					ContainerResults: containerResults,
+				}, nil
+			}
+			Logger.Info("Successfully unmount GPU: ", removeGPU.DeviceFilePath, " from Container: ", container.Name)
+		}
+	}
+
+	// delete slave pod, shared slave pods are deleted once no pod uses their gpu
+	if len(slavePodNames) != 0 {
+		err = gpuMountImpl.DeleteSlavePods(ctx, slavePodNames)
+		if err != nil {
 			Logger.Error(err)
 			return nil, err
 		}
-		Logger.Info("Successfully unmount GPU: ", removeGPU.DeviceFilePath)
 	}
-
-	// delete slave pod
-	err = gpuMountImpl.DeleteSlavePods(slavePodNames)
-	if err != nil {
+	if err := gpuMountImpl.Ledger.Delete(records...); err != nil {
+		Logger.Error("Failed to drop records of unmounted GPUs of Pod: ", targetPod.Name, " Namespace: ", targetPod.Namespace)
 		Logger.Error(err)
-		return nil, err
+	} else {
+		for _, container := range containers {
+			gpuMountImpl.releaseBootstrap(targetPod, container, records)
+		}
+	}
+	for _, removeGPU := range removeGPUs {
+		if _, ok := sharedGPUs[removeGPU.UUID]; ok {
+			gpuMountImpl.releaseUnusedSharedSlavePods()
+			break
+		}
+	}
+	gpuMountImpl.updateMountedGPUsAnnotation(targetPod)
+	var removedUUIDs, containerNames []string
+	for _, removeGPU := range removeGPUs {
+		removedUUIDs = append(removedUUIDs, removeGPU.UUID)
+	}
+	for _, container := range containers {
+		containerNames = append(containerNames, container.Name)
+	}
+	if len(slavePodNames) == 0 {
+		gpuMountImpl.recordEvent(targetPod, corev1.EventTypeNormal, event.ReasonGPUUnmounted, "Unmounted GPU %s from container %s",
+			strings.Join(removedUUIDs, ", "), strings.Join(containerNames, ", "))
+	} else {
+		gpuMountImpl.recordEvent(targetPod, corev1.EventTypeNormal, event.ReasonGPUUnmounted, "Unmounted GPU %s from container %s, released slave pod %s",
+			strings.Join(removedUUIDs, ", "), strings.Join(containerNames, ", "), strings.Join(slavePodNames, ", "))
 	}
 	return &gpu_mount.RemoveGPUResponse{
-		RemoveGpuResult: gpu_mount.RemoveGPUResponse_Success,
+		RemoveGpuResult:  gpu_mount.RemoveGPUResponse_Success,
+		ContainerResults: containerResults,
 	}, nil
 }
+
+// mountedContainers returns the containers the gpu is recorded as mounted into among the containers, all of them
+// if the gpu has no record, and reports whether the gpu stays mounted into other containers of the pod
+func (gpuMountImpl GPUMountImpl) mountedContainers(pod *corev1.Pod, containers []corev1.ContainerStatus, uuid string) ([]corev1.ContainerStatus, bool) {
+	recorded := make(map[string]bool)
+	for _, record := range gpuMountImpl.Ledger.List() {
+		if record.Namespace == pod.Namespace && record.PodName == pod.Name && record.PodUID == string(pod.UID) && record.UUID == uuid {
+			recorded[record.ContainerName] = true
+		}
+	}
+	if len(recorded) == 0 {
+		return containers, false
+	}
+	var mounted []corev1.ContainerStatus
+	for _, container := range containers {
+		if recorded[container.Name] {
+			mounted = append(mounted, container)
+			delete(recorded, container.Name)
+		}
+	}
+	return mounted, len(recorded) != 0
+}
+
+func hasContainer(containers []corev1.ContainerStatus, name string) bool {
+	for _, container := range containers {
+		if container.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,329 @@
+package gpu_mount
+
+import (
+	gpu_mount "GPUMounter/pkg/api/gpu-mount"
+	"GPUMounter/pkg/config"
+	"GPUMounter/pkg/device"
+	"GPUMounter/pkg/util"
+	"GPUMounter/pkg/util/event"
+	"GPUMounter/pkg/util/gpu"
+	"GPUMounter/pkg/util/ledger"
+	. "GPUMounter/pkg/util/log"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	k8s_error "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultSharedEnforceInterval is the interval of checking the gpu memory used on shared gpus, can be set by SHARED_ENFORCE_INTERVAL
+const DefaultSharedEnforceInterval = 10 * time.Second
+
+// GetSharedEnforceInterval returns the enforce interval set by env SHARED_ENFORCE_INTERVAL
+func GetSharedEnforceInterval() (time.Duration, error) {
+	return parsePositiveDuration("SHARED_ENFORCE_INTERVAL", DefaultSharedEnforceInterval)
+}
+
+var (
+	// sharedMu serializes choosing shared gpus, recording their mounts and releasing unused shared slave pods,
+	// so a shared slave pod is not released while its gpu is being mounted
+	sharedMu sync.Mutex
+	// reservingShared is the number of shared slave pods being created, guarded by sharedMu,
+	// unused shared slave pods are not released meanwhile as the new ones are not recorded yet
+	reservingShared int
+)
+
+// insufficientMemoryError is returned if no shared gpu has enough memory left for the memory limit
+type insufficientMemoryError struct {
+	memoryLimitMiB uint64
+}
+
+func (e *insufficientMemoryError) Error() string {
+	return fmt.Sprintf("no GPU has %d MiB of memory left to share", e.memoryLimitMiB)
+}
+
+// sharedGPU is a gpu reserved by a shared slave pod and the memory limits of the pods sharing it
+type sharedGPU struct {
+	uuid         string
+	slavePodName string
+	// record is a record of the gpu, which the gpu is rebuilt from
+	record *ledger.Record
+	// memoryLimits are keyed by pod uid, a pod mounting the gpu into several containers has a record per container
+	memoryLimits map[string]uint64
+}
+
+func (shared *sharedGPU) allocatedMiB() uint64 {
+	var allocated uint64
+	for _, limit := range shared.memoryLimits {
+		allocated += limit
+	}
+	return allocated
+}
+
+func (shared *sharedGPU) device() *device.GPU {
+	gpuDev := shared.record.GPU()
+	gpuDev.State = device.GPU_ALLOCATED_STATE
+	gpuDev.PodName = shared.slavePodName
+	gpuDev.Namespace = gpu.GPUPoolNamespace
+	return gpuDev
+}
+
+// sharedGPUsOf groups the records of shared gpus by gpu
+func sharedGPUsOf(records []*ledger.Record) map[string]*sharedGPU {
+	sharedGPUs := make(map[string]*sharedGPU)
+	for _, record := range records {
+		if !record.Shared {
+			continue
+		}
+		shared, ok := sharedGPUs[record.UUID]
+		if !ok {
+			shared = &sharedGPU{
+				uuid:         record.UUID,
+				slavePodName: record.SlavePodName,
+				record:       record,
+				memoryLimits: make(map[string]uint64),
+			}
+			sharedGPUs[record.UUID] = shared
+		}
+		shared.memoryLimits[record.PodUID] = record.MemoryLimitMiB
+	}
+	return sharedGPUs
+}
+
+// pickSharedGPU returns the shared gpu not used by the pod yet with the least memory left that fits the memory limit,
+// nil if no gpu fits
+func pickSharedGPU(sharedGPUs map[string]*sharedGPU, podUID string, memoryLimitMiB uint64, totalMemoryMiB func(uuid string) (uint64, error)) *sharedGPU {
+	var uuids []string
+	for uuid := range sharedGPUs {
+		uuids = append(uuids, uuid)
+	}
+	sort.Strings(uuids)
+
+	var picked *sharedGPU
+	var pickedLeft uint64
+	for _, uuid := range uuids {
+		shared := sharedGPUs[uuid]
+		if _, ok := shared.memoryLimits[podUID]; ok {
+			continue
+		}
+		total, err := totalMemoryMiB(uuid)
+		if err != nil {
+			Logger.Error("Failed to get memory of GPU: ", uuid)
+			Logger.Error(err)
+			continue
+		}
+		allocated := shared.allocatedMiB()
+		if allocated > total || total-allocated < memoryLimitMiB {
+			continue
+		}
+		if left := total - allocated - memoryLimitMiB; picked == nil || left < pickedLeft {
+			picked, pickedLeft = shared, left
+		}
+	}
+	return picked
+}
+
+func totalMemoryMiB(uuid string) (uint64, error) {
+	return (&device.GPU{Vendor: device.GetVendor(), UUID: uuid}).GetTotalMemoryMiB()
+}
+
+// getSharedGPU returns a shared gpu with memory left for the memory limit, a new gpu is reserved if none fits.
+// The mount is recorded by the records of newRecords before sharedMu is released,
+// so the gpu is neither released nor over allocated until it is unmounted.
+func (gpuMountImpl GPUMountImpl) getSharedGPU(ctx context.Context, pod *corev1.Pod, request *gpu_mount.AddGPURequest, newRecords func([]*device.GPU) []*ledger.Record) ([]*device.GPU, []*ledger.Record, error) {
+	if request.GpuNum != 1 || request.IsEntireMount || request.MemoryLimitMib == 0 {
+		Logger.Error("Invalid shared mount, gpu num: ", request.GpuNum, " is entire mount: ", request.IsEntireMount, " memory limit: ", request.MemoryLimitMib)
+		return nil, nil, errors.New("shared mount should have gpu num 1, memory limit greater than 0 and no entire mount")
+	}
+	sharedMu.Lock()
+	if shared := pickSharedGPU(sharedGPUsOf(gpuMountImpl.Ledger.List()), string(pod.UID), request.MemoryLimitMib, totalMemoryMiB); shared != nil {
+		defer sharedMu.Unlock()
+		Logger.Info("Sharing GPU: ", shared.uuid, " of Slave Pod: ", shared.slavePodName, " with Pod: ", pod.Name, " Namespace: ", pod.Namespace)
+		return gpuMountImpl.recordSharedMount(pod, []*device.GPU{shared.device()}, newRecords)
+	}
+
+	// the slave pod is created without sharedMu, its gpu is not visible to other shared mounts until it is recorded
+	reservingShared++
+	sharedMu.Unlock()
+	gpuDev, err := gpuMountImpl.GetSharedGPU(ctx, pod)
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	reservingShared--
+	if err != nil {
+		return nil, nil, err
+	}
+	// the shared slave pod is released by EnforceSharedGPUs if it is not used
+	total, err := totalMemoryMiB(gpuDev.UUID)
+	if err != nil {
+		Logger.Error("Failed to get memory of GPU: ", gpuDev.UUID)
+		Logger.Error(err)
+		return nil, nil, errors.New(gpu.FailedCreated)
+	}
+	if total < request.MemoryLimitMib {
+		return nil, nil, &insufficientMemoryError{memoryLimitMiB: request.MemoryLimitMib}
+	}
+	Logger.Info("Reserved shared GPU: ", gpuDev.UUID, " by Slave Pod: ", gpuDev.PodName, " for Pod: ", pod.Name, " Namespace: ", pod.Namespace)
+	return gpuMountImpl.recordSharedMount(pod, []*device.GPU{gpuDev}, newRecords)
+}
+
+// recordSharedMount puts the records of mounting the shared gpus, the caller should hold sharedMu
+func (gpuMountImpl GPUMountImpl) recordSharedMount(pod *corev1.Pod, gpus []*device.GPU, newRecords func([]*device.GPU) []*ledger.Record) ([]*device.GPU, []*ledger.Record, error) {
+	records := newRecords(gpus)
+	if err := gpuMountImpl.Ledger.Put(records...); err != nil {
+		Logger.Error("Failed to record mounting shared GPU of Pod: ", pod.Name, " Namespace: ", pod.Namespace)
+		return nil, nil, err
+	}
+	return gpus, records, nil
+}
+
+// sharedGPUsOfPod returns the shared gpus mounted into the pod, keyed by uuid
+func (gpuMountImpl GPUMountImpl) sharedGPUsOfPod(pod *corev1.Pod) map[string]*device.GPU {
+	gpus := make(map[string]*device.GPU)
+	for _, record := range gpuMountImpl.Ledger.List() {
+		if record.Shared && record.State == ledger.StateMounted && record.Namespace == pod.Namespace && record.PodName == pod.Name && record.PodUID == string(pod.UID) {
+			gpus[record.UUID] = (&sharedGPU{uuid: record.UUID, slavePodName: record.SlavePodName, record: record}).device()
+		}
+	}
+	return gpus
+}
+
+// memoryLimitOf returns the memory limit of the pod on the shared gpu, 0 if the gpu is not shared
+func (gpuMountImpl GPUMountImpl) memoryLimitOf(namespace string, podName string, uuid string) uint64 {
+	for _, record := range gpuMountImpl.Ledger.List() {
+		if record.Shared && record.Namespace == namespace && record.PodName == podName && record.UUID == uuid {
+			return record.MemoryLimitMiB
+		}
+	}
+	return 0
+}
+
+// EnforceSharedGPUs kills the processes of pods using more gpu memory than their limit on shared gpus,
+// drops the records of deleted pods and releases the shared slave pods no pod uses
+func (gpuMountImpl GPUMountImpl) EnforceSharedGPUs() {
+	clientset, err := config.GetClientSet()
+	if err != nil {
+		Logger.Error("Connect to k8s failed")
+		return
+	}
+	pods := groupByPod(gpuMountImpl.Ledger.List(), func(record *ledger.Record) bool { return record.Shared })
+	for owner, mounts := range pods {
+		pod, err := clientset.CoreV1().Pods(owner.Namespace).Get(context.TODO(), owner.Name, metav1.GetOptions{})
+		if err != nil && !k8s_error.IsNotFound(err) {
+			Logger.Error("Failed to get Pod: ", owner.Name, " Namespace: ", owner.Namespace)
+			Logger.Error(err)
+			continue
+		}
+		if err != nil || string(pod.UID) != mounts.records[0].PodUID {
+			// the owner pod is deleted or recreated
+			for _, record := range mounts.records {
+				gpuMountImpl.reconcileRecord(record, nil)
+			}
+			continue
+		}
+		for _, uuid := range mounts.uuids {
+			var records []*ledger.Record
+			for _, record := range mounts.records {
+				if record.UUID == uuid {
+					records = append(records, record)
+				}
+			}
+			gpuMountImpl.enforceMemoryLimit(pod, records)
+		}
+	}
+	gpuMountImpl.releaseUnusedSharedSlavePods()
+}
+
+// enforceMemoryLimit kills the processes of the pod on the shared gpu of the records if they use more memory than its limit
+func (gpuMountImpl GPUMountImpl) enforceMemoryLimit(pod *corev1.Pod, records []*ledger.Record) {
+	gpuDev := records[0].GPU()
+	limit := records[0].MemoryLimitMiB
+	var usedBytes uint64
+	processes := make(map[string][]string)
+	containers := make(map[string]corev1.ContainerStatus)
+	for _, record := range records {
+		targetContainers, err := util.GetTargetContainers(pod, record.ContainerName, false)
+		if err != nil {
+			continue
+		}
+		processInfos, err := util.GetPodGPUProcessInfos(pod, targetContainers[0], gpuDev)
+		if err != nil {
+			Logger.Error("Failed to get processes on GPU: ", record.UUID, " of Pod: ", pod.Name, " Namespace: ", pod.Namespace)
+			Logger.Error(err)
+			return
+		}
+		containers[record.ContainerName] = targetContainers[0]
+		for _, processInfo := range processInfos {
+			processes[record.ContainerName] = append(processes[record.ContainerName], strconv.Itoa(int(processInfo.Pid)))
+			// nvml reports the max value if the memory usage is not available
+			if processInfo.UsedGPUMemory != math.MaxUint64 {
+				usedBytes += processInfo.UsedGPUMemory
+			}
+		}
+	}
+	usedMiB := usedBytes / device.MiB
+	if usedMiB <= limit {
+		return
+	}
+
+	Logger.Warn("Pod: ", pod.Name, " Namespace: ", pod.Namespace, " uses ", usedMiB, " MiB on shared GPU: ", gpuDev.UUID, " over its limit ", limit, " MiB")
+	var killed []string
+	for containerName, pids := range processes {
+		if err := util.KillPodProcesses(pod, containers[containerName], pids); err != nil {
+			Logger.Error("Failed to kill processes: ", strings.Join(pids, ", "), " of Pod: ", pod.Name, " Container: ", containerName)
+			Logger.Error(err)
+			continue
+		}
+		killed = append(killed, pids...)
+	}
+	if len(killed) == 0 {
+		return
+	}
+	sort.Strings(killed)
+	gpuMountImpl.recordEvent(pod, corev1.EventTypeWarning, event.ReasonGPUMemoryExceeded,
+		"Killed processes %s on shared GPU %s, they used %d MiB over the limit of %d MiB", strings.Join(killed, ", "), gpuDev.UUID, usedMiB, limit)
+}
+
+// releaseUnusedSharedSlavePods deletes the shared slave pods on the node whose gpu is not recorded in the ledger
+func (gpuMountImpl GPUMountImpl) releaseUnusedSharedSlavePods() {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	if reservingShared > 0 {
+		return
+	}
+	nodeName := os.Getenv("NODE_NAME")
+	slavePodOwners, err := getSlavePodOwners(nodeName)
+	if err != nil {
+		Logger.Error("Failed to get slave pods on Node: ", nodeName)
+		Logger.Error(err)
+		return
+	}
+	used := make(map[string]bool)
+	for _, record := range gpuMountImpl.Ledger.List() {
+		used[record.SlavePodName] = true
+	}
+	clientset, err := config.GetClientSet()
+	if err != nil {
+		Logger.Error("Connect to k8s failed")
+		return
+	}
+	for slavePodName := range slavePodOwners {
+		if !gpu.IsSharedSlavePod(slavePodName) || used[slavePodName] {
+			continue
+		}
+		Logger.Info("Shared GPU of Slave Pod: ", slavePodName, " is not used, releasing")
+		err := clientset.CoreV1().Pods(gpu.GPUPoolNamespace).Delete(context.TODO(), slavePodName, *metav1.NewDeleteOptions(0))
+		if err != nil && !k8s_error.IsNotFound(err) {
+			Logger.Error("Failed to release shared Slave Pod: ", slavePodName)
+			Logger.Error(err)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package gpu_mount
+
+import (
+	gpu_mount "GPUMounter/pkg/api/gpu-mount"
+	"GPUMounter/pkg/device"
+	"GPUMounter/pkg/util/gpu"
+	"GPUMounter/pkg/util/ledger"
+	. "GPUMounter/pkg/util/log"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := ioutil.TempDir("", "gpu-mount")
+	if err != nil {
+		panic(err)
+	}
+	InitLogger(dir+"/", "log")
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestPickSharedGPU(t *testing.T) {
+	records := []*ledger.Record{
+		{PodName: "a", PodUID: "uid-a", ContainerName: "c1", UUID: "GPU-0", SlavePodName: "shared-gpu-000000", Shared: true, MemoryLimitMiB: 8192},
+		// the limit of a pod mounting the gpu into several containers is counted once
+		{PodName: "a", PodUID: "uid-a", ContainerName: "c2", UUID: "GPU-0", SlavePodName: "shared-gpu-000000", Shared: true, MemoryLimitMiB: 8192},
+		{PodName: "b", PodUID: "uid-b", ContainerName: "c1", UUID: "GPU-1", SlavePodName: "shared-gpu-111111", Shared: true, MemoryLimitMiB: 4096},
+		{PodName: "c", PodUID: "uid-c", ContainerName: "c1", UUID: "GPU-2", SlavePodName: "gpu-pod-slave-pod-222222"},
+	}
+	sharedGPUs := sharedGPUsOf(records)
+	if len(sharedGPUs) != 2 || sharedGPUs["GPU-0"].allocatedMiB() != 8192 {
+		t.Fatalf("unexpected shared gpus: %+v", sharedGPUs)
+	}
+	totalMemoryMiB := func(string) (uint64, error) { return 16384, nil }
+
+	tests := []struct {
+		podUID         string
+		memoryLimitMiB uint64
+		want           string
+	}{
+		// the gpu with the least memory left is picked
+		{podUID: "uid-d", memoryLimitMiB: 4096, want: "GPU-0"},
+		{podUID: "uid-d", memoryLimitMiB: 12288, want: "GPU-1"},
+		{podUID: "uid-d", memoryLimitMiB: 16384, want: ""},
+		// a pod does not share a gpu with itself
+		{podUID: "uid-a", memoryLimitMiB: 4096, want: "GPU-1"},
+	}
+	for _, test := range tests {
+		picked := pickSharedGPU(sharedGPUs, test.podUID, test.memoryLimitMiB, totalMemoryMiB)
+		got := ""
+		if picked != nil {
+			got = picked.uuid
+		}
+		if got != test.want {
+			t.Errorf("pod %s with %d MiB: got %q, want %q", test.podUID, test.memoryLimitMiB, got, test.want)
+		}
+	}
+}
+
+func TestIsSharedSlavePod(t *testing.T) {
+	for name, want := range map[string]bool{
+		"shared-gpu-2f66ed":                  true,
+		"gpu-pod-slave-pod-2f66ed":           false,
+		"shared-gpu-pod-slave-pod-2f66ed":    false,
+		"shared-gpu-2f66ed-slave-pod-abcdef": false,
+	} {
+		if got := gpu.IsSharedSlavePod(name); got != want {
+			t.Errorf("%s: got %t, want %t", name, got, want)
+		}
+	}
+}
+
+func TestGetSharedGPURecordsMount(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// the gpus of the fixture have 16160 MiB
+	defer os.Unsetenv("NVML_FIXTURE")
+	os.Setenv("NVML_FIXTURE", "../../util/gpu/collector/nvml/testdata/fixture.yaml")
+
+	mountLedger, err := ledger.NewLedger(filepath.Join(dir, "ledger.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	uuid := "GPU-b9e0e5ce-3b0d-4a4b-8a4c-7f3c2a7b1d01"
+	if err := mountLedger.Put(&ledger.Record{Namespace: "default", PodName: "a", PodUID: "uid-a", ContainerName: "c1",
+		UUID: uuid, SlavePodName: "shared-gpu-000000", Shared: true, MemoryLimitMiB: 8192, State: ledger.StateMounted}); err != nil {
+		t.Fatal(err)
+	}
+	gpuMountImpl := GPUMountImpl{Ledger: mountLedger}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "b", Namespace: "default", UID: "uid-b"}}
+	container := corev1.ContainerStatus{Name: "c1"}
+	newRecords := func(gpus []*device.GPU) []*ledger.Record {
+		var records []*ledger.Record
+		for _, gpuDev := range gpus {
+			record := newRecord(pod, container, gpuDev, ledger.StateMounting)
+			record.Shared = true
+			record.MemoryLimitMiB = 4096
+			records = append(records, record)
+		}
+		return records
+	}
+
+	gpus, records, err := gpuMountImpl.getSharedGPU(context.TODO(), pod, &gpu_mount.AddGPURequest{GpuNum: 1, Shared: true, MemoryLimitMib: 4096}, newRecords)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gpus) != 1 || gpus[0].UUID != uuid || gpus[0].PodName != "shared-gpu-000000" || len(records) != 1 {
+		t.Fatalf("unexpected shared gpu: %v, records: %v", gpus, records)
+	}
+	// the mount is recorded before sharedMu is released
+	if recorded := mountLedger.Get("default", "b", "c1", uuid); recorded == nil || recorded.State != ledger.StateMounting {
+		t.Errorf("shared mount is not recorded: %+v", recorded)
+	}
+	released := make(chan struct{})
+	go func() {
+		sharedMu.Lock()
+		sharedMu.Unlock()
+		close(released)
+	}()
+	select {
+	case <-released:
+	case <-time.After(time.Second):
+		t.Fatalf("sharedMu should not be held after the mount is recorded")
+	}
+}
@@ -0,0 +1,123 @@
+package util
+
+import (
+	"GPUMounter/pkg/device"
+	"GPUMounter/pkg/util/cgroup"
+	"GPUMounter/pkg/util/driver"
+	. "GPUMounter/pkg/util/log"
+	"GPUMounter/pkg/util/namespace"
+	"os"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// HasControlDevices checks whether the nvidiactl device file exists in the container,
+// which is missing if the container is started without gpus and not bootstrapped
+func HasControlDevices(pod *corev1.Pod, container corev1.ContainerStatus) (bool, error) {
+	_, PID, err := getContainerProcess(pod, container)
+	if err != nil {
+		return false, err
+	}
+	return deviceFileExists(PID, device.NVIDIA_CTL_DEVICE_FILE)
+}
+
+// BootstrapDriver makes the driver usable in a container started without gpus, it creates and allows
+// the control device files and mounts the driver libraries and binaries of the host into the container
+func BootstrapDriver(pod *corev1.Pod, container corev1.ContainerStatus) error {
+	Logger.Info("Start bootstrapping driver into Pod: ", pod.Name, " Container: ", container.Name)
+	cgroupPath, PID, err := getContainerProcess(pod, container)
+	if err != nil {
+		return err
+	}
+	files, err := device.GetControlDeviceFiles()
+	if err != nil {
+		Logger.Error("Failed to get control device files")
+		return err
+	}
+	nvidiaDriver, err := driver.Discover()
+	if err != nil {
+		Logger.Error("Failed to discover driver files under driver root: ", driver.GetDriverRoot())
+		return err
+	}
+
+	if err := cgroup.AddDevicePermission(cgroupPath, files); err != nil {
+		Logger.Error("Add control devices permission for Pod: ", pod.Name, " Container: ", container.Name, " failed")
+		return err
+	}
+	cfg := &namespace.Config{
+		Mount:  true, // Execute into mount namespace
+		Target: PID,  // Enter into Target namespace
+	}
+	if err := namespace.AddDeviceFiles(cfg, files); err != nil {
+		Logger.Error("Failed to create control device files in Target PID Namespace: ", PID, " Pod: ", pod.Name, " Namespace: ", pod.Namespace)
+		undoDriverBootstrap(cgroupPath, PID, files)
+		return err
+	}
+	if err := namespace.MountTmpfs(PID, driver.ContainerDir, nvidiaDriver.Mounts, nvidiaDriver.Symlinks); err != nil {
+		Logger.Error("Failed to mount driver files in Target PID Namespace: ", PID, " Pod: ", pod.Name, " Namespace: ", pod.Namespace)
+		Logger.Error(err)
+		undoDriverBootstrap(cgroupPath, PID, files)
+		return err
+	}
+	Logger.Info("Successfully bootstrap driver ", nvidiaDriver.Version, " with ", len(nvidiaDriver.Mounts), " files into Pod: ", pod.Name, " Container: ", container.Name)
+	return nil
+}
+
+// UndoDriverBootstrap unmounts the driver files and removes the control device files from the container
+func UndoDriverBootstrap(pod *corev1.Pod, container corev1.ContainerStatus) error {
+	Logger.Info("Start undoing driver bootstrap of Pod: ", pod.Name, " Container: ", container.Name)
+	cgroupPath, PID, err := getContainerProcess(pod, container)
+	if err != nil {
+		return err
+	}
+	files, err := device.GetControlDeviceFiles()
+	if err != nil {
+		Logger.Error("Failed to get control device files")
+		return err
+	}
+	return undoDriverBootstrap(cgroupPath, PID, files)
+}
+
+func undoDriverBootstrap(cgroupPath string, PID int, files []device.DeviceFile) error {
+	if err := namespace.UnmountTmpfs(PID, driver.ContainerDir); err != nil {
+		Logger.Error("Failed to unmount driver files in Target PID Namespace: ", PID)
+		Logger.Error(err)
+		return err
+	}
+	// the bootstrap may be interrupted before all device files are created
+	var existingFiles []device.DeviceFile
+	for _, file := range files {
+		exists, err := deviceFileExists(PID, file.Path)
+		if err != nil {
+			return err
+		}
+		if exists {
+			existingFiles = append(existingFiles, file)
+		}
+	}
+	cfg := &namespace.Config{
+		Mount:  true, // Execute into mount namespace
+		Target: PID,  // Enter into Target namespace
+	}
+	if err := namespace.RemoveDeviceFiles(cfg, existingFiles); err != nil {
+		Logger.Error("Failed to remove control device files in Target PID Namespace: ", PID)
+		return err
+	}
+	if err := cgroup.RemoveDevicePermission(cgroupPath, files); err != nil {
+		Logger.Error("Remove control devices permission of cgroup: ", cgroupPath, " failed")
+		return err
+	}
+	return nil
+}
+
+func deviceFileExists(PID int, path string) (bool, error) {
+	info, err := os.Stat("/proc/" + strconv.Itoa(PID) + "/root" + path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return info.Mode()&os.ModeCharDevice != 0, nil
+}
@@ -26,6 +26,49 @@ const (
 	systemdSuffix string = ".slice"
 )
 
+// ContainerRuntime is the runtime a container is run by, parsed from the scheme of its container ID
+type ContainerRuntime string
+
+const (
+	Docker     ContainerRuntime = "docker"
+	Containerd ContainerRuntime = "containerd"
+	CRIO       ContainerRuntime = "cri-o"
+)
+
+// ParseContainerID splits the container ID reported in pod status, such as "containerd://<id>",
+// into the container runtime and the runtime specific container ID
+func ParseContainerID(containerID string) (ContainerRuntime, string, error) {
+	parts := strings.SplitN(containerID, "://", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid container ID: %q", containerID)
+	}
+	switch runtime := ContainerRuntime(parts[0]); runtime {
+	case Docker, Containerd, CRIO:
+		return runtime, parts[1], nil
+	default:
+		return "", "", fmt.Errorf("unsupported container runtime: %s", parts[0])
+	}
+}
+
+// containerCgroupName returns the name of the container cgroup under the pod cgroup,
+// which is named by the container runtime after the cgroup driver in use
+func containerCgroupName(cgroupDriver string, runtime ContainerRuntime, id string) string {
+	if cgroupDriver == "systemd" {
+		switch runtime {
+		case Containerd:
+			return "cri-containerd-" + id + ".scope"
+		case CRIO:
+			return "crio-" + id + ".scope"
+		default:
+			return "docker-" + id + ".scope"
+		}
+	}
+	if runtime == CRIO {
+		return "crio-" + id
+	}
+	return id
+}
+
 // NewCgroupName composes a new cgroup name.
 // Use RootCgroupName as base to start at the root.
 // This function does some basic check for invalid characters at the name.
@@ -83,7 +126,14 @@ func GetCgroupDriver() (string, error) {
 	return cgroupDriver, nil
 }
 
+// GetCgroupName returns the cgroup path of the container, containerID is the container ID in pod status
+// with the runtime scheme, such as "docker://<id>"
 func GetCgroupName(cgroupDriver string, pod *corev1.Pod, containerID string) (string, error) {
+	runtime, id, err := ParseContainerID(containerID)
+	if err != nil {
+		return "", err
+	}
+
 	containerRoot := NewCgroupName([]string{}, "kubepods")
 	PodCgroupNamePrefix := "pod"
 	podQos := GetPodQOS(pod)
@@ -103,9 +153,9 @@ func GetCgroupName(cgroupDriver string, pod *corev1.Pod, containerID string) (st
 
 	switch cgroupDriver {
 	case "systemd":
-		return fmt.Sprintf("%s/%s-%s.scope", cgroupName.ToSystemd(), "docker", containerID), nil
+		return fmt.Sprintf("%s/%s", cgroupName.ToSystemd(), containerCgroupName(cgroupDriver, runtime, id)), nil
 	case "cgroupfs":
-		return fmt.Sprintf("%s/%s", cgroupName.ToCgroupfs(), containerID), nil
+		return fmt.Sprintf("%s/%s", cgroupName.ToCgroupfs(), containerCgroupName(cgroupDriver, runtime, id)), nil
 	default:
 	}
 
@@ -113,6 +163,9 @@ func GetCgroupName(cgroupDriver string, pod *corev1.Pod, containerID string) (st
 }
 
 func GetDeviceGroupPath(cgroupPath string) string {
+	if IsCgroupV2() {
+		return unifiedMountpoint + cgroupPath
+	}
 	deviceCgroupPath := "/sys/fs/cgroup/devices" + cgroupPath
 	return deviceCgroupPath
 }
@@ -140,32 +193,49 @@ func GetCgroupPIDs(cgroupPath string) ([]string, error) {
 	return pids, nil
 }
 
-func AddGPUDevicePermission(cgroupPath string, gpu *device.NvidiaGPU) error {
-	deviceCgroupPath := GetDeviceGroupPath(cgroupPath)
-	cmd := "echo 'c " + strconv.Itoa(device.DEFAULT_NVIDA_MAJOR_NUMBER) + ":" + strconv.Itoa(gpu.MinorNumber) + " " + device.DEFAULT_CGROUP_PERMISSION + "' > " + deviceCgroupPath + "/devices.allow"
-	out, err := exec.Command("sh", "-c", cmd).CombinedOutput()
-	if err != nil {
-		Logger.Error("Exec \"" + cmd + "\" failed")
-		Logger.Error("Output: " + string(out))
-		Logger.Error(err)
-		return err
-	} else {
-		return nil
+func AddGPUDevicePermission(cgroupPath string, gpu device.Device) error {
+	files := gpu.DeviceFiles()
+	for _, file := range files {
+		// 0 is no character device, e.g. /dev/kfd without the kfd module loaded
+		if file.MajorNumber == 0 {
+			return fmt.Errorf("unknown major number of device file %s", file.Path)
+		}
 	}
+	return AddDevicePermission(cgroupPath, files)
 }
 
-func RemoveGPUDevicePermission(cgroupPath string, gpu *device.NvidiaGPU) error {
-	deviceCgroupPath := GetDeviceGroupPath(cgroupPath)
-	cmd := "echo 'c " + strconv.Itoa(device.DEFAULT_NVIDA_MAJOR_NUMBER) + ":" + strconv.Itoa(gpu.MinorNumber) + " " + device.DEFAULT_CGROUP_PERMISSION + "' > " + deviceCgroupPath + "/devices.deny"
-	out, err := exec.Command("sh", "-c", cmd).CombinedOutput()
-	if err != nil {
-		Logger.Error("Exec \"" + cmd + "\" failed")
-		Logger.Error("Output: " + string(out))
-		Logger.Error(err)
-		return err
-	} else {
-		return nil
+func RemoveGPUDevicePermission(cgroupPath string, gpu device.Device) error {
+	return RemoveDevicePermission(cgroupPath, gpu.DeviceFiles())
+}
+
+// AddDevicePermission allows the container to access the device files
+func AddDevicePermission(cgroupPath string, files []device.DeviceFile) error {
+	if IsCgroupV2() {
+		return updateDeviceFilter(cgroupPath, files, true)
+	}
+	return writeDeviceRules(GetDeviceGroupPath(cgroupPath)+"/devices.allow", files)
+}
+
+// RemoveDevicePermission denies the container to access the device files
+func RemoveDevicePermission(cgroupPath string, files []device.DeviceFile) error {
+	if IsCgroupV2() {
+		return updateDeviceFilter(cgroupPath, files, false)
+	}
+	return writeDeviceRules(GetDeviceGroupPath(cgroupPath)+"/devices.deny", files)
+}
+
+func writeDeviceRules(path string, files []device.DeviceFile) error {
+	for _, file := range files {
+		cmd := "echo 'c " + strconv.Itoa(file.MajorNumber) + ":" + strconv.Itoa(file.MinorNumber) + " " + device.DEFAULT_CGROUP_PERMISSION + "' > " + path
+		out, err := exec.Command("sh", "-c", cmd).CombinedOutput()
+		if err != nil {
+			Logger.Error("Exec \"" + cmd + "\" failed")
+			Logger.Error("Output: " + string(out))
+			Logger.Error(err)
+			return err
+		}
 	}
+	return nil
 }
 
 var supportedQoSComputeResources = sets.NewString(string(corev1.ResourceCPU), string(corev1.ResourceMemory))
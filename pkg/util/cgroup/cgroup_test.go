@@ -6,9 +6,11 @@ import (
 	"context"
 	"fmt"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"os/exec"
-	"strings"
 	"testing"
+
+	corev1 "k8s.io/api/core/v1"
 )
 
 func TestGetCgroupName(t *testing.T) {
@@ -21,7 +23,6 @@ func TestGetCgroupName(t *testing.T) {
 		panic(err)
 	}
 	containerID := pod.Status.ContainerStatuses[0].ContainerID
-	containerID = strings.Replace(containerID, "docker://", "", 1)
 	fmt.Println(containerID)
 	cgroupDriver, err := GetCgroupDriver()
 	if err != nil {
@@ -70,3 +71,60 @@ func TestGetCgroupPID(t *testing.T) {
 		fmt.Println(idx, " : "+pid+"-")
 	}
 }
+
+func TestParseContainerID(t *testing.T) {
+	cases := []struct {
+		containerID string
+		runtime     ContainerRuntime
+		id          string
+		isErr       bool
+	}{
+		{"docker://abc123", Docker, "abc123", false},
+		{"containerd://abc123", Containerd, "abc123", false},
+		{"cri-o://abc123", CRIO, "abc123", false},
+		{"rkt://abc123", "", "", true},
+		{"abc123", "", "", true},
+		{"docker://", "", "", true},
+	}
+	for _, c := range cases {
+		runtime, id, err := ParseContainerID(c.containerID)
+		if (err != nil) != c.isErr {
+			t.Errorf("%s: expected error %v, got %v", c.containerID, c.isErr, err)
+			continue
+		}
+		if runtime != c.runtime || id != c.id {
+			t.Errorf("%s: expected (%s, %s), got (%s, %s)", c.containerID, c.runtime, c.id, runtime, id)
+		}
+	}
+}
+
+func TestGetCgroupNameRuntimes(t *testing.T) {
+	pod := &corev1.Pod{}
+	pod.UID = types.UID("1234-abcd")
+	cases := []struct {
+		cgroupDriver string
+		containerID  string
+		cgroupName   string
+	}{
+		{"systemd", "docker://abc123", "/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod1234_abcd.slice/docker-abc123.scope"},
+		{"systemd", "containerd://abc123", "/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod1234_abcd.slice/cri-containerd-abc123.scope"},
+		{"systemd", "cri-o://abc123", "/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod1234_abcd.slice/crio-abc123.scope"},
+		{"cgroupfs", "docker://abc123", "/kubepods/besteffort/pod1234-abcd/abc123"},
+		{"cgroupfs", "containerd://abc123", "/kubepods/besteffort/pod1234-abcd/abc123"},
+		{"cgroupfs", "cri-o://abc123", "/kubepods/besteffort/pod1234-abcd/crio-abc123"},
+	}
+	for _, c := range cases {
+		cgroupName, err := GetCgroupName(c.cgroupDriver, pod, c.containerID)
+		if err != nil {
+			t.Errorf("%s %s: unexpected error: %v", c.cgroupDriver, c.containerID, err)
+			continue
+		}
+		if cgroupName != c.cgroupName {
+			t.Errorf("%s %s: expected %s, got %s", c.cgroupDriver, c.containerID, c.cgroupName, cgroupName)
+		}
+	}
+
+	if _, err := GetCgroupName("systemd", pod, "abc123"); err == nil {
+		t.Errorf("container ID without runtime scheme should be rejected")
+	}
+}
@@ -0,0 +1,350 @@
+package cgroup
+
+import (
+	"GPUMounter/pkg/device"
+	. "GPUMounter/pkg/util/log"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"github.com/cilium/ebpf"
+	"github.com/opencontainers/runc/libcontainer/cgroups"
+	"github.com/opencontainers/runc/libcontainer/cgroups/ebpf/devicefilter"
+	"github.com/opencontainers/runc/libcontainer/configs"
+	"github.com/opencontainers/runc/libcontainer/specconv"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// unifiedMountpoint is the mount point of cgroup v2 unified hierarchy
+	unifiedMountpoint = "/sys/fs/cgroup"
+	// maxDeviceFilters is the max number of device programs queried from a cgroup
+	maxDeviceFilters = 64
+	// hostRootPath is the root of the host seen by the worker running in the host PID namespace
+	hostRootPath = "/proc/1/root"
+)
+
+// containerSpecPaths are where the container runtimes keep the OCI spec of the container bundle on the host,
+// formatted with the container ID
+var containerSpecPaths = []string{
+	// containerd with the runc v2 shim, and docker 20.10+ on containerd
+	hostRootPath + "/run/containerd/io.containerd.runtime.v2.task/k8s.io/%s/config.json",
+	hostRootPath + "/run/containerd/io.containerd.runtime.v2.task/moby/%s/config.json",
+	// containerd with the runc v1 shim, and docker before 20.10
+	hostRootPath + "/run/containerd/io.containerd.runtime.v1.linux/k8s.io/%s/config.json",
+	hostRootPath + "/run/docker/containerd/daemon/io.containerd.runtime.v1.linux/moby/%s/config.json",
+	// cri-o
+	hostRootPath + "/run/containers/storage/overlay-containers/%s/userdata/config.json",
+	hostRootPath + "/var/lib/containers/storage/overlay-containers/%s/userdata/config.json",
+}
+
+// deviceFilterMu serializes the rebuilding of device programs, which reads and updates the device grants
+var deviceFilterMu sync.Mutex
+
+// IsCgroupV2 reports whether the host is running in cgroup v2 unified mode,
+// where device access is controlled by BPF_PROG_TYPE_CGROUP_DEVICE programs
+func IsCgroupV2() bool {
+	return cgroups.IsCgroup2UnifiedMode()
+}
+
+// deviceRule returns the rule of the character device file
+func deviceRule(file device.DeviceFile, allow bool) *configs.DeviceRule {
+	return &configs.DeviceRule{
+		Type:        configs.CharDevice,
+		Major:       int64(file.MajorNumber),
+		Minor:       int64(file.MinorNumber),
+		Permissions: device.DEFAULT_CGROUP_PERMISSION,
+		Allow:       allow,
+	}
+}
+
+// withDeviceRules returns the device rules with the rules of the device files replaced by allow or deny rules
+func withDeviceRules(rules []*configs.DeviceRule, files []device.DeviceFile, allow bool) []*configs.DeviceRule {
+	var fileRules []*configs.DeviceRule
+	for _, file := range files {
+		fileRules = append(fileRules, deviceRule(file, allow))
+	}
+	var newRules []*configs.DeviceRule
+	for _, rule := range rules {
+		replaced := false
+		for _, fileRule := range fileRules {
+			if rule.Type == fileRule.Type && rule.Major == fileRule.Major && rule.Minor == fileRule.Minor {
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			newRules = append(newRules, rule)
+		}
+	}
+	// the last matched rule takes effect in device filter program
+	return append(newRules, fileRules...)
+}
+
+// containerDeviceRules rebuilds the device rules of the container, which are the rules in the OCI spec
+// the container runtime created the container with plus the device files granted by GPUMounter.
+// Device files in the container are not trusted, they may be created by the container with CAP_MKNOD.
+func containerDeviceRules(cgroupPath string) ([]*configs.DeviceRule, error) {
+	specPath, err := findContainerSpec(containerIDOfCgroup(cgroupPath))
+	if err != nil {
+		return nil, err
+	}
+	rules, err := specDeviceRules(specPath)
+	if err != nil {
+		Logger.Error("Failed to read device rules of OCI spec: ", specPath)
+		return nil, err
+	}
+	granted, err := grantedDevices.get(cgroupPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range granted {
+		rules = append(rules, deviceRule(file, true))
+	}
+	return rules, nil
+}
+
+// containerIDOfCgroup returns the container ID of the container cgroup named by containerCgroupName
+func containerIDOfCgroup(cgroupPath string) string {
+	name := strings.TrimSuffix(filepath.Base(cgroupPath), ".scope")
+	for _, prefix := range []string{"cri-containerd-", "crio-", "docker-"} {
+		if strings.HasPrefix(name, prefix) {
+			return strings.TrimPrefix(name, prefix)
+		}
+	}
+	return name
+}
+
+// findContainerSpec returns the path of the OCI spec in the bundle of the container
+func findContainerSpec(id string) (string, error) {
+	for _, specPath := range containerSpecPaths {
+		specPath = fmt.Sprintf(specPath, id)
+		if _, err := os.Stat(specPath); err == nil {
+			return specPath, nil
+		}
+	}
+	return "", fmt.Errorf("no OCI spec of container: %s", id)
+}
+
+// specDeviceRules returns the device rules applied by runc for the OCI spec,
+// which are linux.resources.devices followed by the default allowed devices
+func specDeviceRules(specPath string) ([]*configs.DeviceRule, error) {
+	data, err := ioutil.ReadFile(specPath)
+	if err != nil {
+		return nil, err
+	}
+	var spec specs.Spec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, err
+	}
+	var rules []*configs.DeviceRule
+	if spec.Linux != nil && spec.Linux.Resources != nil {
+		for _, specRule := range spec.Linux.Resources.Devices {
+			rule := &configs.DeviceRule{
+				Type:        configs.WildcardDevice,
+				Major:       configs.Wildcard,
+				Minor:       configs.Wildcard,
+				Permissions: configs.DevicePermissions(specRule.Access),
+				Allow:       specRule.Allow,
+			}
+			switch specRule.Type {
+			case "", "a":
+			case "b":
+				rule.Type = configs.BlockDevice
+			case "c":
+				rule.Type = configs.CharDevice
+			default:
+				return nil, fmt.Errorf("invalid device type %q in OCI spec: %s", specRule.Type, specPath)
+			}
+			if specRule.Major != nil {
+				rule.Major = *specRule.Major
+			}
+			if specRule.Minor != nil {
+				rule.Minor = *specRule.Minor
+			}
+			rules = append(rules, rule)
+		}
+	}
+	for _, allowedDevice := range specconv.AllowedDevices {
+		rule := allowedDevice.DeviceRule
+		rules = append(rules, &rule)
+	}
+	return rules, nil
+}
+
+// queryDeviceFilters returns the id of device programs attached to the cgroup directory and the flags they are attached with
+func queryDeviceFilters(dirFD int) ([]ebpf.ProgramID, uint32, error) {
+	progIDs := make([]uint32, maxDeviceFilters)
+	// union bpf_attr for BPF_PROG_QUERY
+	attr := struct {
+		targetFD    uint32
+		attachType  uint32
+		queryFlags  uint32
+		attachFlags uint32
+		progIDs     uint64
+		progCnt     uint32
+	}{
+		targetFD:   uint32(dirFD),
+		attachType: unix.BPF_CGROUP_DEVICE,
+		progIDs:    uint64(uintptr(unsafe.Pointer(&progIDs[0]))),
+		progCnt:    maxDeviceFilters,
+	}
+	_, _, errno := unix.Syscall(unix.SYS_BPF, unix.BPF_PROG_QUERY, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr))
+	if errno != 0 {
+		return nil, 0, fmt.Errorf("failed to call BPF_PROG_QUERY (BPF_CGROUP_DEVICE): %v", errno)
+	}
+	var ids []ebpf.ProgramID
+	for _, id := range progIDs[:attr.progCnt] {
+		ids = append(ids, ebpf.ProgramID(id))
+	}
+	return ids, attr.attachFlags, nil
+}
+
+// attachDeviceFilter attaches the device program to the cgroup directory,
+// with BPF_F_REPLACE it replaces the program of replaceFD atomically
+func attachDeviceFilter(dirFD int, progFD int, flags uint32, replaceFD int) error {
+	// union bpf_attr for BPF_PROG_ATTACH
+	attr := struct {
+		targetFD     uint32
+		attachBpfFD  uint32
+		attachType   uint32
+		attachFlags  uint32
+		replaceBpfFD uint32
+	}{
+		targetFD:     uint32(dirFD),
+		attachBpfFD:  uint32(progFD),
+		attachType:   unix.BPF_CGROUP_DEVICE,
+		attachFlags:  flags,
+		replaceBpfFD: uint32(replaceFD),
+	}
+	_, _, errno := unix.Syscall(unix.SYS_BPF, unix.BPF_PROG_ATTACH, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr))
+	if errno != 0 {
+		return fmt.Errorf("failed to call BPF_PROG_ATTACH (BPF_CGROUP_DEVICE, flags %#x): %w", flags, errno)
+	}
+	return nil
+}
+
+// replaceDeviceFilter replaces the device program of oldProgID attached with BPF_F_ALLOW_MULTI, which needs Linux 5.6+
+func replaceDeviceFilter(dirFD int, progFD int, oldProgID ebpf.ProgramID) error {
+	oldProg, err := ebpf.NewProgramFromID(oldProgID)
+	if err != nil {
+		Logger.Error("Failed to get device program: ", oldProgID)
+		return err
+	}
+	defer oldProg.Close()
+	return attachDeviceFilter(dirFD, progFD, unix.BPF_F_ALLOW_MULTI|unix.BPF_F_REPLACE, oldProg.FD())
+}
+
+// detachDeviceFilter detaches the device program of progID from the cgroup directory
+func detachDeviceFilter(dirFD int, progID ebpf.ProgramID) error {
+	prog, err := ebpf.NewProgramFromID(progID)
+	if err != nil {
+		return err
+	}
+	defer prog.Close()
+	return prog.Detach(dirFD, ebpf.AttachCGroupDevice, 0)
+}
+
+// updateDeviceFilter replaces the device programs of the cgroup by a program allowing or denying the device files,
+// the rules applied by the container runtime and the device files granted before are preserved
+func updateDeviceFilter(cgroupPath string, files []device.DeviceFile, allow bool) error {
+	deviceFilterMu.Lock()
+	defer deviceFilterMu.Unlock()
+
+	dirPath := GetDeviceGroupPath(cgroupPath)
+	dirFD, err := unix.Open(dirPath, unix.O_DIRECTORY|unix.O_RDONLY, 0600)
+	if err != nil {
+		Logger.Error("Open cgroup directory: ", dirPath, " failed")
+		return err
+	}
+	defer unix.Close(dirFD)
+
+	oldProgIDs, attachFlags, err := queryDeviceFilters(dirFD)
+	if err != nil {
+		Logger.Error("Failed to query device programs of cgroup: ", dirPath)
+		return err
+	}
+	if len(oldProgIDs) == 0 {
+		// device access of the container is not restricted, e.g. privileged container
+		Logger.Info("No device program attached to cgroup: ", dirPath, ", skip updating device permission")
+		return nil
+	}
+
+	rules, err := containerDeviceRules(cgroupPath)
+	if err != nil {
+		return err
+	}
+	insts, license, err := devicefilter.DeviceFilter(withDeviceRules(rules, files, allow))
+	if err != nil {
+		Logger.Error("Failed to generate device program for cgroup: ", dirPath)
+		return err
+	}
+
+	// the memlock limit is raised as runc does, it is not inherited into the container
+	_ = unix.Setrlimit(unix.RLIMIT_MEMLOCK, &unix.Rlimit{Cur: unix.RLIM_INFINITY, Max: unix.RLIM_INFINITY})
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{Type: ebpf.CGroupDevice, Instructions: insts, License: license})
+	if err != nil {
+		Logger.Error("Failed to load device program for cgroup: ", dirPath)
+		return err
+	}
+	// the attached program is held by the cgroup
+	defer prog.Close()
+
+	if err := attachDeviceProgram(dirFD, dirPath, prog, oldProgIDs, attachFlags); err != nil {
+		return err
+	}
+	if err := grantedDevices.update(cgroupPath, files, allow); err != nil {
+		Logger.Error("Failed to record device files granted to cgroup: ", dirPath)
+		return err
+	}
+	return nil
+}
+
+// attachDeviceProgram replaces the device programs of oldProgIDs attached to the cgroup directory by prog
+func attachDeviceProgram(dirFD int, dirPath string, prog *ebpf.Program, oldProgIDs []ebpf.ProgramID, attachFlags uint32) error {
+	// the program is attached in the mode of the container runtime, the kernel refuses mixing modes in a cgroup
+	if attachFlags&unix.BPF_F_ALLOW_MULTI == 0 {
+		// e.g. crun and runc before 1.0.0-rc93, the single program is replaced by attaching with the same flags
+		if err := attachDeviceFilter(dirFD, prog.FD(), attachFlags, 0); err != nil {
+			Logger.Error("Failed to replace device program of cgroup: ", dirPath)
+			return err
+		}
+		return nil
+	}
+	if len(oldProgIDs) == 1 {
+		err := replaceDeviceFilter(dirFD, prog.FD(), oldProgIDs[0])
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, unix.EINVAL) {
+			Logger.Error("Failed to replace device program of cgroup: ", dirPath)
+			return err
+		}
+		Logger.Warn("BPF_F_REPLACE is not supported, attaching device program before detaching the old one")
+	}
+
+	// attach the new program before detaching the old ones, so that device access is never unrestricted
+	if err := attachDeviceFilter(dirFD, prog.FD(), unix.BPF_F_ALLOW_MULTI, 0); err != nil {
+		Logger.Error("Failed to attach device program to cgroup: ", dirPath)
+		return err
+	}
+	for _, progID := range oldProgIDs {
+		if err := detachDeviceFilter(dirFD, progID); err != nil {
+			Logger.Error("Failed to detach device program: ", progID, " from cgroup: ", dirPath)
+			// the old programs stay in charge, or the update would be half applied
+			if detachErr := prog.Detach(dirFD, ebpf.AttachCGroupDevice, 0); detachErr != nil {
+				Logger.Error("Failed to detach new device program from cgroup: ", dirPath)
+				Logger.Error(detachErr)
+			}
+			return err
+		}
+	}
+	return nil
+}
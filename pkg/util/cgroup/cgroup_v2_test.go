@@ -0,0 +1,128 @@
+package cgroup
+
+import (
+	"GPUMounter/pkg/device"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/runc/libcontainer/cgroups/ebpf/devicefilter"
+	"github.com/opencontainers/runc/libcontainer/configs"
+)
+
+func TestWithDeviceRules(t *testing.T) {
+	gpu := &device.GPU{MinorNumber: 1}
+	rules := []*configs.DeviceRule{
+		{Type: configs.CharDevice, Major: 1, Minor: 3, Permissions: "rwm", Allow: true},
+		{Type: configs.CharDevice, Major: device.DEFAULT_NVIDA_MAJOR_NUMBER, Minor: 0, Permissions: "rwm", Allow: true},
+		{Type: configs.CharDevice, Major: device.DEFAULT_NVIDA_MAJOR_NUMBER, Minor: 1, Permissions: "rwm", Allow: true},
+	}
+
+	for _, allow := range []bool{true, false} {
+		newRules := withDeviceRules(rules, gpu.DeviceFiles(), allow)
+		if len(newRules) != len(rules) {
+			t.Fatalf("allow %v: expected %d rules, got %d", allow, len(rules), len(newRules))
+		}
+		last := newRules[len(newRules)-1]
+		if last.Major != device.DEFAULT_NVIDA_MAJOR_NUMBER || last.Minor != 1 || last.Allow != allow {
+			t.Errorf("allow %v: unexpected gpu rule: %+v", allow, last)
+		}
+		// rules of other devices are preserved
+		if newRules[0] != rules[0] || newRules[1] != rules[1] {
+			t.Errorf("allow %v: rules of other devices are not preserved", allow)
+		}
+		if _, _, err := devicefilter.DeviceFilter(newRules); err != nil {
+			t.Errorf("allow %v: failed to generate device program: %v", allow, err)
+		}
+	}
+}
+
+func TestWithDeviceRulesMIG(t *testing.T) {
+	mig := device.NewMIG(0, "MIG-test", &device.MIGDevice{CapsMajorNumber: 236, GICapMinorNumber: 21, CICapMinorNumber: 22})
+	rules := []*configs.DeviceRule{
+		{Type: configs.CharDevice, Major: 236, Minor: 21, Permissions: "rwm", Allow: true},
+	}
+
+	newRules := withDeviceRules(rules, mig.DeviceFiles(), true)
+	if len(newRules) != 3 {
+		t.Fatalf("expected 3 rules, got %d", len(newRules))
+	}
+	for i, minor := range []int64{0, 21, 22} {
+		if newRules[i].Minor != minor || !newRules[i].Allow {
+			t.Errorf("unexpected rule %d: %+v", i, newRules[i])
+		}
+	}
+	if _, _, err := devicefilter.DeviceFilter(newRules); err != nil {
+		t.Errorf("failed to generate device program: %v", err)
+	}
+}
+
+// deviceAllowed evaluates read and write access as the device program does, the last matched rule takes effect
+func deviceAllowed(rules []*configs.DeviceRule, deviceType configs.DeviceType, major int64, minor int64) bool {
+	allowed := false
+	for _, rule := range rules {
+		if (rule.Type == configs.WildcardDevice || rule.Type == deviceType) &&
+			(rule.Major == configs.Wildcard || rule.Major == major) &&
+			(rule.Minor == configs.Wildcard || rule.Minor == minor) &&
+			strings.Contains(string(rule.Permissions), "r") && strings.Contains(string(rule.Permissions), "w") {
+			allowed = rule.Allow
+		}
+	}
+	return allowed
+}
+
+func TestContainerDeviceRules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(paths []string) { containerSpecPaths = paths }(containerSpecPaths)
+	containerSpecPaths = []string{dir + "/%s/config.json"}
+	defer func(grants *deviceGrants) { grantedDevices = grants }(grantedDevices)
+	grantedDevices = &deviceGrants{grants: make(map[string][]device.DeviceFile)}
+
+	// the container has created /dev/sda with CAP_MKNOD, which the OCI spec does not allow
+	bundle := dir + "/abc"
+	if err := os.MkdirAll(bundle+"/rootfs/dev", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(bundle+"/rootfs/dev/sda", nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	spec := `{"linux": {"resources": {"devices": [
+		{"allow": false, "access": "rwm"},
+		{"allow": true, "type": "c", "major": 1, "minor": 3, "access": "rwm"},
+		{"allow": true, "type": "c", "major": 195, "minor": 0, "access": "rw"}
+	]}}}`
+	if err := ioutil.WriteFile(bundle+"/config.json", []byte(spec), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cgroupPath := "/kubepods.slice/kubepods-pod1.slice/cri-containerd-abc.scope"
+	grantedDevices.grants[cgroupPath] = (&device.GPU{MinorNumber: 1}).DeviceFiles()
+
+	rules, err := containerDeviceRules(cgroupPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		deviceType configs.DeviceType
+		major      int64
+		minor      int64
+		allowed    bool
+	}{
+		{configs.CharDevice, 1, 3, true},
+		{configs.CharDevice, device.DEFAULT_NVIDA_MAJOR_NUMBER, 0, true},
+		{configs.CharDevice, device.DEFAULT_NVIDA_MAJOR_NUMBER, 1, true},
+		{configs.CharDevice, device.DEFAULT_NVIDA_MAJOR_NUMBER, 2, false},
+		{configs.BlockDevice, 8, 0, false},
+	} {
+		if allowed := deviceAllowed(rules, c.deviceType, c.major, c.minor); allowed != c.allowed {
+			t.Errorf("%c %d:%d: expected allowed %v, got %v", c.deviceType, c.major, c.minor, c.allowed, allowed)
+		}
+	}
+	if _, err := containerDeviceRules("/kubepods/pod1/def"); err == nil {
+		t.Errorf("expected error for container without OCI spec")
+	}
+}
@@ -0,0 +1,136 @@
+package cgroup
+
+import (
+	"GPUMounter/pkg/device"
+	. "GPUMounter/pkg/util/log"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	// DefaultDeviceGrantsPath is the path of the file recording the device files granted to containers on cgroup v2,
+	// can be set by DEVICE_GRANTS_PATH
+	DefaultDeviceGrantsPath = "/var/lib/GPUMounter/device-grants.json"
+)
+
+// GetDeviceGrantsPath returns the device grants file path set by env DEVICE_GRANTS_PATH
+func GetDeviceGrantsPath() string {
+	if path := os.Getenv("DEVICE_GRANTS_PATH"); path != "" {
+		return path
+	}
+	return DefaultDeviceGrantsPath
+}
+
+// deviceGrants records the device files granted to each container cgroup, the device program of the container
+// is rebuilt from its OCI spec and the grants, as a cgroup v2 device program can not be read back into rules
+type deviceGrants struct {
+	mu     sync.Mutex
+	grants map[string][]device.DeviceFile
+}
+
+var grantedDevices = &deviceGrants{}
+
+// load reads the grants file once, an empty grants is used if the file does not exist
+func (deviceGrants *deviceGrants) load() error {
+	if deviceGrants.grants != nil {
+		return nil
+	}
+	grants := make(map[string][]device.DeviceFile)
+	data, err := ioutil.ReadFile(GetDeviceGrantsPath())
+	if err != nil && !os.IsNotExist(err) {
+		Logger.Error("Failed to read device grants file: ", GetDeviceGrantsPath())
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &grants); err != nil {
+			Logger.Error("Failed to parse device grants file: ", GetDeviceGrantsPath())
+			return err
+		}
+	}
+	deviceGrants.grants = grants
+	return nil
+}
+
+// get returns the device files granted to the container cgroup
+func (deviceGrants *deviceGrants) get(cgroupPath string) ([]device.DeviceFile, error) {
+	deviceGrants.mu.Lock()
+	defer deviceGrants.mu.Unlock()
+	if err := deviceGrants.load(); err != nil {
+		return nil, err
+	}
+	return append([]device.DeviceFile(nil), deviceGrants.grants[cgroupPath]...), nil
+}
+
+// update adds or removes the device files granted to the container cgroup and persists the grants,
+// grants of the cgroups that no longer exist are dropped
+func (deviceGrants *deviceGrants) update(cgroupPath string, files []device.DeviceFile, allow bool) error {
+	deviceGrants.mu.Lock()
+	defer deviceGrants.mu.Unlock()
+	if err := deviceGrants.load(); err != nil {
+		return err
+	}
+	var granted []device.DeviceFile
+	for _, grant := range deviceGrants.grants[cgroupPath] {
+		if !hasDeviceNumber(files, grant) {
+			granted = append(granted, grant)
+		}
+	}
+	if allow {
+		granted = append(granted, files...)
+	}
+	if len(granted) == 0 {
+		delete(deviceGrants.grants, cgroupPath)
+	} else {
+		deviceGrants.grants[cgroupPath] = granted
+	}
+	for path := range deviceGrants.grants {
+		if _, err := os.Stat(GetDeviceGroupPath(path)); os.IsNotExist(err) {
+			delete(deviceGrants.grants, path)
+		}
+	}
+	return deviceGrants.save()
+}
+
+// save writes the grants to a temporary file and renames it, so that the grants file is never half written
+func (deviceGrants *deviceGrants) save() error {
+	data, err := json.MarshalIndent(deviceGrants.grants, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := GetDeviceGrantsPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		Logger.Error("Failed to create device grants directory: ", filepath.Dir(path))
+		return err
+	}
+	tmpFile, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		Logger.Error("Failed to create temporary device grants file")
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpFile.Name(), path); err != nil {
+		Logger.Error("Failed to write device grants file: ", path)
+		return err
+	}
+	return nil
+}
+
+// hasDeviceNumber reports whether a device file of the same device number as file is in files
+func hasDeviceNumber(files []device.DeviceFile, file device.DeviceFile) bool {
+	for _, f := range files {
+		if f.MajorNumber == file.MajorNumber && f.MinorNumber == file.MinorNumber {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,178 @@
+// Package driver discovers the nvidia driver libraries and binaries on the host, which are mounted
+// into containers started without gpus the way nvidia-container-toolkit does for gpu containers
+package driver
+
+import (
+	"GPUMounter/pkg/util/gpu/collector/nvml"
+	. "GPUMounter/pkg/util/log"
+	"GPUMounter/pkg/util/namespace"
+	"debug/elf"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ContainerDir is where the driver files are mounted in the container, CUDA images have
+	// its lib64 in LD_LIBRARY_PATH and its bin in PATH
+	ContainerDir = "/usr/local/nvidia"
+	// HostRootPath is the root of the host seen by the worker running in the host PID namespace
+	HostRootPath = "/proc/1/root"
+	// DefaultDriverRoot is the driver root of the host, can be set by DRIVER_ROOT,
+	// e.g. /run/nvidia/driver for drivers installed by the gpu operator
+	DefaultDriverRoot = "/"
+)
+
+// libraries and binaries of the utility and compute driver capabilities of nvidia-container-toolkit
+var (
+	Libraries = []string{
+		"libnvidia-ml.so",
+		"libnvidia-cfg.so",
+		"libcuda.so",
+		"libcudadebugger.so",
+		"libnvidia-opencl.so",
+		"libnvidia-gpucomp.so",
+		"libnvidia-ptxjitcompiler.so",
+		"libnvidia-fatbinaryloader.so",
+		"libnvidia-allocator.so",
+		"libnvidia-compiler.so",
+		"libnvidia-pkcs11.so",
+		"libnvidia-pkcs11-openssl3.so",
+		"libnvidia-nvvm.so",
+	}
+	Binaries = []string{
+		"nvidia-smi",
+		"nvidia-debugdump",
+		"nvidia-persistenced",
+		"nvidia-cuda-mps-control",
+		"nvidia-cuda-mps-server",
+	}
+
+	libraryDirs = []string{
+		"/usr/lib64",
+		"/usr/lib/x86_64-linux-gnu",
+		"/usr/lib/aarch64-linux-gnu",
+		"/usr/lib",
+		"/lib64",
+		"/lib/x86_64-linux-gnu",
+		"/lib/aarch64-linux-gnu",
+	}
+	binaryDirs = []string{
+		"/usr/bin",
+		"/usr/sbin",
+		"/usr/local/bin",
+		"/bin",
+		"/sbin",
+	}
+	// libraries without which the driver is unusable
+	requiredLibraries = []string{"libnvidia-ml.so", "libcuda.so"}
+)
+
+// IsBootstrapEnabled reports whether the driver is bootstrapped into containers started without gpus, set by env DRIVER_BOOTSTRAP
+func IsBootstrapEnabled() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv("DRIVER_BOOTSTRAP"))
+	return enabled
+}
+
+// GetDriverRoot returns the driver root of the host set by env DRIVER_ROOT
+func GetDriverRoot() string {
+	if root := os.Getenv("DRIVER_ROOT"); root != "" {
+		return root
+	}
+	return DefaultDriverRoot
+}
+
+// Driver is the layout of the driver files in the container
+type Driver struct {
+	Version  string
+	Mounts   []namespace.BindMount
+	Symlinks []namespace.Symlink
+}
+
+// Discover finds the files of the driver in use under the driver root of the host
+func Discover() (*Driver, error) {
+	lib, err := nvml.New()
+	if err != nil {
+		Logger.Error("Failed to load nvml")
+		return nil, err
+	}
+	if err := lib.Init(); err != nil {
+		Logger.Errorf("nvml error: %+v", err)
+		return nil, err
+	}
+	defer lib.Shutdown()
+	version, err := lib.GetDriverVersion()
+	if err != nil {
+		Logger.Error("Failed to get driver version")
+		return nil, err
+	}
+	return discover(filepath.Join(HostRootPath, GetDriverRoot()), version)
+}
+
+func discover(root string, version string) (*Driver, error) {
+	driver := &Driver{Version: version}
+	found := make(map[string]bool)
+	for _, library := range Libraries {
+		// the libraries are named by the driver version, e.g. libcuda.so.525.60.13
+		fileName := library + "." + version
+		source := findFile(root, libraryDirs, fileName)
+		if source == "" {
+			continue
+		}
+		found[library] = true
+		target := filepath.Join(ContainerDir, "lib64", fileName)
+		driver.Mounts = append(driver.Mounts, namespace.BindMount{Source: source, Target: target})
+
+		soname, err := getSoname(source)
+		if err != nil {
+			Logger.Warn("Failed to read soname of ", source, ": ", err)
+		} else if soname != "" && soname != fileName {
+			driver.Symlinks = append(driver.Symlinks, namespace.Symlink{Path: filepath.Join(ContainerDir, "lib64", soname), Target: fileName})
+		}
+		if library == "libcuda.so" && soname != "" {
+			// linked by applications building against the driver api
+			driver.Symlinks = append(driver.Symlinks, namespace.Symlink{Path: filepath.Join(ContainerDir, "lib64", library), Target: soname})
+		}
+	}
+	for _, library := range requiredLibraries {
+		if !found[library] {
+			return nil, errors.New("no " + library + "." + version + " of the driver under " + root)
+		}
+	}
+	for _, binary := range Binaries {
+		if source := findFile(root, binaryDirs, binary); source != "" {
+			driver.Mounts = append(driver.Mounts, namespace.BindMount{Source: source, Target: filepath.Join(ContainerDir, "bin", binary)})
+		}
+	}
+	return driver, nil
+}
+
+// findFile returns the path of the first regular file named fileName in dirs under root, "" if not found
+func findFile(root string, dirs []string, fileName string) string {
+	for _, dir := range dirs {
+		path := filepath.Join(root, dir, fileName)
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return path
+		}
+	}
+	return ""
+}
+
+// getSoname returns the DT_SONAME of the shared library, e.g. libcuda.so.1
+func getSoname(path string) (string, error) {
+	f, err := elf.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	sonames, err := f.DynString(elf.DT_SONAME)
+	if err != nil {
+		return "", err
+	}
+	if len(sonames) == 0 {
+		return "", nil
+	}
+	return strings.TrimSpace(sonames[0]), nil
+}
@@ -0,0 +1,76 @@
+package driver
+
+import (
+	. "GPUMounter/pkg/util/log"
+	"GPUMounter/pkg/util/namespace"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := ioutil.TempDir("", "driver")
+	if err != nil {
+		panic(err)
+	}
+	InitLogger(dir+"/", "log")
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestDiscover(t *testing.T) {
+	root, err := ioutil.TempDir("", "driver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	for _, path := range []string{
+		"usr/lib/x86_64-linux-gnu/libnvidia-ml.so.525.60.13",
+		"usr/lib/x86_64-linux-gnu/libcuda.so.525.60.13",
+		// libraries of other driver versions are left out
+		"usr/lib/x86_64-linux-gnu/libnvidia-cfg.so.470.82.01",
+		"usr/bin/nvidia-smi",
+	} {
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, nil, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	driver, err := discover(root, "525.60.13")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []namespace.BindMount{
+		{Source: filepath.Join(root, "usr/lib/x86_64-linux-gnu/libnvidia-ml.so.525.60.13"), Target: "/usr/local/nvidia/lib64/libnvidia-ml.so.525.60.13"},
+		{Source: filepath.Join(root, "usr/lib/x86_64-linux-gnu/libcuda.so.525.60.13"), Target: "/usr/local/nvidia/lib64/libcuda.so.525.60.13"},
+		{Source: filepath.Join(root, "usr/bin/nvidia-smi"), Target: "/usr/local/nvidia/bin/nvidia-smi"},
+	}
+	if !reflect.DeepEqual(driver.Mounts, expected) {
+		t.Errorf("expected mounts %+v, got %+v", expected, driver.Mounts)
+	}
+
+	if _, err := discover(root, "470.82.01"); err == nil {
+		t.Errorf("expected error without libcuda.so of the driver version")
+	}
+}
+
+func TestGetSoname(t *testing.T) {
+	for _, path := range []string{"/lib/x86_64-linux-gnu/libc.so.6", "/lib64/libc.so.6", "/lib/aarch64-linux-gnu/libc.so.6"} {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		soname, err := getSoname(path)
+		if err != nil || soname != "libc.so.6" {
+			t.Errorf("expected soname libc.so.6 of %s, got %q, %v", path, soname, err)
+		}
+		return
+	}
+	t.Skip("no libc.so.6 found")
+}
@@ -0,0 +1,64 @@
+// Package event records Kubernetes Events on the owner pods of hot mounted gpus,
+// so that `kubectl describe pod` explains what gpu mounter did to the pod
+package event
+
+import (
+	"GPUMounter/pkg/config"
+	. "GPUMounter/pkg/util/log"
+	"os"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// Component is the source component of the events recorded by workers
+const Component = "gpu-mounter-worker"
+
+// reasons of the events, the failures of slave pods are recorded with their reasons, e.g. InsufficientGPU
+const (
+	ReasonGPUMounted        = "GPUMounted"
+	ReasonGPUUnmounted      = "GPUUnmounted"
+	ReasonMountFailed       = "MountFailed"
+	ReasonUnmountFailed     = "UnmountFailed"
+	ReasonQuotaExceeded     = "QuotaExceeded"
+	ReasonGPUBusy           = "GPUBusy"
+	ReasonGPUNotFound       = "GPUNotFound"
+	ReasonContainerNotFound = "ContainerNotFound"
+	// ReasonGPULeaseExpired is recorded when a gpu is unmounted after its lease expired
+	ReasonGPULeaseExpired = "GPULeaseExpired"
+	// ReasonIdleGPUUnmounted is recorded when a gpu is unmounted after no process of the pod used it for the idle window
+	ReasonIdleGPUUnmounted = "IdleGPUUnmounted"
+	// ReasonGPUMemoryExceeded is recorded when the processes of a pod using more memory than its limit on a shared gpu are killed
+	ReasonGPUMemoryExceeded = "GPUMemoryExceeded"
+)
+
+// NewRecorder creates a recorder writing events of the host through the clientset
+func NewRecorder(clientset kubernetes.Interface, host string) record.EventRecorder {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientset.CoreV1().Events("")})
+	return broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: Component, Host: host})
+}
+
+var (
+	recorder     record.EventRecorder
+	recorderErr  error
+	recorderOnce sync.Once
+)
+
+// GetRecorder returns the recorder shared by the process, events are recorded with env NODE_NAME as host
+func GetRecorder() (record.EventRecorder, error) {
+	recorderOnce.Do(func() {
+		clientset, err := config.GetClientSet()
+		if err != nil {
+			Logger.Error("Connect to k8s failed")
+			recorderErr = err
+			return
+		}
+		recorder = NewRecorder(clientset, os.Getenv("NODE_NAME"))
+	})
+	return recorder, recorderErr
+}
@@ -3,30 +3,61 @@ package allocator
 import (
 	"GPUMounter/pkg/config"
 	"GPUMounter/pkg/device"
+	"GPUMounter/pkg/metrics"
 	"GPUMounter/pkg/util"
 	"GPUMounter/pkg/util/gpu"
 	"GPUMounter/pkg/util/gpu/collector"
 	. "GPUMounter/pkg/util/log"
+	"GPUMounter/pkg/util/quota"
 	"context"
 	"crypto/rand"
 	"errors"
 	"fmt"
+	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
-	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	watchtools "k8s.io/client-go/tools/watch"
 )
 
 type GPUAllocator struct {
 	*collector.GPUCollector
+	// deadline of waiting slave pods to be running or deleted
+	SlavePodTimeout time.Duration
+	// default placement strategy of mounts
+	Strategy Strategy
+	// max slave pod batches created to find a preferred placement
+	PlacementAttempts int
 }
 
 func NewGPUAllocator() (*GPUAllocator, error) {
 	Logger.Info("Creating gpu allocator")
-	gpuAllocator := &GPUAllocator{}
+	slavePodTimeout, err := GetSlavePodTimeout()
+	if err != nil {
+		Logger.Error("Invalid slave pod timeout")
+		return nil, err
+	}
+	strategy, err := GetStrategy("")
+	if err != nil {
+		Logger.Error("Invalid placement strategy")
+		return nil, err
+	}
+	placementAttempts, err := GetPlacementAttempts()
+	if err != nil {
+		Logger.Error("Invalid placement max attempts")
+		return nil, err
+	}
+	Logger.Info("Placement strategy: ", strategy.Name(), ", max attempts: ", placementAttempts)
+	gpuAllocator := &GPUAllocator{SlavePodTimeout: slavePodTimeout, Strategy: strategy, PlacementAttempts: placementAttempts}
 	tmp, err := collector.NewGPUCollector()
 	if err != nil {
 		Logger.Error("Failed to init gpu collector")
@@ -37,7 +68,21 @@ func NewGPUAllocator() (*GPUAllocator, error) {
 	return gpuAllocator, nil
 }
 
-func (gpuAllocator *GPUAllocator) GetAvailableGPU(ownerPod *corev1.Pod, totalGpuNum int, gpuNumPerPod int) ([]*device.NvidiaGPU, error) {
+// GetSlavePodTimeout returns the slave pod timeout set by env SLAVE_POD_TIMEOUT, e.g. "90s", "5m"
+func GetSlavePodTimeout() (time.Duration, error) {
+	timeoutStr := os.Getenv("SLAVE_POD_TIMEOUT")
+	if timeoutStr == "" {
+		return gpu.DefaultSlavePodTimeout, nil
+	}
+	timeout, err := time.ParseDuration(timeoutStr)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid slave pod timeout: %s", timeoutStr)
+	}
+	return timeout, nil
+}
+
+// GetAvailableGPU allocates gpus for the owner pod by slave pods, placed by strategy or the default strategy if nil
+func (gpuAllocator *GPUAllocator) GetAvailableGPU(ctx context.Context, ownerPod *corev1.Pod, totalGpuNum int, gpuNumPerPod int, strategy Strategy) ([]*device.GPU, error) {
 	clientset, err := config.GetClientSet()
 	if err != nil {
 		Logger.Error(err)
@@ -45,60 +90,251 @@ func (gpuAllocator *GPUAllocator) GetAvailableGPU(ownerPod *corev1.Pod, totalGpu
 		return nil, errors.New(gpu.FailedCreated)
 	}
 
+	if err := quota.Check(clientset, ownerPod.Namespace, totalGpuNum); err != nil {
+		if _, ok := err.(*quota.ExceededError); ok {
+			Logger.Warn(err)
+			return nil, err
+		}
+		Logger.Error(err)
+		Logger.Error("Failed to check quota of Namespace: ", ownerPod.Namespace)
+		return nil, errors.New(gpu.FailedCreated)
+	}
+
+	if strategy == nil {
+		strategy = gpuAllocator.Strategy
+	}
+	var held []string
+	target, hasTarget := 0, false
+	if strategy.Name() != gpu.FirstFit {
+		held, target, hasTarget = gpuAllocator.preferredPlacement(ownerPod, strategy, totalGpuNum)
+	}
+
+	// the device plugin chooses the gpus of slave pods, so slave pods of unpreferred gpus are held
+	// while creating more, keeping the device plugin from allocating the same gpus again
+	var candidates []*slavePodGPUs
+	for attempt := 1; ; attempt++ {
+		batch, err := gpuAllocator.createSlavePods(ctx, clientset, ownerPod, totalGpuNum/gpuNumPerPod, func() *corev1.Pod {
+			return newGPUSlavePod(ownerPod, gpuNumPerPod)
+		})
+		if err != nil {
+			if len(candidates) == 0 {
+				return nil, err
+			}
+			Logger.Warn("Failed to create more slave pods for Owner Pod: ", ownerPod.Name, " reason: ", err, ", use the best placement so far")
+			break
+		}
+		candidates = append(candidates, batch...)
+		if !hasTarget {
+			break
+		}
+		_, uuids := choose(strategy, candidates, held, totalGpuNum, gpuAllocator.Topology)
+		score := strategy.Score(uuids, held, gpuAllocator.Topology)
+		if score >= target {
+			Logger.Info("Placement of GPU: ", strings.Join(uuids, ", "), " is preferred by strategy: ", strategy.Name())
+			break
+		}
+		if attempt >= gpuAllocator.PlacementAttempts {
+			Logger.Warn("No preferred placement by strategy: ", strategy.Name(), " after ", attempt, " attempts, use GPU: ", strings.Join(uuids, ", "), " score: ", score, " preferred: ", target)
+			break
+		}
+		Logger.Info("Placement of GPU: ", strings.Join(uuids, ", "), " score: ", score, " is below preferred: ", target, ", retrying")
+	}
+
+	chosen, _ := choose(strategy, candidates, held, totalGpuNum, gpuAllocator.Topology)
+	var availableGPUResource []*device.GPU
+	var releasedSlavePods []string
+	for _, candidate := range candidates {
+		if containSlavePod(chosen, candidate) {
+			availableGPUResource = append(availableGPUResource, candidate.gpus...)
+		} else {
+			releasedSlavePods = append(releasedSlavePods, candidate.name)
+		}
+	}
+	if len(releasedSlavePods) != 0 {
+		Logger.Info("Releasing unpreferred Slave Pod: ", strings.Join(releasedSlavePods, ", "))
+		recycleSlavePods(releasedSlavePods)
+	}
+	return availableGPUResource, nil
+}
+
+// preferredPlacement returns the gpus held by the owner pod and the score of the best placement among the free gpus,
+// false if the placement can not be rated
+func (gpuAllocator *GPUAllocator) preferredPlacement(ownerPod *corev1.Pod, strategy Strategy, totalGpuNum int) ([]string, int, bool) {
+	if gpuAllocator.Topology == nil {
+		Logger.Warn("GPU topology is unknown, placement strategy: ", strategy.Name(), " falls back to ", gpu.FirstFit)
+		return nil, 0, false
+	}
+	heldGPUs, err := gpuAllocator.GetPodGPUResources(ownerPod.Name, ownerPod.Namespace)
+	if err != nil {
+		Logger.Error(err)
+		Logger.Error("Failed to get gpu resources of Pod: ", ownerPod.Name, " Namespace: ", ownerPod.Namespace, ", placement strategy falls back to ", gpu.FirstFit)
+		return nil, 0, false
+	}
+	var free []*device.GPU
+	for _, gpuDev := range gpuAllocator.GetGPUs() {
+		if gpuDev.State == device.GPU_FREE_STATE && !gpuDev.IsMIG() {
+			free = append(free, gpuDev)
+		}
+	}
+	held := uuidsOf(heldGPUs)
+	target, ok := preferredScore(strategy, free, held, totalGpuNum, gpuAllocator.Topology)
+	return held, target, ok
+}
+
+func containSlavePod(slavePods []*slavePodGPUs, slavePod *slavePodGPUs) bool {
+	for _, item := range slavePods {
+		if item == slavePod {
+			return true
+		}
+	}
+	return false
+}
+
+// createSlavePods creates slavePodNum slave pods by newSlavePod and waits for them running,
+// the slave pods are recycled if any of them fails
+func (gpuAllocator *GPUAllocator) createSlavePods(ctx context.Context, clientset kubernetes.Interface, ownerPod *corev1.Pod, slavePodNum int, newSlavePod func() *corev1.Pod) ([]*slavePodGPUs, error) {
 	var slavePodNames []string
-	for idx := 0; idx < totalGpuNum/gpuNumPerPod; idx++ {
+	createStart := time.Now()
+	for idx := 0; idx < slavePodNum; idx++ {
 		// try create a gpu pod on specify node
-		slavePod := newGPUSlavePod(ownerPod, gpuNumPerPod)
-		slavePod, err = clientset.CoreV1().Pods(slavePod.Namespace).Create(context.TODO(), slavePod, metav1.CreateOptions{})
+		slavePod := newSlavePod()
+		slavePod, err := clientset.CoreV1().Pods(slavePod.Namespace).Create(context.TODO(), slavePod, metav1.CreateOptions{})
 		if err != nil {
 			Logger.Error(err)
 			Logger.Error("Failed to create GPU Slave Pod for Owner Pod: " + ownerPod.Name)
+			recycleSlavePods(slavePodNames)
 			return nil, errors.New(gpu.FailedCreated)
 		}
 		slavePodNames = append(slavePodNames, slavePod.Name)
 		Logger.Info("Creating GPU Slave Pod: " + slavePod.Name + " for Owner Pod: " + ownerPod.Name)
 	}
+	metrics.ObservePhase(metrics.PhaseSlavePodCreate, createStart)
 
-	ch := make(chan string)
-	go checkCreateState(slavePodNames, ch)
-	switch <-ch {
-	case gpu.InsufficientGPU:
-		for _, slavePodName := range slavePodNames {
-			err = clientset.CoreV1().Pods(gpu.GPUPoolNamespace).Delete(context.TODO(), slavePodName, *metav1.NewDeleteOptions(0))
-			if err != nil {
-				Logger.Error(err)
-				Logger.Error("Failed to recycle slave pod: ", slavePodName, " Namespace: ", gpu.GPUPoolNamespace)
-			}
-		}
-		return nil, errors.New(gpu.InsufficientGPU)
-	case gpu.FailedCreated:
-		for _, slavePodName := range slavePodNames {
-			err = clientset.CoreV1().Pods(gpu.GPUPoolNamespace).Delete(context.TODO(), slavePodName, *metav1.NewDeleteOptions(0))
-			if err != nil {
-				Logger.Error(err)
-				Logger.Error("Failed to recycle slave pod: ", slavePodName, " Namespace: ", gpu.GPUPoolNamespace)
-			}
-		}
-		return nil, errors.New(gpu.FailedCreated)
+	waitCtx, cancel := context.WithTimeout(ctx, gpuAllocator.SlavePodTimeout)
+	defer cancel()
+	scheduleStart := time.Now()
+	state := checkCreateState(waitCtx, slavePodNames)
+	metrics.ObservePhase(metrics.PhaseSlavePodSchedule, scheduleStart)
+	switch state {
 	case gpu.SuccessfullyCreated:
 		Logger.Infof("Successfully create Slave Pod: %s, for Owner Pod: %s ", strings.Join(slavePodNames, ", "), ownerPod.Name)
-		var availableGPUResource []*device.NvidiaGPU
+		var slavePods []*slavePodGPUs
 		for _, slavePodName := range slavePodNames {
 			gpuResources, err := gpuAllocator.GetPodGPUResources(slavePodName, gpu.GPUPoolNamespace)
 			if err != nil {
 				Logger.Error(err)
 				Logger.Error("Failed to get gpu resource for Slave Pod: ", slavePodName, " in Namespace: ", gpu.GPUPoolNamespace)
+				recycleSlavePods(slavePodNames)
 				return nil, errors.New(gpu.FailedCreated)
 			}
-			availableGPUResource = append(availableGPUResource, gpuResources...)
+			slavePods = append(slavePods, &slavePodGPUs{name: slavePodName, gpus: gpuResources})
+		}
+		return slavePods, nil
+	default:
+		Logger.Error("Failed to create Slave Pod: ", strings.Join(slavePodNames, ", "), " for Owner Pod: ", ownerPod.Name, " reason: ", state)
+		recycleSlavePods(slavePodNames)
+		return nil, errors.New(state)
+	}
+}
+
+// GetSharedGPU reserves a gpu to be shared by several pods with a shared slave pod, which has no owner pod
+// and is charged to the namespace of the owner pod
+func (gpuAllocator *GPUAllocator) GetSharedGPU(ctx context.Context, ownerPod *corev1.Pod) (*device.GPU, error) {
+	clientset, err := config.GetClientSet()
+	if err != nil {
+		Logger.Error(err)
+		Logger.Error("Connect to k8s failed")
+		return nil, errors.New(gpu.FailedCreated)
+	}
+
+	if err := quota.Check(clientset, ownerPod.Namespace, 1); err != nil {
+		if _, ok := err.(*quota.ExceededError); ok {
+			Logger.Warn(err)
+			return nil, err
+		}
+		Logger.Error(err)
+		Logger.Error("Failed to check quota of Namespace: ", ownerPod.Namespace)
+		return nil, errors.New(gpu.FailedCreated)
+	}
+
+	slavePods, err := gpuAllocator.createSlavePods(ctx, clientset, ownerPod, 1, func() *corev1.Pod {
+		return newSharedSlavePod(ownerPod)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(slavePods[0].gpus) != 1 {
+		Logger.Error("Shared Slave Pod: ", slavePods[0].name, " reserves ", len(slavePods[0].gpus), " GPUs")
+		recycleSlavePods([]string{slavePods[0].name})
+		return nil, errors.New(gpu.FailedCreated)
+	}
+	return slavePods[0].gpus[0], nil
+}
+
+// GetAvailableMIG allocates MIG devices of the profile for the owner pod, one slave pod each
+func (gpuAllocator *GPUAllocator) GetAvailableMIG(ctx context.Context, ownerPod *corev1.Pod, profile string, migNum int) ([]*device.GPU, error) {
+	clientset, err := config.GetClientSet()
+	if err != nil {
+		Logger.Error(err)
+		Logger.Error("Connect to k8s failed")
+		return nil, errors.New(gpu.FailedCreated)
+	}
+
+	if err := quota.Check(clientset, ownerPod.Namespace, migNum); err != nil {
+		if _, ok := err.(*quota.ExceededError); ok {
+			Logger.Warn(err)
+			return nil, err
 		}
+		Logger.Error(err)
+		Logger.Error("Failed to check quota of Namespace: ", ownerPod.Namespace)
+		return nil, errors.New(gpu.FailedCreated)
+	}
 
-		return availableGPUResource, nil
+	slavePods, err := gpuAllocator.createSlavePods(ctx, clientset, ownerPod, migNum, func() *corev1.Pod {
+		return newMIGSlavePod(ownerPod, profile)
+	})
+	if err != nil {
+		return nil, err
+	}
+	var migResources []*device.GPU
+	for _, slavePod := range slavePods {
+		migResources = append(migResources, slavePod.gpus...)
 	}
-	return nil, errors.New(gpu.FailedCreated)
+	for _, migDev := range migResources {
+		if !migDev.IsMIG() {
+			Logger.Error("Slave Pod of Owner Pod: ", ownerPod.Name, " reserves GPU: ", migDev.UUID, " which is not a MIG device")
+			recycleSlavePods(slavePodNamesOf(slavePods))
+			return nil, errors.New(gpu.FailedCreated)
+		}
+	}
+	return migResources, nil
 }
 
-func (gpuAllocator *GPUAllocator) GetRemoveGPU(ownerPod *corev1.Pod, uuids []string) ([]*device.NvidiaGPU, error) {
+func slavePodNamesOf(slavePods []*slavePodGPUs) []string {
+	var names []string
+	for _, slavePod := range slavePods {
+		names = append(names, slavePod.name)
+	}
+	return names
+}
+
+func recycleSlavePods(slavePodNames []string) {
+	clientset, err := config.GetClientSet()
+	if err != nil {
+		Logger.Error(err)
+		Logger.Error("Connect to k8s failed")
+		return
+	}
+	for _, slavePodName := range slavePodNames {
+		err = clientset.CoreV1().Pods(gpu.GPUPoolNamespace).Delete(context.TODO(), slavePodName, *metav1.NewDeleteOptions(0))
+		if err != nil {
+			Logger.Error(err)
+			Logger.Error("Failed to recycle slave pod: ", slavePodName, " Namespace: ", gpu.GPUPoolNamespace)
+		}
+	}
+}
+
+func (gpuAllocator *GPUAllocator) GetRemoveGPU(ownerPod *corev1.Pod, uuids []string) ([]*device.GPU, error) {
 
 	gpuResources, err := gpuAllocator.GetPodGPUResources(ownerPod.Name, ownerPod.Namespace)
 	if err != nil {
@@ -107,7 +343,7 @@ func (gpuAllocator *GPUAllocator) GetRemoveGPU(ownerPod *corev1.Pod, uuids []str
 		return nil, err
 	}
 
-	var removeGPUs []*device.NvidiaGPU
+	var removeGPUs []*device.GPU
 	mountType := gpuAllocator.GetMountType(ownerPod)
 	for _, gpuDev := range gpuResources {
 		// GPU Mounter can only unmount the gpu mounted by GPU Mounter
@@ -119,13 +355,13 @@ func (gpuAllocator *GPUAllocator) GetRemoveGPU(ownerPod *corev1.Pod, uuids []str
 	}
 	// if exists unmatch gpu, return empty
 	if len(uuids) != len(removeGPUs) {
-		return []*device.NvidiaGPU{}, nil
+		return []*device.GPU{}, nil
 	}
 
 	return removeGPUs, nil
 }
 
-func (gpuAllocator *GPUAllocator) DeleteSlavePods(slavePodNames []string) error {
+func (gpuAllocator *GPUAllocator) DeleteSlavePods(ctx context.Context, slavePodNames []string) error {
 	Logger.Info("Deleting slave pods: ", strings.Join(slavePodNames, ", "))
 	clientset, err := config.GetClientSet()
 	if err != nil {
@@ -140,18 +376,26 @@ func (gpuAllocator *GPUAllocator) DeleteSlavePods(slavePodNames []string) error
 		}
 	}
 
-	ch := make(chan string)
-	go checkDeleteState(slavePodNames, ch)
-
-	switch <-ch {
+	waitCtx, cancel := context.WithTimeout(ctx, gpuAllocator.SlavePodTimeout)
+	defer cancel()
+	deleteStart := time.Now()
+	state := checkDeleteState(waitCtx, slavePodNames)
+	metrics.ObservePhase(metrics.PhaseSlavePodDelete, deleteStart)
+	switch state {
 	case gpu.FailedDeleted:
 		Logger.Error("Failed to delete slave pods")
 		return errors.New("Failed to delete slave pods ")
+	case gpu.SlavePodTimeout:
+		Logger.Error("Timeout waiting slave pods deleted")
+		return errors.New(gpu.SlavePodTimeout)
+	case gpu.SlavePodCanceled:
+		Logger.Error("Canceled waiting slave pods deleted")
+		return errors.New(gpu.SlavePodCanceled)
 	case gpu.SuccessfullyDeleted:
 		Logger.Info("Successfully delete slave pods")
 		return nil
 	}
-	return errors.New("Unkown status from checking goroutine ")
+	return errors.New("Unkown status from checking slave pods ")
 
 }
 
@@ -164,6 +408,11 @@ func (gpuAllocator *GPUAllocator) GetMountType(pod *corev1.Pod) gpu.MountType {
 		return gpu.UnknownMount
 	}
 
+	return MountTypeOf(pod.Name, gpuResources)
+}
+
+// MountTypeOf returns the mount type of pod given the gpu resources of the pod and its slave pods
+func MountTypeOf(podName string, gpuResources []*device.GPU) gpu.MountType {
 	if len(gpuResources) == 0 {
 		return gpu.NoMount
 	}
@@ -171,7 +420,7 @@ func (gpuAllocator *GPUAllocator) GetMountType(pod *corev1.Pod) gpu.MountType {
 	slavePodNames := make(map[string]interface{}, 0)
 	gpuNum := 0
 	for _, gpuDev := range gpuResources {
-		if gpuDev.PodName != pod.Name {
+		if gpuDev.PodName != podName {
 			slavePodNames[gpuDev.PodName] = struct{}{}
 		}
 		gpuNum++
@@ -199,6 +448,10 @@ func newGPUSlavePod(ownerPod *corev1.Pod, gpuNum int) *corev1.Pod {
 			Labels: map[string]string{
 				"app": "gpu-pool",
 			},
+			Annotations: map[string]string{
+				gpu.OwnerPodAnnotation:       ownerPod.Name,
+				gpu.OwnerNamespaceAnnotation: ownerPod.Namespace,
+			},
 			// set owner ref, so the slave pod will be auto removed if owner pod was removed
 			OwnerReferences: []metav1.OwnerReference{
 				{
@@ -220,7 +473,7 @@ func newGPUSlavePod(ownerPod *corev1.Pod, gpuNum int) *corev1.Pod {
 					Args:    []string{"-c", "while true; do echo this is a gpu pool container; sleep 10;done"},
 					Resources: corev1.ResourceRequirements{
 						Limits: map[corev1.ResourceName]resource.Quantity{
-							"nvidia.com/gpu": resource.MustParse(strconv.Itoa(gpuNum)),
+							corev1.ResourceName(device.GetBackend().ResourceName()): resource.MustParse(strconv.Itoa(gpuNum)),
 						},
 					},
 				},
@@ -233,85 +486,176 @@ func newGPUSlavePod(ownerPod *corev1.Pod, gpuNum int) *corev1.Pod {
 	}
 }
 
-func checkCreateState(podNames []string, ch chan string) {
+// newMIGSlavePod returns a slave pod reserving a MIG device of the profile on the node of the owner pod
+func newMIGSlavePod(ownerPod *corev1.Pod, profile string) *corev1.Pod {
+	slavePod := newGPUSlavePod(ownerPod, 1)
+	slavePod.Spec.Containers[0].Resources.Limits = map[corev1.ResourceName]resource.Quantity{
+		corev1.ResourceName(gpu.MIGResourceName(profile)): resource.MustParse("1"),
+	}
+	return slavePod
+}
+
+// newSharedSlavePod returns a slave pod reserving a gpu on the node of the owner pod, without owner reference
+// so it is kept when the owner pod is deleted
+func newSharedSlavePod(ownerPod *corev1.Pod) *corev1.Pod {
+	slavePod := newGPUSlavePod(ownerPod, 1)
+	slavePod.Name = gpu.SharedSlavePodPrefix + strings.TrimPrefix(slavePod.Name, ownerPod.Name+"-slave-pod-")
+	slavePod.OwnerReferences = nil
+	slavePod.Annotations = map[string]string{
+		gpu.SharedAnnotation:         "true",
+		gpu.OwnerNamespaceAnnotation: ownerPod.Namespace,
+	}
+	return slavePod
+}
+
+// slavePodListWatch watches all slave pods in gpu pool
+func slavePodListWatch(clientset kubernetes.Interface) *cache.ListWatch {
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.LabelSelector = "app=gpu-pool"
+			return clientset.CoreV1().Pods(gpu.GPUPoolNamespace).List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.LabelSelector = "app=gpu-pool"
+			return clientset.CoreV1().Pods(gpu.GPUPoolNamespace).Watch(context.TODO(), options)
+		},
+	}
+}
+
+// checkCreateState waits until all slave pods are running or one of them fails,
+// returns SuccessfullyCreated or the failure reason
+func checkCreateState(ctx context.Context, podNames []string) string {
 
 	Logger.Info("Checking Pods: " + strings.Join(podNames, ", ") + " state")
 	clientset, err := config.GetClientSet()
 	if err != nil {
 		Logger.Error(err)
 		Logger.Error("Connect to k8s failed")
-		ch <- gpu.FailedCreated
+		return gpu.FailedCreated
 	}
 
-	for {
-		flag := true
-		for _, slavePodName := range podNames {
-			pod, err := clientset.CoreV1().Pods(gpu.GPUPoolNamespace).Get(context.TODO(), slavePodName, metav1.GetOptions{})
-			if err != nil {
-				if k8s_errors.IsNotFound(err) {
-					Logger.Info("Not Found....")
-					continue
-				} else {
-					Logger.Error(err)
-					ch <- gpu.FailedCreated
-					return
-				}
-			}
-			if pod.Status.Phase == "Running" {
-				continue
-			} else if !(len(pod.Status.Conditions) > 0) {
-				flag = false
-				Logger.Info("Pod: " + slavePodName + " creating")
-				continue
-			} else if pod.Status.Conditions[0].Reason == corev1.PodReasonUnschedulable {
-				flag = false
-				Logger.Info("No enough gpu for Pod: ", slavePodName)
-				ch <- gpu.InsufficientGPU
-				return
-			} else {
-				// pod is creating
-				flag = false
-			}
+	_, err = watchtools.UntilWithSync(ctx, slavePodListWatch(clientset), &corev1.Pod{}, nil, slavePodsRunning(podNames))
+	if err != nil && ctx.Err() == context.Canceled {
+		Logger.Error("Canceled waiting Pods: " + strings.Join(podNames, ", ") + " running")
+		return gpu.SlavePodCanceled
+	} else if err == wait.ErrWaitTimeout {
+		Logger.Error("Timeout waiting Pods: " + strings.Join(podNames, ", ") + " running")
+		return gpu.SlavePodTimeout
+	} else if err != nil {
+		Logger.Error(err)
+		return err.Error()
+	}
+	Logger.Info("Pods: " + strings.Join(podNames, ", ") + " are running")
+	return gpu.SuccessfullyCreated
+}
+
+// slavePodsRunning returns the watch condition met when all slave pods are running, a pod counts as running
+// by its latest state only, and the condition fails once a pod fails to run or is deleted
+func slavePodsRunning(podNames []string) watchtools.ConditionFunc {
+	runningPods := make(map[string]bool)
+	return func(event watch.Event) (bool, error) {
+		pod, ok := event.Object.(*corev1.Pod)
+		if !ok || !util.ContainString(podNames, pod.Name) {
+			return false, nil
 		}
-		if flag {
-			Logger.Info("Pods: " + strings.Join(podNames, ", ") + " are running")
-			ch <- gpu.SuccessfullyCreated
-			return
+		if event.Type == watch.Deleted {
+			Logger.Error("Pod: " + pod.Name + " was deleted while creating")
+			return false, errors.New(gpu.FailedCreated)
+		}
+		delete(runningPods, pod.Name)
+		switch state := slavePodState(pod); state {
+		case "":
+			Logger.Info("Pod: " + pod.Name + " creating")
+			return false, nil
+		case gpu.SuccessfullyCreated:
+			runningPods[pod.Name] = true
+			return len(runningPods) == len(podNames), nil
+		default:
+			Logger.Info("Pod: ", pod.Name, " failed to run: ", state)
+			return false, errors.New(state)
 		}
 	}
 }
 
-func checkDeleteState(podNames []string, ch chan string) {
+// slavePodState returns SuccessfullyCreated if the slave pod is running,
+// the failure reason if it can not run, or "" if it is still creating
+func slavePodState(pod *corev1.Pod) string {
+	for _, containerStatus := range pod.Status.ContainerStatuses {
+		if containerStatus.State.Waiting == nil {
+			continue
+		}
+		switch containerStatus.State.Waiting.Reason {
+		case "ErrImagePull", "ImagePullBackOff", "InvalidImageName", "ErrImageNeverPull":
+			return gpu.SlavePodImagePullFailed
+		case "CrashLoopBackOff":
+			return gpu.SlavePodCrashLoopBackOff
+		}
+	}
+
+	switch pod.Status.Phase {
+	case corev1.PodRunning:
+		return gpu.SuccessfullyCreated
+	case corev1.PodFailed:
+		if pod.Status.Reason == "Evicted" {
+			return gpu.SlavePodEvicted
+		}
+		return gpu.SlavePodFailed
+	}
+
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodScheduled && condition.Reason == corev1.PodReasonUnschedulable {
+			return gpu.InsufficientGPU
+		}
+	}
+	// pod is creating
+	return ""
+}
+
+// checkDeleteState waits until all slave pods are deleted
+func checkDeleteState(ctx context.Context, podNames []string) string {
 
 	Logger.Info("Checking Pods: " + strings.Join(podNames, ", ") + " state")
 	clientset, err := config.GetClientSet()
 	if err != nil {
 		Logger.Error(err)
 		Logger.Error("Connect to k8s failed")
-		ch <- gpu.FailedDeleted
-		return
+		return gpu.FailedDeleted
 	}
 
-	for {
-		flag := true
-		for _, slavePodName := range podNames {
-			_, err := clientset.CoreV1().Pods(gpu.GPUPoolNamespace).Get(context.TODO(), slavePodName, metav1.GetOptions{})
-			if err != nil {
-				if k8s_errors.IsNotFound(err) {
+	deletingPods := make(map[string]bool)
+	for _, podName := range podNames {
+		deletingPods[podName] = true
+	}
+	_, err = watchtools.UntilWithSync(ctx, slavePodListWatch(clientset), &corev1.Pod{},
+		func(store cache.Store) (bool, error) {
+			for _, podName := range podNames {
+				_, exists, err := store.GetByKey(gpu.GPUPoolNamespace + "/" + podName)
+				if err != nil {
+					return false, err
+				}
+				if !exists {
 					// this slavePod has been deleted
-					continue
-				} else {
-					Logger.Error(err)
-					ch <- gpu.FailedDeleted
-					return
+					delete(deletingPods, podName)
 				}
 			}
-			flag = false
-		}
-		if flag {
-			Logger.Info("Pods: " + strings.Join(podNames, ", ") + " deleted successfully")
-			ch <- gpu.SuccessfullyDeleted
-			return
-		}
+			return len(deletingPods) == 0, nil
+		},
+		func(event watch.Event) (bool, error) {
+			if pod, ok := event.Object.(*corev1.Pod); ok && event.Type == watch.Deleted {
+				delete(deletingPods, pod.Name)
+			}
+			return len(deletingPods) == 0, nil
+		})
+	if err != nil && ctx.Err() == context.Canceled {
+		Logger.Error("Canceled waiting Pods: " + strings.Join(podNames, ", ") + " deleted")
+		return gpu.SlavePodCanceled
+	} else if err == wait.ErrWaitTimeout {
+		Logger.Error("Timeout waiting Pods: " + strings.Join(podNames, ", ") + " deleted")
+		return gpu.SlavePodTimeout
+	} else if err != nil {
+		Logger.Error(err)
+		return gpu.FailedDeleted
 	}
+	Logger.Info("Pods: " + strings.Join(podNames, ", ") + " deleted successfully")
+	return gpu.SuccessfullyDeleted
 }
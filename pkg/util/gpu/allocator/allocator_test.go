@@ -2,16 +2,38 @@ package allocator
 
 import (
 	"GPUMounter/pkg/config"
+	"GPUMounter/pkg/util/gpu"
 	. "GPUMounter/pkg/util/log"
 	"context"
+	"io/ioutil"
+	"os"
 	"testing"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
 )
 
+// TestMain loads the gpus from collector/nvml/testdata/fixture.yaml, set NVML_FIXTURE to use another fixture
+func TestMain(m *testing.M) {
+	dir, err := ioutil.TempDir("", "allocator")
+	if err != nil {
+		panic(err)
+	}
+	InitLogger(dir+"/", "log")
+	if os.Getenv("NVML_FIXTURE") == "" {
+		os.Setenv("NVML_FIXTURE", "../collector/nvml/testdata/fixture.yaml")
+	}
+	code := m.Run()
+	Logger.Sync()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
 func TestGetAvailableGPU(t *testing.T) {
-	InitLogger(".", "log")
-	defer Logger.Sync()
+	if _, err := os.Stat(gpu.SocketPath); os.IsNotExist(err) {
+		t.Skipf("kubelet pod resources socket %s is absent", gpu.SocketPath)
+	}
 
 	gpuAllocator, err := NewGPUAllocator()
 	if err != nil {
@@ -28,7 +50,7 @@ func TestGetAvailableGPU(t *testing.T) {
 		Logger.Error("get pod " + pod.Name + " failed")
 		panic(err)
 	}
-	gpuResources, err := gpuAllocator.GetAvailableGPU(pod, 2, 1)
+	gpuResources, err := gpuAllocator.GetAvailableGPU(context.TODO(), pod, 2, 1, nil)
 	if err != nil {
 		panic(err)
 	}
@@ -36,3 +58,74 @@ func TestGetAvailableGPU(t *testing.T) {
 		Logger.Info(gpuDev)
 	}
 }
+
+func TestSlavePodState(t *testing.T) {
+	waiting := func(reason string) corev1.PodStatus {
+		return corev1.PodStatus{
+			Phase: corev1.PodPending,
+			ContainerStatuses: []corev1.ContainerStatus{
+				{State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: reason}}},
+			},
+		}
+	}
+	cases := []struct {
+		name   string
+		status corev1.PodStatus
+		state  string
+	}{
+		{"creating", corev1.PodStatus{Phase: corev1.PodPending}, ""},
+		{"container creating", waiting("ContainerCreating"), ""},
+		{"running", corev1.PodStatus{Phase: corev1.PodRunning}, gpu.SuccessfullyCreated},
+		{"unschedulable", corev1.PodStatus{
+			Phase: corev1.PodPending,
+			Conditions: []corev1.PodCondition{
+				{Type: corev1.PodScheduled, Status: corev1.ConditionFalse, Reason: corev1.PodReasonUnschedulable},
+			},
+		}, gpu.InsufficientGPU},
+		{"image pull back off", waiting("ImagePullBackOff"), gpu.SlavePodImagePullFailed},
+		{"err image pull", waiting("ErrImagePull"), gpu.SlavePodImagePullFailed},
+		{"crash loop back off", corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{
+				{State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}},
+			},
+		}, gpu.SlavePodCrashLoopBackOff},
+		{"evicted", corev1.PodStatus{Phase: corev1.PodFailed, Reason: "Evicted"}, gpu.SlavePodEvicted},
+		{"failed", corev1.PodStatus{Phase: corev1.PodFailed}, gpu.SlavePodFailed},
+	}
+	for _, c := range cases {
+		pod := &corev1.Pod{Status: c.status}
+		if state := slavePodState(pod); state != c.state {
+			t.Errorf("%s: expected state %q, got %q", c.name, c.state, state)
+		}
+	}
+}
+
+func TestSlavePodsRunning(t *testing.T) {
+	pod := func(name string, phase corev1.PodPhase) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name}, Status: corev1.PodStatus{Phase: phase}}
+	}
+	condition := slavePodsRunning([]string{"slave-a", "slave-b"})
+	for _, event := range []watch.Event{
+		{Type: watch.Modified, Object: pod("slave-a", corev1.PodRunning)},
+		{Type: watch.Modified, Object: pod("other", corev1.PodRunning)},
+		// slave-a no longer counts as running once it is pending again
+		{Type: watch.Modified, Object: pod("slave-a", corev1.PodPending)},
+		{Type: watch.Modified, Object: pod("slave-b", corev1.PodRunning)},
+	} {
+		if done, err := condition(event); done || err != nil {
+			t.Fatalf("unexpected result of %s %s: %t, %v", event.Type, event.Object.(*corev1.Pod).Name, done, err)
+		}
+	}
+	if done, err := condition(watch.Event{Type: watch.Modified, Object: pod("slave-a", corev1.PodRunning)}); !done || err != nil {
+		t.Errorf("expected all slave pods running, got %t, %v", done, err)
+	}
+
+	condition = slavePodsRunning([]string{"slave-a"})
+	if _, err := condition(watch.Event{Type: watch.Modified, Object: pod("slave-a", corev1.PodFailed)}); err == nil || err.Error() != gpu.SlavePodFailed {
+		t.Errorf("expected %s, got %v", gpu.SlavePodFailed, err)
+	}
+	if _, err := condition(watch.Event{Type: watch.Deleted, Object: pod("slave-a", corev1.PodRunning)}); err == nil || err.Error() != gpu.FailedCreated {
+		t.Errorf("expected %s, got %v", gpu.FailedCreated, err)
+	}
+}
@@ -0,0 +1,187 @@
+package allocator
+
+import (
+	"GPUMounter/pkg/device"
+	"GPUMounter/pkg/util/gpu"
+	"GPUMounter/pkg/util/gpu/topology"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// DefaultPlacementAttempts is the number of slave pod batches created to find a preferred placement,
+// can be set by PLACEMENT_MAX_ATTEMPTS
+const DefaultPlacementAttempts = 3
+
+// Strategy rates the placements of gpus, the device plugin chooses the gpus of slave pods,
+// so the allocator holds the slave pods of unpreferred gpus and creates more until the placement is preferred
+type Strategy interface {
+	Name() gpu.PlacementStrategy
+	// Score rates placing the gpus for the pod already holding held, higher is better
+	Score(uuids []string, held []string, gpuTopology *topology.Topology) int
+}
+
+// GetStrategy returns the strategy of the name, the strategy set by env PLACEMENT_STRATEGY if name is empty
+func GetStrategy(name string) (Strategy, error) {
+	if name == "" {
+		name = os.Getenv("PLACEMENT_STRATEGY")
+	}
+	switch gpu.PlacementStrategy(name) {
+	case "", gpu.FirstFit:
+		return firstFit{}, nil
+	case gpu.TopologyAware:
+		return topologyAware{}, nil
+	case gpu.NUMAAffine:
+		return numaAffine{}, nil
+	case gpu.Spread:
+		return spread{}, nil
+	}
+	return nil, fmt.Errorf("unknown placement strategy: %s", name)
+}
+
+// GetPlacementAttempts returns the max placement attempts set by env PLACEMENT_MAX_ATTEMPTS
+func GetPlacementAttempts() (int, error) {
+	attemptsStr := os.Getenv("PLACEMENT_MAX_ATTEMPTS")
+	if attemptsStr == "" {
+		return DefaultPlacementAttempts, nil
+	}
+	attempts, err := strconv.Atoi(attemptsStr)
+	if err != nil || attempts <= 0 {
+		return 0, fmt.Errorf("invalid placement max attempts: %s", attemptsStr)
+	}
+	return attempts, nil
+}
+
+// sumPairs sums fn over the pairs of the new gpus, and the pairs of a new gpu and a held gpu
+func sumPairs(uuids []string, held []string, fn func(a string, b string) int) int {
+	sum := 0
+	for i, a := range uuids {
+		for _, b := range uuids[i+1:] {
+			sum += fn(a, b)
+		}
+		for _, b := range held {
+			sum += fn(a, b)
+		}
+	}
+	return sum
+}
+
+type firstFit struct{}
+
+func (firstFit) Name() gpu.PlacementStrategy {
+	return gpu.FirstFit
+}
+
+// Score is the same for every placement, so the first allocated gpus are kept
+func (firstFit) Score([]string, []string, *topology.Topology) int {
+	return 0
+}
+
+type topologyAware struct{}
+
+func (topologyAware) Name() gpu.PlacementStrategy {
+	return gpu.TopologyAware
+}
+
+func (topologyAware) Score(uuids []string, held []string, gpuTopology *topology.Topology) int {
+	return sumPairs(uuids, held, gpuTopology.Affinity)
+}
+
+type numaAffine struct{}
+
+func (numaAffine) Name() gpu.PlacementStrategy {
+	return gpu.NUMAAffine
+}
+
+func (numaAffine) Score(uuids []string, held []string, gpuTopology *topology.Topology) int {
+	return sumPairs(uuids, held, func(a string, b string) int {
+		if gpuTopology.SameNUMANode(a, b) {
+			return 1
+		}
+		return 0
+	})
+}
+
+type spread struct{}
+
+func (spread) Name() gpu.PlacementStrategy {
+	return gpu.Spread
+}
+
+func (spread) Score(uuids []string, held []string, gpuTopology *topology.Topology) int {
+	return -sumPairs(uuids, held, gpuTopology.Affinity)
+}
+
+// slavePodGPUs are the gpus of a slave pod, which are kept or released together
+type slavePodGPUs struct {
+	name string
+	gpus []*device.GPU
+}
+
+func uuidsOf(gpus []*device.GPU) []string {
+	var uuids []string
+	for _, gpuDev := range gpus {
+		uuids = append(uuids, gpuDev.UUID)
+	}
+	return uuids
+}
+
+// choose picks the slave pods with num gpus of the best score, greedily from every slave pod as the first pick
+func choose(strategy Strategy, candidates []*slavePodGPUs, held []string, num int, gpuTopology *topology.Topology) ([]*slavePodGPUs, []string) {
+	var chosen []*slavePodGPUs
+	var chosenUUIDs []string
+	bestScore := 0
+	for first := range candidates {
+		slavePods, uuids := chooseFrom(strategy, candidates, first, held, num, gpuTopology)
+		if uuids == nil {
+			continue
+		}
+		if score := strategy.Score(uuids, held, gpuTopology); chosenUUIDs == nil || score > bestScore {
+			chosen, chosenUUIDs, bestScore = slavePods, uuids, score
+		}
+	}
+	return chosen, chosenUUIDs
+}
+
+// chooseFrom picks the first slave pod, then the slave pod adding the best score until num gpus are picked
+func chooseFrom(strategy Strategy, candidates []*slavePodGPUs, first int, held []string, num int, gpuTopology *topology.Topology) ([]*slavePodGPUs, []string) {
+	if len(candidates[first].gpus) > num {
+		return nil, nil
+	}
+	chosen := []*slavePodGPUs{candidates[first]}
+	uuids := uuidsOf(candidates[first].gpus)
+	picked := map[int]bool{first: true}
+	for len(uuids) < num {
+		best := -1
+		bestScore := 0
+		for i, candidate := range candidates {
+			if picked[i] || len(uuids)+len(candidate.gpus) > num {
+				continue
+			}
+			score := strategy.Score(append(append([]string(nil), uuids...), uuidsOf(candidate.gpus)...), held, gpuTopology)
+			if best == -1 || score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		if best == -1 {
+			return nil, nil
+		}
+		picked[best] = true
+		chosen = append(chosen, candidates[best])
+		uuids = append(uuids, uuidsOf(candidates[best].gpus)...)
+	}
+	return chosen, uuids
+}
+
+// preferredScore is the score of the best placement of num gpus among the free gpus
+func preferredScore(strategy Strategy, free []*device.GPU, held []string, num int, gpuTopology *topology.Topology) (int, bool) {
+	var candidates []*slavePodGPUs
+	for _, gpuDev := range free {
+		candidates = append(candidates, &slavePodGPUs{gpus: []*device.GPU{gpuDev}})
+	}
+	_, uuids := choose(strategy, candidates, held, num, gpuTopology)
+	if uuids == nil {
+		return 0, false
+	}
+	return strategy.Score(uuids, held, gpuTopology), true
+}
@@ -0,0 +1,74 @@
+package allocator
+
+import (
+	"GPUMounter/pkg/device"
+	"GPUMounter/pkg/util/gpu/topology"
+	"reflect"
+	"testing"
+)
+
+// newTestTopology is 4 gpus on 2 NUMA nodes, GPU-0 and GPU-1 linked by 2 nvlinks
+func newTestTopology() *topology.Topology {
+	gpuTopology := topology.New()
+	gpuTopology.SetLevel("GPU-0", "GPU-1", topology.LevelHostBridge)
+	gpuTopology.SetLevel("GPU-2", "GPU-3", topology.LevelHostBridge)
+	for _, a := range []string{"GPU-0", "GPU-1"} {
+		for _, b := range []string{"GPU-2", "GPU-3"} {
+			gpuTopology.SetLevel(a, b, topology.LevelSystem)
+		}
+	}
+	gpuTopology.AddNVLink("GPU-0", "GPU-1")
+	gpuTopology.AddNVLink("GPU-0", "GPU-1")
+	return gpuTopology
+}
+
+func newTestSlavePods(uuids ...string) []*slavePodGPUs {
+	var slavePods []*slavePodGPUs
+	for _, uuid := range uuids {
+		slavePods = append(slavePods, &slavePodGPUs{name: "slave-" + uuid, gpus: []*device.GPU{{UUID: uuid}}})
+	}
+	return slavePods
+}
+
+func TestChoose(t *testing.T) {
+	gpuTopology := newTestTopology()
+	tests := []struct {
+		strategy   string
+		candidates []string
+		held       []string
+		num        int
+		want       []string
+	}{
+		{strategy: "first-fit", candidates: []string{"GPU-3", "GPU-0", "GPU-1"}, num: 2, want: []string{"GPU-3", "GPU-0"}},
+		{strategy: "topology", candidates: []string{"GPU-3", "GPU-0", "GPU-1"}, num: 2, want: []string{"GPU-0", "GPU-1"}},
+		{strategy: "topology", candidates: []string{"GPU-3", "GPU-0", "GPU-1"}, held: []string{"GPU-2"}, num: 1, want: []string{"GPU-3"}},
+		{strategy: "numa", candidates: []string{"GPU-3", "GPU-2", "GPU-0"}, held: []string{"GPU-1"}, num: 1, want: []string{"GPU-0"}},
+		{strategy: "spread", candidates: []string{"GPU-0", "GPU-1", "GPU-3"}, num: 2, want: []string{"GPU-0", "GPU-3"}},
+	}
+	for _, test := range tests {
+		strategy, err := GetStrategy(test.strategy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, uuids := choose(strategy, newTestSlavePods(test.candidates...), test.held, test.num, gpuTopology)
+		if !reflect.DeepEqual(uuids, test.want) {
+			t.Errorf("%s with held %v: got %v, want %v", test.strategy, test.held, uuids, test.want)
+		}
+	}
+}
+
+func TestPreferredScore(t *testing.T) {
+	gpuTopology := newTestTopology()
+	strategy, _ := GetStrategy("topology")
+	free := []*device.GPU{{UUID: "GPU-0"}, {UUID: "GPU-1"}, {UUID: "GPU-2"}}
+	score, ok := preferredScore(strategy, free, nil, 2, gpuTopology)
+	if want := gpuTopology.Affinity("GPU-0", "GPU-1"); !ok || score != want {
+		t.Errorf("got %d, %t, want %d", score, ok, want)
+	}
+	if _, ok := preferredScore(strategy, free, nil, 4, gpuTopology); ok {
+		t.Error("4 gpus should not be placed on 3 free gpus")
+	}
+	if _, err := GetStrategy("best-fit"); err == nil {
+		t.Error("best-fit should be unknown")
+	}
+}
@@ -0,0 +1,29 @@
+package gpu
+
+import "encoding/json"
+
+// MountedGPUsAnnotation on the owner pod lists its hot mounted gpus
+const MountedGPUsAnnotation = "gpumounter.io/mounted-gpus"
+
+// MountedGPU is an entry of MountedGPUsAnnotation
+type MountedGPU struct {
+	UUID           string   `json:"uuid"`
+	SlavePod       string   `json:"slavePod"`
+	Containers     []string `json:"containers"`
+	LeaseExpiresAt string   `json:"leaseExpiresAt,omitempty"`
+	// MemoryLimitMiB is the gpu memory the pod may use on a shared gpu
+	MemoryLimitMiB uint64 `json:"memoryLimitMiB,omitempty"`
+}
+
+// GetMountedGPUs parses MountedGPUsAnnotation of the owner pod, nil if the pod has no hot mounted gpu
+func GetMountedGPUs(annotations map[string]string) ([]*MountedGPU, error) {
+	value, ok := annotations[MountedGPUsAnnotation]
+	if !ok || value == "" {
+		return nil, nil
+	}
+	var mountedGPUs []*MountedGPU
+	if err := json.Unmarshal([]byte(value), &mountedGPUs); err != nil {
+		return nil, err
+	}
+	return mountedGPUs, nil
+}
@@ -3,13 +3,14 @@ package collector
 import (
 	"GPUMounter/pkg/device"
 	"GPUMounter/pkg/util/gpu"
-	"GPUMounter/pkg/util/gpu/collector/nvml"
+	"GPUMounter/pkg/util/gpu/topology"
 	. "GPUMounter/pkg/util/log"
 	"context"
 	"fmt"
 	"net"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"google.golang.org/grpc"
@@ -17,7 +18,12 @@ import (
 )
 
 type GPUCollector struct {
-	GPUList []*device.NvidiaGPU
+	// mu guards the state of the gpus in GPUList, which is updated by UpdateGPUStatus,
+	// read the gpus by GetGPUs and GetPodGPUResources which return copies
+	mu      sync.Mutex
+	GPUList []*device.GPU
+	// Topology is nil if nvml can not report it
+	Topology *topology.Topology
 }
 
 func NewGPUCollector() (*GPUCollector, error) {
@@ -28,7 +34,14 @@ func NewGPUCollector() (*GPUCollector, error) {
 		return nil, err
 	}
 
-	err := gpuCollector.UpdateGPUStatus()
+	gpuTopology, err := LoadTopology(gpuCollector.GPUList)
+	if err != nil {
+		Logger.Warn("Failed to load gpu topology, topology aware placement is disabled")
+		Logger.Warn(err)
+	}
+	gpuCollector.Topology = gpuTopology
+
+	err = gpuCollector.UpdateGPUStatus()
 	if err != nil {
 		Logger.Error("Failed to update gpu status")
 		return nil, err
@@ -39,46 +52,43 @@ func NewGPUCollector() (*GPUCollector, error) {
 
 func (gpuCollector *GPUCollector) GetGPUInfo() error {
 
-	Logger.Info("Start get gpu info")
-	if err := nvml.Init(); err != nil {
-		Logger.Error("nvml error: %+v", err)
+	Logger.Info("Start get gpu info of vendor: ", device.GetVendor())
+	gpus, err := device.GetBackend().Discover()
+	if err != nil {
+		Logger.Error("Failed to discover gpus")
 		return err
 	}
-	defer nvml.Shutdown()
+	gpuCollector.mu.Lock()
+	defer gpuCollector.mu.Unlock()
+	gpuCollector.GPUList = append(gpuCollector.GPUList, gpus...)
+	return nil
+}
 
-	num, err := nvml.GetDeviceCount()
-	if err != nil {
-		Logger.Error("Failed to get GPU num")
-	} else {
-		Logger.Info("GPU Num: ", num)
+// GetGPUs returns copies of the gpus with the state of the last UpdateGPUStatus
+func (gpuCollector *GPUCollector) GetGPUs() []*device.GPU {
+	gpuCollector.mu.Lock()
+	defer gpuCollector.mu.Unlock()
+	var gpus []*device.GPU
+	for _, gpuDev := range gpuCollector.GPUList {
+		copied := *gpuDev
+		gpus = append(gpus, &copied)
 	}
+	return gpus
+}
 
-	for i := uint(0); i < num; i++ {
-		dev, err := nvml.DeviceGetHandleByIndex(i)
-		if err != nil {
-			Logger.Error("Failed to get GPU ", i)
-			return err
-		}
-
-		minorNum, err := dev.DeviceGetMinorNumber()
-		if err != nil {
-			Logger.Error("Failed to get minor number of GPU ", i)
-			return err
-		}
-
-		uuid, err := dev.DeviceGetUUID()
-		if err != nil {
-			Logger.Error("Failed to get uuid of GPU ", i)
-			return err
-		}
-
-		gpuDev := device.New(int(minorNum), uuid)
-		gpuCollector.GPUList = append(gpuCollector.GPUList, gpuDev)
+// GetGPUByUUID returns a copy of the gpu with the uuid
+func (gpuCollector *GPUCollector) GetGPUByUUID(uuid string) (*device.GPU, error) {
+	gpuCollector.mu.Lock()
+	defer gpuCollector.mu.Unlock()
+	gpuDev, err := gpuCollector.getGPUByUUID(uuid)
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	copied := *gpuDev
+	return &copied, nil
 }
 
-func (gpuCollector *GPUCollector) GetGPUByUUID(uuid string) (*device.NvidiaGPU, error) {
+func (gpuCollector *GPUCollector) getGPUByUUID(uuid string) (*device.GPU, error) {
 	for _, gpuDev := range gpuCollector.GPUList {
 		if gpuDev.UUID == uuid {
 			return gpuDev, nil
@@ -110,17 +120,20 @@ func (gpuCollector *GPUCollector) UpdateGPUStatus() error {
 		return err
 	}
 
+	gpuCollector.mu.Lock()
+	defer gpuCollector.mu.Unlock()
 	gpuCollector.resetGPUStatus()
+	backend := device.GetBackend()
 	for _, pod := range listPodResp.GetPodResources() {
 		for _, container := range pod.GetContainers() {
 			for _, dev := range container.GetDevices() {
 
-				if dev.GetResourceName() != gpu.NvidiaResourceName {
+				if !backend.IsResource(dev.GetResourceName()) {
 					continue
 				}
 
 				for _, uuid := range dev.GetDeviceIds() {
-					if nvidiaGPU, err := gpuCollector.GetGPUByUUID(uuid); err != nil {
+					if nvidiaGPU, err := gpuCollector.getGPUByUUID(uuid); err != nil {
 						Logger.Error("No GPU with UUID: ", uuid)
 						return err
 					} else {
@@ -137,6 +150,7 @@ func (gpuCollector *GPUCollector) UpdateGPUStatus() error {
 	return nil
 }
 
+// resetGPUStatus frees all gpus, the caller should hold mu
 func (gpuCollector *GPUCollector) resetGPUStatus() {
 	for _, gpuDev := range gpuCollector.GPUList {
 		gpuDev.ResetState()
@@ -144,16 +158,16 @@ func (gpuCollector *GPUCollector) resetGPUStatus() {
 }
 
 /**
-get gpu resources of pod and it slave pod
+get gpu resources of pod and it slave pod, the gpus are copies which are not changed by later status updates
 */
-func (gpuCollector *GPUCollector) GetPodGPUResources(podName string, namespace string) ([]*device.NvidiaGPU, error) {
+func (gpuCollector *GPUCollector) GetPodGPUResources(podName string, namespace string) ([]*device.GPU, error) {
 	err := gpuCollector.UpdateGPUStatus()
 	if err != nil {
 		Logger.Error("Failed to update gpu status")
 		return nil, err
 	}
-	var gpuResources []*device.NvidiaGPU
-	for _, gpuDev := range gpuCollector.GPUList {
+	var gpuResources []*device.GPU
+	for _, gpuDev := range gpuCollector.GetGPUs() {
 		if (gpuDev.PodName == podName && gpuDev.Namespace == namespace) ||
 			(strings.Contains(gpuDev.PodName, podName+"-slave-pod-") && gpuDev.Namespace == gpu.GPUPoolNamespace) {
 			gpuResources = append(gpuResources, gpuDev)
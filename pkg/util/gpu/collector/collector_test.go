@@ -1,38 +1,125 @@
 package collector
 
 import (
+	"GPUMounter/pkg/device"
+	"GPUMounter/pkg/util/gpu"
 	. "GPUMounter/pkg/util/log"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
+// TestMain loads the gpus from nvml/testdata/fixture.yaml, set NVML_FIXTURE to use another fixture
+func TestMain(m *testing.M) {
+	dir, err := ioutil.TempDir("", "collector")
+	if err != nil {
+		panic(err)
+	}
+	InitLogger(dir+"/", "log")
+	if os.Getenv("NVML_FIXTURE") == "" {
+		os.Setenv("NVML_FIXTURE", "nvml/testdata/fixture.yaml")
+		if err := fakeMIGProcfs(dir); err != nil {
+			panic(err)
+		}
+	}
+	code := m.Run()
+	Logger.Sync()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// fakeMIGProcfs writes the capabilities reported by the driver for the MIG device of the fixture
+func fakeMIGProcfs(dir string) error {
+	device.ProcDevicesPath = filepath.Join(dir, "devices")
+	device.NvidiaCapsProcPath = filepath.Join(dir, "capabilities")
+	if err := ioutil.WriteFile(device.ProcDevicesPath, []byte("Character devices:\n195 nvidia-frontend\n508 nvidia-caps\n"), 0644); err != nil {
+		return err
+	}
+	ciPath := filepath.Join(device.NvidiaCapsProcPath, "gpu2", "mig", "gi7", "ci0")
+	if err := os.MkdirAll(ciPath, 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(ciPath, "..", "access"), []byte("DeviceFileMinor: 66\n"), 0644); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(ciPath, "access"), []byte("DeviceFileMinor: 67\n"), 0644)
+}
+
+func skipWithoutKubelet(t *testing.T) {
+	if _, err := os.Stat(gpu.SocketPath); os.IsNotExist(err) {
+		t.Skipf("kubelet pod resources socket %s is absent", gpu.SocketPath)
+	}
+}
+
 func TestGetGPUInfo(t *testing.T) {
-	InitLogger(".", "log")
-	defer Logger.Sync()
+	gpuCollector := &GPUCollector{}
+	if err := gpuCollector.GetGPUInfo(); err != nil {
+		t.Fatal(err)
+	}
+	// the MIG device is listed instead of its parent gpu
+	if len(gpuCollector.GPUList) != 3 {
+		t.Fatalf("expected 3 gpus, got %d", len(gpuCollector.GPUList))
+	}
+	for _, gpuDev := range gpuCollector.GPUList {
+		Logger.Info(gpuDev)
+	}
 
-	gpuCollector, err := NewGPUCollector()
+	gpuDev, err := gpuCollector.GetGPUByUUID("GPU-b9e0e5ce-3b0d-4a4b-8a4c-7f3c2a7b1d01")
 	if err != nil {
-		Logger.Error("Failed to get gpus info")
-		panic(err)
+		t.Fatal(err)
+	}
+	if gpuDev.DeviceFilePath != "/dev/nvidia0" {
+		t.Errorf("expected /dev/nvidia0, got %s", gpuDev.DeviceFilePath)
+	}
+	processes, err := gpuDev.GetRunningProcess()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// graphics processes come first
+	if len(processes) != 2 || processes[0].Pid != 2402 || processes[1].Pid != 2331 || processes[1].UsedGPUMemory != 1024*device.MiB {
+		t.Errorf("expected processes 2402 and 2331, got %d processes", len(processes))
 	}
-	for _, gpu := range gpuCollector.GPUList {
-		Logger.Info(gpu)
+	migDev, err := gpuCollector.GetGPUByUUID("MIG-5c1a7f0e-2d3b-5e4f-9a8b-7c6d5e4f3a21")
+	if err != nil {
+		t.Fatal(err)
 	}
+	if !migDev.IsMIG() || migDev.MIG.Profile != "1g.5gb" || migDev.MIG.CICapMinorNumber != 67 {
+		t.Errorf("unexpected MIG device: %+v", migDev.MIG)
+	}
+}
 
+func TestGetGPUsReturnsCopies(t *testing.T) {
+	gpuCollector := &GPUCollector{}
+	if err := gpuCollector.GetGPUInfo(); err != nil {
+		t.Fatal(err)
+	}
+	gpus := gpuCollector.GetGPUs()
+	gpus[0].State = device.GPU_ALLOCATED_STATE
+	gpus[0].PodName = "gpu-pod-slave-pod-2b1c9e"
+
+	// a status update resets the gpus of the collector, not the copies held by callers
+	gpuCollector.mu.Lock()
+	gpuCollector.resetGPUStatus()
+	gpuCollector.mu.Unlock()
+	if gpus[0].PodName != "gpu-pod-slave-pod-2b1c9e" {
+		t.Errorf("copy is reset by status update")
+	}
+	if gpuDev, _ := gpuCollector.GetGPUByUUID(gpus[0].UUID); gpuDev.PodName != "" {
+		t.Errorf("collector gpu is changed through its copy: %+v", gpuDev)
+	}
 }
 
 func TestGPUCollector_UpdateGPUStatus(t *testing.T) {
-	InitLogger(".", "log")
-	defer Logger.Sync()
+	skipWithoutKubelet(t)
 
 	gpuCollector, err := NewGPUCollector()
 	if err != nil {
-		Logger.Error(err)
-		panic(err)
+		t.Fatal(err)
 	}
 	err = gpuCollector.UpdateGPUStatus()
 	if err != nil {
-		Logger.Error(err)
-		panic(err)
+		t.Fatal(err)
 	}
 	for _, gpuDev := range gpuCollector.GPUList {
 		Logger.Info(gpuDev)
@@ -40,24 +127,20 @@ func TestGPUCollector_UpdateGPUStatus(t *testing.T) {
 }
 
 func TestGPUCollector_GetPodGPUResources(t *testing.T) {
-	InitLogger(".", "log")
-	defer Logger.Sync()
+	skipWithoutKubelet(t)
 
 	gpuCollector, err := NewGPUCollector()
 	if err != nil {
-		Logger.Error(err)
-		panic(err)
+		t.Fatal(err)
 	}
 	gpuResources, err := gpuCollector.GetPodGPUResources("gpu-pod2", "default")
 	if err != nil {
-		Logger.Error(err)
-		panic(err)
+		t.Fatal(err)
 	}
 	for _, gpuResource := range gpuResources {
 		procs, err := gpuResource.GetRunningProcess()
 		if err != nil {
-			Logger.Error(err)
-			panic(err)
+			t.Fatal(err)
 		}
 		for _, proc := range procs {
 			Logger.Info(proc.Pid)
@@ -1,3 +1,5 @@
+// +build !fakenvml
+
 /*
  * Copyright (c) 2020, NVIDIA CORPORATION.  All rights reserved.
  *
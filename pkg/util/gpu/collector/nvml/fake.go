@@ -0,0 +1,231 @@
+package nvml
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// topology levels reported by Fake, gpus on the same NUMA node share a NODE ancestor
+const (
+	fakeLevelInternal = 0
+	fakeLevelNode     = 40
+	fakeLevelSystem   = 50
+)
+
+const mib = 1024 * 1024
+
+// Fixture describes the gpus reported by Fake
+type Fixture struct {
+	DriverVersion string       `json:"driverVersion,omitempty"`
+	NVMLVersion   string       `json:"nvmlVersion,omitempty"`
+	Devices       []FakeDevice `json:"devices"`
+}
+
+// FakeDevice is a gpu of Fixture, MIG is enabled on it if it has MIG devices
+type FakeDevice struct {
+	UUID        string `json:"uuid"`
+	MinorNumber uint   `json:"minorNumber"`
+	Name        string `json:"name,omitempty"`
+	PciBusID    string `json:"pciBusID,omitempty"`
+	NumaNode    int    `json:"numaNode,omitempty"`
+	// NVLinks are the pci bus ids of the remote ends of the nvlinks
+	NVLinks     []string      `json:"nvlinks,omitempty"`
+	MemoryMiB   uint64        `json:"memoryMiB,omitempty"`
+	Utilization uint          `json:"utilization,omitempty"`
+	Processes   []FakeProcess `json:"processes,omitempty"`
+	MIGDevices  []FakeDevice  `json:"migDevices,omitempty"`
+	// GPUInstanceID and ComputeInstanceID are set for MIG devices
+	GPUInstanceID     uint `json:"gpuInstanceID,omitempty"`
+	ComputeInstanceID uint `json:"computeInstanceID,omitempty"`
+}
+
+// FakeProcess is a process running on a FakeDevice, a compute process unless Graphics is set
+type FakeProcess struct {
+	Pid              uint   `json:"pid"`
+	UsedGPUMemoryMiB uint64 `json:"usedGPUMemoryMiB,omitempty"`
+	Graphics         bool   `json:"graphics,omitempty"`
+}
+
+// Fake implements Interface with the gpus of Fixture, so GPUMounter can run without gpus
+type Fake struct {
+	Fixture
+}
+
+// LoadFake returns the Fake of the fixture file in YAML or JSON
+func LoadFake(path string) (*Fake, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	fake := &Fake{}
+	if err := yaml.UnmarshalStrict(content, &fake.Fixture); err != nil {
+		return nil, fmt.Errorf("invalid nvml fixture %s: %v", path, err)
+	}
+	return fake, nil
+}
+
+func (fake *Fake) Init() error {
+	return nil
+}
+
+func (fake *Fake) Shutdown() error {
+	return nil
+}
+
+func (fake *Fake) GetDeviceCount() (uint, error) {
+	return uint(len(fake.Devices)), nil
+}
+
+func (fake *Fake) GetDriverVersion() (string, error) {
+	return fake.DriverVersion, nil
+}
+
+func (fake *Fake) GetNVMLVersion() (string, error) {
+	return fake.NVMLVersion, nil
+}
+
+func (fake *Fake) DeviceGetHandleByIndex(idx uint) (DeviceHandle, error) {
+	if idx >= uint(len(fake.Devices)) {
+		return nil, fmt.Errorf("Invalid Argument: no device of index %d", idx)
+	}
+	return &fakeHandle{fake: fake, dev: &fake.Devices[idx]}, nil
+}
+
+// DeviceGetHandleByUUID finds the gpu or MIG device of the uuid
+func (fake *Fake) DeviceGetHandleByUUID(uuid string) (DeviceHandle, error) {
+	for i := range fake.Devices {
+		dev := &fake.Devices[i]
+		if dev.UUID == uuid {
+			return &fakeHandle{fake: fake, dev: dev}, nil
+		}
+		for j := range dev.MIGDevices {
+			if dev.MIGDevices[j].UUID == uuid {
+				return &fakeHandle{fake: fake, dev: &dev.MIGDevices[j], parent: dev}, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("Not Found: no device with uuid %s", uuid)
+}
+
+type fakeHandle struct {
+	fake *Fake
+	dev  *FakeDevice
+	// parent is the gpu of a MIG device
+	parent *FakeDevice
+}
+
+func (h *fakeHandle) DeviceGetMinorNumber() (uint, error) {
+	if h.parent != nil {
+		return 0, fmt.Errorf("Not Supported: minor number of MIG device %s", h.dev.UUID)
+	}
+	return h.dev.MinorNumber, nil
+}
+
+func (h *fakeHandle) DeviceGetUUID() (string, error) {
+	return h.dev.UUID, nil
+}
+
+func (h *fakeHandle) DeviceGetName() (string, error) {
+	return h.dev.Name, nil
+}
+
+func (h *fakeHandle) GetComputeRunningProcesses(size int) ([]*ProcessInfo, error) {
+	return h.processes(false, size)
+}
+
+func (h *fakeHandle) GetGraphicsRunningProcesses(size int) ([]*ProcessInfo, error) {
+	return h.processes(true, size)
+}
+
+// processes returns the processes of the device, and of its MIG devices for a MIG enabled gpu
+func (h *fakeHandle) processes(graphics bool, size int) ([]*ProcessInfo, error) {
+	var processInfos []*ProcessInfo
+	for _, dev := range append([]FakeDevice{*h.dev}, h.dev.MIGDevices...) {
+		for _, process := range dev.Processes {
+			if process.Graphics == graphics {
+				processInfos = append(processInfos, &ProcessInfo{Pid: process.Pid, UsedGPUMemory: process.UsedGPUMemoryMiB * mib})
+			}
+		}
+	}
+	if len(processInfos) > size {
+		return nil, fmt.Errorf("Insufficient Size: %d processes", len(processInfos))
+	}
+	return processInfos, nil
+}
+
+func (h *fakeHandle) DeviceGetUtilizationRates() (uint, uint, error) {
+	return h.dev.Utilization, 0, nil
+}
+
+// DeviceGetMemoryInfo returns the total and used memory of the device in bytes, the used memory is summed from its processes
+func (h *fakeHandle) DeviceGetMemoryInfo() (uint64, uint64, error) {
+	var used uint64
+	for _, graphics := range []bool{false, true} {
+		processInfos, err := h.processes(graphics, len(h.dev.Processes)+len(h.dev.MIGDevices)*1024)
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, processInfo := range processInfos {
+			used += processInfo.UsedGPUMemory
+		}
+	}
+	return h.dev.MemoryMiB * mib, used, nil
+}
+
+func (h *fakeHandle) DeviceGetTopologyCommonAncestor(other DeviceHandle) (uint, error) {
+	otherHandle, ok := other.(*fakeHandle)
+	if !ok {
+		return 0, fmt.Errorf("%T is not a device of the fake nvml", other)
+	}
+	if otherHandle.dev == h.dev {
+		return fakeLevelInternal, nil
+	}
+	if otherHandle.dev.NumaNode == h.dev.NumaNode {
+		return fakeLevelNode, nil
+	}
+	return fakeLevelSystem, nil
+}
+
+func (h *fakeHandle) DeviceGetPciBusID() (string, error) {
+	if h.dev.PciBusID == "" {
+		return "", fmt.Errorf("Not Supported: no pci bus id of device %s", h.dev.UUID)
+	}
+	return strings.ToUpper(h.dev.PciBusID), nil
+}
+
+func (h *fakeHandle) DeviceGetNvLinkRemoteBusIDs() ([]string, error) {
+	var busIDs []string
+	for _, busID := range h.dev.NVLinks {
+		busIDs = append(busIDs, strings.ToUpper(busID))
+	}
+	return busIDs, nil
+}
+
+func (h *fakeHandle) DeviceGetMigEnabled() (bool, error) {
+	return len(h.dev.MIGDevices) != 0, nil
+}
+
+func (h *fakeHandle) DeviceGetMigDeviceHandles() ([]DeviceHandle, error) {
+	var handles []DeviceHandle
+	for i := range h.dev.MIGDevices {
+		handles = append(handles, &fakeHandle{fake: h.fake, dev: &h.dev.MIGDevices[i], parent: h.dev})
+	}
+	return handles, nil
+}
+
+func (h *fakeHandle) DeviceGetGpuInstanceId() (uint, error) {
+	if h.parent == nil {
+		return 0, fmt.Errorf("Invalid Argument: %s is not a MIG device", h.dev.UUID)
+	}
+	return h.dev.GPUInstanceID, nil
+}
+
+func (h *fakeHandle) DeviceGetComputeInstanceId() (uint, error) {
+	if h.parent == nil {
+		return 0, fmt.Errorf("Invalid Argument: %s is not a MIG device", h.dev.UUID)
+	}
+	return h.dev.ComputeInstanceID, nil
+}
@@ -0,0 +1,8 @@
+// +build fakenvml
+
+package nvml
+
+// newDefault returns a Fake without gpus, the nvml library is not built in with tag fakenvml
+func newDefault() Interface {
+	return &Fake{}
+}
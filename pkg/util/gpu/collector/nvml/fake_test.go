@@ -0,0 +1,118 @@
+package nvml
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadFake(t *testing.T) {
+	fake, err := LoadFake("testdata/fixture.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count, err := fake.GetDeviceCount(); err != nil || count != 3 {
+		t.Errorf("expected 3 devices, got %d, %v", count, err)
+	}
+	if version, err := fake.GetDriverVersion(); err != nil || version != "525.60.13" {
+		t.Errorf("expected driver 525.60.13, got %q, %v", version, err)
+	}
+
+	handle, err := fake.DeviceGetHandleByIndex(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if minor, err := handle.DeviceGetMinorNumber(); err != nil || minor != 0 {
+		t.Errorf("expected minor 0, got %d, %v", minor, err)
+	}
+	compute, err := handle.GetComputeRunningProcesses(1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []*ProcessInfo{{Pid: 2331, UsedGPUMemory: 1024 * mib}}; !reflect.DeepEqual(compute, expected) {
+		t.Errorf("expected compute processes %v, got %v", expected, compute)
+	}
+	graphics, err := handle.GetGraphicsRunningProcesses(1024)
+	if err != nil || len(graphics) != 1 || graphics[0].Pid != 2402 {
+		t.Errorf("expected graphics process 2402, got %v, %v", graphics, err)
+	}
+	if total, used, err := handle.DeviceGetMemoryInfo(); err != nil || total != 16160*mib || used != 1536*mib {
+		t.Errorf("expected 16160 MiB with 1536 MiB used, got %d, %d, %v", total, used, err)
+	}
+	if busIDs, err := handle.DeviceGetNvLinkRemoteBusIDs(); err != nil || !reflect.DeepEqual(busIDs, []string{"00000000:5E:00.0"}) {
+		t.Errorf("expected nvlink to 00000000:5E:00.0, got %v, %v", busIDs, err)
+	}
+	other, _ := fake.DeviceGetHandleByIndex(1)
+	if level, err := handle.DeviceGetTopologyCommonAncestor(other); err != nil || level != fakeLevelNode {
+		t.Errorf("expected level %d, got %d, %v", fakeLevelNode, level, err)
+	}
+	if _, err := fake.DeviceGetHandleByUUID("GPU-missing"); err == nil {
+		t.Errorf("expected error for unknown uuid")
+	}
+}
+
+func TestLoadFakeMIG(t *testing.T) {
+	fake, err := LoadFake("testdata/fixture.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handle, err := fake.DeviceGetHandleByIndex(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enabled, err := handle.DeviceGetMigEnabled(); err != nil || !enabled {
+		t.Errorf("expected MIG enabled, got %t, %v", enabled, err)
+	}
+	migHandles, err := handle.DeviceGetMigDeviceHandles()
+	if err != nil || len(migHandles) != 1 {
+		t.Fatalf("expected 1 MIG device, got %d, %v", len(migHandles), err)
+	}
+	if gi, err := migHandles[0].DeviceGetGpuInstanceId(); err != nil || gi != 7 {
+		t.Errorf("expected gpu instance 7, got %d, %v", gi, err)
+	}
+	// processes on MIG devices are reported on the parent gpu too
+	migHandle, err := fake.DeviceGetHandleByUUID("MIG-5c1a7f0e-2d3b-5e4f-9a8b-7c6d5e4f3a21")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, h := range []DeviceHandle{handle, migHandle} {
+		if processes, err := h.GetComputeRunningProcesses(1024); err != nil || len(processes) != 1 || processes[0].Pid != 3100 {
+			t.Errorf("expected process 3100, got %v, %v", processes, err)
+		}
+	}
+}
+
+func TestNewFromFixture(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nvml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "fixture.json")
+	if err := ioutil.WriteFile(path, []byte(`{"devices": [{"uuid": "GPU-json", "minorNumber": 4}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv("NVML_FIXTURE")
+	os.Setenv("NVML_FIXTURE", path)
+
+	lib, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	handle, err := lib.DeviceGetHandleByUUID("GPU-json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if minor, err := handle.DeviceGetMinorNumber(); err != nil || minor != 4 {
+		t.Errorf("expected minor 4, got %d, %v", minor, err)
+	}
+
+	if err := ioutil.WriteFile(path, []byte(`{"devices": [{"uuid": "GPU-json", "minor": 4}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(); err == nil {
+		t.Errorf("expected error for unknown field")
+	}
+}
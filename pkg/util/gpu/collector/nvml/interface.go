@@ -0,0 +1,46 @@
+package nvml
+
+import "os"
+
+// Interface is the part of nvml used by GPUMounter, implemented by the nvml library and Fake
+type Interface interface {
+	Init() error
+	Shutdown() error
+	GetDeviceCount() (uint, error)
+	GetDriverVersion() (string, error)
+	GetNVMLVersion() (string, error)
+	DeviceGetHandleByIndex(idx uint) (DeviceHandle, error)
+	DeviceGetHandleByUUID(uuid string) (DeviceHandle, error)
+}
+
+// DeviceHandle is a gpu or MIG device of Interface
+type DeviceHandle interface {
+	DeviceGetMinorNumber() (uint, error)
+	DeviceGetUUID() (string, error)
+	DeviceGetName() (string, error)
+	GetComputeRunningProcesses(size int) ([]*ProcessInfo, error)
+	GetGraphicsRunningProcesses(size int) ([]*ProcessInfo, error)
+	DeviceGetUtilizationRates() (uint, uint, error)
+	DeviceGetMemoryInfo() (uint64, uint64, error)
+	DeviceGetTopologyCommonAncestor(other DeviceHandle) (uint, error)
+	DeviceGetPciBusID() (string, error)
+	DeviceGetNvLinkRemoteBusIDs() ([]string, error)
+	DeviceGetMigEnabled() (bool, error)
+	DeviceGetMigDeviceHandles() ([]DeviceHandle, error)
+	DeviceGetGpuInstanceId() (uint, error)
+	DeviceGetComputeInstanceId() (uint, error)
+}
+
+type ProcessInfo struct {
+	Pid           uint
+	UsedGPUMemory uint64
+}
+
+// New returns the Fake loaded from the fixture set by env NVML_FIXTURE, otherwise the nvml library,
+// which is replaced by an empty Fake in builds with tag fakenvml
+func New() (Interface, error) {
+	if path := os.Getenv("NVML_FIXTURE"); path != "" {
+		return LoadFake(path)
+	}
+	return newDefault(), nil
+}
@@ -0,0 +1,38 @@
+// +build !fakenvml
+
+package nvml
+
+// library is the Interface of libnvidia-ml.so
+type library struct{}
+
+func newDefault() Interface {
+	return library{}
+}
+
+func (library) Init() error {
+	return Init()
+}
+
+func (library) Shutdown() error {
+	return Shutdown()
+}
+
+func (library) GetDeviceCount() (uint, error) {
+	return GetDeviceCount()
+}
+
+func (library) GetDriverVersion() (string, error) {
+	return GetDriverVersion()
+}
+
+func (library) GetNVMLVersion() (string, error) {
+	return GetNVMLVersion()
+}
+
+func (library) DeviceGetHandleByIndex(idx uint) (DeviceHandle, error) {
+	return DeviceGetHandleByIndex(idx)
+}
+
+func (library) DeviceGetHandleByUUID(uuid string) (DeviceHandle, error) {
+	return DeviceGetHandleByUUID(uuid)
+}
@@ -1,18 +1,13 @@
 // Copyright (c) 2015-2018, NVIDIA CORPORATION. All rights reserved.
 
+// +build !fakenvml
+
 package nvml
 
 // #include "nvml.h"
 import "C"
 
-type Device struct {
-	Handle
-}
-
-type ProcessInfo struct {
-	Pid           uint
-	UsedGPUMemory uint64
-}
+import "fmt"
 
 func (h Handle) DeviceGetMinorNumber() (uint, error) {
 	var minor C.uint
@@ -117,3 +112,125 @@ func DeviceGetHandleByUUID(uuid string) (Handle, error) {
 
 	return Handle{dev}, errorString(r)
 }
+
+func (h Handle) DeviceGetUtilizationRates() (uint, uint, error) {
+	var usage C.nvmlUtilization_t
+
+	r := C.nvmlDeviceGetUtilizationRates(h.dev, &usage)
+
+	return uint(usage.gpu), uint(usage.memory), errorString(r)
+}
+
+// DeviceGetTopologyCommonAncestor returns the topology level of the closest common ancestor of the devices,
+// e.g. 10 if they only traverse a single PCIe switch, 50 if they are across NUMA nodes
+func (h Handle) DeviceGetTopologyCommonAncestor(other DeviceHandle) (uint, error) {
+	otherHandle, ok := other.(Handle)
+	if !ok {
+		return 0, fmt.Errorf("%T is not a device of the nvml library", other)
+	}
+	var level C.nvmlGpuTopologyLevel_t
+
+	r := C.nvmlDeviceGetTopologyCommonAncestor(h.dev, otherHandle.dev, &level)
+
+	return uint(level), errorString(r)
+}
+
+func (h Handle) DeviceGetPciBusID() (string, error) {
+	var pci C.nvmlPciInfo_t
+
+	r := C.nvmlDeviceGetPciInfo(h.dev, &pci)
+
+	return C.GoString(&pci.busId[0]), errorString(r)
+}
+
+// DeviceGetNvLinkRemoteBusIDs returns the pci bus id of the remote end of each active nvlink, empty if nvlink is not supported
+func (h Handle) DeviceGetNvLinkRemoteBusIDs() ([]string, error) {
+	var busIDs []string
+	for link := C.uint(0); link < C.NVML_NVLINK_MAX_LINKS; link++ {
+		var state C.nvmlEnableState_t
+		r := C.nvmlDeviceGetNvLinkState(h.dev, link, &state)
+		if r == C.NVML_ERROR_NOT_SUPPORTED || r == C.NVML_ERROR_INVALID_ARGUMENT {
+			break
+		}
+		if r != C.NVML_SUCCESS {
+			return nil, errorString(r)
+		}
+		if state != C.NVML_FEATURE_ENABLED {
+			continue
+		}
+		var pci C.nvmlPciInfo_t
+		r = C.nvmlDeviceGetNvLinkRemotePciInfo(h.dev, link, &pci)
+		if r != C.NVML_SUCCESS {
+			return nil, errorString(r)
+		}
+		busIDs = append(busIDs, C.GoString(&pci.busId[0]))
+	}
+	return busIDs, nil
+}
+
+// DeviceGetMemoryInfo returns the total and used memory of the device in bytes
+func (h Handle) DeviceGetMemoryInfo() (uint64, uint64, error) {
+	var memory C.nvmlMemory_t
+
+	r := C.nvmlDeviceGetMemoryInfo(h.dev, &memory)
+
+	return uint64(memory.total), uint64(memory.used), errorString(r)
+}
+
+func (h Handle) DeviceGetName() (string, error) {
+	var name [C.NVML_DEVICE_NAME_V2_BUFFER_SIZE]C.char
+
+	r := C.nvmlDeviceGetName(h.dev, &name[0], C.NVML_DEVICE_NAME_V2_BUFFER_SIZE)
+
+	return C.GoString(&name[0]), errorString(r)
+}
+
+// DeviceGetMigEnabled reports whether MIG mode is currently enabled on the device, false if MIG is not supported
+func (h Handle) DeviceGetMigEnabled() (bool, error) {
+	var current, pending C.uint
+
+	r := C.nvmlDeviceGetMigMode(h.dev, &current, &pending)
+	if r == C.NVML_ERROR_NOT_SUPPORTED {
+		return false, nil
+	}
+
+	return current == C.NVML_DEVICE_MIG_ENABLE, errorString(r)
+}
+
+// DeviceGetMigDeviceHandles returns the handles of the MIG devices created on the device
+func (h Handle) DeviceGetMigDeviceHandles() ([]DeviceHandle, error) {
+	var count C.uint
+	r := C.nvmlDeviceGetMaxMigDeviceCount(h.dev, &count)
+	if r != C.NVML_SUCCESS {
+		return nil, errorString(r)
+	}
+	var handles []DeviceHandle
+	for idx := C.uint(0); idx < count; idx++ {
+		var dev C.nvmlDevice_t
+		r = C.nvmlDeviceGetMigDeviceHandleByIndex(h.dev, idx, &dev)
+		if r == C.NVML_ERROR_NOT_FOUND {
+			continue
+		}
+		if r != C.NVML_SUCCESS {
+			return nil, errorString(r)
+		}
+		handles = append(handles, Handle{dev})
+	}
+	return handles, nil
+}
+
+func (h Handle) DeviceGetGpuInstanceId() (uint, error) {
+	var id C.uint
+
+	r := C.nvmlDeviceGetGpuInstanceId(h.dev, &id)
+
+	return uint(id), errorString(r)
+}
+
+func (h Handle) DeviceGetComputeInstanceId() (uint, error) {
+	var id C.uint
+
+	r := C.nvmlDeviceGetComputeInstanceId(h.dev, &id)
+
+	return uint(id), errorString(r)
+}
@@ -1,6 +1,7 @@
 // Copyright (c) 2015-2018, NVIDIA CORPORATION. All rights reserved.
 
 // +build linux darwin
+// +build !fakenvml
 
 package nvml
 
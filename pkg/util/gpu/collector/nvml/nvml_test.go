@@ -1,78 +1,104 @@
 package nvml
 
 import (
-	"fmt"
+	"os"
 	"testing"
 )
 
+// newTestLibrary returns the nvml loaded from testdata/fixture.yaml, which needs no gpu or libnvidia-ml
+func newTestLibrary(t *testing.T) Interface {
+	defer os.Unsetenv("NVML_FIXTURE")
+	os.Setenv("NVML_FIXTURE", "testdata/fixture.yaml")
+	lib, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lib.Init(); err != nil {
+		t.Fatal(err)
+	}
+	return lib
+}
+
 func check(err error, t *testing.T) {
 	if err != nil {
-		t.Errorf("%v\n", err)
+		t.Fatalf("%v\n", err)
 	}
 }
 
 func TestDeviceCount(t *testing.T) {
-	Init()
-	defer Shutdown()
+	lib := newTestLibrary(t)
+	defer lib.Shutdown()
 
-	count, err := GetDeviceCount()
+	count, err := lib.GetDeviceCount()
 	check(err, t)
 
-	fmt.Println(count)
+	if count != 3 {
+		t.Errorf("expected 3 devices, got %d", count)
+	}
 }
 
 func TestGetDriverVersion(t *testing.T) {
-	Init()
-	defer Shutdown()
+	lib := newTestLibrary(t)
+	defer lib.Shutdown()
 
-	ver, err := GetDriverVersion()
+	ver, err := lib.GetDriverVersion()
 	check(err, t)
 
-	fmt.Println(ver)
+	if ver != "525.60.13" {
+		t.Errorf("expected driver 525.60.13, got %s", ver)
+	}
 }
 
 func TestGetNVMLVersion(t *testing.T) {
-	Init()
-	defer Shutdown()
+	lib := newTestLibrary(t)
+	defer lib.Shutdown()
 
-	ver, err := GetNVMLVersion()
+	ver, err := lib.GetNVMLVersion()
 	check(err, t)
 
-	fmt.Println(ver)
+	if ver != "12.525.60.13" {
+		t.Errorf("expected nvml 12.525.60.13, got %s", ver)
+	}
 }
 
 func TestDeviceGetHandleByIndex(t *testing.T) {
-	Init()
-	defer Shutdown()
+	lib := newTestLibrary(t)
+	defer lib.Shutdown()
 
-	handle, err := DeviceGetHandleByIndex(0)
+	_, err := lib.DeviceGetHandleByIndex(0)
 	check(err, t)
 
-	fmt.Println(handle)
+	if _, err := lib.DeviceGetHandleByIndex(3); err == nil {
+		t.Errorf("expected error for index out of range")
+	}
 }
 
 func TestHandle_DeviceGetMinorNumber(t *testing.T) {
-	Init()
-	defer Shutdown()
+	lib := newTestLibrary(t)
+	defer lib.Shutdown()
 
-	handle, err := DeviceGetHandleByIndex(2)
+	handle, err := lib.DeviceGetHandleByIndex(2)
 	check(err, t)
 
 	minorNumber, err := handle.DeviceGetMinorNumber()
 	check(err, t)
 
-	fmt.Println(minorNumber)
+	if minorNumber != 2 {
+		t.Errorf("expected minor 2, got %d", minorNumber)
+	}
 }
 
 func TestHandle_DeviceGetUUID(t *testing.T) {
-	Init()
-	defer Shutdown()
+	lib := newTestLibrary(t)
+	defer lib.Shutdown()
 
-	handle, err := DeviceGetHandleByIndex(2)
+	handle, err := lib.DeviceGetHandleByIndex(2)
 	check(err, t)
 
 	uuid, err := handle.DeviceGetUUID()
 	check(err, t)
 
-	fmt.Println(uuid)
+	if uuid != "GPU-1f2e3d4c-5b6a-4798-8a9b-0c1d2e3f4a05" {
+		t.Errorf("unexpected uuid %s", uuid)
+	}
 }
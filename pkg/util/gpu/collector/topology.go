@@ -0,0 +1,73 @@
+package collector
+
+import (
+	"GPUMounter/pkg/device"
+	"GPUMounter/pkg/util/gpu/collector/nvml"
+	"GPUMounter/pkg/util/gpu/topology"
+	. "GPUMounter/pkg/util/log"
+	"errors"
+)
+
+// LoadTopology queries nvml for the PCIe paths and nvlinks between the gpus, MIG devices are left out
+func LoadTopology(gpuList []*device.GPU) (*topology.Topology, error) {
+	if vendor := device.GetVendor(); vendor != device.VendorNvidia {
+		return nil, errors.New("no topology of " + string(vendor) + " gpus")
+	}
+	var gpus []*device.GPU
+	for _, gpuDev := range gpuList {
+		if !gpuDev.IsMIG() {
+			gpus = append(gpus, gpuDev)
+		}
+	}
+	lib, err := nvml.New()
+	if err != nil {
+		Logger.Error("Failed to load nvml")
+		return nil, err
+	}
+	if err := lib.Init(); err != nil {
+		Logger.Errorf("nvml error: %+v", err)
+		return nil, err
+	}
+	defer lib.Shutdown()
+
+	handles := make([]nvml.DeviceHandle, len(gpus))
+	uuidOfBusID := make(map[string]string)
+	for i, gpuDev := range gpus {
+		handle, err := lib.DeviceGetHandleByUUID(gpuDev.UUID)
+		if err != nil {
+			Logger.Error("Failed to get GPU: ", gpuDev.UUID)
+			return nil, err
+		}
+		busID, err := handle.DeviceGetPciBusID()
+		if err != nil {
+			Logger.Error("Failed to get pci bus id of GPU: ", gpuDev.UUID)
+			return nil, err
+		}
+		handles[i] = handle
+		uuidOfBusID[busID] = gpuDev.UUID
+	}
+
+	gpuTopology := topology.New()
+	for i := range gpus {
+		for j := i + 1; j < len(gpus); j++ {
+			level, err := handles[i].DeviceGetTopologyCommonAncestor(handles[j])
+			if err != nil {
+				Logger.Error("Failed to get topology between GPU: ", gpus[i].UUID, " and GPU: ", gpus[j].UUID)
+				return nil, err
+			}
+			gpuTopology.SetLevel(gpus[i].UUID, gpus[j].UUID, int(level))
+		}
+		remoteBusIDs, err := handles[i].DeviceGetNvLinkRemoteBusIDs()
+		if err != nil {
+			Logger.Error("Failed to get nvlinks of GPU: ", gpus[i].UUID)
+			return nil, err
+		}
+		for _, busID := range remoteBusIDs {
+			// each link is reported by both ends, count it once, and skip the links to nvswitches
+			if remote, ok := uuidOfBusID[busID]; ok && gpus[i].UUID < remote {
+				gpuTopology.AddNVLink(gpus[i].UUID, remote)
+			}
+		}
+	}
+	return gpuTopology, nil
+}
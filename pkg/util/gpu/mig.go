@@ -0,0 +1,32 @@
+package gpu
+
+import (
+	"regexp"
+	"strings"
+)
+
+// MIGResourcePrefix is the prefix of the MIG resources exposed by the device plugin with mixed strategy,
+// e.g. nvidia.com/mig-1g.5gb
+const MIGResourcePrefix = "nvidia.com/mig-"
+
+var migProfilePattern = regexp.MustCompile(`^([0-9]+c\.)?[0-9]+g\.[0-9]+gb(\+[a-z0-9.]+)?$`)
+
+// IsValidMIGProfile reports whether the MIG profile is well formed, e.g. 1g.5gb or 1c.3g.20gb
+func IsValidMIGProfile(profile string) bool {
+	return migProfilePattern.MatchString(profile)
+}
+
+// MIGResourceName returns the resource name of the MIG profile
+func MIGResourceName(profile string) string {
+	return MIGResourcePrefix + profile
+}
+
+// IsNvidiaResource reports whether the resource is a whole gpu or a MIG device
+func IsNvidiaResource(resourceName string) bool {
+	return resourceName == NvidiaResourceName || strings.HasPrefix(resourceName, MIGResourcePrefix)
+}
+
+// IsGPUResource reports whether the resource is a gpu of any vendor or a MIG device
+func IsGPUResource(resourceName string) bool {
+	return IsNvidiaResource(resourceName) || resourceName == AMDResourceName
+}
@@ -0,0 +1,27 @@
+package gpu
+
+// PlacementStrategy decides which gpus of the node are preferred for a mount
+type PlacementStrategy string
+
+const (
+	// FirstFit keeps the gpus allocated by the device plugin
+	FirstFit PlacementStrategy = "first-fit"
+	// TopologyAware prefers the gpus closest to each other and to the gpus of the pod, by nvlink then PCIe path
+	TopologyAware PlacementStrategy = "topology"
+	// NUMAAffine prefers the gpus on the NUMA nodes of the gpus of the pod
+	NUMAAffine PlacementStrategy = "numa"
+	// Spread prefers the gpus farthest from each other and from the gpus of the pod
+	Spread PlacementStrategy = "spread"
+)
+
+// PlacementStrategies are the valid placement strategies
+var PlacementStrategies = []PlacementStrategy{FirstFit, TopologyAware, NUMAAffine, Spread}
+
+func IsValidPlacementStrategy(name string) bool {
+	for _, strategy := range PlacementStrategies {
+		if string(strategy) == name {
+			return true
+		}
+	}
+	return false
+}
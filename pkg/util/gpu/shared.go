@@ -0,0 +1,18 @@
+package gpu
+
+import "strings"
+
+const (
+	// SharedMount is the mount type of gpus shared with other pods
+	SharedMount MountType = "shared-mount"
+	// SharedAnnotation marks the slave pods reserving gpus shared by several pods,
+	// which have no owner pod and are deleted once no pod uses their gpus
+	SharedAnnotation = "gpumounter.io/shared"
+	// SharedSlavePodPrefix is the name prefix of shared slave pods
+	SharedSlavePodPrefix = "shared-gpu-"
+)
+
+// IsSharedSlavePod reports whether the slave pod reserves a shared gpu
+func IsSharedSlavePod(slavePodName string) bool {
+	return strings.HasPrefix(slavePodName, SharedSlavePodPrefix) && !strings.Contains(slavePodName, "-slave-pod-")
+}
@@ -0,0 +1,76 @@
+// Package topology describes how the gpus of a node are connected
+package topology
+
+// Levels of the closest common ancestor of two gpus, the same as nvmlGpuTopologyLevel_t
+const (
+	LevelInternal   = 0
+	LevelSingle     = 10
+	LevelMultiple   = 20
+	LevelHostBridge = 30
+	LevelNode       = 40
+	LevelSystem     = 50
+)
+
+type pair struct {
+	a string
+	b string
+}
+
+func newPair(a string, b string) pair {
+	if a > b {
+		a, b = b, a
+	}
+	return pair{a: a, b: b}
+}
+
+// Topology is the interconnect between the gpus of a node, keyed by gpu uuid
+type Topology struct {
+	levels  map[pair]int
+	nvlinks map[pair]int
+}
+
+func New() *Topology {
+	return &Topology{
+		levels:  make(map[pair]int),
+		nvlinks: make(map[pair]int),
+	}
+}
+
+// SetLevel records the topology level of the closest common ancestor of the gpus
+func (topology *Topology) SetLevel(a string, b string, level int) {
+	topology.levels[newPair(a, b)] = level
+}
+
+// AddNVLink records an nvlink between the gpus
+func (topology *Topology) AddNVLink(a string, b string) {
+	topology.nvlinks[newPair(a, b)]++
+}
+
+// Level returns the topology level of the closest common ancestor of the gpus, LevelSystem if unknown
+func (topology *Topology) Level(a string, b string) int {
+	if topology == nil {
+		return LevelSystem
+	}
+	if level, ok := topology.levels[newPair(a, b)]; ok {
+		return level
+	}
+	return LevelSystem
+}
+
+// NVLinks returns the number of nvlinks between the gpus
+func (topology *Topology) NVLinks(a string, b string) int {
+	if topology == nil {
+		return 0
+	}
+	return topology.nvlinks[newPair(a, b)]
+}
+
+// SameNUMANode reports whether the gpus are attached to the same NUMA node
+func (topology *Topology) SameNUMANode(a string, b string) bool {
+	return topology.Level(a, b) <= LevelNode
+}
+
+// Affinity rates how closely the gpus are connected, higher is closer. Every nvlink outweighs any PCIe path.
+func (topology *Topology) Affinity(a string, b string) int {
+	return topology.NVLinks(a, b)*(LevelSystem+10) + LevelSystem - topology.Level(a, b)
+}
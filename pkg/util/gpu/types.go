@@ -8,14 +8,32 @@ const (
 
 	ConnectionTimeout  = 10 * time.Second
 	NvidiaResourceName = "nvidia.com/gpu"
+	AMDResourceName    = "amd.com/gpu"
 
 	InsufficientGPU     = "InsufficientGPU"
 	SuccessfullyCreated = "SuccessfullyCreated"
 	FailedCreated       = "FailedCreated"
 	SuccessfullyDeleted = "SuccessfullyDeleted"
 	FailedDeleted       = "FailedDeleted"
+	ContainerNotFound   = "ContainerNotFound"
+
+	// reasons of slave pod failing to run
+	SlavePodImagePullFailed  = "SlavePodImagePullFailed"
+	SlavePodCrashLoopBackOff = "SlavePodCrashLoopBackOff"
+	SlavePodEvicted          = "SlavePodEvicted"
+	SlavePodFailed           = "SlavePodFailed"
+	SlavePodTimeout          = "SlavePodTimeout"
+	// SlavePodCanceled is the reason of the request being canceled while waiting slave pods
+	SlavePodCanceled = "SlavePodCanceled"
+
+	// DefaultSlavePodTimeout is the deadline of slave pods creating or deleting, can be set by SLAVE_POD_TIMEOUT
+	DefaultSlavePodTimeout = 2 * time.Minute
 
 	GPUPoolNamespace = "gpu-pool"
+
+	// annotations on slave pod recording its owner pod
+	OwnerPodAnnotation       = "gpumounter.io/owner-pod"
+	OwnerNamespaceAnnotation = "gpumounter.io/owner-namespace"
 )
 
 type MountType string
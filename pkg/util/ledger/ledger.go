@@ -0,0 +1,226 @@
+// Package ledger records the gpu mounts done by gpu mounter worker in an on-node state file,
+// so that half-finished mounts can be repaired or rolled back after the worker restarts
+package ledger
+
+import (
+	"GPUMounter/pkg/device"
+	. "GPUMounter/pkg/util/log"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultLedgerPath is the path of ledger file, can be set by LEDGER_PATH
+	DefaultLedgerPath = "/var/lib/GPUMounter/ledger.json"
+)
+
+type State string
+
+const (
+	// slave pod is running and the gpu is being mounted into the container
+	StateMounting State = "Mounting"
+	// the gpu is mounted into the container
+	StateMounted State = "Mounted"
+	// the gpu is being unmounted from the container and the slave pod will be deleted
+	StateUnmounting State = "Unmounting"
+)
+
+// Record is the mount of a gpu into a container of the owner pod
+type Record struct {
+	Namespace     string `json:"namespace"`
+	PodName       string `json:"podName"`
+	PodUID        string `json:"podUID"`
+	ContainerName string `json:"containerName"`
+	ContainerID   string `json:"containerID"`
+	UUID          string `json:"uuid"`
+	MinorNumber   int    `json:"minorNumber"`
+	// MIG is the MIG device of the uuid, nil for a whole gpu
+	MIG          *device.MIGDevice `json:"mig,omitempty"`
+	SlavePodName string            `json:"slavePodName"`
+	State        State             `json:"state"`
+	UpdatedAt    time.Time         `json:"updatedAt"`
+	// LeaseExpiresAt is when the gpu is reclaimed, zero for no lease
+	LeaseExpiresAt time.Time `json:"leaseExpiresAt,omitempty"`
+	// ForceReclaim kills the processes on the gpu when the lease expires
+	ForceReclaim bool `json:"forceReclaim,omitempty"`
+	// Shared is set if the gpu is shared with other pods, its slave pod is kept until no pod uses the gpu
+	Shared bool `json:"shared,omitempty"`
+	// MemoryLimitMiB is the gpu memory the pod may use on the shared gpu
+	MemoryLimitMiB uint64 `json:"memoryLimitMiB,omitempty"`
+	// Bootstrapped is set if the driver is bootstrapped into the container, it is undone with the last gpu unmounted
+	Bootstrapped bool `json:"bootstrapped,omitempty"`
+}
+
+// GPU returns the gpu of the record, which is a MIG device if the record has MIG
+func (record *Record) GPU() *device.GPU {
+	if record.MIG != nil {
+		mig := *record.MIG
+		return device.NewMIG(record.MinorNumber, record.UUID, &mig)
+	}
+	return device.New(record.MinorNumber, record.UUID)
+}
+
+// Key identifies the record by the owner pod, container and gpu
+func (record *Record) Key() string {
+	return record.Namespace + "/" + record.PodName + "/" + record.ContainerName + "/" + record.UUID
+}
+
+type Ledger struct {
+	path    string
+	mu      sync.Mutex
+	records map[string]*Record
+}
+
+// GetLedgerPath returns the ledger file path set by env LEDGER_PATH
+func GetLedgerPath() string {
+	if path := os.Getenv("LEDGER_PATH"); path != "" {
+		return path
+	}
+	return DefaultLedgerPath
+}
+
+// NewLedger loads the ledger from the file, an empty ledger is returned if the file does not exist
+func NewLedger(path string) (*Ledger, error) {
+	ledger := &Ledger{
+		path:    path,
+		records: make(map[string]*Record),
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			Logger.Info("No ledger file: ", path, ", starting with an empty ledger")
+			return ledger, nil
+		}
+		Logger.Error("Failed to read ledger file: ", path)
+		return nil, err
+	}
+	var records []*Record
+	if err := json.Unmarshal(data, &records); err != nil {
+		Logger.Error("Failed to parse ledger file: ", path)
+		return nil, err
+	}
+	for _, record := range records {
+		ledger.records[record.Key()] = record
+	}
+	return ledger, nil
+}
+
+// Put adds or updates the records and persists the ledger
+func (ledger *Ledger) Put(records ...*Record) error {
+	ledger.mu.Lock()
+	defer ledger.mu.Unlock()
+	now := time.Now()
+	for _, record := range records {
+		record.UpdatedAt = now
+		copied := *record
+		ledger.records[record.Key()] = &copied
+	}
+	return ledger.save()
+}
+
+// Delete removes the records and persists the ledger
+func (ledger *Ledger) Delete(records ...*Record) error {
+	ledger.mu.Lock()
+	defer ledger.mu.Unlock()
+	for _, record := range records {
+		delete(ledger.records, record.Key())
+	}
+	return ledger.save()
+}
+
+// Update calls update on each record and persists the ledger if any record is changed,
+// update returns whether the record is changed. Copies of the changed records are returned.
+func (ledger *Ledger) Update(update func(record *Record) bool) ([]*Record, error) {
+	ledger.mu.Lock()
+	defer ledger.mu.Unlock()
+	now := time.Now()
+	var updated []*Record
+	for _, record := range ledger.records {
+		if update(record) {
+			record.UpdatedAt = now
+			copied := *record
+			updated = append(updated, &copied)
+		}
+	}
+	if len(updated) == 0 {
+		return nil, nil
+	}
+	sort.Slice(updated, func(i, j int) bool {
+		return updated[i].Key() < updated[j].Key()
+	})
+	return updated, ledger.save()
+}
+
+// Get returns a copy of the record of the gpu mounted into the container, nil if there is no such record
+func (ledger *Ledger) Get(namespace string, podName string, containerName string, uuid string) *Record {
+	ledger.mu.Lock()
+	defer ledger.mu.Unlock()
+	record, ok := ledger.records[namespace+"/"+podName+"/"+containerName+"/"+uuid]
+	if !ok {
+		return nil
+	}
+	copied := *record
+	return &copied
+}
+
+// List returns copies of all records ordered by key
+func (ledger *Ledger) List() []*Record {
+	ledger.mu.Lock()
+	defer ledger.mu.Unlock()
+	var records []*Record
+	for _, record := range ledger.records {
+		copied := *record
+		records = append(records, &copied)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Key() < records[j].Key()
+	})
+	return records
+}
+
+// save writes the ledger to a temporary file and renames it, so that the ledger file is never half written
+func (ledger *Ledger) save() error {
+	records := make([]*Record, 0, len(ledger.records))
+	for _, record := range ledger.records {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Key() < records[j].Key()
+	})
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(ledger.path), 0755); err != nil {
+		Logger.Error("Failed to create ledger directory: ", filepath.Dir(ledger.path))
+		return err
+	}
+	tmpFile, err := ioutil.TempFile(filepath.Dir(ledger.path), filepath.Base(ledger.path)+".tmp")
+	if err != nil {
+		Logger.Error("Failed to create temporary ledger file")
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpFile.Name(), ledger.path); err != nil {
+		Logger.Error("Failed to write ledger file: ", ledger.path)
+		return err
+	}
+	return nil
+}
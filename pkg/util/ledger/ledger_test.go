@@ -0,0 +1,150 @@
+package ledger
+
+import (
+	"GPUMounter/pkg/device"
+	. "GPUMounter/pkg/util/log"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLedger(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	InitLogger(dir+"/", "log")
+	defer Logger.Sync()
+
+	path := filepath.Join(dir, "state", "ledger.json")
+	ledger, err := NewLedger(path)
+	if err != nil {
+		t.Fatalf("failed to create empty ledger: %v", err)
+	}
+	mounting := &Record{Namespace: "default", PodName: "gpu-pod", ContainerName: "trainer", UUID: "GPU-0", SlavePodName: "gpu-pod-slave-pod-0", State: StateMounting}
+	mounted := &Record{Namespace: "default", PodName: "gpu-pod", ContainerName: "trainer", UUID: "GPU-1", SlavePodName: "gpu-pod-slave-pod-1", State: StateMounted}
+	if err := ledger.Put(mounting, mounted); err != nil {
+		t.Fatalf("failed to put records: %v", err)
+	}
+	mounting.State = StateMounted
+	if err := ledger.Put(mounting); err != nil {
+		t.Fatalf("failed to update record: %v", err)
+	}
+	if record := ledger.Get("default", "gpu-pod", "trainer", "GPU-0"); record == nil || record.State != StateMounted {
+		t.Errorf("record is not updated: %+v", record)
+	}
+
+	reloaded, err := NewLedger(path)
+	if err != nil {
+		t.Fatalf("failed to reload ledger: %v", err)
+	}
+	if records := reloaded.List(); len(records) != 2 || records[0].UUID != "GPU-0" || records[1].State != StateMounted {
+		t.Errorf("unexpected reloaded records: %+v", records)
+	}
+
+	if err := reloaded.Delete(mounted); err != nil {
+		t.Fatalf("failed to delete record: %v", err)
+	}
+	reloaded, err = NewLedger(path)
+	if err != nil {
+		t.Fatalf("failed to reload ledger: %v", err)
+	}
+	if records := reloaded.List(); len(records) != 1 || records[0].UUID != "GPU-0" {
+		t.Errorf("unexpected records after delete: %+v", records)
+	}
+	if record := reloaded.Get("default", "gpu-pod", "trainer", "GPU-1"); record != nil {
+		t.Errorf("deleted record should not be found: %+v", record)
+	}
+}
+
+func TestLedgerUpdate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	InitLogger(dir+"/", "log")
+	defer Logger.Sync()
+
+	path := filepath.Join(dir, "ledger.json")
+	ledger, err := NewLedger(path)
+	if err != nil {
+		t.Fatalf("failed to create empty ledger: %v", err)
+	}
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	if err := ledger.Put(
+		&Record{Namespace: "default", PodName: "gpu-pod", ContainerName: "trainer", UUID: "GPU-0", State: StateMounted, LeaseExpiresAt: expiresAt},
+		&Record{Namespace: "default", PodName: "gpu-pod", ContainerName: "trainer", UUID: "GPU-1", State: StateMounted},
+	); err != nil {
+		t.Fatalf("failed to put records: %v", err)
+	}
+
+	extended := expiresAt.Add(time.Hour)
+	updated, err := ledger.Update(func(record *Record) bool {
+		if record.LeaseExpiresAt.IsZero() {
+			return false
+		}
+		record.LeaseExpiresAt = extended
+		return true
+	})
+	if err != nil {
+		t.Fatalf("failed to update records: %v", err)
+	}
+	if len(updated) != 1 || updated[0].UUID != "GPU-0" {
+		t.Fatalf("unexpected updated records: %+v", updated)
+	}
+
+	reloaded, err := NewLedger(path)
+	if err != nil {
+		t.Fatalf("failed to reload ledger: %v", err)
+	}
+	if record := reloaded.Get("default", "gpu-pod", "trainer", "GPU-0"); record == nil || !record.LeaseExpiresAt.Equal(extended) {
+		t.Errorf("lease is not extended: %+v", record)
+	}
+	if record := reloaded.Get("default", "gpu-pod", "trainer", "GPU-1"); record == nil || !record.LeaseExpiresAt.IsZero() {
+		t.Errorf("record without lease should not be changed: %+v", record)
+	}
+}
+
+func TestRecordMIG(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	InitLogger(dir+"/", "log")
+	defer Logger.Sync()
+
+	path := filepath.Join(dir, "ledger.json")
+	ledger, err := NewLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mig := &device.MIGDevice{ParentUUID: "GPU-0", Profile: "1g.5gb", GPUInstanceID: 7, CapsMajorNumber: 508, GICapMinorNumber: 66, CICapMinorNumber: 67}
+	if err := ledger.Put(
+		&Record{Namespace: "default", PodName: "gpu-pod", ContainerName: "trainer", UUID: "MIG-0", MinorNumber: 2, MIG: mig, State: StateMounted},
+		&Record{Namespace: "default", PodName: "gpu-pod", ContainerName: "trainer", UUID: "GPU-1", MinorNumber: 1, State: StateMounted},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	// the MIG device is rebuilt from the ledger file with its capability device files
+	reloaded, err := NewLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gpuDev := reloaded.Get("default", "gpu-pod", "trainer", "MIG-0").GPU()
+	if !gpuDev.IsMIG() || *gpuDev.MIG != *mig || gpuDev.MinorNumber != 2 {
+		t.Fatalf("unexpected MIG device: %+v", gpuDev)
+	}
+	files := gpuDev.DeviceFiles()
+	if len(files) != 3 || files[1].MajorNumber != 508 || files[1].MinorNumber != 66 || files[2].MinorNumber != 67 {
+		t.Errorf("unexpected device files of MIG device: %+v", files)
+	}
+	if gpuDev := reloaded.Get("default", "gpu-pod", "trainer", "GPU-1").GPU(); gpuDev.IsMIG() || gpuDev.DeviceFilePath != "/dev/nvidia1" {
+		t.Errorf("unexpected gpu: %+v", gpuDev)
+	}
+}
@@ -0,0 +1,46 @@
+package mtls
+
+import (
+	. "GPUMounter/pkg/util/log"
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// AuthorizeInterceptor rejects the calls to the services unless the client certificate identity is in the allowlist,
+// calls to other services only need a client certificate signed by the CA
+func AuthorizeInterceptor(allowlist []string, services ...string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		protected := false
+		for _, service := range services {
+			if strings.HasPrefix(info.FullMethod, "/"+service+"/") {
+				protected = true
+				break
+			}
+		}
+		if !protected {
+			return handler(ctx, req)
+		}
+
+		p, ok := peer.FromContext(ctx)
+		if !ok {
+			return nil, status.Error(codes.Unauthenticated, "no peer")
+		}
+		tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+		if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+			Logger.Error("Unauthenticated call of ", info.FullMethod, " from ", p.Addr)
+			return nil, status.Error(codes.Unauthenticated, "no verified client certificate")
+		}
+		cert := tlsInfo.State.VerifiedChains[0][0]
+		if !Allowed(cert, allowlist) {
+			Logger.Error("Client: ", cert.Subject.CommonName, " from ", p.Addr, " is not allowed to call ", info.FullMethod)
+			return nil, status.Error(codes.PermissionDenied, "client "+cert.Subject.CommonName+" is not allowed")
+		}
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,228 @@
+// Package mtls secures the grpc calls between gpu mounter master and workers by mutual TLS,
+// the certificates are loaded from a mounted Secret and reloaded when the Secret is rotated
+package mtls
+
+import (
+	. "GPUMounter/pkg/util/log"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+)
+
+const (
+	// DefaultCertDir is the directory of certificates, can be set by GRPC_TLS_CERT_DIR
+	DefaultCertDir = "/etc/GPUMounter/tls"
+	CertFile       = "tls.crt"
+	KeyFile        = "tls.key"
+	CAFile         = "ca.crt"
+
+	// DefaultClientAllowlist is the identity of clients allowed to call workers, can be set by GRPC_CLIENT_ALLOWLIST
+	DefaultClientAllowlist = "gpu-mounter-master"
+	// DefaultServerAllowlist is the identity of workers trusted by master, can be set by GRPC_SERVER_ALLOWLIST
+	DefaultServerAllowlist = "gpu-mounter-worker"
+
+	// reloadInterval is the minimum interval of checking the certificate files for rotation
+	reloadInterval = 10 * time.Second
+)
+
+// Enabled reports whether mutual TLS is enabled by env GRPC_TLS
+func Enabled() bool {
+	return os.Getenv("GRPC_TLS") == "true"
+}
+
+// GetCertDir returns the certificate directory set by env GRPC_TLS_CERT_DIR
+func GetCertDir() string {
+	if dir := os.Getenv("GRPC_TLS_CERT_DIR"); dir != "" {
+		return dir
+	}
+	return DefaultCertDir
+}
+
+// GetAllowlist returns the comma separated identities set by env, or the default identities
+func GetAllowlist(env string, defaultAllowlist string) []string {
+	allowlistStr := os.Getenv(env)
+	if allowlistStr == "" {
+		allowlistStr = defaultAllowlist
+	}
+	var allowlist []string
+	for _, identity := range strings.Split(allowlistStr, ",") {
+		if identity = strings.TrimSpace(identity); identity != "" {
+			allowlist = append(allowlist, identity)
+		}
+	}
+	return allowlist
+}
+
+// Allowed reports whether the identity of the certificate is in the allowlist,
+// entries starting with spiffe:// match the URI SANs, others match the common name
+func Allowed(cert *x509.Certificate, allowlist []string) bool {
+	for _, identity := range allowlist {
+		if strings.HasPrefix(identity, "spiffe://") {
+			for _, uri := range cert.URIs {
+				if uri.String() == identity {
+					return true
+				}
+			}
+		} else if cert.Subject.CommonName == identity {
+			return true
+		}
+	}
+	return false
+}
+
+// Reloader holds the key pair and the CA of the certificate directory,
+// they are reloaded on handshakes after the files are changed
+type Reloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	pool      *x509.CertPool
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+// NewReloader loads the certificates in the directory
+func NewReloader(dir string) (*Reloader, error) {
+	reloader := &Reloader{
+		certFile: filepath.Join(dir, CertFile),
+		keyFile:  filepath.Join(dir, KeyFile),
+		caFile:   filepath.Join(dir, CAFile),
+	}
+	if err := reloader.load(); err != nil {
+		Logger.Error("Failed to load certificates in: ", dir)
+		return nil, err
+	}
+	return reloader, nil
+}
+
+// latestModTime returns the latest modification time of the certificate files
+func (reloader *Reloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{reloader.certFile, reloader.keyFile, reloader.caFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (reloader *Reloader) load() error {
+	modTime, err := reloader.latestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(reloader.certFile, reloader.keyFile)
+	if err != nil {
+		return err
+	}
+	caPEM, err := ioutil.ReadFile(reloader.caFile)
+	if err != nil {
+		return err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return errors.New("no certificate in " + reloader.caFile)
+	}
+	reloader.cert = &cert
+	reloader.pool = pool
+	reloader.modTime = modTime
+	return nil
+}
+
+// current returns the key pair and the CA, the files are checked for rotation at most once per reloadInterval
+// and the previous certificates are kept if the rotated files can not be loaded
+func (reloader *Reloader) current() (*tls.Certificate, *x509.CertPool) {
+	reloader.mu.Lock()
+	defer reloader.mu.Unlock()
+	if time.Since(reloader.checkedAt) < reloadInterval {
+		return reloader.cert, reloader.pool
+	}
+	reloader.checkedAt = time.Now()
+	modTime, err := reloader.latestModTime()
+	if err == nil && modTime.Equal(reloader.modTime) {
+		return reloader.cert, reloader.pool
+	}
+	if err := reloader.load(); err != nil {
+		Logger.Error("Failed to reload certificates, keep using the previous ones")
+		Logger.Error(err)
+		return reloader.cert, reloader.pool
+	}
+	Logger.Info("Reloaded certificates from: ", filepath.Dir(reloader.certFile))
+	return reloader.cert, reloader.pool
+}
+
+// ServerCredentials requires clients to present a certificate signed by the CA
+func ServerCredentials(reloader *Reloader) credentials.TransportCredentials {
+	return credentials.NewTLS(&tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, pool := reloader.current()
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				ClientAuth:   tls.RequireAndVerifyClientCert,
+				Certificates: []tls.Certificate{*cert},
+				ClientCAs:    pool,
+			}, nil
+		},
+	})
+}
+
+// ClientCredentials presents the client certificate and verifies that the server certificate is signed by the CA
+// and its identity is in the allowlist. Workers are dialed by pod IP which is not known when issuing their certificates,
+// so the server identity is checked instead of the host name.
+func ClientCredentials(reloader *Reloader, serverAllowlist []string) credentials.TransportCredentials {
+	return credentials.NewTLS(&tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// the certificate chain is verified by VerifyPeerCertificate
+		InsecureSkipVerify: true,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _ := reloader.current()
+			return cert, nil
+		},
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errors.New("no server certificate")
+			}
+			var certs []*x509.Certificate
+			for _, rawCert := range rawCerts {
+				cert, err := x509.ParseCertificate(rawCert)
+				if err != nil {
+					return err
+				}
+				certs = append(certs, cert)
+			}
+			_, pool := reloader.current()
+			intermediates := x509.NewCertPool()
+			for _, cert := range certs[1:] {
+				intermediates.AddCert(cert)
+			}
+			if _, err := certs[0].Verify(x509.VerifyOptions{
+				Roots:         pool,
+				Intermediates: intermediates,
+				KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+			}); err != nil {
+				return err
+			}
+			if !Allowed(certs[0], serverAllowlist) {
+				return errors.New("server identity " + certs[0].Subject.CommonName + " is not allowed")
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,243 @@
+package mtls
+
+import (
+	gpu_mount "GPUMounter/pkg/api/gpu-mount"
+	. "GPUMounter/pkg/util/log"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := ioutil.TempDir("", "mtls")
+	if err != nil {
+		panic(err)
+	}
+	InitLogger(dir+"/", "log")
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "gpu-mounter-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// writeCertDir issues a certificate of the common name, and writes it with the CA into a new directory
+func (ca *testCA) writeCertDir(t *testing.T, commonName string, usage x509.ExtKeyUsage) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "mtls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{
+		CertFile: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		KeyFile:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		CAFile:   ca.pem,
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), content, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+type fakeAddGPUService struct{}
+
+func (fakeAddGPUService) AddGPU(context.Context, *gpu_mount.AddGPURequest) (*gpu_mount.AddGPUResponse, error) {
+	return &gpu_mount.AddGPUResponse{AddGpuResult: gpu_mount.AddGPUResponse_Success}, nil
+}
+
+func TestMutualTLS(t *testing.T) {
+	ca := newTestCA(t)
+	serverDir := ca.writeCertDir(t, "gpu-mounter-worker", x509.ExtKeyUsageServerAuth)
+	masterDir := ca.writeCertDir(t, "gpu-mounter-master", x509.ExtKeyUsageClientAuth)
+	intruderDir := ca.writeCertDir(t, "intruder", x509.ExtKeyUsageClientAuth)
+	otherCADir := newTestCA(t).writeCertDir(t, "gpu-mounter-master", x509.ExtKeyUsageClientAuth)
+	for _, dir := range []string{serverDir, masterDir, intruderDir, otherCADir} {
+		defer os.RemoveAll(dir)
+	}
+
+	serverReloader, err := NewReloader(serverDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer(
+		grpc.Creds(ServerCredentials(serverReloader)),
+		grpc.UnaryInterceptor(AuthorizeInterceptor([]string{"gpu-mounter-master"}, "gpu_mount.AddGPUService")),
+	)
+	gpu_mount.RegisterAddGPUServiceServer(server, fakeAddGPUService{})
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(server, healthServer)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(lis)
+	defer server.Stop()
+
+	dial := func(dir string, serverAllowlist []string) *grpc.ClientConn {
+		reloader, err := NewReloader(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(ClientCredentials(reloader, serverAllowlist)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conn
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	master := dial(masterDir, []string{"gpu-mounter-worker"})
+	defer master.Close()
+	if _, err := gpu_mount.NewAddGPUServiceClient(master).AddGPU(ctx, &gpu_mount.AddGPURequest{}); err != nil {
+		t.Errorf("allowed client should be served: %v", err)
+	}
+
+	intruder := dial(intruderDir, []string{"gpu-mounter-worker"})
+	defer intruder.Close()
+	if _, err := gpu_mount.NewAddGPUServiceClient(intruder).AddGPU(ctx, &gpu_mount.AddGPURequest{}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("expected PermissionDenied, got %v", err)
+	}
+	if _, err := healthpb.NewHealthClient(intruder).Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Errorf("unprotected service should be served: %v", err)
+	}
+
+	untrusted := dial(otherCADir, []string{"gpu-mounter-worker"})
+	defer untrusted.Close()
+	if _, err := healthpb.NewHealthClient(untrusted).Check(ctx, &healthpb.HealthCheckRequest{}); err == nil {
+		t.Errorf("client signed by another CA should be rejected")
+	}
+
+	wrongServer := dial(masterDir, []string{"other-worker"})
+	defer wrongServer.Close()
+	if _, err := healthpb.NewHealthClient(wrongServer).Check(ctx, &healthpb.HealthCheckRequest{}); err == nil {
+		t.Errorf("server not in allowlist should be rejected")
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	spiffeID, _ := url.Parse("spiffe://cluster.local/ns/kube-system/sa/gpumounter")
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "gpu-mounter-master"}, URIs: []*url.URL{spiffeID}}
+	testCases := []struct {
+		allowlist []string
+		expected  bool
+	}{
+		{[]string{"gpu-mounter-master"}, true},
+		{[]string{"spiffe://cluster.local/ns/kube-system/sa/gpumounter"}, true},
+		{[]string{"spiffe://cluster.local/ns/default/sa/default", "other"}, false},
+		{nil, false},
+	}
+	for _, testCase := range testCases {
+		if allowed := Allowed(cert, testCase.allowlist); allowed != testCase.expected {
+			t.Errorf("allowlist %v: expected %v, got %v", testCase.allowlist, testCase.expected, allowed)
+		}
+	}
+}
+
+func TestReload(t *testing.T) {
+	ca := newTestCA(t)
+	dir := ca.writeCertDir(t, "gpu-mounter-master", x509.ExtKeyUsageClientAuth)
+	defer os.RemoveAll(dir)
+	reloader, err := NewReloader(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous, _ := reloader.current()
+
+	// rotate the certificate
+	rotatedDir := ca.writeCertDir(t, "gpu-mounter-master", x509.ExtKeyUsageClientAuth)
+	defer os.RemoveAll(rotatedDir)
+	future := time.Now().Add(time.Minute)
+	for _, name := range []string{CertFile, KeyFile} {
+		if err := os.Rename(filepath.Join(rotatedDir, name), filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(filepath.Join(dir, name), future, future); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cert, _ := reloader.current(); cert != previous {
+		t.Errorf("certificate should not be checked again within reload interval")
+	}
+	reloader.checkedAt = time.Time{}
+	if cert, _ := reloader.current(); cert == previous {
+		t.Errorf("rotated certificate should be reloaded")
+	}
+
+	// broken files keep the previous certificate
+	loaded, _ := reloader.current()
+	if err := ioutil.WriteFile(filepath.Join(dir, KeyFile), []byte("broken"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	later := future.Add(time.Minute)
+	os.Chtimes(filepath.Join(dir, KeyFile), later, later)
+	reloader.checkedAt = time.Time{}
+	if cert, _ := reloader.current(); cert != loaded {
+		t.Errorf("previous certificate should be kept if reloading failed")
+	}
+}
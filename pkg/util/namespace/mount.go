@@ -0,0 +1,155 @@
+package namespace
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// flags of open_tree and move_mount, which are missing in golang.org/x/sys/unix
+const (
+	openTreeClone       = 0x1
+	moveMountFEmptyPath = 0x4
+)
+
+// BindMount mounts Source of the worker mount namespace at Target of the target mount namespace
+type BindMount struct {
+	Source string
+	Target string
+}
+
+// Symlink creates a symbolic link at Path pointing to Target
+type Symlink struct {
+	Path   string
+	Target string
+}
+
+// MountError is returned when the mount can not be set up or torn down
+type MountError struct {
+	Op   string
+	Path string
+	Err  error
+}
+
+func (e *MountError) Error() string {
+	return e.Op + " " + e.Path + ": " + e.Err.Error()
+}
+
+func (e *MountError) Unwrap() error {
+	return e.Err
+}
+
+// MountTmpfs mounts a read only tmpfs at dir in the mount namespace of pid, holding the bind mounts and symlinks.
+// The sources are cloned by open_tree before entering the namespace, which needs Linux 5.2+ and native mode
+func MountTmpfs(pid int, dir string, mounts []BindMount, symlinks []Symlink) error {
+	if GetMode() != NativeMode {
+		return fmt.Errorf("mounting into containers is only supported in %s mode", NativeMode)
+	}
+	var fds []int
+	defer func() {
+		for _, fd := range fds {
+			unix.Close(fd)
+		}
+	}()
+	for _, mount := range mounts {
+		fd, err := openTree(mount.Source)
+		if err != nil {
+			return &MountError{Op: "open_tree", Path: mount.Source, Err: err}
+		}
+		fds = append(fds, fd)
+	}
+
+	return WithMountNamespace(pid, func() error {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return &MountError{Op: "mkdir", Path: dir, Err: err}
+		}
+		if err := unix.Mount("tmpfs", dir, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=755"); err != nil {
+			return &MountError{Op: "mount", Path: dir, Err: err}
+		}
+		if err := populateTmpfs(dir, mounts, fds, symlinks); err != nil {
+			unix.Unmount(dir, unix.MNT_DETACH)
+			return err
+		}
+		return nil
+	})
+}
+
+func populateTmpfs(dir string, mounts []BindMount, fds []int, symlinks []Symlink) error {
+	for i, mount := range mounts {
+		if err := os.MkdirAll(filepath.Dir(mount.Target), 0755); err != nil {
+			return &MountError{Op: "mkdir", Path: filepath.Dir(mount.Target), Err: err}
+		}
+		// the mount point of a file
+		f, err := os.OpenFile(mount.Target, os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return &MountError{Op: "create", Path: mount.Target, Err: err}
+		}
+		f.Close()
+		if err := moveMount(fds[i], mount.Target); err != nil {
+			return &MountError{Op: "move_mount", Path: mount.Target, Err: err}
+		}
+		if err := unix.Mount("", mount.Target, "", unix.MS_REMOUNT|unix.MS_BIND|unix.MS_RDONLY, ""); err != nil {
+			return &MountError{Op: "remount", Path: mount.Target, Err: err}
+		}
+	}
+	for _, symlink := range symlinks {
+		if err := os.MkdirAll(filepath.Dir(symlink.Path), 0755); err != nil {
+			return &MountError{Op: "mkdir", Path: filepath.Dir(symlink.Path), Err: err}
+		}
+		if err := os.Symlink(symlink.Target, symlink.Path); err != nil {
+			return &MountError{Op: "symlink", Path: symlink.Path, Err: err}
+		}
+	}
+	if err := unix.Mount("", dir, "", unix.MS_REMOUNT|unix.MS_RDONLY|unix.MS_NOSUID|unix.MS_NODEV, ""); err != nil {
+		return &MountError{Op: "remount", Path: dir, Err: err}
+	}
+	return nil
+}
+
+// UnmountTmpfs detaches the tmpfs at dir together with the mounts under it in the mount namespace of pid,
+// nothing is done if dir is not a mount point
+func UnmountTmpfs(pid int, dir string) error {
+	return WithMountNamespace(pid, func() error {
+		err := unix.Unmount(dir, unix.MNT_DETACH)
+		if err == unix.EINVAL || err == unix.ENOENT {
+			return nil
+		}
+		if err != nil {
+			return &MountError{Op: "umount", Path: dir, Err: err}
+		}
+		return nil
+	})
+}
+
+func openTree(path string) (int, error) {
+	p, err := unix.BytePtrFromString(path)
+	if err != nil {
+		return -1, err
+	}
+	dirfd := unix.AT_FDCWD
+	fd, _, errno := unix.Syscall(unix.SYS_OPEN_TREE, uintptr(dirfd), uintptr(unsafe.Pointer(p)), openTreeClone|unix.O_CLOEXEC)
+	if errno != 0 {
+		return -1, errno
+	}
+	return int(fd), nil
+}
+
+func moveMount(fd int, target string) error {
+	empty, err := unix.BytePtrFromString("")
+	if err != nil {
+		return err
+	}
+	p, err := unix.BytePtrFromString(target)
+	if err != nil {
+		return err
+	}
+	dirfd := unix.AT_FDCWD
+	_, _, errno := unix.Syscall6(unix.SYS_MOVE_MOUNT, uintptr(fd), uintptr(unsafe.Pointer(empty)), uintptr(dirfd), uintptr(unsafe.Pointer(p)), moveMountFEmptyPath, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package namespace
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMountTmpfs(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("mount and setns require root")
+	}
+	dir, err := ioutil.TempDir("", "namespace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	source := filepath.Join(dir, "libfoo.so.1.0")
+	if err := ioutil.WriteFile(source, []byte("foo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(dir, "nvidia")
+	mounts := []BindMount{{Source: source, Target: filepath.Join(target, "lib64", "libfoo.so.1.0")}}
+	symlinks := []Symlink{{Path: filepath.Join(target, "lib64", "libfoo.so.1"), Target: "libfoo.so.1.0"}}
+
+	if err := MountTmpfs(os.Getpid(), target, mounts, symlinks); err != nil {
+		t.Skipf("mount is not permitted: %v", err)
+	}
+	content, err := ioutil.ReadFile(symlinks[0].Path)
+	if err != nil || string(content) != "foo" {
+		t.Errorf("expected content foo through the symlink, got %q, %v", content, err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(target, "new"), nil, 0644); err == nil {
+		t.Errorf("expected the tmpfs to be read only")
+	}
+
+	if err := UnmountTmpfs(os.Getpid(), target); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if entries, err := ioutil.ReadDir(target); err != nil || len(entries) != 0 {
+		t.Errorf("expected empty directory after unmount, got %d entries, %v", len(entries), err)
+	}
+	// unmounting again is allowed
+	if err := UnmountTmpfs(os.Getpid(), target); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"context"
 	"fmt"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 )
@@ -164,31 +165,68 @@ func (c *Config) buildCommand(ctx context.Context) (*exec.Cmd, error) {
 	return cmd, nil
 }
 
-func AddGPUDeviceFile(config *Config, gpu *device.NvidiaGPU) error {
-	cmd := "mknod -m " + device.DEFAULT_DEVICE_FILE_PERMISSION + " " + gpu.DeviceFilePath + " c " + strconv.Itoa(device.DEFAULT_NVIDA_MAJOR_NUMBER) + " " + strconv.Itoa(gpu.MinorNumber)
-	stdout, stderr, err := config.Execute("sh", "-c", cmd)
-	if err != nil {
-		Logger.Error("Failed to execute cmd: " + cmd)
-		Logger.Error("Std Output: " + stdout)
-		Logger.Error("Err Output: " + stderr)
-		return err
+// AddGPUDeviceFile creates the device files of the gpu in the mount namespace of config.Target
+func AddGPUDeviceFile(config *Config, gpu device.Device) error {
+	return AddDeviceFiles(config, gpu.DeviceFiles())
+}
+
+// RemoveGPUDeviceFile removes the device files of the gpu in the mount namespace of config.Target
+func RemoveGPUDeviceFile(config *Config, gpu device.Device) error {
+	return RemoveDeviceFiles(config, gpu.DeviceFiles())
+}
+
+// AddDeviceFiles creates the device files in the mount namespace of config.Target
+func AddDeviceFiles(config *Config, files []device.DeviceFile) error {
+	for _, file := range files {
+		if GetMode() == NativeMode {
+			if err := addDeviceFileNative(config.Target, file); err != nil {
+				Logger.Error("Failed to create device file: ", file.Path, " in mount namespace of PID: ", config.Target)
+				Logger.Error(err)
+				return err
+			}
+			continue
+		}
+		// the device file of the parent gpu exists if another MIG device on it is mounted
+		cmd := "mkdir -p " + filepath.Dir(file.Path) + " && { [ -c " + file.Path + " ] || mknod -m " + device.DEFAULT_DEVICE_FILE_PERMISSION + " " + file.Path + " c " + strconv.Itoa(file.MajorNumber) + " " + strconv.Itoa(file.MinorNumber) + "; }"
+		stdout, stderr, err := config.Execute("sh", "-c", cmd)
+		if err != nil {
+			Logger.Error("Failed to execute cmd: " + cmd)
+			Logger.Error("Std Output: " + stdout)
+			Logger.Error("Err Output: " + stderr)
+			return err
+		}
 	}
 	return nil
 }
 
-func RemoveGPUDeviceFile(config *Config, gpu *device.NvidiaGPU) error {
-	cmd := "rm " + gpu.DeviceFilePath
-	stdout, stderr, err := config.Execute("sh", "-c", cmd)
-	if err != nil {
-		Logger.Error("Failed to execute cmd: " + cmd)
-		Logger.Error("Std Output: " + stdout)
-		Logger.Error("Err Output: " + stderr)
-		return err
+// RemoveDeviceFiles removes the device files in the mount namespace of config.Target
+func RemoveDeviceFiles(config *Config, files []device.DeviceFile) error {
+	for _, file := range files {
+		if GetMode() == NativeMode {
+			if err := removeDeviceFileNative(config.Target, file); err != nil {
+				Logger.Error("Failed to remove device file: ", file.Path, " in mount namespace of PID: ", config.Target)
+				Logger.Error(err)
+				return err
+			}
+			continue
+		}
+		cmd := "rm " + file.Path
+		stdout, stderr, err := config.Execute("sh", "-c", cmd)
+		if err != nil {
+			Logger.Error("Failed to execute cmd: " + cmd)
+			Logger.Error("Std Output: " + stdout)
+			Logger.Error("Err Output: " + stderr)
+			return err
+		}
 	}
 	return nil
 }
 
+// KillRunningGPUProcesses terminates the processes using the gpu
 func KillRunningGPUProcesses(config *Config, podGPUProcesses []string) error {
+	if GetMode() == NativeMode {
+		return killProcessesNative(podGPUProcesses)
+	}
 	cmd := "kill " + strings.Join(podGPUProcesses, " ")
 	stdout, stderr, err := config.Execute("sh", "-c", cmd)
 	if err != nil {
@@ -0,0 +1,143 @@
+package namespace
+
+import (
+	"GPUMounter/pkg/device"
+	. "GPUMounter/pkg/util/log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+type Mode string
+
+const (
+	// NativeMode joins the mount namespace of the container by setns and calls mknod/unlink directly
+	NativeMode Mode = "native"
+	// NsenterMode runs mknod/rm/kill through nsenter and sh, which need to be available in the container
+	NsenterMode Mode = "nsenter"
+)
+
+// GetMode returns the mode set by env NAMESPACE_MODE, native by default
+func GetMode() Mode {
+	if mode := Mode(os.Getenv("NAMESPACE_MODE")); mode == NsenterMode {
+		return NsenterMode
+	}
+	return NativeMode
+}
+
+// NamespaceError is returned when the namespace of the target process can not be entered
+type NamespaceError struct {
+	PID int
+	Err error
+}
+
+func (e *NamespaceError) Error() string {
+	return "enter mount namespace of PID " + strconv.Itoa(e.PID) + ": " + e.Err.Error()
+}
+
+func (e *NamespaceError) Unwrap() error {
+	return e.Err
+}
+
+// DeviceFileError is returned when the device file can not be created or removed in the target namespace
+type DeviceFileError struct {
+	Op   string
+	Path string
+	Err  error
+}
+
+func (e *DeviceFileError) Error() string {
+	return e.Op + " " + e.Path + ": " + e.Err.Error()
+}
+
+func (e *DeviceFileError) Unwrap() error {
+	return e.Err
+}
+
+// WithMountNamespace runs fn in the mount namespace of the target process.
+// fn runs on a dedicated OS thread which is never unlocked, so the thread is destroyed
+// after fn returns instead of being reused by other goroutines in the container namespace.
+func WithMountNamespace(pid int, fn func() error) error {
+	errCh := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+
+		nsFile, err := os.Open("/proc/" + strconv.Itoa(pid) + "/ns/mnt")
+		if err != nil {
+			errCh <- &NamespaceError{PID: pid, Err: err}
+			return
+		}
+		defer nsFile.Close()
+		// a thread sharing fs attributes with other threads is not allowed to change mount namespace
+		if err := unix.Unshare(unix.CLONE_FS); err != nil {
+			errCh <- &NamespaceError{PID: pid, Err: err}
+			return
+		}
+		if err := unix.Setns(int(nsFile.Fd()), unix.CLONE_NEWNS); err != nil {
+			errCh <- &NamespaceError{PID: pid, Err: err}
+			return
+		}
+		errCh <- fn()
+	}()
+	return <-errCh
+}
+
+func addDeviceFileNative(pid int, file device.DeviceFile) error {
+	perm, err := strconv.ParseUint(device.DEFAULT_DEVICE_FILE_PERMISSION, 8, 32)
+	if err != nil {
+		return err
+	}
+	dev := unix.Mkdev(uint32(file.MajorNumber), uint32(file.MinorNumber))
+	return WithMountNamespace(pid, func() error {
+		// e.g. /dev/nvidia-caps is missing in containers without MIG devices
+		if err := os.MkdirAll(filepath.Dir(file.Path), 0755); err != nil {
+			return &DeviceFileError{Op: "mkdir", Path: filepath.Dir(file.Path), Err: err}
+		}
+		err := unix.Mknod(file.Path, unix.S_IFCHR|uint32(perm), int(dev))
+		if err == unix.EEXIST {
+			// the device file is left by a previous mount
+			var stat unix.Stat_t
+			if err := unix.Stat(file.Path, &stat); err != nil {
+				return &DeviceFileError{Op: "stat", Path: file.Path, Err: err}
+			}
+			if stat.Mode&unix.S_IFMT != unix.S_IFCHR || stat.Rdev != dev {
+				return &DeviceFileError{Op: "mknod", Path: file.Path, Err: err}
+			}
+		} else if err != nil {
+			return &DeviceFileError{Op: "mknod", Path: file.Path, Err: err}
+		}
+		// mknod mode is masked by umask
+		if err := unix.Chmod(file.Path, uint32(perm)); err != nil {
+			return &DeviceFileError{Op: "chmod", Path: file.Path, Err: err}
+		}
+		return nil
+	})
+}
+
+func removeDeviceFileNative(pid int, file device.DeviceFile) error {
+	return WithMountNamespace(pid, func() error {
+		if err := unix.Unlink(file.Path); err != nil {
+			return &DeviceFileError{Op: "unlink", Path: file.Path, Err: err}
+		}
+		return nil
+	})
+}
+
+// killProcessesNative sends SIGTERM to the processes, the PIDs are in the host PID namespace
+func killProcessesNative(podGPUProcesses []string) error {
+	for _, pidStr := range podGPUProcesses {
+		pid, err := strconv.Atoi(pidStr)
+		if err != nil {
+			Logger.Error("Invalid PID: ", pidStr)
+			return err
+		}
+		if err := unix.Kill(pid, unix.SIGTERM); err != nil && err != unix.ESRCH {
+			Logger.Error("Failed to kill process: ", pid)
+			return err
+		}
+	}
+	return nil
+}
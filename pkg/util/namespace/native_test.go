@@ -0,0 +1,66 @@
+package namespace
+
+import (
+	"GPUMounter/pkg/device"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestGetMode(t *testing.T) {
+	defer os.Unsetenv("NAMESPACE_MODE")
+	for env, expected := range map[string]Mode{"": NativeMode, "native": NativeMode, "nsenter": NsenterMode, "unknown": NativeMode} {
+		os.Setenv("NAMESPACE_MODE", env)
+		if mode := GetMode(); mode != expected {
+			t.Errorf("NAMESPACE_MODE=%q: expected %s, got %s", env, expected, mode)
+		}
+	}
+}
+
+func TestNativeDeviceFile(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("mknod and setns require root")
+	}
+	dir, err := ioutil.TempDir("", "namespace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gpu := device.New(0, "GPU-test")
+	gpu.DeviceFilePath = filepath.Join(dir, "nvidia0")
+	file := gpu.DeviceFiles()[0]
+
+	if err := addDeviceFileNative(os.Getpid(), file); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var stat unix.Stat_t
+	if err := unix.Stat(gpu.DeviceFilePath, &stat); err != nil {
+		t.Fatal(err)
+	}
+	if stat.Mode&unix.S_IFMT != unix.S_IFCHR || stat.Mode&0777 != 0666 || unix.Major(stat.Rdev) != device.DEFAULT_NVIDA_MAJOR_NUMBER {
+		t.Errorf("unexpected device file mode: %o rdev: %d", stat.Mode, stat.Rdev)
+	}
+	// creating the same device file again is allowed
+	if err := addDeviceFileNative(os.Getpid(), file); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := removeDeviceFileNative(os.Getpid(), file); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = removeDeviceFileNative(os.Getpid(), file)
+	var deviceFileErr *DeviceFileError
+	if !errors.As(err, &deviceFileErr) || !errors.Is(err, unix.ENOENT) {
+		t.Errorf("expected DeviceFileError of ENOENT, got %v", err)
+	}
+
+	err = addDeviceFileNative(-1, file)
+	var namespaceErr *NamespaceError
+	if !errors.As(err, &namespaceErr) {
+		t.Errorf("expected NamespaceError, got %v", err)
+	}
+}
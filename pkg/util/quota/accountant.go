@@ -0,0 +1,68 @@
+package quota
+
+import (
+	"GPUMounter/pkg/util/gpu"
+	. "GPUMounter/pkg/util/log"
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Accountant records the gpu hours consumed by slave pods when they are released.
+// Slave pods released while no accountant is running are not accounted.
+type Accountant struct {
+	clientset kubernetes.Interface
+	informer  cache.SharedIndexInformer
+}
+
+// NewAccountant creates an accountant watching all slave pods in gpu pool
+func NewAccountant(clientset kubernetes.Interface) *Accountant {
+	listWatch := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.LabelSelector = "app=gpu-pool"
+			return clientset.CoreV1().Pods(gpu.GPUPoolNamespace).List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.LabelSelector = "app=gpu-pool"
+			return clientset.CoreV1().Pods(gpu.GPUPoolNamespace).Watch(context.TODO(), options)
+		},
+	}
+	accountant := &Accountant{
+		clientset: clientset,
+		informer:  cache.NewSharedIndexInformer(listWatch, &corev1.Pod{}, 0, cache.Indexers{}),
+	}
+	accountant.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if slavePod, ok := obj.(*corev1.Pod); ok {
+				accountant.record(slavePod)
+			}
+		},
+	})
+	return accountant
+}
+
+func (accountant *Accountant) record(slavePod *corev1.Pod) {
+	releasedAt := time.Now()
+	if slavePod.DeletionTimestamp != nil && slavePod.DeletionTimestamp.Time.Before(releasedAt) {
+		releasedAt = slavePod.DeletionTimestamp.Time
+	}
+	if err := RecordUsage(accountant.clientset, slavePod, releasedAt); err != nil {
+		Logger.Error("Failed to record gpu hours of Slave Pod: ", slavePod.Name)
+		Logger.Error(err)
+	}
+}
+
+// Run starts accounting until stopCh is closed
+func (accountant *Accountant) Run(stopCh <-chan struct{}) {
+	Logger.Info("Starting quota accountant")
+	accountant.informer.Run(stopCh)
+}
@@ -0,0 +1,256 @@
+// Package quota limits the gpus hot mounted into the pods of each namespace.
+// Hot mounted gpus are held by slave pods in the gpu pool namespace, so they are not counted by the ResourceQuota
+// of the owner namespace. The quota of each namespace is configured in ConfigMap gpu-mounter-quota, and the consumed
+// gpu hours of released slave pods are accounted in ConfigMap gpu-mounter-quota-usage.
+package quota
+
+import (
+	"GPUMounter/pkg/util/gpu"
+	. "GPUMounter/pkg/util/log"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	k8s_error "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	ConfigNamespace    = "kube-system"
+	ConfigMapName      = "gpu-mounter-quota"
+	UsageConfigMapName = "gpu-mounter-quota-usage"
+	// DefaultKey is the quota of namespaces without their own quota
+	DefaultKey = "default"
+)
+
+// Quota is the limit of a namespace, nil fields are unlimited
+type Quota struct {
+	// MaxGPUs is the maximum number of gpus hot mounted into the pods of the namespace at the same time
+	MaxGPUs *int `json:"maxGPUs,omitempty"`
+	// MaxGPUHours is the maximum gpu hours consumed by the hot mounted gpus in a period
+	MaxGPUHours *float64 `json:"maxGPUHours,omitempty"`
+	// Period is the duration after which the consumed gpu hours are reset, e.g. "720h", never reset by default
+	Period string `json:"period,omitempty"`
+}
+
+// Usage is the gpu hours consumed by released slave pods of a namespace since the start of current period,
+// the period of a namespace starts when its first slave pod is created
+type Usage struct {
+	GPUHours float64     `json:"gpuHours"`
+	Since    metav1.Time `json:"since"`
+}
+
+// ExceededError is returned if mounting more gpus exceeds the quota of the namespace
+type ExceededError struct {
+	Namespace string
+	Message   string
+}
+
+func (e *ExceededError) Error() string {
+	return "quota of namespace " + e.Namespace + " exceeded: " + e.Message
+}
+
+// GetQuota returns the quota of the namespace, nil if the namespace is unlimited
+func GetQuota(clientset kubernetes.Interface, namespace string) (*Quota, error) {
+	configMap, err := clientset.CoreV1().ConfigMaps(ConfigNamespace).Get(context.TODO(), ConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		if k8s_error.IsNotFound(err) {
+			return nil, nil
+		}
+		Logger.Error("Failed to get quota ConfigMap: ", ConfigMapName)
+		return nil, err
+	}
+	data, ok := configMap.Data[namespace]
+	if !ok {
+		if data, ok = configMap.Data[DefaultKey]; !ok {
+			return nil, nil
+		}
+	}
+	quota := &Quota{}
+	if err := yaml.Unmarshal([]byte(data), quota); err != nil {
+		Logger.Error("Invalid quota of Namespace: ", namespace)
+		return nil, err
+	}
+	if _, err := quota.period(); err != nil {
+		Logger.Error("Invalid quota period of Namespace: ", namespace)
+		return nil, err
+	}
+	return quota, nil
+}
+
+// period returns the duration of gpu hours period, 0 if the gpu hours are never reset
+func (quota *Quota) period() (time.Duration, error) {
+	if quota.Period == "" {
+		return 0, nil
+	}
+	period, err := time.ParseDuration(quota.Period)
+	if err != nil || period <= 0 {
+		return 0, fmt.Errorf("invalid quota period: %s", quota.Period)
+	}
+	return period, nil
+}
+
+// currentUsage returns the usage of the namespace in current period, the usage is reset if its period is over.
+// Since is zero if no usage is recorded.
+func currentUsage(usageConfigMap *corev1.ConfigMap, namespace string, period time.Duration, now time.Time) (*Usage, error) {
+	usage := &Usage{}
+	if usageConfigMap != nil {
+		if data, ok := usageConfigMap.Data[namespace]; ok {
+			if err := json.Unmarshal([]byte(data), usage); err != nil {
+				Logger.Error("Invalid quota usage of Namespace: ", namespace)
+				return nil, err
+			}
+		}
+	}
+	if period > 0 && !usage.Since.IsZero() && now.Sub(usage.Since.Time) >= period {
+		usage = &Usage{Since: metav1.NewTime(now)}
+	}
+	return usage, nil
+}
+
+func getUsageConfigMap(clientset kubernetes.Interface) (*corev1.ConfigMap, error) {
+	configMap, err := clientset.CoreV1().ConfigMaps(ConfigNamespace).Get(context.TODO(), UsageConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		if k8s_error.IsNotFound(err) {
+			return nil, nil
+		}
+		Logger.Error("Failed to get quota usage ConfigMap: ", UsageConfigMapName)
+		return nil, err
+	}
+	return configMap, nil
+}
+
+// SlavePodGPUNum returns the number of gpus of any vendor reserved by the slave pod, a MIG device counts as a gpu
+func SlavePodGPUNum(slavePod *corev1.Pod) int {
+	gpuNum := 0
+	for _, container := range slavePod.Spec.Containers {
+		for resourceName, quantity := range container.Resources.Limits {
+			if gpu.IsGPUResource(string(resourceName)) {
+				gpuNum += int(quantity.Value())
+			}
+		}
+	}
+	return gpuNum
+}
+
+// slavePodGPUHours returns the gpu hours consumed by the slave pod from since to now
+func slavePodGPUHours(slavePod *corev1.Pod, since time.Time, now time.Time) float64 {
+	start := slavePod.CreationTimestamp.Time
+	if start.Before(since) {
+		start = since
+	}
+	if !now.After(start) {
+		return 0
+	}
+	return float64(SlavePodGPUNum(slavePod)) * now.Sub(start).Hours()
+}
+
+// Check returns ExceededError if mounting gpuNum more gpus into the pods of the namespace exceeds its quota.
+// The gpus and gpu hours of slave pods being released may still be counted, so the check is conservative.
+func Check(clientset kubernetes.Interface, namespace string, gpuNum int) error {
+	quota, err := GetQuota(clientset, namespace)
+	if err != nil {
+		return err
+	}
+	if quota == nil {
+		return nil
+	}
+	period, _ := quota.period()
+	now := time.Now()
+	usageConfigMap, err := getUsageConfigMap(clientset)
+	if err != nil {
+		return err
+	}
+	usage, err := currentUsage(usageConfigMap, namespace, period, now)
+	if err != nil {
+		return err
+	}
+
+	slavePods, err := clientset.CoreV1().Pods(gpu.GPUPoolNamespace).List(context.TODO(), metav1.ListOptions{LabelSelector: "app=gpu-pool"})
+	if err != nil {
+		Logger.Error("Failed to list slave pods")
+		return err
+	}
+	mountedGPUs := 0
+	gpuHours := usage.GPUHours
+	for i := range slavePods.Items {
+		slavePod := &slavePods.Items[i]
+		if slavePod.Annotations[gpu.OwnerNamespaceAnnotation] != namespace {
+			continue
+		}
+		mountedGPUs += SlavePodGPUNum(slavePod)
+		gpuHours += slavePodGPUHours(slavePod, usage.Since.Time, now)
+	}
+
+	if quota.MaxGPUs != nil && mountedGPUs+gpuNum > *quota.MaxGPUs {
+		return &ExceededError{
+			Namespace: namespace,
+			Message:   fmt.Sprintf("requested %d gpus, %d of %d gpus are mounted", gpuNum, mountedGPUs, *quota.MaxGPUs),
+		}
+	}
+	if quota.MaxGPUHours != nil && gpuHours >= *quota.MaxGPUHours {
+		message := fmt.Sprintf("%.2f of %.2f gpu hours are used", gpuHours, *quota.MaxGPUHours)
+		if period > 0 && !usage.Since.IsZero() {
+			message += ", reset at " + usage.Since.Add(period).Format(time.RFC3339)
+		}
+		return &ExceededError{Namespace: namespace, Message: message}
+	}
+	return nil
+}
+
+// RecordUsage adds the gpu hours consumed by the released slave pod to the usage of its owner namespace
+func RecordUsage(clientset kubernetes.Interface, slavePod *corev1.Pod, releasedAt time.Time) error {
+	namespace := slavePod.Annotations[gpu.OwnerNamespaceAnnotation]
+	if namespace == "" {
+		return nil
+	}
+	quota, err := GetQuota(clientset, namespace)
+	if err != nil {
+		return err
+	}
+	if quota == nil || quota.MaxGPUHours == nil {
+		return nil
+	}
+	period, _ := quota.period()
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		usageConfigMap, err := getUsageConfigMap(clientset)
+		if err != nil {
+			return err
+		}
+		usage, err := currentUsage(usageConfigMap, namespace, period, releasedAt)
+		if err != nil {
+			return err
+		}
+		if usage.Since.IsZero() {
+			usage.Since = slavePod.CreationTimestamp
+		}
+		usage.GPUHours += slavePodGPUHours(slavePod, usage.Since.Time, releasedAt)
+		data, err := json.Marshal(usage)
+		if err != nil {
+			return err
+		}
+		if usageConfigMap == nil {
+			_, err = clientset.CoreV1().ConfigMaps(ConfigNamespace).Create(context.TODO(), &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: UsageConfigMapName, Namespace: ConfigNamespace},
+				Data:       map[string]string{namespace: string(data)},
+			}, metav1.CreateOptions{})
+			if k8s_error.IsAlreadyExists(err) {
+				// created by another master, retry as a conflict
+				return k8s_error.NewConflict(corev1.Resource("configmaps"), UsageConfigMapName, err)
+			}
+			return err
+		}
+		if usageConfigMap.Data == nil {
+			usageConfigMap.Data = make(map[string]string)
+		}
+		usageConfigMap.Data[namespace] = string(data)
+		_, err = clientset.CoreV1().ConfigMaps(ConfigNamespace).Update(context.TODO(), usageConfigMap, metav1.UpdateOptions{})
+		return err
+	})
+}
@@ -0,0 +1,169 @@
+package quota
+
+import (
+	"GPUMounter/pkg/util/gpu"
+	. "GPUMounter/pkg/util/log"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := ioutil.TempDir("", "quota")
+	if err != nil {
+		panic(err)
+	}
+	InitLogger(dir+"/", "log")
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func newQuotaConfigMap(data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: ConfigMapName, Namespace: ConfigNamespace},
+		Data:       data,
+	}
+}
+
+func newSlavePod(name string, namespace string, gpuNum int, created time.Time) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         gpu.GPUPoolNamespace,
+			Labels:            map[string]string{"app": "gpu-pool"},
+			Annotations:       map[string]string{gpu.OwnerNamespaceAnnotation: namespace},
+			CreationTimestamp: metav1.NewTime(created),
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name: "gpu-container",
+				Resources: corev1.ResourceRequirements{
+					Limits: corev1.ResourceList{gpu.NvidiaResourceName: *resource.NewQuantity(int64(gpuNum), resource.DecimalSI)},
+				},
+			}},
+		},
+	}
+}
+
+func TestGetQuota(t *testing.T) {
+	client := kubefake.NewSimpleClientset()
+	if quota, err := GetQuota(client, "tenant-a"); err != nil || quota != nil {
+		t.Fatalf("expected unlimited without ConfigMap, got %v %v", quota, err)
+	}
+
+	client = kubefake.NewSimpleClientset(newQuotaConfigMap(map[string]string{
+		"default":  "maxGPUs: 1",
+		"tenant-a": "maxGPUs: 4\nmaxGPUHours: 10\nperiod: 24h",
+		"tenant-b": "period: never",
+	}))
+	quota, err := GetQuota(client, "tenant-a")
+	if err != nil || quota == nil || *quota.MaxGPUs != 4 || *quota.MaxGPUHours != 10 || quota.Period != "24h" {
+		t.Fatalf("unexpected quota of tenant-a: %+v %v", quota, err)
+	}
+	quota, err = GetQuota(client, "tenant-c")
+	if err != nil || quota == nil || *quota.MaxGPUs != 1 || quota.MaxGPUHours != nil {
+		t.Fatalf("expected default quota of tenant-c, got %+v %v", quota, err)
+	}
+	if _, err := GetQuota(client, "tenant-b"); err == nil {
+		t.Fatal("expected error of invalid period")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	now := time.Now()
+	client := kubefake.NewSimpleClientset(
+		newQuotaConfigMap(map[string]string{
+			"tenant-a": "maxGPUs: 4",
+			"tenant-b": "maxGPUHours: 10",
+		}),
+		newSlavePod("pod-a-slave-pod-1", "tenant-a", 2, now),
+		newSlavePod("pod-a-slave-pod-2", "tenant-a", 1, now),
+		newSlavePod("pod-b-slave-pod-1", "tenant-b", 2, now.Add(-4*time.Hour)),
+	)
+
+	if err := Check(client, "tenant-a", 1); err != nil {
+		t.Fatalf("expected 4 gpus to be allowed, got %v", err)
+	}
+	err := Check(client, "tenant-a", 2)
+	exceeded, ok := err.(*ExceededError)
+	if !ok || exceeded.Namespace != "tenant-a" || !strings.Contains(exceeded.Message, "3 of 4 gpus") {
+		t.Fatalf("expected max gpus exceeded, got %v", err)
+	}
+	if err := Check(client, "tenant-c", 100); err != nil {
+		t.Fatalf("expected tenant-c to be unlimited, got %v", err)
+	}
+
+	// 8 gpu hours are used by the running slave pod
+	if err := Check(client, "tenant-b", 1); err != nil {
+		t.Fatalf("expected gpu hours to be allowed, got %v", err)
+	}
+	usage, _ := json.Marshal(&Usage{GPUHours: 3, Since: metav1.NewTime(now.Add(-24 * time.Hour))})
+	if _, err := client.CoreV1().ConfigMaps(ConfigNamespace).Create(context.TODO(), &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: UsageConfigMapName, Namespace: ConfigNamespace},
+		Data:       map[string]string{"tenant-b": string(usage)},
+	}, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := Check(client, "tenant-b", 1).(*ExceededError); !ok {
+		t.Fatal("expected max gpu hours exceeded")
+	}
+}
+
+func TestRecordUsage(t *testing.T) {
+	now := time.Now()
+	client := kubefake.NewSimpleClientset(newQuotaConfigMap(map[string]string{
+		"tenant-a": "maxGPUHours: 100\nperiod: 24h",
+	}))
+	getUsage := func() *Usage {
+		configMap, err := client.CoreV1().ConfigMaps(ConfigNamespace).Get(context.TODO(), UsageConfigMapName, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		usage := &Usage{}
+		if err := json.Unmarshal([]byte(configMap.Data["tenant-a"]), usage); err != nil {
+			t.Fatal(err)
+		}
+		return usage
+	}
+
+	if err := RecordUsage(client, newSlavePod("pod-slave-pod-1", "tenant-a", 2, now.Add(-time.Hour)), now); err != nil {
+		t.Fatal(err)
+	}
+	if usage := getUsage(); usage.GPUHours < 1.99 || usage.GPUHours > 2.01 {
+		t.Fatalf("expected 2 gpu hours, got %v", usage.GPUHours)
+	}
+	if err := RecordUsage(client, newSlavePod("pod-slave-pod-2", "tenant-a", 1, now.Add(-30*time.Minute)), now); err != nil {
+		t.Fatal(err)
+	}
+	if usage := getUsage(); usage.GPUHours < 2.49 || usage.GPUHours > 2.51 {
+		t.Fatalf("expected 2.5 gpu hours, got %v", usage.GPUHours)
+	}
+
+	// the usage is reset after the period, only the hours in new period are counted
+	later := now.Add(25 * time.Hour)
+	if err := RecordUsage(client, newSlavePod("pod-slave-pod-3", "tenant-a", 1, now), later); err != nil {
+		t.Fatal(err)
+	}
+	if usage := getUsage(); usage.GPUHours != 0 || !usage.Since.Time.Equal(later.Truncate(time.Second)) {
+		t.Fatalf("expected usage to be reset, got %+v", usage)
+	}
+
+	// namespaces without gpu hours quota are not accounted
+	if err := RecordUsage(client, newSlavePod("pod-slave-pod-4", "tenant-b", 1, now.Add(-time.Hour)), now); err != nil {
+		t.Fatal(err)
+	}
+	configMap, _ := client.CoreV1().ConfigMaps(ConfigNamespace).Get(context.TODO(), UsageConfigMapName, metav1.GetOptions{})
+	if _, ok := configMap.Data["tenant-b"]; ok {
+		t.Fatal("expected tenant-b not to be accounted")
+	}
+}
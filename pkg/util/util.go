@@ -3,24 +3,58 @@ package util
 import (
 	gpu_mount "GPUMounter/pkg/api/gpu-mount"
 	"GPUMounter/pkg/device"
+	"GPUMounter/pkg/metrics"
 	"GPUMounter/pkg/util/cgroup"
 	"GPUMounter/pkg/util/gpu"
 	. "GPUMounter/pkg/util/log"
 	"GPUMounter/pkg/util/namespace"
 	"errors"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 )
 
-func MountGPU(pod *corev1.Pod, gpu *device.NvidiaGPU) error {
+// GetTargetContainers returns the status of containers the gpu is mounted into or unmounted from, which is
+// the container named containerName, all containers if allContainers is set, or the first container of pod by default
+func GetTargetContainers(pod *corev1.Pod, containerName string, allContainers bool) ([]corev1.ContainerStatus, error) {
+	if containerName == "" && !allContainers && len(pod.Spec.Containers) > 0 {
+		containerName = pod.Spec.Containers[0].Name
+	}
+	var containers []corev1.ContainerStatus
+	for _, containerStatus := range pod.Status.ContainerStatuses {
+		if !allContainers && containerStatus.Name != containerName {
+			continue
+		}
+		if containerStatus.ContainerID == "" || containerStatus.State.Running == nil {
+			if allContainers {
+				continue
+			}
+			Logger.Error("Container: ", containerName, " of Pod: ", pod.Name, " is not running")
+			return nil, errors.New(gpu.ContainerNotFound)
+		}
+		containers = append(containers, containerStatus)
+	}
+	if len(containers) == 0 {
+		Logger.Error("No running container: ", containerName, " in Pod: ", pod.Name)
+		return nil, errors.New(gpu.ContainerNotFound)
+	}
+	return containers, nil
+}
+
+func MountGPU(pod *corev1.Pod, container corev1.ContainerStatus, gpu *device.GPU) error {
 
-	Logger.Info("Start mount GPU: " + gpu.String() + " to Pod: " + pod.Name)
+	Logger.Info("Start mount GPU: " + gpu.String() + " to Pod: " + pod.Name + " Container: " + container.Name)
+	if err := gpu.CheckHealth(); err != nil {
+		Logger.Error("GPU: " + gpu.UUID + " is unhealthy")
+		return err
+	}
 
 	// change devices control group
-	containerID := pod.Status.ContainerStatuses[0].ContainerID
-	containerID = strings.Replace(containerID, "docker://", "", 1)
+	containerID := container.ContainerID
 	Logger.Info("Pod :" + pod.Name + " container ID: " + containerID)
 	cgroupDriver, err := cgroup.GetCgroupDriver()
 	if err != nil {
@@ -34,10 +68,12 @@ func MountGPU(pod *corev1.Pod, gpu *device.NvidiaGPU) error {
 	}
 	Logger.Info("Successfully get cgroup path: " + cgroupPath + " for Pod: " + pod.Name)
 
+	cgroupStart := time.Now()
 	if err := cgroup.AddGPUDevicePermission(cgroupPath, gpu); err != nil {
 		Logger.Error("Add GPU " + gpu.String() + "failed")
 		return err
 	}
+	metrics.ObservePhase(metrics.PhaseCgroupUpdate, cgroupStart)
 	Logger.Info("Successfully add GPU: " + gpu.String() + " permisssion for Pod: " + pod.Name)
 
 	// get target PID of this group
@@ -61,21 +97,22 @@ func MountGPU(pod *corev1.Pod, gpu *device.NvidiaGPU) error {
 		Mount:  true, // Execute into mount namespace
 		Target: PID,  // Enter into Target namespace
 	}
+	mknodStart := time.Now()
 	if err := namespace.AddGPUDeviceFile(cfg, gpu); err != nil {
 		Logger.Error("Failed to create device file in Target PID Namespace: ", PID, " Pod: ", pod.Name, " Namespace: ", pod.Namespace)
 		return err
 	}
+	metrics.ObservePhase(metrics.PhaseMknod, mknodStart)
 	Logger.Info("Successfully create device file in Target PID Namespace: ", PID, " Pod: ", pod.Name, " Namespace: ", pod.Namespace)
 	return nil
 
 }
 
-func UnmountGPU(pod *corev1.Pod, gpu *device.NvidiaGPU, forceRemove bool) error {
-	Logger.Info("Start unmount GPU: " + gpu.String() + " from Pod: " + pod.Name)
+func UnmountGPU(pod *corev1.Pod, container corev1.ContainerStatus, gpu *device.GPU, forceRemove bool) error {
+	Logger.Info("Start unmount GPU: " + gpu.String() + " from Pod: " + pod.Name + " Container: " + container.Name)
 
 	// get devices control group
-	containerID := pod.Status.ContainerStatuses[0].ContainerID
-	containerID = strings.Replace(containerID, "docker://", "", 1)
+	containerID := container.ContainerID
 	Logger.Info("Pod :" + pod.Name + " container ID: " + containerID)
 	cgroupDriver, err := cgroup.GetCgroupDriver()
 	if err != nil {
@@ -97,7 +134,7 @@ func UnmountGPU(pod *corev1.Pod, gpu *device.NvidiaGPU, forceRemove bool) error
 		return err
 	}
 
-	podGPUProcesses, err := GetPodGPUProcesses(pod, gpu)
+	podGPUProcesses, err := GetPodGPUProcesses(pod, container, gpu)
 	if err != nil {
 		Logger.Error("Failed to get GPU: ", gpu.DeviceFilePath+" status in Pod: ", pod.Name, " in Namespace: ", pod.Namespace)
 		Logger.Error(err)
@@ -108,13 +145,6 @@ func UnmountGPU(pod *corev1.Pod, gpu *device.NvidiaGPU, forceRemove bool) error
 		return errors.New(string(gpu_mount.RemoveGPUResponse_GPUBusy))
 	}
 
-	// remove permission
-	if err := cgroup.RemoveGPUDevicePermission(cgroupPath, gpu); err != nil {
-		Logger.Error("Remove GPU " + gpu.String() + "failed")
-		return err
-	}
-
-	// delete device files
 	PID, err := strconv.Atoi(pids[0])
 	if err != nil {
 		Logger.Error("Invalid PID: ", pids[0])
@@ -122,16 +152,34 @@ func UnmountGPU(pod *corev1.Pod, gpu *device.NvidiaGPU, forceRemove bool) error
 		return err
 	}
 	Logger.Info("Successfully get PID: " + strconv.Itoa(PID) + " of Pod: " + pod.Name + " Container: " + containerID)
+	files, err := releasedDeviceFiles(PID, gpu)
+	if err != nil {
+		Logger.Error("Failed to get device files of GPU: ", gpu.UUID, " used by other MIG devices in Pod: ", pod.Name, " Namespace: ", pod.Namespace)
+		Logger.Error(err)
+		return err
+	}
+
+	// remove permission
+	cgroupStart := time.Now()
+	if err := cgroup.RemoveDevicePermission(cgroupPath, files); err != nil {
+		Logger.Error("Remove GPU " + gpu.String() + "failed")
+		return err
+	}
+	metrics.ObservePhase(metrics.PhaseCgroupUpdate, cgroupStart)
+
+	// delete device files
 
 	// enter container namespace
 	cfg := &namespace.Config{
 		Mount:  true, // Execute into mount namespace
 		Target: PID,  // Enter into Target namespace
 	}
-	if err := namespace.RemoveGPUDeviceFile(cfg, gpu); err != nil {
+	mknodStart := time.Now()
+	if err := namespace.RemoveDeviceFiles(cfg, files); err != nil {
 		Logger.Error("Failed to remove device file in Target PID Namespace: ", PID, " Pod: ", pod.Name, " Namespace: ", pod.Namespace)
 		return err
 	}
+	metrics.ObservePhase(metrics.PhaseMknod, mknodStart)
 
 	// kill all running procs
 	if podGPUProcesses != nil {
@@ -146,13 +194,87 @@ func UnmountGPU(pod *corev1.Pod, gpu *device.NvidiaGPU, forceRemove bool) error
 	return nil
 }
 
+// releasedDeviceFiles returns the device files of the gpu the container does not need once the gpu is unmounted,
+// the device file of a MIG enabled gpu and the capability of a gpu instance are kept while other MIG devices use them,
+// and /dev/kfd is kept while other amd gpus are in the container
+func releasedDeviceFiles(PID int, gpu *device.GPU) ([]device.DeviceFile, error) {
+	if gpu.Vendor == device.VendorAMD {
+		files := gpu.DeviceFiles()
+		renderNodes, err := filepath.Glob("/proc/" + strconv.Itoa(PID) + "/root" + device.AMD_RENDER_DEVICE_FILE_PREFIX + "*")
+		if err != nil {
+			return nil, err
+		}
+		for _, renderNode := range renderNodes {
+			if !strings.HasSuffix(renderNode, gpu.DeviceFilePath) {
+				return files[1:], nil
+			}
+		}
+		return files, nil
+	}
+	if !gpu.IsMIG() {
+		return gpu.DeviceFiles(), nil
+	}
+	mig := gpu.MIG
+	files := []device.DeviceFile{mig.CICapDeviceFile()}
+	ciMinors, err := device.GetCICapMinorNumbers(gpu.MinorNumber, mig.GPUInstanceID)
+	if err != nil {
+		return nil, err
+	}
+	if inUse, err := capsInUse(PID, ciMinors, mig.CICapMinorNumber); err != nil || inUse {
+		return files, err
+	}
+	files = append(files, mig.GICapDeviceFile())
+	giMinors, err := device.GetGICapMinorNumbers(gpu.MinorNumber)
+	if err != nil {
+		return nil, err
+	}
+	if inUse, err := capsInUse(PID, giMinors, mig.GICapMinorNumber); err != nil || inUse {
+		return files, err
+	}
+	return append(files, gpu.DeviceFiles()[0]), nil
+}
+
+// capsInUse checks whether the capability device file of any minor number except excluded exists in the container
+func capsInUse(PID int, minors []int, excluded int) (bool, error) {
+	for _, minor := range minors {
+		if minor == excluded {
+			continue
+		}
+		_, err := os.Stat("/proc/" + strconv.Itoa(PID) + "/root" + device.NVIDIA_CAPS_DEVICE_FILE_PREFIX + strconv.Itoa(minor))
+		if err == nil {
+			return true, nil
+		}
+		if !os.IsNotExist(err) {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
 /**
-get all gpu proc pid in pod, return nil if no gpu proc in pod
+get all gpu proc pid in the container of pod, return nil if no gpu proc in the container
 */
-func GetPodGPUProcesses(pod *corev1.Pod, gpu *device.NvidiaGPU) ([]string, error) {
+func GetPodGPUProcesses(pod *corev1.Pod, container corev1.ContainerStatus, gpu *device.GPU) ([]string, error) {
+	processInfos, err := GetPodGPUProcessInfos(pod, container, gpu)
+	if err != nil {
+		return nil, err
+	}
+	var podGPUProcess []string
+	for _, processInfo := range processInfos {
+		podGPUProcess = append(podGPUProcess, strconv.Itoa(int(processInfo.Pid)))
+	}
+	if len(podGPUProcess) != 0 {
+		Logger.Debug("{Namespace: ", pod.Namespace, " Pod: ", pod.Name, "}proc PID: ", strings.Join(podGPUProcess, ", "), " running on GPU: ", gpu.UUID)
+		return podGPUProcess, nil
+	}
+	Logger.Debug("{Namespace: ", pod.Namespace, " Pod: ", pod.Name, "} has no proc running on GPU: ", gpu.UUID)
+	return nil, nil
+}
+
+// GetPodGPUProcessInfos returns the processes of the container running on the gpu with their gpu memory usage
+func GetPodGPUProcessInfos(pod *corev1.Pod, container corev1.ContainerStatus, gpu *device.GPU) ([]*device.Process, error) {
 	// get devices control group
-	containerID := pod.Status.ContainerStatuses[0].ContainerID
-	containerID = strings.Replace(containerID, "docker://", "", 1)
+	containerID := container.ContainerID
 	Logger.Info("Pod: " + pod.Name + " container ID: " + containerID)
 	cgroupDriver, err := cgroup.GetCgroupDriver()
 	if err != nil {
@@ -181,18 +303,86 @@ func GetPodGPUProcesses(pod *corev1.Pod, gpu *device.NvidiaGPU) ([]string, error
 		return nil, err
 	}
 
-	var podGPUProcess []string
+	var podGPUProcess []*device.Process
 	for _, processInfo := range gpuProcess {
 		if ContainString(podProcess, strconv.Itoa(int(processInfo.Pid))) {
-			podGPUProcess = append(podGPUProcess, strconv.Itoa(int(processInfo.Pid)))
+			podGPUProcess = append(podGPUProcess, processInfo)
 		}
 	}
-	if len(podGPUProcess) != 0 {
-		Logger.Debug("{Namespace: ", pod.Namespace, " Pod: ", pod.Name, "}proc PID: ", strings.Join(podGPUProcess, ", "), " running on GPU: ", gpu.UUID)
-		return podGPUProcess, nil
+	return podGPUProcess, nil
+}
+
+// KillPodProcesses kills the processes of the container
+func KillPodProcesses(pod *corev1.Pod, container corev1.ContainerStatus, pids []string) error {
+	_, PID, err := getContainerProcess(pod, container)
+	if err != nil {
+		return err
 	}
-	Logger.Debug("{Namespace: ", pod.Namespace, " Pod: ", pod.Name, "} has no proc running on GPU: ", gpu.UUID)
-	return nil, nil
+	cfg := &namespace.Config{
+		Mount:  true, // Execute into mount namespace
+		Target: PID,  // Enter into Target namespace
+	}
+	Logger.Info("Killing Processes: ", strings.Join(pids, ", "), " on Pod: ", pod.Name, " Namespace: ", pod.Namespace, " Container: ", container.Name)
+	return namespace.KillRunningGPUProcesses(cfg, pids)
+}
+
+// getContainerProcess returns the cgroup path and a PID of the container
+func getContainerProcess(pod *corev1.Pod, container corev1.ContainerStatus) (string, int, error) {
+	cgroupDriver, err := cgroup.GetCgroupDriver()
+	if err != nil {
+		Logger.Error("Get cgroup driver failed")
+		return "", 0, err
+	}
+	cgroupPath, err := cgroup.GetCgroupName(cgroupDriver, pod, container.ContainerID)
+	if err != nil {
+		Logger.Error("Get cgroup path for Pod: " + pod.Name + " failed")
+		return "", 0, err
+	}
+	pids, err := cgroup.GetCgroupPIDs(cgroupPath)
+	if err != nil {
+		Logger.Error("Get PID of Pod: " + pod.Name + " Container: " + container.ContainerID + " failed")
+		return "", 0, err
+	}
+	if len(pids) == 0 {
+		return "", 0, errors.New("no process in container: " + container.ContainerID)
+	}
+	PID, err := strconv.Atoi(pids[0])
+	if err != nil {
+		Logger.Error("Invalid PID: ", pids[0])
+		return "", 0, err
+	}
+	return cgroupPath, PID, nil
+}
+
+// IsGPUMounted checks whether the device file of the gpu exists in the container,
+// which is the capability of the compute instance for MIG devices
+func IsGPUMounted(pod *corev1.Pod, container corev1.ContainerStatus, gpu *device.GPU) (bool, error) {
+	_, PID, err := getContainerProcess(pod, container)
+	if err != nil {
+		return false, err
+	}
+	files := gpu.DeviceFiles()
+	info, err := os.Stat("/proc/" + strconv.Itoa(PID) + "/root" + files[len(files)-1].Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return info.Mode()&os.ModeCharDevice != 0, nil
+}
+
+// RestoreGPUDevicePermission applies the devices cgroup rule of the gpu to the container again
+func RestoreGPUDevicePermission(pod *corev1.Pod, container corev1.ContainerStatus, gpu *device.GPU) error {
+	cgroupPath, _, err := getContainerProcess(pod, container)
+	if err != nil {
+		return err
+	}
+	if err := cgroup.AddGPUDevicePermission(cgroupPath, gpu); err != nil {
+		Logger.Error("Restore GPU " + gpu.String() + " permission failed")
+		return err
+	}
+	return nil
 }
 
 func ContainString(stringList []string, aimString string) bool {
@@ -0,0 +1,303 @@
+// Package worker finds the gpu mounter workers and connects to them
+package worker
+
+import (
+	"GPUMounter/pkg/config"
+	"GPUMounter/pkg/metrics"
+	. "GPUMounter/pkg/util/log"
+	"GPUMounter/pkg/util/mtls"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	WorkerNamespace     = "kube-system"
+	WorkerLabelSelector = "app=gpu-mounter-worker"
+	WorkerPort          = "1200"
+	// WorkerMetricsPort serves the prometheus metrics of worker
+	WorkerMetricsPort = "1201"
+
+	// NotFound is the error of no gpu mounter worker on the node
+	NotFound = "WorkerNotFound"
+
+	nodeNameIndex = "nodeName"
+	// healthCheckInterval is the interval of checking pooled connections
+	healthCheckInterval = 30 * time.Second
+	healthCheckTimeout  = 5 * time.Second
+	// registrySyncTimeout bounds the first sync of workers, e.g. if listing pods is forbidden
+	registrySyncTimeout = time.Minute
+)
+
+var (
+	dialOptions    []grpc.DialOption
+	dialOptionsErr error
+	dialOnce       sync.Once
+)
+
+// DialOptions returns the options of connecting to gpu mounter workers, with mutual TLS if GRPC_TLS is enabled
+func DialOptions() ([]grpc.DialOption, error) {
+	dialOnce.Do(func() {
+		dialOptions = []grpc.DialOption{grpc.WithUnaryInterceptor(metrics.UnaryClientInterceptor)}
+		if !mtls.Enabled() {
+			Logger.Warn("gRPC TLS is disabled, connecting to gpu mounter workers insecurely")
+			dialOptions = append(dialOptions, grpc.WithInsecure())
+			return
+		}
+		reloader, err := mtls.NewReloader(mtls.GetCertDir())
+		if err != nil {
+			dialOptionsErr = err
+			return
+		}
+		serverAllowlist := mtls.GetAllowlist("GRPC_SERVER_ALLOWLIST", mtls.DefaultServerAllowlist)
+		dialOptions = append(dialOptions, grpc.WithTransportCredentials(mtls.ClientCredentials(reloader, serverAllowlist)))
+	})
+	return dialOptions, dialOptionsErr
+}
+
+type pooledConn struct {
+	podUID types.UID
+	target string
+	conn   *grpc.ClientConn
+
+	mu sync.Mutex
+	// calls is the number of calls in flight
+	calls   int
+	retired bool
+}
+
+// track counts the calls in flight on the connection, so that a retired connection is closed after they finish
+func (pooled *pooledConn) track(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	pooled.mu.Lock()
+	pooled.calls++
+	pooled.mu.Unlock()
+	defer func() {
+		pooled.mu.Lock()
+		defer pooled.mu.Unlock()
+		pooled.calls--
+		if pooled.retired && pooled.calls == 0 {
+			pooled.conn.Close()
+		}
+	}()
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+// retire closes the connection once the calls in flight finish
+func (pooled *pooledConn) retire() {
+	pooled.mu.Lock()
+	defer pooled.mu.Unlock()
+	pooled.retired = true
+	if pooled.calls == 0 {
+		pooled.conn.Close()
+	}
+}
+
+// Registry keeps the gpu mounter worker of each node up to date by an informer,
+// and a long-lived connection to each worker
+type Registry struct {
+	informer cache.SharedIndexInformer
+	// port is the grpc port of workers
+	port string
+
+	mu    sync.Mutex
+	conns map[string]*pooledConn
+}
+
+// NewRegistry creates a registry watching gpu mounter worker pods, Run should be called before GetConn
+func NewRegistry(clientset kubernetes.Interface) *Registry {
+	listWatch := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.LabelSelector = WorkerLabelSelector
+			return clientset.CoreV1().Pods(WorkerNamespace).List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.LabelSelector = WorkerLabelSelector
+			return clientset.CoreV1().Pods(WorkerNamespace).Watch(context.TODO(), options)
+		},
+	}
+	registry := &Registry{
+		informer: cache.NewSharedIndexInformer(listWatch, &corev1.Pod{}, 0, cache.Indexers{
+			nodeNameIndex: func(obj interface{}) ([]string, error) {
+				return []string{obj.(*corev1.Pod).Spec.NodeName}, nil
+			},
+		}),
+		port:  WorkerPort,
+		conns: make(map[string]*pooledConn),
+	}
+	registry.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(_, newObj interface{}) {
+			pod := newObj.(*corev1.Pod)
+			registry.invalidate(pod.Spec.NodeName, func(pooled *pooledConn) bool {
+				return pooled.podUID != pod.UID || pooled.target != pod.Status.PodIP+":"+registry.port
+			})
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if pod, ok := obj.(*corev1.Pod); ok {
+				registry.invalidate(pod.Spec.NodeName, func(pooled *pooledConn) bool {
+					return pooled.podUID == pod.UID
+				})
+			}
+		},
+	})
+	return registry
+}
+
+// Run starts watching workers and checking the health of pooled connections until stopCh is closed
+func (registry *Registry) Run(stopCh <-chan struct{}) {
+	go registry.informer.Run(stopCh)
+	if !cache.WaitForCacheSync(stopCh, registry.informer.HasSynced) {
+		Logger.Error("Failed to sync gpu mounter workers")
+		return
+	}
+	Logger.Info("Synced gpu mounter workers")
+	go wait.Until(registry.checkHealth, healthCheckInterval, stopCh)
+	<-stopCh
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	for nodeName, pooled := range registry.conns {
+		pooled.conn.Close()
+		delete(registry.conns, nodeName)
+	}
+}
+
+// GetWorker returns the running gpu mounter worker on the node
+func (registry *Registry) GetWorker(nodeName string) (*corev1.Pod, error) {
+	objs, err := registry.informer.GetIndexer().ByIndex(nodeNameIndex, nodeName)
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range objs {
+		pod := obj.(*corev1.Pod)
+		if pod.DeletionTimestamp == nil && pod.Status.Phase == corev1.PodRunning && pod.Status.PodIP != "" {
+			return pod, nil
+		}
+	}
+	Logger.Error("Failed found gpu mounter on Node: ", nodeName)
+	return nil, errors.New(NotFound)
+}
+
+// GetConn returns the pooled connection to the gpu mounter worker on the node,
+// the connection is shared and should not be closed by the caller
+func (registry *Registry) GetConn(nodeName string) (*grpc.ClientConn, error) {
+	pod, err := registry.GetWorker(nodeName)
+	if err != nil {
+		return nil, err
+	}
+	target := pod.Status.PodIP + ":" + registry.port
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if pooled, ok := registry.conns[nodeName]; ok {
+		if pooled.podUID == pod.UID && pooled.target == target {
+			return pooled.conn, nil
+		}
+		pooled.retire()
+		delete(registry.conns, nodeName)
+	}
+	Logger.Info("Connecting to gpu mounter worker: ", pod.Name, " on Node: ", nodeName, " at ", target)
+	dialOptions, err := DialOptions()
+	if err != nil {
+		Logger.Error("Failed to load gpu mounter worker dial options")
+		Logger.Error(err)
+		return nil, err
+	}
+	pooled := &pooledConn{podUID: pod.UID, target: target}
+	conn, err := grpc.Dial(target, append(dialOptions, grpc.WithChainUnaryInterceptor(pooled.track))...)
+	if err != nil {
+		Logger.Error("Failed to connect to gpu mounter worker")
+		Logger.Error(err)
+		return nil, err
+	}
+	pooled.conn = conn
+	registry.conns[nodeName] = pooled
+	return conn, nil
+}
+
+// invalidate drops the pooled connection of the node if it matches, calls in flight on it are not interrupted
+func (registry *Registry) invalidate(nodeName string, match func(pooled *pooledConn) bool) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	pooled, ok := registry.conns[nodeName]
+	if !ok || !match(pooled) {
+		return
+	}
+	Logger.Info("Gpu mounter worker on Node: ", nodeName, " is changed, closing connection to ", pooled.target)
+	pooled.retire()
+	delete(registry.conns, nodeName)
+}
+
+// checkHealth drops the pooled connections to unhealthy workers, they are reconnected on next GetConn
+func (registry *Registry) checkHealth() {
+	registry.mu.Lock()
+	conns := make(map[string]*pooledConn, len(registry.conns))
+	for nodeName, pooled := range registry.conns {
+		conns[nodeName] = pooled
+	}
+	registry.mu.Unlock()
+
+	for nodeName, pooled := range conns {
+		ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+		resp, err := healthpb.NewHealthClient(pooled.conn).Check(ctx, &healthpb.HealthCheckRequest{})
+		cancel()
+		if err == nil && resp.Status == healthpb.HealthCheckResponse_SERVING {
+			continue
+		}
+		Logger.Warn("Gpu mounter worker on Node: ", nodeName, " at ", pooled.target, " is unhealthy: ", err)
+		registry.invalidate(nodeName, func(current *pooledConn) bool {
+			return current == pooled
+		})
+	}
+}
+
+var (
+	registry     *Registry
+	registryErr  error
+	registryOnce sync.Once
+)
+
+// GetRegistry returns the registry shared by the process, which is started on first call
+func GetRegistry() (*Registry, error) {
+	registryOnce.Do(func() {
+		clientset, err := config.GetClientSet()
+		if err != nil {
+			Logger.Error("Connect to k8s failed")
+			registryErr = err
+			return
+		}
+		registry = NewRegistry(clientset)
+		go registry.Run(wait.NeverStop)
+		timeoutCh := make(chan struct{})
+		timer := time.AfterFunc(registrySyncTimeout, func() { close(timeoutCh) })
+		defer timer.Stop()
+		if !cache.WaitForCacheSync(timeoutCh, registry.informer.HasSynced) {
+			Logger.Error("Failed to sync gpu mounter workers in ", registrySyncTimeout)
+			registryErr = errors.New("failed to sync gpu mounter workers")
+		}
+	})
+	return registry, registryErr
+}
+
+// GetConn returns the pooled connection to the gpu mounter worker on the node,
+// the connection is shared and should not be closed by the caller
+func GetConn(nodeName string) (*grpc.ClientConn, error) {
+	registry, err := GetRegistry()
+	if err != nil {
+		return nil, err
+	}
+	return registry.GetConn(nodeName)
+}
@@ -0,0 +1,172 @@
+package worker
+
+import (
+	. "GPUMounter/pkg/util/log"
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := ioutil.TempDir("", "worker")
+	if err != nil {
+		panic(err)
+	}
+	InitLogger(dir+"/", "log")
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func newWorkerPod(podIP string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "gpu-mounter-worker",
+			Namespace: WorkerNamespace,
+			UID:       "worker-uid",
+			Labels:    map[string]string{"app": "gpu-mounter-worker"},
+		},
+		Spec:   corev1.PodSpec{NodeName: "gpu-node"},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning, PodIP: podIP},
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(server, healthServer)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	clientset := kubefake.NewSimpleClientset(newWorkerPod("127.0.0.1"))
+	registry := NewRegistry(clientset)
+	_, registry.port, _ = net.SplitHostPort(lis.Addr().String())
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go registry.Run(stopCh)
+
+	var conn *grpc.ClientConn
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		conn, err = registry.GetConn("gpu-node")
+		return err == nil, nil
+	}); err != nil {
+		t.Fatalf("failed to connect to worker: %v", err)
+	}
+	if pooled, _ := registry.GetConn("gpu-node"); pooled != conn {
+		t.Errorf("connection should be reused")
+	}
+	if _, err := registry.GetConn("cpu-node"); err == nil || err.Error() != NotFound {
+		t.Errorf("expected %s, got %v", NotFound, err)
+	}
+
+	// healthy connection is kept
+	registry.checkHealth()
+	if pooled, _ := registry.GetConn("gpu-node"); pooled != conn {
+		t.Errorf("healthy connection should be kept")
+	}
+
+	// unhealthy connection is replaced
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	registry.checkHealth()
+	if conn.GetState() != connectivity.Shutdown {
+		t.Errorf("connection to unhealthy worker should be closed")
+	}
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	conn, err = registry.GetConn("gpu-node")
+	if err != nil {
+		t.Fatalf("failed to reconnect to worker: %v", err)
+	}
+
+	// rescheduled worker invalidates the connection
+	if _, err := clientset.CoreV1().Pods(WorkerNamespace).Update(context.TODO(), newWorkerPod("127.0.0.2"), metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return conn.GetState() == connectivity.Shutdown, nil
+	}); err != nil {
+		t.Errorf("connection to rescheduled worker should be closed")
+	}
+
+	if err := clientset.CoreV1().Pods(WorkerNamespace).Delete(context.TODO(), "gpu-mounter-worker", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		_, err := registry.GetConn("gpu-node")
+		return err != nil && err.Error() == NotFound, nil
+	}); err != nil {
+		t.Errorf("deleted worker should not be found")
+	}
+}
+
+func TestRegistryDrainsRetiredConn(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	server := grpc.NewServer(grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		started <- struct{}{}
+		<-release
+		return handler(ctx, req)
+	}))
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(lis)
+	defer server.Stop()
+
+	registry := NewRegistry(kubefake.NewSimpleClientset(newWorkerPod("127.0.0.1")))
+	_, registry.port, _ = net.SplitHostPort(lis.Addr().String())
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go registry.Run(stopCh)
+
+	var conn *grpc.ClientConn
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		conn, err = registry.GetConn("gpu-node")
+		return err == nil, nil
+	}); err != nil {
+		t.Fatalf("failed to connect to worker: %v", err)
+	}
+
+	callErr := make(chan error, 1)
+	go func() {
+		_, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+		callErr <- err
+	}()
+	<-started
+
+	// the call in flight keeps the invalidated connection open
+	registry.invalidate("gpu-node", func(*pooledConn) bool { return true })
+	if conn.GetState() == connectivity.Shutdown {
+		t.Fatalf("connection with calls in flight should not be closed")
+	}
+	if pooled, err := registry.GetConn("gpu-node"); err != nil || pooled == conn {
+		t.Errorf("invalidated connection should be replaced: %v", err)
+	}
+
+	close(release)
+	if err := <-callErr; err != nil {
+		t.Errorf("call in flight should succeed: %v", err)
+	}
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return conn.GetState() == connectivity.Shutdown, nil
+	}); err != nil {
+		t.Errorf("retired connection should be closed after the calls finish")
+	}
+}